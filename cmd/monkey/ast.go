@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/parser"
+)
+
+// runAST prints the syntax tree of the file, or the standard input if no file is given,
+// as an outline or as JSON produced by ast.MarshalJSON.
+func runAST(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("ast", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the tree as JSON")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey ast [--json] [file]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	filename, src, err := readInput(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey ast: %v\n", err)
+		return 1
+	}
+
+	l := lexer.NewFile(filename, src)
+	l.SetMode(lexer.ScanComments)
+
+	program, err := parser.New(l).ParseProgram()
+	if err != nil {
+		parser.Render(stderr, src, err)
+		return 1
+	}
+
+	if !*asJSON {
+		ast.Outline(stdout, program)
+		return 0
+	}
+
+	data, err := ast.MarshalJSON(program)
+	if err == nil {
+		err = writeIndentedJSON(stdout, data)
+	}
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey ast: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+func writeIndentedJSON(w io.Writer, data []byte) error {
+	var out bytes.Buffer
+	if err := json.Indent(&out, data, "", "  "); err != nil {
+		return err
+	}
+	out.WriteByte('\n')
+
+	_, err := w.Write(out.Bytes())
+	return err
+}
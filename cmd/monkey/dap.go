@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/adrian83/monkey/pkg/dap"
+)
+
+// runDAP serves the Debug Adapter Protocol over the standard input and output.
+func runDAP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintln(stderr, "usage: monkey dap")
+		return 2
+	}
+
+	if err := dap.NewServer().Serve(stdin, stdout); err != nil {
+		fmt.Fprintf(stderr, "monkey dap: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
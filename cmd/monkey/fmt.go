@@ -0,0 +1,120 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+
+	"github.com/adrian83/monkey/pkg/diff"
+	"github.com/adrian83/monkey/pkg/parser"
+	"github.com/adrian83/monkey/pkg/printer"
+)
+
+const stdinName = "<standard input>"
+
+type fmtOptions struct {
+	write bool // rewrite the files with the formatted source
+	diff  bool // show the changes instead of the formatted source
+	list  bool // list the files which aren't formatted
+}
+
+// runFmt formats the given files, or the standard input if there are none. By default the formatted
+// source is written to the standard output, flags allow to rewrite the files or to show the changes.
+func runFmt(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	var opts fmtOptions
+
+	flags := flag.NewFlagSet("fmt", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	flags.BoolVar(&opts.write, "w", false, "write the result to the source file instead of the standard output")
+	flags.BoolVar(&opts.diff, "d", false, "display diffs instead of rewriting files")
+	flags.BoolVar(&opts.list, "l", false, "list files whose formatting differs from monkey fmt's")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey fmt [-w] [-d] [-l] [files]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if flags.NArg() == 0 {
+		if opts.write {
+			fmt.Fprintln(stderr, "monkey fmt: cannot use -w with standard input")
+			return 2
+		}
+
+		src, err := ioutil.ReadAll(stdin)
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %v\n", err)
+			return 1
+		}
+
+		return formatSource(stdinName, src, opts, stdout, stderr)
+	}
+
+	status := 0
+	for _, filename := range flags.Args() {
+		if code := formatFile(filename, opts, stdout, stderr); code > status {
+			status = code
+		}
+	}
+
+	return status
+}
+
+func formatFile(filename string, opts fmtOptions, stdout, stderr io.Writer) int {
+	src, err := ioutil.ReadFile(filename)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey fmt: %v\n", err)
+		return 1
+	}
+
+	return formatSource(filename, src, opts, stdout, stderr)
+}
+
+func formatSource(filename string, src []byte, opts fmtOptions, stdout, stderr io.Writer) int {
+	formatted, err := printer.Format(filename, src)
+	if err != nil {
+		parser.Render(stderr, string(src), err)
+		return 1
+	}
+
+	changed := !bytes.Equal(src, formatted)
+
+	if opts.list && changed {
+		fmt.Fprintln(stdout, filename)
+	}
+
+	if opts.write && changed {
+		if err := writeFile(filename, formatted); err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %v\n", err)
+			return 1
+		}
+	}
+
+	if opts.diff && changed {
+		fmt.Fprint(stdout, diff.Unified(filename+".orig", filename, string(src), string(formatted)))
+	}
+
+	if !opts.list && !opts.write && !opts.diff {
+		if _, err := stdout.Write(formatted); err != nil {
+			fmt.Fprintf(stderr, "monkey fmt: %v\n", err)
+			return 1
+		}
+	}
+
+	return 0
+}
+
+// writeFile replaces the content of the file, keeping its permissions.
+func writeFile(filename string, content []byte) error {
+	info, err := os.Stat(filename)
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(filename, content, info.Mode().Perm())
+}
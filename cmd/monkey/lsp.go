@@ -0,0 +1,23 @@
+package main
+
+import (
+	"fmt"
+	"io"
+
+	"github.com/adrian83/monkey/pkg/lsp"
+)
+
+// runLSP serves the Language Server Protocol over the standard input and output.
+func runLSP(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) > 0 {
+		fmt.Fprintln(stderr, "usage: monkey lsp")
+		return 2
+	}
+
+	if err := lsp.NewServer().Serve(stdin, stdout); err != nil {
+		fmt.Fprintf(stderr, "monkey lsp: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
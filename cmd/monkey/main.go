@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+)
+
+type command struct {
+	usage string
+	run   func(args []string, stdin io.Reader, stdout, stderr io.Writer) int
+}
+
+var commands = map[string]command{
+	"ast":    {"print the syntax tree of a Monkey source file", runAST},
+	"dap":    {"start the debug adapter speaking DAP over stdio", runDAP},
+	"eval":   {"evaluate code given as an argument and print the result", runEval},
+	"fmt":    {"format Monkey source files", runFmt},
+	"lsp":    {"start the language server speaking LSP over stdio", runLSP},
+	"repl":   {"start the interactive interpreter", runREPL},
+	"run":    {"run a Monkey script", runScript},
+	"tokens": {"print the tokens of a Monkey source file", runTokens},
+}
+
+func main() {
+	os.Exit(run(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+}
+
+// run executes the command given as the first argument and returns the exit status.
+// Without a command the arguments are passed to the run command, so that 'monkey script.mk'
+// runs the script and 'monkey -e code' evaluates the code. Without any arguments the REPL is
+// started if the standard input is a terminal, otherwise the standard input is run as a script.
+func run(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	if len(args) == 0 {
+		if isTerminal(stdin) {
+			return runREPL(args, stdin, stdout, stderr)
+		}
+		return runScript(args, stdin, stdout, stderr)
+	}
+
+	switch args[0] {
+	case "-h", "-help", "--help", "help":
+		usage(stdout)
+		return 0
+	}
+
+	if cmd, ok := commands[args[0]]; ok {
+		return cmd.run(args[1:], stdin, stdout, stderr)
+	}
+
+	return runScript(args, stdin, stdout, stderr)
+}
+
+func usage(w io.Writer) {
+	fmt.Fprintln(w, "usage: monkey <command> [arguments]")
+	fmt.Fprintln(w, "       monkey [-engine eval|vm] [file|-] [arguments]")
+	fmt.Fprintln(w, "       monkey [-engine eval|vm] -e code [arguments]")
+	fmt.Fprintln(w, "\ncommands:")
+
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		fmt.Fprintf(w, "\t%-8s %s\n", name, commands[name].usage)
+	}
+}
+
+// readInput reads the file given as the only argument or the standard input if there are no arguments
+// or the argument is "-".
+func readInput(args []string, stdin io.Reader) (string, string, error) {
+	if len(args) == 0 || args[0] == "-" {
+		src, err := ioutil.ReadAll(stdin)
+		return "", string(src), err
+	}
+
+	src, err := ioutil.ReadFile(args[0])
+	return args[0], string(src), err
+}
@@ -0,0 +1,98 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRun(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "script.mk")
+	err = ioutil.WriteFile(script, []byte("#!/usr/bin/env monkey\nlet count = len(args);\nif (count > 1) { 1 / 0 }\n"), 0644)
+	assert.NoError(t, err)
+
+	testData := map[string]struct {
+		args   []string
+		stdin  string
+		status int
+		stdout string
+		stderr string
+	}{
+		"eval":               {[]string{"-e", "1 + 2"}, "", 0, "3\n", ""},
+		"eval with engine":   {[]string{"-engine", "vm", "-e", "args", "a"}, "", 0, "[a]\n", ""},
+		"eval command":       {[]string{"eval", "-engine", "vm", "1 + 2"}, "", 0, "3\n", ""},
+		"eval null":          {[]string{"-e", "puts"}, "", 0, "builtin function\n", ""},
+		"eval args":          {[]string{"-e", "args", "a", "b"}, "", 0, "[a, b]\n", ""},
+		"eval no args":       {[]string{"-e", "args"}, "", 0, "[]\n", ""},
+		"eval runtime error": {[]string{"-e", "1 / 0"}, "", 1, "", "-e:1:3: division by zero\n"},
+		"eval recursion":     {[]string{"-e", "let f = fn() { f() }; f()"}, "", 1, "", "-e:1:16: maximum call depth of 1024 exceeded\nf(...)\n"},
+		"eval parse error":   {[]string{"-e", "let"}, "", 1, "", "expected next token to be IDENT"},
+		"eval ampersand":     {[]string{"-e", "1 & 2"}, "", 1, "", "illegal character '&'"},
+		"eval bar":           {[]string{"eval", "1 | 2"}, "", 1, "", "illegal character '|'"},
+		"script":             {[]string{script, "a"}, "", 0, "", ""},
+		"script error":       {[]string{"run", script, "a", "b"}, "", 1, "", "script.mk:3:20: division by zero\nmain()\n"},
+		"stdin":              {nil, "let x = 1;\nx / 0", 1, "", "2:3: division by zero\n"},
+		"stdin dash":         {[]string{"-", "a", "b"}, "len(args) / 0", 1, "", "1:11: division by zero\n"},
+		"missing script":     {[]string{filepath.Join(dir, "missing.mk")}, "", 1, "", "no such file"},
+		"unknown engine":     {[]string{"-engine", "jit", "-"}, "", 2, "", "unknown engine: jit\n"},
+		"help":               {[]string{"-h"}, "", 0, "usage: monkey", ""},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			status := run(data.args, strings.NewReader(data.stdin), &stdout, &stderr)
+
+			assert.Equal(t, data.status, status)
+			assert.Contains(t, stdout.String(), data.stdout)
+			assert.Contains(t, stderr.String(), data.stderr)
+			if data.stdout == "" {
+				assert.Empty(t, stdout.String())
+			}
+			if data.stderr == "" {
+				assert.Empty(t, stderr.String())
+			}
+		})
+	}
+}
+
+func TestFlagErrors(t *testing.T) {
+	testData := map[string]struct {
+		args  []string
+		usage string
+	}{
+		"run":     {[]string{"run", "-bogus"}, "usage: monkey run"},
+		"default": {[]string{"-bogus"}, "usage: monkey run"},
+		"eval":    {[]string{"eval", "-bogus", "1"}, "usage: monkey eval"},
+		"repl":    {[]string{"repl", "-bogus"}, "usage: monkey repl"},
+		"ast":     {[]string{"ast", "-bogus"}, "usage: monkey ast"},
+		"tokens":  {[]string{"tokens", "-bogus"}, "usage: monkey tokens"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			var stdout, stderr bytes.Buffer
+
+			status := run(data.args, strings.NewReader(""), &stdout, &stderr)
+
+			assert.Equal(t, 2, status)
+			assert.Empty(t, stdout.String())
+			assert.True(t, strings.HasPrefix(stderr.String(), "flag provided but not defined: -bogus\n"), stderr.String())
+			assert.Equal(t, 1, strings.Count(stderr.String(), data.usage), stderr.String())
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"os/user"
+	"path/filepath"
+
+	"github.com/adrian83/monkey/pkg/monkey"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+	"github.com/adrian83/monkey/pkg/repl"
+)
+
+// evalName is the file name reported in errors of the code given on the command line.
+const evalName = "-e"
+
+func engineFlag(flags *flag.FlagSet) *string {
+	return flags.String("engine", string(monkey.EngineEval), "backend executing the code: eval or vm")
+}
+
+// runScript executes the file, or the standard input if the file is "-" or not given, or the code
+// given with -e. The following arguments are passed to the script in the args array.
+func runScript(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("run", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engine := engineFlag(flags)
+	code := flags.String("e", "", "evaluate the code instead of a script and print its result")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey run [-engine eval|vm] [file|-] [arguments]")
+		fmt.Fprintln(stderr, "       monkey run [-engine eval|vm] -e code [arguments]")
+		flags.PrintDefaults()
+	}
+
+	// the flag set prints the usage on errors
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+
+	if isFlagSet(flags, "e") {
+		return execute(monkey.Engine(*engine), evalName, *code, append([]string{}, flags.Args()...), true, stdout, stderr)
+	}
+
+	var input []string
+	if flags.NArg() > 0 {
+		input = flags.Args()[:1]
+	}
+
+	filename, src, err := readInput(input, stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return 1
+	}
+
+	return execute(monkey.Engine(*engine), filename, src, scriptArgs(flags.Args()), false, stdout, stderr)
+}
+
+// runEval executes the code given as the first argument and prints its result unless it is null.
+func runEval(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("eval", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engine := engineFlag(flags)
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey eval [-engine eval|vm] code [arguments]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() == 0 {
+		flags.Usage()
+		return 2
+	}
+
+	return execute(monkey.Engine(*engine), evalName, flags.Arg(0), scriptArgs(flags.Args()), true, stdout, stderr)
+}
+
+// runREPL starts the interactive session reading the standard input.
+func runREPL(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("repl", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	engine := engineFlag(flags)
+	historyFile := flags.String("history", defaultHistoryFile(), "file keeping the entered lines, empty to keep none")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey repl [-engine eval|vm] [-history file]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 0 {
+		flags.Usage()
+		return 2
+	}
+
+	if sysUser, err := user.Current(); err == nil {
+		fmt.Fprintf(stdout, "Hello %s!\n", sysUser.Username)
+	}
+	fmt.Fprintln(stdout, "This is the Monkey programming language!")
+	fmt.Fprintln(stdout, "Feel free to type in commands")
+
+	if err := repl.Start(stdin, stdout, monkey.Engine(*engine), repl.WithHistoryFile(*historyFile)); err != nil {
+		fmt.Fprintf(stderr, "monkey repl: %v\n", err)
+		return 1
+	}
+
+	return 0
+}
+
+// defaultHistoryFile returns the path of the REPL history in the home directory or an empty path
+// if the home directory is unknown.
+func defaultHistoryFile() string {
+	home, err := os.UserHomeDir()
+	if err != nil {
+		return ""
+	}
+
+	return filepath.Join(home, ".monkey_history")
+}
+
+// isFlagSet reports whether the flag was given on the command line.
+func isFlagSet(flags *flag.FlagSet, name string) bool {
+	set := false
+	flags.Visit(func(f *flag.Flag) {
+		set = set || f.Name == name
+	})
+
+	return set
+}
+
+// scriptArgs returns the arguments following the script, the array is empty rather than null
+// if there aren't any.
+func scriptArgs(args []string) []string {
+	rest := []string{}
+	if len(args) > 1 {
+		rest = append(rest, args[1:]...)
+	}
+
+	return rest
+}
+
+// execute runs the source and reports errors, the exit status is 1 if the source can't be parsed
+// or its evaluation returns an error.
+func execute(engine monkey.Engine, filename, src string, args []string, printResult bool, stdout, stderr io.Writer) int {
+	interpreter, err := monkey.New(monkey.WithEngine(engine))
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return 2
+	}
+
+	if err := interpreter.Define("args", args); err != nil {
+		fmt.Fprintf(stderr, "monkey: %v\n", err)
+		return 1
+	}
+
+	result, err := interpreter.Eval(context.Background(), filename, src)
+	if err != nil {
+		reportError(stderr, src, err)
+		return 1
+	}
+
+	if printResult && result != nil && result != object.NullValue {
+		fmt.Fprintln(stdout, result.Inspect())
+	}
+
+	return 0
+}
+
+func reportError(w io.Writer, src string, err error) {
+	runtimeErr, ok := err.(*monkey.RuntimeError)
+	if !ok {
+		parser.Render(w, src, err)
+		return
+	}
+
+	fmt.Fprintln(w, runtimeErr.Error())
+	if len(runtimeErr.Trace) > 0 {
+		fmt.Fprint(w, runtimeErr.StackTrace())
+	}
+}
+
+// isTerminal reports whether the reader is an interactive terminal.
+func isTerminal(r io.Reader) bool {
+	file, ok := r.(*os.File)
+	if !ok {
+		return false
+	}
+
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
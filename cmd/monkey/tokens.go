@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/parser"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+// runTokens prints the tokens of the file, or the standard input if no file is given,
+// including comments. Problems found by the lexer are reported after the tokens.
+func runTokens(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("tokens", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	asJSON := flags.Bool("json", false, "print the tokens as a JSON array")
+	flags.Usage = func() {
+		fmt.Fprintln(stderr, "usage: monkey tokens [--json] [file]")
+		flags.PrintDefaults()
+	}
+
+	if err := flags.Parse(args); err != nil {
+		return 2
+	}
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	}
+
+	filename, src, err := readInput(flags.Args(), stdin)
+	if err != nil {
+		fmt.Fprintf(stderr, "monkey tokens: %v\n", err)
+		return 1
+	}
+
+	var errs parser.ErrorList
+
+	l := lexer.NewFile(filename, src)
+	l.SetMode(lexer.ScanComments)
+	l.SetErrorHandler(func(tok token.Token, pos token.Position, msg string) {
+		errs.Add(pos, tok, parser.SeverityError, "%s", msg)
+	})
+
+	tokens := []token.Token{}
+	for tok := l.NextToken(); tok.Type != token.Eof; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	if *asJSON {
+		data, err := json.Marshal(tokens)
+		if err == nil {
+			err = writeIndentedJSON(stdout, data)
+		}
+		if err != nil {
+			fmt.Fprintf(stderr, "monkey tokens: %v\n", err)
+			return 1
+		}
+	} else {
+		for _, tok := range tokens {
+			fmt.Fprintf(stdout, "%s %s %q\n", tok.Pos, tok.Type, tok.Literal)
+		}
+	}
+
+	if len(errs) > 0 {
+		parser.Render(stderr, src, errs)
+		return 1
+	}
+
+	return 0
+}
@@ -3,6 +3,7 @@ package ast
 import (
 	"bytes"
 	"fmt"
+	"sort"
 	"strings"
 
 	"github.com/adrian83/monkey/pkg/token"
@@ -15,6 +16,8 @@ type BodyHolder interface {
 type Node interface {
 	NodeToken() token.Token
 	String() string
+	Pos() token.Position // position of the first character belonging to the node
+	End() token.Position // position immediately after the node
 }
 
 type Statement interface {
@@ -27,6 +30,10 @@ type Expression interface {
 
 type Program struct {
 	Statements []Statement
+
+	// Comments and CommentMap are set only if the lexer scans comments
+	Comments   []*Comment // all comments in the order of appearance
+	CommentMap CommentMap // comments attached to the statements
 }
 
 func (p *Program) BodyStatements() []Statement {
@@ -41,6 +48,22 @@ func (p *Program) NodeToken() token.Token {
 	return token.Token{Type: token.Eof, Literal: ""}
 }
 
+func (p *Program) Pos() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[0].Pos()
+	}
+
+	return token.Position{}
+}
+
+func (p *Program) End() token.Position {
+	if len(p.Statements) > 0 {
+		return p.Statements[len(p.Statements)-1].End()
+	}
+
+	return token.Position{}
+}
+
 func (p *Program) String() string {
 	var out bytes.Buffer
 
@@ -51,6 +74,45 @@ func (p *Program) String() string {
 	return out.String()
 }
 
+// Comment is a // or /* */ comment or the #! line starting a script,
+// the literal of the token includes the comment markers.
+type Comment struct {
+	Token token.Token // the token.Comment token
+}
+
+func (c *Comment) NodeToken() token.Token {
+	return c.Token
+}
+
+func (c *Comment) Pos() token.Position {
+	return c.Token.Pos
+}
+
+func (c *Comment) End() token.Position {
+	return c.Token.End
+}
+
+func (c *Comment) String() string {
+	return c.Token.Literal
+}
+
+// Text returns the comment without the comment markers and the surrounding whitespace.
+func (c *Comment) Text() string {
+	text := c.Token.Literal
+
+	if strings.HasPrefix(text, "//") || strings.HasPrefix(text, "#!") {
+		text = text[2:]
+	} else {
+		text = strings.TrimSuffix(strings.TrimPrefix(text, "/*"), "*/")
+	}
+
+	return strings.TrimSpace(text)
+}
+
+// CommentMap maps a statement to the comments preceding it and the comments following it
+// on the line where the statement ends.
+type CommentMap map[Node][]*Comment
+
 // expression
 type Identifier struct {
 	Token token.Token // the token.IDENT token
@@ -61,6 +123,14 @@ func (i *Identifier) NodeToken() token.Token {
 	return i.Token
 }
 
+func (i *Identifier) Pos() token.Position {
+	return i.Token.Pos
+}
+
+func (i *Identifier) End() token.Position {
+	return i.Token.End
+}
+
 func (i *Identifier) String() string {
 	return i.Value
 }
@@ -75,10 +145,40 @@ func (il *IntegerLiteral) NodeToken() token.Token {
 	return il.Token
 }
 
+func (il *IntegerLiteral) Pos() token.Position {
+	return il.Token.Pos
+}
+
+func (il *IntegerLiteral) End() token.Position {
+	return il.Token.End
+}
+
 func (il *IntegerLiteral) String() string {
 	return il.Token.Literal
 }
 
+// expression
+type FloatLiteral struct {
+	Token token.Token
+	Value float64
+}
+
+func (fl *FloatLiteral) NodeToken() token.Token {
+	return fl.Token
+}
+
+func (fl *FloatLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+func (fl *FloatLiteral) End() token.Position {
+	return fl.Token.End
+}
+
+func (fl *FloatLiteral) String() string {
+	return fl.Token.Literal
+}
+
 // expression
 type StringLiteral struct {
 	Token token.Token
@@ -89,6 +189,14 @@ func (sl *StringLiteral) NodeToken() token.Token {
 	return sl.Token
 }
 
+func (sl *StringLiteral) Pos() token.Position {
+	return sl.Token.Pos
+}
+
+func (sl *StringLiteral) End() token.Position {
+	return sl.Token.End
+}
+
 func (sl *StringLiteral) String() string {
 	return sl.Token.Literal
 }
@@ -104,6 +212,14 @@ func (pe *PrefixExpression) NodeToken() token.Token {
 	return pe.Token
 }
 
+func (pe *PrefixExpression) Pos() token.Position {
+	return pe.Token.Pos
+}
+
+func (pe *PrefixExpression) End() token.Position {
+	return pe.Right.End()
+}
+
 func (pe *PrefixExpression) String() string {
 	return fmt.Sprintf("(%v%v)", pe.Operator, pe.Right.String())
 }
@@ -120,10 +236,48 @@ func (ie *InfixExpression) NodeToken() token.Token {
 	return ie.Token
 }
 
+func (ie *InfixExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
+
+func (ie *InfixExpression) End() token.Position {
+	return ie.Right.End()
+}
+
 func (ie *InfixExpression) String() string {
 	return fmt.Sprintf("(%v %v %v)", ie.Left.String(), ie.Operator, ie.Right.String())
 }
 
+// expression
+type AssignExpression struct {
+	Token    token.Token // the assignment operator token, e.g. = or +=
+	Target   Expression  // Identifier or IndexExpression
+	Operator string
+	Value    Expression
+}
+
+func (ae *AssignExpression) NodeToken() token.Token {
+	return ae.Token
+}
+
+func (ae *AssignExpression) Pos() token.Position {
+	return ae.Target.Pos()
+}
+
+func (ae *AssignExpression) End() token.Position {
+	return ae.Value.End()
+}
+
+func (ae *AssignExpression) String() string {
+	return fmt.Sprintf("(%v %v %v)", ae.Target.String(), ae.Operator, ae.Value.String())
+}
+
+// BinaryOperator returns the operator applied by a compound assignment, e.g. + for +=,
+// and an empty string for a plain assignment.
+func (ae *AssignExpression) BinaryOperator() string {
+	return strings.TrimSuffix(ae.Operator, "=")
+}
+
 // expression
 type BooleanLiteral struct {
 	Token token.Token
@@ -134,6 +288,14 @@ func (b *BooleanLiteral) NodeToken() token.Token {
 	return b.Token
 }
 
+func (b *BooleanLiteral) Pos() token.Position {
+	return b.Token.Pos
+}
+
+func (b *BooleanLiteral) End() token.Position {
+	return b.Token.End
+}
+
 func (b *BooleanLiteral) String() string {
 	return b.Token.Literal
 }
@@ -150,6 +312,18 @@ func (ie *IfExpression) NodeToken() token.Token {
 	return ie.Token
 }
 
+func (ie *IfExpression) Pos() token.Position {
+	return ie.Token.Pos
+}
+
+func (ie *IfExpression) End() token.Position {
+	if ie.Alternative != nil {
+		return ie.Alternative.End()
+	}
+
+	return ie.Consequence.End()
+}
+
 func (ie *IfExpression) String() string {
 	out := fmt.Sprintf("if %v %v", ie.Condition.String(), ie.Consequence.String())
 
@@ -165,12 +339,21 @@ type FunctionLiteral struct {
 	Token      token.Token // The 'fn' token
 	Parameters []*Identifier
 	Body       *BlockStatement
+	Name       string // name of the binding if the function is bound with 'let'
 }
 
 func (fl *FunctionLiteral) NodeToken() token.Token {
 	return fl.Token
 }
 
+func (fl *FunctionLiteral) Pos() token.Position {
+	return fl.Token.Pos
+}
+
+func (fl *FunctionLiteral) End() token.Position {
+	return fl.Body.End()
+}
+
 func (fl *FunctionLiteral) String() string {
 	params := []string{}
 	for _, p := range fl.Parameters {
@@ -180,16 +363,53 @@ func (fl *FunctionLiteral) String() string {
 	return fmt.Sprintf("%v(%v) %v", fl.Token.Literal, strings.Join(params, ", "), fl.Body.String())
 }
 
+// expression, macros are expanded before the evaluation of the program
+type MacroLiteral struct {
+	Token      token.Token // The 'macro' token
+	Parameters []*Identifier
+	Body       *BlockStatement
+}
+
+func (ml *MacroLiteral) NodeToken() token.Token {
+	return ml.Token
+}
+
+func (ml *MacroLiteral) Pos() token.Position {
+	return ml.Token.Pos
+}
+
+func (ml *MacroLiteral) End() token.Position {
+	return ml.Body.End()
+}
+
+func (ml *MacroLiteral) String() string {
+	params := []string{}
+	for _, p := range ml.Parameters {
+		params = append(params, p.String())
+	}
+
+	return fmt.Sprintf("%v(%v) %v", ml.Token.Literal, strings.Join(params, ", "), ml.Body.String())
+}
+
 // expression
 type ArrayLiteral struct {
 	Token    token.Token // the '[' token
 	Elements []Expression
+	Rbracket token.Position // position of the closing ']'
 }
 
 func (al *ArrayLiteral) NodeToken() token.Token {
 	return al.Token
 }
 
+func (al *ArrayLiteral) Pos() token.Position {
+	return al.Token.Pos
+}
+
+func (al *ArrayLiteral) End() token.Position {
+	return afterDelimiter(al.Rbracket)
+}
+
 func (al *ArrayLiteral) String() string {
 	var out bytes.Buffer
 
@@ -206,9 +426,10 @@ func (al *ArrayLiteral) String() string {
 }
 
 type IndexExpression struct {
-	Token token.Token // The [ token
-	Left  Expression
-	Index Expression
+	Token    token.Token // The [ token
+	Left     Expression
+	Index    Expression
+	Rbracket token.Position // position of the closing ']'
 }
 
 func (ie *IndexExpression) expressionNode() {}
@@ -217,6 +438,14 @@ func (ie *IndexExpression) NodeToken() token.Token {
 	return ie.Token
 }
 
+func (ie *IndexExpression) Pos() token.Position {
+	return ie.Left.Pos()
+}
+
+func (ie *IndexExpression) End() token.Position {
+	return afterDelimiter(ie.Rbracket)
+}
+
 func (ie *IndexExpression) String() string {
 	var out bytes.Buffer
 
@@ -230,8 +459,9 @@ func (ie *IndexExpression) String() string {
 }
 
 type HashLiteral struct {
-	Token token.Token // the '{' token
-	Pairs map[Expression]Expression
+	Token  token.Token // the '{' token
+	Pairs  map[Expression]Expression
+	Rbrace token.Position // position of the closing '}'
 }
 
 func (hl *HashLiteral) expressionNode() {}
@@ -239,6 +469,32 @@ func (hl *HashLiteral) expressionNode() {}
 func (hl *HashLiteral) NodeToken() token.Token {
 	return hl.Token
 }
+
+func (hl *HashLiteral) Pos() token.Position {
+	return hl.Token.Pos
+}
+
+func (hl *HashLiteral) End() token.Position {
+	return afterDelimiter(hl.Rbrace)
+}
+
+// SortedKeys returns the keys in the order in which they appear in the source.
+func (hl *HashLiteral) SortedKeys() []Expression {
+	keys := make([]Expression, 0, len(hl.Pairs))
+	for key := range hl.Pairs {
+		keys = append(keys, key)
+	}
+
+	sort.Slice(keys, func(i, j int) bool {
+		if keys[i].Pos().Offset != keys[j].Pos().Offset {
+			return keys[i].Pos().Offset < keys[j].Pos().Offset
+		}
+		// keys created without positions are ordered by their text
+		return keys[i].String() < keys[j].String()
+	})
+
+	return keys
+}
 func (hl *HashLiteral) String() string {
 	var out bytes.Buffer
 
@@ -259,12 +515,21 @@ type CallExpression struct {
 	Token     token.Token // The '(' token
 	Function  Expression  // Identifier or FunctionLiteral
 	Arguments []Expression
+	Rparen    token.Position // position of the closing ')'
 }
 
 func (ce *CallExpression) NodeToken() token.Token {
 	return ce.Token
 }
 
+func (ce *CallExpression) Pos() token.Position {
+	return ce.Function.Pos()
+}
+
+func (ce *CallExpression) End() token.Position {
+	return afterDelimiter(ce.Rparen)
+}
+
 func (ce *CallExpression) String() string {
 	var out bytes.Buffer
 
@@ -294,6 +559,18 @@ func (es *ExpressionStatement) NodeToken() token.Token {
 	return es.Token
 }
 
+func (es *ExpressionStatement) Pos() token.Position {
+	return es.Token.Pos
+}
+
+func (es *ExpressionStatement) End() token.Position {
+	if es.Expression != nil {
+		return es.Expression.End()
+	}
+
+	return es.Token.End
+}
+
 func (es *ExpressionStatement) String() string {
 	if es.Expression != nil {
 		return es.Expression.String()
@@ -312,6 +589,18 @@ func (ls *LetStatement) NodeToken() token.Token {
 	return ls.Token
 }
 
+func (ls *LetStatement) Pos() token.Position {
+	return ls.Token.Pos
+}
+
+func (ls *LetStatement) End() token.Position {
+	if ls.Value != nil {
+		return ls.Value.End()
+	}
+
+	return ls.Name.End()
+}
+
 func (ls *LetStatement) String() string {
 	var out bytes.Buffer
 
@@ -331,6 +620,7 @@ func (ls *LetStatement) String() string {
 type BlockStatement struct {
 	Token      token.Token // the { token
 	Statements []Statement
+	Rbrace     token.Position // position of the closing '}'
 }
 
 func (bs *BlockStatement) BodyStatements() []Statement {
@@ -341,6 +631,14 @@ func (bs *BlockStatement) NodeToken() token.Token {
 	return bs.Token
 }
 
+func (bs *BlockStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BlockStatement) End() token.Position {
+	return afterDelimiter(bs.Rbrace)
+}
+
 func (bs *BlockStatement) String() string {
 	var out bytes.Buffer
 
@@ -361,6 +659,18 @@ func (rs *ReturnStatement) NodeToken() token.Token {
 	return rs.Token
 }
 
+func (rs *ReturnStatement) Pos() token.Position {
+	return rs.Token.Pos
+}
+
+func (rs *ReturnStatement) End() token.Position {
+	if rs.ReturnValue != nil {
+		return rs.ReturnValue.End()
+	}
+
+	return rs.Token.End
+}
+
 func (rs *ReturnStatement) String() string {
 	out := fmt.Sprintf("%v ", rs.NodeToken().Literal)
 
@@ -372,3 +682,110 @@ func (rs *ReturnStatement) String() string {
 
 	return out
 }
+
+// statement
+type WhileStatement struct {
+	Token     token.Token // the 'while' token
+	Condition Expression
+	Body      *BlockStatement
+}
+
+func (ws *WhileStatement) NodeToken() token.Token {
+	return ws.Token
+}
+
+func (ws *WhileStatement) Pos() token.Position {
+	return ws.Token.Pos
+}
+
+func (ws *WhileStatement) End() token.Position {
+	return ws.Body.End()
+}
+
+func (ws *WhileStatement) String() string {
+	return fmt.Sprintf("while %v %v", ws.Condition.String(), ws.Body.String())
+}
+
+// statement
+type ForStatement struct {
+	Token    token.Token // the 'for' token
+	Key      *Identifier // optional, index of an array or a string, key of a hash
+	Value    *Identifier
+	Iterable Expression
+	Body     *BlockStatement
+}
+
+func (fs *ForStatement) NodeToken() token.Token {
+	return fs.Token
+}
+
+func (fs *ForStatement) Pos() token.Position {
+	return fs.Token.Pos
+}
+
+func (fs *ForStatement) End() token.Position {
+	return fs.Body.End()
+}
+
+func (fs *ForStatement) String() string {
+	vars := fs.Value.String()
+	if fs.Key != nil {
+		vars = fs.Key.String() + ", " + vars
+	}
+
+	return fmt.Sprintf("for (%v in %v) %v", vars, fs.Iterable.String(), fs.Body.String())
+}
+
+// statement
+type BreakStatement struct {
+	Token token.Token // the 'break' token
+}
+
+func (bs *BreakStatement) NodeToken() token.Token {
+	return bs.Token
+}
+
+func (bs *BreakStatement) Pos() token.Position {
+	return bs.Token.Pos
+}
+
+func (bs *BreakStatement) End() token.Position {
+	return bs.Token.End
+}
+
+func (bs *BreakStatement) String() string {
+	return bs.Token.Literal + ";"
+}
+
+// statement
+type ContinueStatement struct {
+	Token token.Token // the 'continue' token
+}
+
+func (cs *ContinueStatement) NodeToken() token.Token {
+	return cs.Token
+}
+
+func (cs *ContinueStatement) Pos() token.Position {
+	return cs.Token.Pos
+}
+
+func (cs *ContinueStatement) End() token.Position {
+	return cs.Token.End
+}
+
+func (cs *ContinueStatement) String() string {
+	return cs.Token.Literal + ";"
+}
+
+// afterDelimiter returns position immediately after the single character delimiter at the given position.
+func afterDelimiter(pos token.Position) token.Position {
+	if !pos.IsValid() {
+		return pos
+	}
+
+	pos.Offset++
+	pos.Column++
+
+	return pos
+}
@@ -0,0 +1,272 @@
+package ast
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"unicode"
+	"unicode/utf8"
+)
+
+// nodeKinds maps the kind of a node, which is the name of its type, to the type.
+var nodeKinds = kindsOf(
+	&Program{}, &Comment{}, &Identifier{}, &IntegerLiteral{}, &FloatLiteral{}, &StringLiteral{},
+	&BooleanLiteral{}, &PrefixExpression{}, &InfixExpression{}, &AssignExpression{}, &IfExpression{},
+	&FunctionLiteral{}, &MacroLiteral{}, &ArrayLiteral{}, &IndexExpression{}, &HashLiteral{},
+	&CallExpression{}, &ExpressionStatement{}, &LetStatement{}, &BlockStatement{}, &ReturnStatement{},
+	&WhileStatement{}, &ForStatement{}, &BreakStatement{}, &ContinueStatement{},
+)
+
+var (
+	nodeType          = reflect.TypeOf((*Node)(nil)).Elem()
+	expressionMapType = reflect.TypeOf(map[Expression]Expression{})
+	commentMapType    = reflect.TypeOf(CommentMap{})
+)
+
+func kindsOf(nodes ...Node) map[string]reflect.Type {
+	kinds := make(map[string]reflect.Type, len(nodes))
+	for _, node := range nodes {
+		typ := reflect.TypeOf(node).Elem()
+		kinds[typ.Name()] = typ
+	}
+
+	return kinds
+}
+
+// MarshalJSON encodes the node as a JSON object with the "kind" member holding the name
+// of the node type, e.g. "InfixExpression", followed by the fields of the node named like
+// in Go but starting with a lower case letter. Tokens keep their positions. Pairs of a hash
+// literal are encoded as an array of objects with "key" and "value" members. The comment map
+// of a program is not encoded, the comments are.
+func MarshalJSON(node Node) ([]byte, error) {
+	var out bytes.Buffer
+	if err := encodeNode(&out, node); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// UnmarshalJSON decodes the node encoded by MarshalJSON.
+func UnmarshalJSON(data []byte) (Node, error) {
+	return decodeNode(data)
+}
+
+func jsonName(field string) string {
+	first, width := utf8.DecodeRuneInString(field)
+	return string(unicode.ToLower(first)) + field[width:]
+}
+
+// encodedFields returns indexes of the fields of the node type which are encoded.
+func encodedFields(typ reflect.Type) []int {
+	var fields []int
+	for i := 0; i < typ.NumField(); i++ {
+		field := typ.Field(i)
+		if field.PkgPath != "" || field.Type == commentMapType {
+			continue
+		}
+		fields = append(fields, i)
+	}
+
+	return fields
+}
+
+func encodeNode(out *bytes.Buffer, node Node) error {
+	value := reflect.ValueOf(node)
+	if node == nil || value.IsNil() {
+		out.WriteString("null")
+		return nil
+	}
+
+	typ := value.Elem().Type()
+	if nodeKinds[typ.Name()] != typ {
+		return fmt.Errorf("ast: cannot marshal node of type %T", node)
+	}
+
+	fmt.Fprintf(out, `{"kind":%q`, typ.Name())
+
+	for _, i := range encodedFields(typ) {
+		fmt.Fprintf(out, `,%q:`, jsonName(typ.Field(i).Name))
+		if err := encodeValue(out, value.Elem().Field(i)); err != nil {
+			return err
+		}
+	}
+
+	out.WriteString("}")
+	return nil
+}
+
+func encodeValue(out *bytes.Buffer, value reflect.Value) error {
+	switch {
+	case value.Type() == expressionMapType:
+		pairs := value.Interface().(map[Expression]Expression)
+		hash := &HashLiteral{Pairs: pairs}
+
+		out.WriteString("[")
+		for i, key := range hash.SortedKeys() {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			out.WriteString(`{"key":`)
+			if err := encodeNode(out, key); err != nil {
+				return err
+			}
+			out.WriteString(`,"value":`)
+			if err := encodeNode(out, pairs[key]); err != nil {
+				return err
+			}
+			out.WriteString("}")
+		}
+		out.WriteString("]")
+		return nil
+
+	case value.Kind() == reflect.Interface && value.Type().Implements(nodeType),
+		value.Kind() == reflect.Ptr && value.Type().Implements(nodeType):
+		if value.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+		return encodeNode(out, value.Interface().(Node))
+
+	case value.Kind() == reflect.Slice:
+		if value.IsNil() {
+			out.WriteString("null")
+			return nil
+		}
+
+		out.WriteString("[")
+		for i := 0; i < value.Len(); i++ {
+			if i > 0 {
+				out.WriteString(",")
+			}
+			if err := encodeValue(out, value.Index(i)); err != nil {
+				return err
+			}
+		}
+		out.WriteString("]")
+		return nil
+
+	default:
+		data, err := json.Marshal(value.Interface())
+		if err != nil {
+			return err
+		}
+		out.Write(data)
+		return nil
+	}
+}
+
+// isNull reports whether the value is null or missing.
+func isNull(data []byte) bool {
+	trimmed := string(bytes.TrimSpace(data))
+	return trimmed == "" || trimmed == "null"
+}
+
+func decodeNode(data []byte) (Node, error) {
+	if isNull(data) {
+		return nil, nil
+	}
+
+	var members map[string]json.RawMessage
+	if err := json.Unmarshal(data, &members); err != nil {
+		return nil, err
+	}
+
+	var kind string
+	if err := json.Unmarshal(members["kind"], &kind); err != nil {
+		return nil, fmt.Errorf("ast: node without kind: %s", data)
+	}
+
+	typ, ok := nodeKinds[kind]
+	if !ok {
+		return nil, fmt.Errorf("ast: unknown node kind %q", kind)
+	}
+
+	node := reflect.New(typ)
+	for _, i := range encodedFields(typ) {
+		field := typ.Field(i)
+
+		raw, ok := members[jsonName(field.Name)]
+		if !ok {
+			continue
+		}
+
+		if err := decodeValue(raw, node.Elem().Field(i)); err != nil {
+			return nil, fmt.Errorf("ast: %s.%s: %v", kind, field.Name, err)
+		}
+	}
+
+	return node.Interface().(Node), nil
+}
+
+func decodeValue(data []byte, value reflect.Value) error {
+	switch {
+	case value.Type() == expressionMapType:
+		var pairs []struct {
+			Key   json.RawMessage `json:"key"`
+			Value json.RawMessage `json:"value"`
+		}
+		if err := json.Unmarshal(data, &pairs); err != nil {
+			return err
+		}
+		if pairs == nil {
+			return nil
+		}
+
+		decoded := make(map[Expression]Expression, len(pairs))
+		for _, pair := range pairs {
+			key, err := decodeNode(pair.Key)
+			if err != nil {
+				return err
+			}
+			val, err := decodeNode(pair.Value)
+			if err != nil {
+				return err
+			}
+			if key == nil || val == nil {
+				return fmt.Errorf("hash pair without key or value")
+			}
+			decoded[key] = val
+		}
+
+		value.Set(reflect.ValueOf(decoded))
+		return nil
+
+	case value.Kind() == reflect.Interface && value.Type().Implements(nodeType),
+		value.Kind() == reflect.Ptr && value.Type().Implements(nodeType):
+		node, err := decodeNode(data)
+		if err != nil || node == nil {
+			return err
+		}
+
+		if !reflect.TypeOf(node).AssignableTo(value.Type()) {
+			return fmt.Errorf("expected %s, got %T", value.Type(), node)
+		}
+
+		value.Set(reflect.ValueOf(node))
+		return nil
+
+	case value.Kind() == reflect.Slice:
+		var items []json.RawMessage
+		if err := json.Unmarshal(data, &items); err != nil {
+			return err
+		}
+		if items == nil {
+			return nil
+		}
+
+		slice := reflect.MakeSlice(value.Type(), len(items), len(items))
+		for i, item := range items {
+			if err := decodeValue(item, slice.Index(i)); err != nil {
+				return err
+			}
+		}
+
+		value.Set(slice)
+		return nil
+
+	default:
+		return json.Unmarshal(data, value.Addr().Interface())
+	}
+}
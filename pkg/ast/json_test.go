@@ -0,0 +1,104 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/token"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNodeKindsCoverAllNodeTypes(t *testing.T) {
+	types := nodeTypes(t)
+
+	assert.Len(t, nodeKinds, len(types))
+	for _, typ := range types {
+		_, ok := nodeKinds[strings.TrimPrefix(typ, "*ast.")]
+		assert.True(t, ok, "%s has no kind", typ)
+	}
+}
+
+func TestJSONRoundTrip(t *testing.T) {
+	program := allNodesProgram()
+	program.Comments = []*Comment{{Token: token.Token{Type: token.Comment, Literal: "// c"}}}
+
+	data, err := MarshalJSON(program)
+	assert.NoError(t, err)
+
+	for _, typ := range nodeTypes(t) {
+		kind := strings.TrimPrefix(typ, "*ast.")
+		assert.Contains(t, string(data), `"kind":"`+kind+`"`)
+	}
+
+	decoded, err := UnmarshalJSON(data)
+	assert.NoError(t, err)
+	assert.IsType(t, &Program{}, decoded)
+	assert.Equal(t, program.String(), decoded.String())
+
+	again, err := MarshalJSON(decoded)
+	assert.NoError(t, err)
+	assert.Equal(t, string(data), string(again))
+}
+
+func TestMarshalJSON(t *testing.T) {
+	node := &PrefixExpression{
+		Token:    token.Token{Type: token.OperatorMinus, Literal: "-", Pos: token.Position{Offset: 0, Line: 1, Column: 1}, End: token.Position{Offset: 1, Line: 1, Column: 2}},
+		Operator: "-",
+		Right:    &Identifier{Token: token.Token{Type: token.Ident, Literal: "x", Pos: token.Position{Filename: "a.mk", Offset: 1, Line: 1, Column: 2}}, Value: "x"},
+	}
+
+	data, err := MarshalJSON(node)
+	assert.NoError(t, err)
+
+	expected := `{"kind":"PrefixExpression",` +
+		`"token":{"type":"-","literal":"-","pos":{"offset":0,"line":1,"column":1},"end":{"offset":1,"line":1,"column":2}},` +
+		`"operator":"-",` +
+		`"right":{"kind":"Identifier","token":{"type":"IDENT","literal":"x","pos":{"filename":"a.mk","offset":1,"line":1,"column":2},"end":{"offset":0,"line":0,"column":0}},"value":"x"}}`
+	assert.Equal(t, expected, string(data))
+}
+
+func TestMarshalJSONHashPairs(t *testing.T) {
+	hash := &HashLiteral{Pairs: map[Expression]Expression{
+		&StringLiteral{Token: token.Token{Literal: "b", Pos: token.Position{Offset: 5}}, Value: "b"}: integer(2),
+		&StringLiteral{Token: token.Token{Literal: "a", Pos: token.Position{Offset: 1}}, Value: "a"}: integer(1),
+	}}
+
+	data, err := MarshalJSON(hash)
+	assert.NoError(t, err)
+	assert.True(t, strings.Index(string(data), `"value":"a"`) < strings.Index(string(data), `"value":"b"`), string(data))
+
+	decoded, err := UnmarshalJSON(data)
+	assert.NoError(t, err)
+	assert.Len(t, decoded.(*HashLiteral).Pairs, 2)
+}
+
+func TestUnmarshalJSONErrors(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"unknown kind":  {`{"kind":"Loop"}`, `ast: unknown node kind "Loop"`},
+		"missing kind":  {`{"value":"x"}`, `ast: node without kind: {"value":"x"}`},
+		"wrong type":    {`{"kind":"LetStatement","name":{"kind":"IntegerLiteral","value":1}}`, "ast: LetStatement.Name: expected *ast.Identifier, got *ast.IntegerLiteral"},
+		"invalid value": {`{"kind":"IntegerLiteral","value":"one"}`, "ast: IntegerLiteral.Value: json: cannot unmarshal string into Go value of type int64"},
+		"partial pair":  {`{"kind":"HashLiteral","pairs":[{"key":{"kind":"Identifier"}}]}`, "ast: HashLiteral.Pairs: hash pair without key or value"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			_, err := UnmarshalJSON([]byte(data.input))
+			if assert.Error(t, err) {
+				assert.Equal(t, data.expected, err.Error())
+			}
+		})
+	}
+}
+
+func TestUnmarshalJSONNull(t *testing.T) {
+	node, err := UnmarshalJSON([]byte("null"))
+	assert.NoError(t, err)
+	assert.Nil(t, node)
+}
@@ -0,0 +1,31 @@
+package ast
+
+import (
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Outline prints each node of the tree on its own line as its kind, position and the literal
+// of its token, indented by the depth of the node.
+func Outline(w io.Writer, node Node) {
+	Walk(&outliner{out: w}, node)
+}
+
+type outliner struct {
+	out   io.Writer
+	depth int
+}
+
+func (o *outliner) Visit(node Node) Visitor {
+	if node == nil {
+		o.depth--
+		return nil
+	}
+
+	kind := strings.TrimPrefix(fmt.Sprintf("%T", node), "*ast.")
+	fmt.Fprintf(o.out, "%s%s %s %q\n", strings.Repeat("  ", o.depth), kind, node.Pos(), node.NodeToken().Literal)
+
+	o.depth++
+	return o
+}
@@ -0,0 +1,32 @@
+package ast
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/token"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOutline(t *testing.T) {
+	pos := func(line, column int) token.Position { return token.Position{Line: line, Column: column} }
+
+	x := ident("x")
+	x.Token.Pos = pos(1, 5)
+	one := integer(1)
+	one.Token.Pos = pos(1, 9)
+
+	program := &Program{Statements: []Statement{
+		&LetStatement{Token: token.Token{Type: token.KeywordLet, Literal: "let", Pos: pos(1, 1)}, Name: x, Value: one},
+	}}
+
+	var out strings.Builder
+	Outline(&out, program)
+
+	expected := "Program 1:1 \"let\"\n" +
+		"  LetStatement 1:1 \"let\"\n" +
+		"    Identifier 1:5 \"x\"\n" +
+		"    IntegerLiteral 1:9 \"1\"\n"
+	assert.Equal(t, expected, out.String())
+}
@@ -0,0 +1,450 @@
+package ast
+
+import "fmt"
+
+// Visitor's Visit method is invoked for each node encountered by Walk. If the result visitor w
+// is not nil, Walk visits each of the children of the node with the visitor w, followed by
+// a call of w.Visit(nil).
+type Visitor interface {
+	Visit(node Node) (w Visitor)
+}
+
+// Walk traverses the tree in depth-first order, children are visited in the order in which they
+// appear in the source. Comments of a program are not visited, they are not children of any node.
+func Walk(v Visitor, node Node) {
+	if v = v.Visit(node); v == nil {
+		return
+	}
+
+	switch n := node.(type) {
+	case *Program:
+		walkStatements(v, n.Statements)
+
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *BooleanLiteral,
+		*BreakStatement, *ContinueStatement, *Comment:
+		// leaves
+
+	case *PrefixExpression:
+		Walk(v, n.Right)
+
+	case *InfixExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Right)
+
+	case *AssignExpression:
+		Walk(v, n.Target)
+		Walk(v, n.Value)
+
+	case *IfExpression:
+		Walk(v, n.Condition)
+		Walk(v, n.Consequence)
+		if n.Alternative != nil {
+			Walk(v, n.Alternative)
+		}
+
+	case *FunctionLiteral:
+		for _, param := range n.Parameters {
+			Walk(v, param)
+		}
+		Walk(v, n.Body)
+
+	case *MacroLiteral:
+		for _, param := range n.Parameters {
+			Walk(v, param)
+		}
+		Walk(v, n.Body)
+
+	case *ArrayLiteral:
+		walkExpressions(v, n.Elements)
+
+	case *IndexExpression:
+		Walk(v, n.Left)
+		Walk(v, n.Index)
+
+	case *HashLiteral:
+		for _, key := range n.SortedKeys() {
+			Walk(v, key)
+			Walk(v, n.Pairs[key])
+		}
+
+	case *CallExpression:
+		Walk(v, n.Function)
+		walkExpressions(v, n.Arguments)
+
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			Walk(v, n.Expression)
+		}
+
+	case *LetStatement:
+		Walk(v, n.Name)
+		Walk(v, n.Value)
+
+	case *BlockStatement:
+		walkStatements(v, n.Statements)
+
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			Walk(v, n.ReturnValue)
+		}
+
+	case *WhileStatement:
+		Walk(v, n.Condition)
+		Walk(v, n.Body)
+
+	case *ForStatement:
+		if n.Key != nil {
+			Walk(v, n.Key)
+		}
+		Walk(v, n.Value)
+		Walk(v, n.Iterable)
+		Walk(v, n.Body)
+
+	default:
+		panic(fmt.Sprintf("ast.Walk: unexpected node type %T", n))
+	}
+
+	v.Visit(nil)
+}
+
+func walkStatements(v Visitor, stmts []Statement) {
+	for _, stmt := range stmts {
+		Walk(v, stmt)
+	}
+}
+
+func walkExpressions(v Visitor, exprs []Expression) {
+	for _, expr := range exprs {
+		Walk(v, expr)
+	}
+}
+
+type inspector func(Node) bool
+
+func (f inspector) Visit(node Node) Visitor {
+	if f(node) {
+		return f
+	}
+	return nil
+}
+
+// Inspect traverses the tree in depth-first order calling f(node) for each node. If f returns true,
+// Inspect visits the children of the node, followed by a call of f(nil).
+func Inspect(node Node, f func(Node) bool) {
+	Walk(inspector(f), node)
+}
+
+// ModifierFunc returns the node which replaces the given one.
+type ModifierFunc func(Node) Node
+
+// Modify replaces the children of the node with the results of Modify called for each of them
+// and returns the result of the modifier called for the node itself, so the tree is rewritten
+// bottom-up. Nodes are modified in place. A child which must have a concrete type,
+// e.g. the name of a let statement, is kept if the modifier returns a node of another type.
+func Modify(node Node, modifier ModifierFunc) Node {
+	switch n := node.(type) {
+	case *Program:
+		modifyStatements(n.Statements, modifier)
+
+	case *Identifier, *IntegerLiteral, *FloatLiteral, *StringLiteral, *BooleanLiteral,
+		*BreakStatement, *ContinueStatement, *Comment:
+		// leaves
+
+	case *PrefixExpression:
+		n.Right = Modify(n.Right, modifier)
+
+	case *InfixExpression:
+		n.Left = Modify(n.Left, modifier)
+		n.Right = Modify(n.Right, modifier)
+
+	case *AssignExpression:
+		n.Target = Modify(n.Target, modifier)
+		n.Value = Modify(n.Value, modifier)
+
+	case *IfExpression:
+		n.Condition = Modify(n.Condition, modifier)
+		n.Consequence = modifyBlock(n.Consequence, modifier)
+		if n.Alternative != nil {
+			n.Alternative = modifyBlock(n.Alternative, modifier)
+		}
+
+	case *FunctionLiteral:
+		for i, param := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(param, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+
+	case *MacroLiteral:
+		for i, param := range n.Parameters {
+			n.Parameters[i] = modifyIdentifier(param, modifier)
+		}
+		n.Body = modifyBlock(n.Body, modifier)
+
+	case *ArrayLiteral:
+		modifyExpressions(n.Elements, modifier)
+
+	case *IndexExpression:
+		n.Left = Modify(n.Left, modifier)
+		n.Index = Modify(n.Index, modifier)
+
+	case *HashLiteral:
+		pairs := make(map[Expression]Expression, len(n.Pairs))
+		for _, key := range n.SortedKeys() {
+			value := n.Pairs[key]
+			pairs[Modify(key, modifier)] = Modify(value, modifier)
+		}
+		n.Pairs = pairs
+
+	case *CallExpression:
+		n.Function = Modify(n.Function, modifier)
+		modifyExpressions(n.Arguments, modifier)
+
+	case *ExpressionStatement:
+		if n.Expression != nil {
+			n.Expression = Modify(n.Expression, modifier)
+		}
+
+	case *LetStatement:
+		n.Name = modifyIdentifier(n.Name, modifier)
+		n.Value = Modify(n.Value, modifier)
+
+	case *BlockStatement:
+		modifyStatements(n.Statements, modifier)
+
+	case *ReturnStatement:
+		if n.ReturnValue != nil {
+			n.ReturnValue = Modify(n.ReturnValue, modifier)
+		}
+
+	case *WhileStatement:
+		n.Condition = Modify(n.Condition, modifier)
+		n.Body = modifyBlock(n.Body, modifier)
+
+	case *ForStatement:
+		if n.Key != nil {
+			n.Key = modifyIdentifier(n.Key, modifier)
+		}
+		n.Value = modifyIdentifier(n.Value, modifier)
+		n.Iterable = Modify(n.Iterable, modifier)
+		n.Body = modifyBlock(n.Body, modifier)
+
+	default:
+		panic(fmt.Sprintf("ast.Modify: unexpected node type %T", n))
+	}
+
+	return modifier(node)
+}
+
+func modifyStatements(stmts []Statement, modifier ModifierFunc) {
+	for i, stmt := range stmts {
+		stmts[i] = Modify(stmt, modifier)
+	}
+}
+
+func modifyExpressions(exprs []Expression, modifier ModifierFunc) {
+	for i, expr := range exprs {
+		exprs[i] = Modify(expr, modifier)
+	}
+}
+
+func modifyIdentifier(ident *Identifier, modifier ModifierFunc) *Identifier {
+	if modified, ok := Modify(ident, modifier).(*Identifier); ok {
+		return modified
+	}
+	return ident
+}
+
+func modifyBlock(block *BlockStatement, modifier ModifierFunc) *BlockStatement {
+	if modified, ok := Modify(block, modifier).(*BlockStatement); ok {
+		return modified
+	}
+	return block
+}
+
+// Copy returns a deep copy of the node, so that the copy can be modified without changing the original.
+func Copy(node Node) Node {
+	switch n := node.(type) {
+	case *Program:
+		c := *n
+		c.Statements = copyStatements(n.Statements)
+		return &c
+
+	case *Identifier:
+		c := *n
+		return &c
+
+	case *IntegerLiteral:
+		c := *n
+		return &c
+
+	case *FloatLiteral:
+		c := *n
+		return &c
+
+	case *StringLiteral:
+		c := *n
+		return &c
+
+	case *BooleanLiteral:
+		c := *n
+		return &c
+
+	case *BreakStatement:
+		c := *n
+		return &c
+
+	case *ContinueStatement:
+		c := *n
+		return &c
+
+	case *Comment:
+		c := *n
+		return &c
+
+	case *PrefixExpression:
+		c := *n
+		c.Right = Copy(n.Right)
+		return &c
+
+	case *InfixExpression:
+		c := *n
+		c.Left = Copy(n.Left)
+		c.Right = Copy(n.Right)
+		return &c
+
+	case *AssignExpression:
+		c := *n
+		c.Target = Copy(n.Target)
+		c.Value = Copy(n.Value)
+		return &c
+
+	case *IfExpression:
+		c := *n
+		c.Condition = Copy(n.Condition)
+		c.Consequence = Copy(n.Consequence).(*BlockStatement)
+		if n.Alternative != nil {
+			c.Alternative = Copy(n.Alternative).(*BlockStatement)
+		}
+		return &c
+
+	case *FunctionLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = Copy(n.Body).(*BlockStatement)
+		return &c
+
+	case *MacroLiteral:
+		c := *n
+		c.Parameters = copyIdentifiers(n.Parameters)
+		c.Body = Copy(n.Body).(*BlockStatement)
+		return &c
+
+	case *ArrayLiteral:
+		c := *n
+		c.Elements = copyExpressions(n.Elements)
+		return &c
+
+	case *IndexExpression:
+		c := *n
+		c.Left = Copy(n.Left)
+		c.Index = Copy(n.Index)
+		return &c
+
+	case *HashLiteral:
+		c := *n
+		c.Pairs = make(map[Expression]Expression, len(n.Pairs))
+		for key, value := range n.Pairs {
+			c.Pairs[Copy(key)] = Copy(value)
+		}
+		return &c
+
+	case *CallExpression:
+		c := *n
+		c.Function = Copy(n.Function)
+		c.Arguments = copyExpressions(n.Arguments)
+		return &c
+
+	case *ExpressionStatement:
+		c := *n
+		if n.Expression != nil {
+			c.Expression = Copy(n.Expression)
+		}
+		return &c
+
+	case *LetStatement:
+		c := *n
+		c.Name = Copy(n.Name).(*Identifier)
+		c.Value = Copy(n.Value)
+		return &c
+
+	case *BlockStatement:
+		c := *n
+		c.Statements = copyStatements(n.Statements)
+		return &c
+
+	case *ReturnStatement:
+		c := *n
+		if n.ReturnValue != nil {
+			c.ReturnValue = Copy(n.ReturnValue)
+		}
+		return &c
+
+	case *WhileStatement:
+		c := *n
+		c.Condition = Copy(n.Condition)
+		c.Body = Copy(n.Body).(*BlockStatement)
+		return &c
+
+	case *ForStatement:
+		c := *n
+		if n.Key != nil {
+			c.Key = Copy(n.Key).(*Identifier)
+		}
+		c.Value = Copy(n.Value).(*Identifier)
+		c.Iterable = Copy(n.Iterable)
+		c.Body = Copy(n.Body).(*BlockStatement)
+		return &c
+
+	default:
+		panic(fmt.Sprintf("ast.Copy: unexpected node type %T", n))
+	}
+}
+
+func copyStatements(stmts []Statement) []Statement {
+	if stmts == nil {
+		return nil
+	}
+
+	copied := make([]Statement, len(stmts))
+	for i, stmt := range stmts {
+		copied[i] = Copy(stmt)
+	}
+
+	return copied
+}
+
+func copyExpressions(exprs []Expression) []Expression {
+	if exprs == nil {
+		return nil
+	}
+
+	copied := make([]Expression, len(exprs))
+	for i, expr := range exprs {
+		copied[i] = Copy(expr)
+	}
+
+	return copied
+}
+
+func copyIdentifiers(idents []*Identifier) []*Identifier {
+	if idents == nil {
+		return nil
+	}
+
+	copied := make([]*Identifier, len(idents))
+	for i, ident := range idents {
+		copied[i] = Copy(ident).(*Identifier)
+	}
+
+	return copied
+}
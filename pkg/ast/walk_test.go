@@ -0,0 +1,241 @@
+package ast
+
+import (
+	"fmt"
+	goast "go/ast"
+	goparser "go/parser"
+	gotoken "go/token"
+	"os"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/token"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func ident(name string) *Identifier {
+	return &Identifier{Token: token.Token{Type: token.Ident, Literal: name}, Value: name}
+}
+
+func integer(value int64) *IntegerLiteral {
+	return &IntegerLiteral{Token: token.Token{Type: token.TypeInteger, Literal: strconv.FormatInt(value, 10)}, Value: value}
+}
+
+func block(stmts ...Statement) *BlockStatement {
+	return &BlockStatement{Statements: stmts}
+}
+
+func expr(e Expression) *ExpressionStatement {
+	return &ExpressionStatement{Expression: e}
+}
+
+// allNodesProgram returns a program which contains every node type.
+func allNodesProgram() *Program {
+	return &Program{Statements: []Statement{
+		&LetStatement{Name: ident("f"), Value: &FunctionLiteral{
+			Parameters: []*Identifier{ident("a")},
+			Body: block(
+				&ReturnStatement{ReturnValue: &InfixExpression{Left: ident("a"), Operator: "+", Right: integer(1)}},
+			),
+		}},
+		expr(&IfExpression{
+			Condition:   &PrefixExpression{Operator: "!", Right: &BooleanLiteral{Value: true}},
+			Consequence: block(expr(&FloatLiteral{Value: 1.5})),
+			Alternative: block(expr(&StringLiteral{Value: "s"})),
+		}),
+		&WhileStatement{Condition: &BooleanLiteral{Value: false}, Body: block(&BreakStatement{})},
+		&ForStatement{Key: ident("k"), Value: ident("v"), Iterable: &ArrayLiteral{Elements: []Expression{integer(1)}}, Body: block(&ContinueStatement{})},
+		expr(&AssignExpression{
+			Target:   &IndexExpression{Left: ident("h"), Index: &StringLiteral{Value: "k"}},
+			Operator: "=",
+			Value:    &HashLiteral{Pairs: map[Expression]Expression{integer(1): integer(1)}},
+		}),
+		expr(&CallExpression{Function: ident("f"), Arguments: []Expression{integer(1)}}),
+		&LetStatement{Name: ident("m"), Value: &MacroLiteral{Parameters: []*Identifier{ident("x")}, Body: block(expr(ident("x")))}},
+	}}
+}
+
+// nodeTypes returns names of all types of the package implementing Node, they are found in the sources,
+// so that a new node type can't be added without being covered by Walk and Modify.
+func nodeTypes(t *testing.T) []string {
+	pkgs, err := goparser.ParseDir(gotoken.NewFileSet(), ".", func(info os.FileInfo) bool {
+		return !strings.HasSuffix(info.Name(), "_test.go")
+	}, 0)
+	assert.NoError(t, err)
+
+	var types []string
+	for _, file := range pkgs["ast"].Files {
+		for _, decl := range file.Decls {
+			fn, ok := decl.(*goast.FuncDecl)
+			if !ok || fn.Recv == nil || fn.Name.Name != "NodeToken" {
+				continue
+			}
+
+			recv := fn.Recv.List[0].Type.(*goast.StarExpr).X.(*goast.Ident)
+			types = append(types, "*ast."+recv.Name)
+		}
+	}
+
+	return types
+}
+
+func TestInspectVisitsAllNodeTypes(t *testing.T) {
+	visited := map[string]bool{}
+	record := func(node Node) bool {
+		if node != nil {
+			visited[fmt.Sprintf("%T", node)] = true
+		}
+		return true
+	}
+
+	Inspect(allNodesProgram(), record)
+	Inspect(&Comment{}, record)
+
+	types := nodeTypes(t)
+	assert.NotEmpty(t, types)
+
+	for _, typ := range types {
+		assert.True(t, visited[typ], "%s is not visited", typ)
+	}
+}
+
+func TestModifyVisitsAllNodeTypes(t *testing.T) {
+	visited := map[string]bool{}
+	record := func(node Node) Node {
+		visited[fmt.Sprintf("%T", node)] = true
+		return node
+	}
+
+	Modify(allNodesProgram(), record)
+	Modify(&Comment{}, record)
+
+	for _, typ := range nodeTypes(t) {
+		assert.True(t, visited[typ], "%s is not modified", typ)
+	}
+}
+
+type orderVisitor struct {
+	events *[]string
+}
+
+func (v orderVisitor) Visit(node Node) Visitor {
+	if node == nil {
+		*v.events = append(*v.events, "end")
+	} else {
+		*v.events = append(*v.events, node.String())
+	}
+	return v
+}
+
+func TestWalkOrder(t *testing.T) {
+	events := []string{}
+	node := &InfixExpression{Left: ident("a"), Operator: "*", Right: &PrefixExpression{Operator: "-", Right: integer(2)}}
+	Walk(orderVisitor{&events}, node)
+
+	assert.Equal(t, []string{"(a * (-2))", "a", "end", "(-2)", "2", "end", "end", "end"}, events)
+}
+
+func TestInspectSkipsChildren(t *testing.T) {
+	visited := map[string]bool{}
+	Inspect(allNodesProgram(), func(node Node) bool {
+		visited[fmt.Sprintf("%T", node)] = true
+
+		_, isFunction := node.(*FunctionLiteral)
+		_, isIf := node.(*IfExpression)
+		return !isFunction && !isIf
+	})
+
+	assert.True(t, visited["*ast.FunctionLiteral"])
+	assert.True(t, visited["*ast.IfExpression"])
+	assert.False(t, visited["*ast.ReturnStatement"])
+	assert.False(t, visited["*ast.FloatLiteral"])
+	assert.False(t, visited["*ast.PrefixExpression"])
+}
+
+func TestCopyIsDeep(t *testing.T) {
+	original := allNodesProgram()
+	copied := Copy(original)
+
+	originalNodes := map[Node]bool{}
+	Inspect(original, func(node Node) bool {
+		originalNodes[node] = true
+		return true
+	})
+
+	copiedTypes := map[string]bool{}
+	Inspect(copied, func(node Node) bool {
+		if node != nil {
+			copiedTypes[fmt.Sprintf("%T", node)] = true
+			assert.False(t, originalNodes[node], "%T is shared with the original", node)
+		}
+		return true
+	})
+
+	assert.Equal(t, original.String(), copied.String())
+	for _, typ := range nodeTypes(t) {
+		if typ != "*ast.Comment" {
+			assert.True(t, copiedTypes[typ], "%s is not copied", typ)
+		}
+	}
+	assert.IsType(t, &Comment{}, Copy(&Comment{}))
+}
+
+func TestModify(t *testing.T) {
+	one := func() Expression { return integer(1) }
+	two := func() Expression { return integer(2) }
+
+	turnOneIntoTwo := func(node Node) Node {
+		if literal, ok := node.(*IntegerLiteral); ok && literal.Value == 1 {
+			return integer(2)
+		}
+		return node
+	}
+
+	testData := map[string]struct {
+		input    Node
+		expected Node
+	}{
+		"integer":    {one(), two()},
+		"program":    {&Program{Statements: []Statement{expr(one())}}, &Program{Statements: []Statement{expr(two())}}},
+		"infix":      {&InfixExpression{Left: one(), Operator: "+", Right: two()}, &InfixExpression{Left: two(), Operator: "+", Right: two()}},
+		"prefix":     {&PrefixExpression{Operator: "-", Right: one()}, &PrefixExpression{Operator: "-", Right: two()}},
+		"index":      {&IndexExpression{Left: one(), Index: one()}, &IndexExpression{Left: two(), Index: two()}},
+		"assign":     {&AssignExpression{Target: ident("x"), Operator: "+=", Value: one()}, &AssignExpression{Target: ident("x"), Operator: "+=", Value: two()}},
+		"if":         {&IfExpression{Condition: one(), Consequence: block(expr(one())), Alternative: block(expr(one()))}, &IfExpression{Condition: two(), Consequence: block(expr(two())), Alternative: block(expr(two()))}},
+		"return":     {&ReturnStatement{ReturnValue: one()}, &ReturnStatement{ReturnValue: two()}},
+		"let":        {&LetStatement{Name: ident("x"), Value: one()}, &LetStatement{Name: ident("x"), Value: two()}},
+		"function":   {&FunctionLiteral{Body: block(expr(one()))}, &FunctionLiteral{Body: block(expr(two()))}},
+		"array":      {&ArrayLiteral{Elements: []Expression{one(), one()}}, &ArrayLiteral{Elements: []Expression{two(), two()}}},
+		"call":       {&CallExpression{Function: ident("f"), Arguments: []Expression{one()}}, &CallExpression{Function: ident("f"), Arguments: []Expression{two()}}},
+		"while":      {&WhileStatement{Condition: one(), Body: block(expr(one()))}, &WhileStatement{Condition: two(), Body: block(expr(two()))}},
+		"for":        {&ForStatement{Value: ident("v"), Iterable: one(), Body: block(expr(one()))}, &ForStatement{Value: ident("v"), Iterable: two(), Body: block(expr(two()))}},
+		"hash value": {&HashLiteral{Pairs: map[Expression]Expression{&StringLiteral{Value: "a"}: one()}}, &HashLiteral{Pairs: map[Expression]Expression{&StringLiteral{Value: "a"}: two()}}},
+		"hash key":   {&HashLiteral{Pairs: map[Expression]Expression{one(): &StringLiteral{Value: "a"}}}, &HashLiteral{Pairs: map[Expression]Expression{two(): &StringLiteral{Value: "a"}}}},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.expected.String(), Modify(data.input, turnOneIntoTwo).String())
+		})
+	}
+}
+
+func TestModifyReplacesNodes(t *testing.T) {
+	program := &Program{Statements: []Statement{
+		&LetStatement{Token: token.Token{Type: token.KeywordLet, Literal: "let"}, Name: ident("x"), Value: &InfixExpression{Left: ident("x"), Operator: "+", Right: ident("y")}},
+	}}
+
+	// identifiers are replaced by integers except for the name of the let statement, which must be an identifier
+	modified := Modify(program, func(node Node) Node {
+		if _, ok := node.(*Identifier); ok {
+			return integer(0)
+		}
+		return node
+	})
+
+	assert.Equal(t, "let x = (0 + 0);", modified.String())
+}
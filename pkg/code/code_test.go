@@ -0,0 +1,108 @@
+package code
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestMake(t *testing.T) {
+	testData := map[string]struct {
+		op       Opcode
+		operands []int
+		expected []byte
+	}{
+		"constant": {OpConstant, []int{65534}, []byte{byte(OpConstant), 255, 254}},
+		"add":      {OpAdd, []int{}, []byte{byte(OpAdd)}},
+		"local":    {OpGetLocal, []int{255}, []byte{byte(OpGetLocal), 255}},
+		"closure":  {OpClosure, []int{65534, 255}, []byte{byte(OpClosure), 255, 254, 255}},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.expected, Make(data.op, data.operands...))
+		})
+	}
+}
+
+func TestCheckOperands(t *testing.T) {
+	testData := map[string]struct {
+		op       Opcode
+		operands []int
+		err      string
+	}{
+		"constant":          {OpConstant, []int{65535}, ""},
+		"constant too big":  {OpConstant, []int{65536}, "operand 1 of OpConstant is out of range: 65536, the maximum is 65535"},
+		"local too big":     {OpGetLocal, []int{256}, "operand 1 of OpGetLocal is out of range: 256, the maximum is 255"},
+		"negative":          {OpGetLocal, []int{-1}, "operand 1 of OpGetLocal is out of range: -1, the maximum is 255"},
+		"closure free vars": {OpClosure, []int{1, 256}, "operand 2 of OpClosure is out of range: 256, the maximum is 255"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			err := CheckOperands(data.op, data.operands...)
+			if data.err == "" {
+				assert.NoError(t, err)
+			} else {
+				assert.EqualError(t, err, data.err)
+			}
+		})
+	}
+}
+
+func TestInstructionsString(t *testing.T) {
+	instructions := []Instructions{
+		Make(OpAdd),
+		Make(OpGetLocal, 1),
+		Make(OpConstant, 2),
+		Make(OpConstant, 65535),
+		Make(OpClosure, 65535, 255),
+	}
+
+	expected := `0000 OpAdd
+0001 OpGetLocal 1
+0003 OpConstant 2
+0006 OpConstant 65535
+0009 OpClosure 65535 255
+`
+
+	concatted := Instructions{}
+	for _, ins := range instructions {
+		concatted = append(concatted, ins...)
+	}
+
+	assert.Equal(t, expected, concatted.String())
+}
+
+func TestReadOperands(t *testing.T) {
+	testData := map[string]struct {
+		op        Opcode
+		operands  []int
+		bytesRead int
+	}{
+		"constant": {OpConstant, []int{65535}, 2},
+		"local":    {OpGetLocal, []int{255}, 1},
+		"closure":  {OpClosure, []int{65535, 255}, 3},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			instruction := Make(data.op, data.operands...)
+
+			def, err := Lookup(byte(data.op))
+			if err != nil {
+				t.Fatalf("definition not found: %q\n", err)
+			}
+
+			operandsRead, n := ReadOperands(def, instruction[1:])
+			assert.Equal(t, data.bytesRead, n)
+			assert.Equal(t, data.operands, operandsRead)
+		})
+	}
+}
@@ -0,0 +1,799 @@
+package compiler
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/code"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+var infixOpcodes = map[string]code.Opcode{
+	token.OperatorPlus:         code.OpAdd,
+	token.OperatorMinus:        code.OpSub,
+	token.OperatorAsterisk:     code.OpMul,
+	token.OperatorSlash:        code.OpDiv,
+	token.OperatorEqual:        code.OpEqual,
+	token.OperatorNotEqual:     code.OpNotEqual,
+	token.OperatorGreaterThan:  code.OpGreaterThan,
+	token.OperatorLowerThan:    code.OpLowerThan,
+	token.OperatorPercent:      code.OpMod,
+	token.OperatorPower:        code.OpPow,
+	token.OperatorGreaterEqual: code.OpGreaterEqual,
+	token.OperatorLowerEqual:   code.OpLowerEqual,
+}
+
+var prefixOpcodes = map[string]code.Opcode{
+	token.OperatorBang:  code.OpBang,
+	token.OperatorMinus: code.OpMinus,
+}
+
+// Error describes code which cannot be compiled.
+type Error struct {
+	Pos token.Position
+	Msg string
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+func errorAt(pos token.Position, format string, a ...interface{}) error {
+	return &Error{Pos: pos, Msg: fmt.Sprintf(format, a...)}
+}
+
+type Bytecode struct {
+	Instructions code.Instructions
+	Constants    []object.Object
+	GlobalNames  []string
+	Positions    map[int]token.Position
+}
+
+type EmittedInstruction struct {
+	Opcode   code.Opcode
+	Position int
+}
+
+type CompilationScope struct {
+	instructions        code.Instructions
+	positions           map[int]token.Position
+	lastInstruction     EmittedInstruction
+	previousInstruction EmittedInstruction
+	loops               []*loop // loops enclosing the current instruction, the innermost is the last one
+}
+
+// loop describes a loop being compiled.
+type loop struct {
+	start  int   // position of the first instruction of an iteration, target of 'continue'
+	breaks []int // positions of jumps emitted for 'break', they're patched once the loop is compiled
+}
+
+func newCompilationScope() CompilationScope {
+	return CompilationScope{positions: make(map[int]token.Position)}
+}
+
+type Compiler struct {
+	constants   []object.Object
+	symbolTable *SymbolTable
+
+	scopes     []CompilationScope
+	scopeIndex int
+
+	node ast.Node // the innermost node being compiled
+	err  error    // the first operand which doesn't fit in its instruction
+}
+
+func New() *Compiler {
+	return NewWithBuiltins(object.NewRegistry())
+}
+
+// NewWithBuiltins creates a compiler which resolves builtins using the given registry,
+// the same registry has to be passed to the vm running the bytecode.
+func NewWithBuiltins(builtins *object.Registry) *Compiler {
+	symbolTable := NewSymbolTable()
+	for i, name := range builtins.Names() {
+		symbolTable.DefineBuiltin(i, name)
+	}
+
+	return NewWithState(symbolTable, []object.Object{})
+}
+
+// NewWithState creates a compiler which continues with the symbols and constants
+// of a previous compilation, e.g. the previous line typed into the REPL.
+func NewWithState(s *SymbolTable, constants []object.Object) *Compiler {
+	return &Compiler{
+		constants:   constants,
+		symbolTable: s,
+		scopes:      []CompilationScope{newCompilationScope()},
+		scopeIndex:  0,
+	}
+}
+
+func (c *Compiler) SymbolTable() *SymbolTable {
+	return c.symbolTable
+}
+
+func (c *Compiler) Bytecode() *Bytecode {
+	return &Bytecode{
+		Instructions: c.currentInstructions(),
+		Constants:    c.constants,
+		GlobalNames:  c.symbolTable.Global().Names(),
+		Positions:    c.scopes[c.scopeIndex].positions,
+	}
+}
+
+func (c *Compiler) Compile(n ast.Node) error {
+	outer := c.node
+	c.node = n
+	defer func() { c.node = outer }()
+
+	err := c.compileNode(n)
+	if err == nil {
+		err = c.err
+	}
+	c.err = nil
+
+	return err
+}
+
+func (c *Compiler) compileNode(n ast.Node) error {
+	switch node := n.(type) {
+	case *ast.Program:
+		return c.compileStatements(node.Statements)
+
+	case *ast.BlockStatement:
+		return c.compileStatements(node.Statements)
+
+	case *ast.ExpressionStatement:
+		if err := c.Compile(node.Expression); err != nil {
+			return err
+		}
+		c.emit(code.OpPop)
+
+	case *ast.LetStatement:
+		// a function bound with 'let' may assign to its own binding, so a local binding
+		// has to exist before the function is compiled, globals are defined on assignment
+		var symbol Symbol
+		if fn, ok := node.Value.(*ast.FunctionLiteral); ok && fn.Name != "" && c.symbolTable.Outer != nil {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if symbol.Name == "" {
+			symbol = c.symbolTable.Define(node.Name.Value)
+		}
+		c.storeSymbol(symbol)
+
+	case *ast.ReturnStatement:
+		if err := c.Compile(node.ReturnValue); err != nil {
+			return err
+		}
+		c.emit(code.OpReturnValue)
+
+	case *ast.WhileStatement:
+		return c.compileWhileStatement(node)
+
+	case *ast.ForStatement:
+		return c.compileForStatement(node)
+
+	case *ast.BreakStatement:
+		l := c.currentLoop()
+		l.breaks = append(l.breaks, c.emit(code.OpJump, 9999))
+
+	case *ast.ContinueStatement:
+		c.emit(code.OpJump, c.currentLoop().start)
+
+	case *ast.Identifier:
+		symbol, ok := c.symbolTable.Resolve(node.Value)
+		if !ok {
+			// the name may still be bound later by a global 'let', the vm reports
+			// an error if it's not bound at the time the identifier is evaluated
+			symbol = c.symbolTable.Global().Define(node.Value)
+		}
+		c.loadSymbol(symbol, node.Pos())
+
+	case *ast.IntegerLiteral:
+		c.emit(code.OpConstant, c.addConstant(object.NewInteger(node.Value)))
+
+	case *ast.FloatLiteral:
+		c.emit(code.OpConstant, c.addConstant(object.NewFloat(node.Value)))
+
+	case *ast.StringLiteral:
+		c.emit(code.OpConstant, c.addConstant(object.NewString(node.Value)))
+
+	case *ast.BooleanLiteral:
+		if node.Value {
+			c.emit(code.OpTrue)
+		} else {
+			c.emit(code.OpFalse)
+		}
+
+	case *ast.PrefixExpression:
+		return c.compilePrefixExpression(node)
+
+	case *ast.InfixExpression:
+		return c.compileInfixExpression(node)
+
+	case *ast.IfExpression:
+		return c.compileIfExpression(node)
+
+	case *ast.FunctionLiteral:
+		return c.compileFunctionLiteral(node)
+
+	case *ast.CallExpression:
+		return c.compileCallExpression(node)
+
+	case *ast.ArrayLiteral:
+		if err := c.compileExpressions(node.Elements); err != nil {
+			return err
+		}
+		c.emit(code.OpArray, len(node.Elements))
+
+	case *ast.HashLiteral:
+		return c.compileHashLiteral(node)
+
+	case *ast.AssignExpression:
+		return c.compileAssignExpression(node)
+
+	case *ast.IndexExpression:
+		if err := c.Compile(node.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Index); err != nil {
+			return err
+		}
+		c.emitAt(node.Token.Pos, code.OpIndex)
+
+	default:
+		return fmt.Errorf("unsupported node type: %T", n)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileStatements(stmts []ast.Statement) error {
+	for _, s := range stmts {
+		if err := c.Compile(s); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileExpressions(exps []ast.Expression) error {
+	for _, e := range exps {
+		if err := c.Compile(e); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (c *Compiler) compilePrefixExpression(node *ast.PrefixExpression) error {
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	op, ok := prefixOpcodes[node.Operator]
+	if !ok {
+		return errorAt(node.Token.Pos, "unknown operator %s", node.Operator)
+	}
+
+	c.emitAt(node.Token.Pos, op)
+	return nil
+}
+
+func (c *Compiler) compileInfixExpression(node *ast.InfixExpression) error {
+	if err := c.Compile(node.Left); err != nil {
+		return err
+	}
+
+	if node.Operator == token.OperatorAnd || node.Operator == token.OperatorOr {
+		return c.compileLogicalExpression(node)
+	}
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	op, ok := infixOpcodes[node.Operator]
+	if !ok {
+		return errorAt(node.Token.Pos, "unknown operator %s", node.Operator)
+	}
+
+	c.emitAt(node.Token.Pos, op)
+	return nil
+}
+
+// compileLogicalExpression compiles the right operand of && or ||, which is evaluated only
+// if the left operand (already on the stack) doesn't decide the result.
+func (c *Compiler) compileLogicalExpression(node *ast.InfixExpression) error {
+	jump := code.OpJumpNotTruthyOrPop
+	if node.Operator == token.OperatorOr {
+		jump = code.OpJumpTruthyOrPop
+	}
+
+	jumpPos := c.emit(jump, 9999)
+
+	if err := c.Compile(node.Right); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileIfExpression(node *ast.IfExpression) error {
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	// emit an `OpJumpNotTruthy` with a bogus value, it's changed once the consequence is compiled
+	jumpNotTruthyPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.compileBlockValue(node.Consequence); err != nil {
+		return err
+	}
+
+	jumpPos := c.emit(code.OpJump, 9999)
+	c.changeOperand(jumpNotTruthyPos, len(c.currentInstructions()))
+
+	if node.Alternative == nil {
+		c.emit(code.OpNull)
+	} else if err := c.compileBlockValue(node.Alternative); err != nil {
+		return err
+	}
+
+	c.changeOperand(jumpPos, len(c.currentInstructions()))
+	return nil
+}
+
+func (c *Compiler) compileWhileStatement(node *ast.WhileStatement) error {
+	l := c.enterLoop()
+
+	if err := c.Compile(node.Condition); err != nil {
+		return err
+	}
+
+	exitPos := c.emit(code.OpJumpNotTruthy, 9999)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, l.start)
+	c.changeOperand(exitPos, len(c.currentInstructions()))
+
+	c.leaveLoop()
+	c.emitLoopValue()
+
+	return nil
+}
+
+// compileForStatement compiles the loop so that the iterator stays on the stack
+// while the loop is executed.
+func (c *Compiler) compileForStatement(node *ast.ForStatement) error {
+	if err := c.Compile(node.Iterable); err != nil {
+		return err
+	}
+
+	c.emitAt(node.Iterable.Pos(), code.OpIter)
+
+	l := c.enterLoop()
+
+	numValues := 1
+	if node.Key != nil {
+		numValues = 2
+	}
+
+	nextPos := c.emit(code.OpIterNext, 9999, numValues)
+
+	c.storeSymbol(c.symbolTable.Define(node.Value.Value))
+	if node.Key != nil {
+		c.storeSymbol(c.symbolTable.Define(node.Key.Value))
+	}
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	c.emit(code.OpJump, l.start)
+
+	end := len(c.currentInstructions())
+	c.replaceInstruction(nextPos, c.makeInstruction(code.OpIterNext, end, numValues))
+
+	c.leaveLoop()
+	c.emit(code.OpPop)
+	c.emitLoopValue()
+
+	return nil
+}
+
+func (c *Compiler) enterLoop() *loop {
+	l := &loop{start: len(c.currentInstructions())}
+
+	scope := &c.scopes[c.scopeIndex]
+	scope.loops = append(scope.loops, l)
+
+	return l
+}
+
+// leaveLoop makes jumps emitted for 'break' statements jump to the current position.
+func (c *Compiler) leaveLoop() {
+	scope := &c.scopes[c.scopeIndex]
+	l := scope.loops[len(scope.loops)-1]
+	scope.loops = scope.loops[:len(scope.loops)-1]
+
+	for _, pos := range l.breaks {
+		c.changeOperand(pos, len(c.currentInstructions()))
+	}
+}
+
+func (c *Compiler) currentLoop() *loop {
+	loops := c.scopes[c.scopeIndex].loops
+	return loops[len(loops)-1]
+}
+
+// emitLoopValue emits the value of a loop statement, which is null, like an expression statement does.
+func (c *Compiler) emitLoopValue() {
+	c.emit(code.OpNull)
+	c.emit(code.OpPop)
+}
+
+// compileAssignExpression compiles the assignment so that it leaves the assigned value on the stack.
+func (c *Compiler) compileAssignExpression(node *ast.AssignExpression) error {
+	var binaryOp code.Opcode
+	if operator := node.BinaryOperator(); operator != "" {
+		op, ok := infixOpcodes[operator]
+		if !ok {
+			return errorAt(node.Token.Pos, "unknown operator: %s", node.Operator)
+		}
+		binaryOp = op
+	}
+
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		symbol := c.resolveAssignable(target.Value)
+
+		if binaryOp != 0 {
+			c.loadSymbol(symbol, target.Pos())
+		}
+
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		if binaryOp != 0 {
+			c.emitAt(node.Token.Pos, binaryOp)
+		}
+
+		if err := c.assignSymbol(symbol, target.Pos()); err != nil {
+			return err
+		}
+
+		c.loadSymbol(symbol, target.Pos())
+
+	case *ast.IndexExpression:
+		if err := c.Compile(target.Left); err != nil {
+			return err
+		}
+		if err := c.Compile(target.Index); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Value); err != nil {
+			return err
+		}
+
+		c.emitAt(node.Token.Pos, code.OpSetIndex, int(binaryOp))
+
+	default:
+		return errorAt(node.Target.Pos(), "cannot assign to %s", node.Target.String())
+	}
+
+	return nil
+}
+
+// resolveAssignable resolves the variable to assign to. Once assigned, the name of the
+// function being compiled refers to the binding created by 'let' in the enclosing scope.
+// Names which are not bound are global, assigning to them fails at run time.
+func (c *Compiler) resolveAssignable(name string) Symbol {
+	symbol, ok := c.symbolTable.Resolve(name)
+	if ok && symbol.Scope == FunctionScope {
+		c.symbolTable.unbindFunctionName(name)
+		symbol, ok = c.symbolTable.Resolve(name)
+	}
+	if !ok {
+		symbol = c.symbolTable.Global().Define(name)
+	}
+
+	return symbol
+}
+
+// compileBlockValue compiles the block so that it leaves its value on the stack.
+func (c *Compiler) compileBlockValue(block *ast.BlockStatement) error {
+	if err := c.Compile(block); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.removeLastPop()
+	} else {
+		c.emit(code.OpNull)
+	}
+
+	return nil
+}
+
+func (c *Compiler) compileFunctionLiteral(node *ast.FunctionLiteral) error {
+	c.enterScope()
+
+	if node.Name != "" {
+		c.symbolTable.DefineFunctionName(node.Name)
+	}
+
+	for _, p := range node.Parameters {
+		c.symbolTable.Define(p.Value)
+	}
+
+	c.declareLocals(node.Body)
+
+	if err := c.Compile(node.Body); err != nil {
+		return err
+	}
+
+	if c.lastInstructionIs(code.OpPop) {
+		c.replaceLastPopWithReturn()
+	}
+	if !c.lastInstructionIs(code.OpReturnValue) {
+		c.emit(code.OpReturn)
+	}
+
+	freeSymbols := c.symbolTable.FreeSymbols
+	numLocals := c.symbolTable.NumDefinitions()
+	localNames := c.symbolTable.Names()
+	positions := c.scopes[c.scopeIndex].positions
+	instructions := c.leaveScope()
+
+	freeNames := make([]string, len(freeSymbols))
+	for i, s := range freeSymbols {
+		c.captureSymbol(s, node.Pos())
+		freeNames[i] = s.Name
+	}
+
+	compiledFn := &object.CompiledFunction{
+		Name:          node.Name,
+		Positions:     positions,
+		Instructions:  instructions,
+		NumLocals:     numLocals,
+		NumParameters: len(node.Parameters),
+		LocalNames:    localNames,
+		FreeNames:     freeNames,
+	}
+
+	c.emit(code.OpClosure, c.addConstant(compiledFn), len(freeSymbols))
+	return nil
+}
+
+// declareLocals declares names bound with 'let' in the function body, names bound in nested functions
+// belong to them.
+func (c *Compiler) declareLocals(body *ast.BlockStatement) {
+	ast.Inspect(body, func(n ast.Node) bool {
+		switch node := n.(type) {
+		case *ast.FunctionLiteral:
+			return false
+		case *ast.LetStatement:
+			c.symbolTable.Declare(node.Name.Value)
+		}
+
+		return true
+	})
+}
+
+func (c *Compiler) compileCallExpression(node *ast.CallExpression) error {
+	// quote and unquote return code of the program, which only the evaluator has, macros using them
+	// are expanded before the compilation
+	if ident, ok := node.Function.(*ast.Identifier); ok && (ident.Value == "quote" || ident.Value == "unquote") {
+		return errorAt(node.Pos(), "%s is not supported by the vm engine", ident.Value)
+	}
+
+	if err := c.Compile(node.Function); err != nil {
+		return err
+	}
+
+	if err := c.compileExpressions(node.Arguments); err != nil {
+		return err
+	}
+
+	c.emitAt(node.Pos(), code.OpCall, len(node.Arguments))
+	return nil
+}
+
+func (c *Compiler) compileHashLiteral(node *ast.HashLiteral) error {
+	keys := []ast.Expression{}
+	for k := range node.Pairs {
+		keys = append(keys, k)
+	}
+
+	// map iteration order is random, sorting keeps the bytecode deterministic
+	sort.Slice(keys, func(i, j int) bool {
+		return keys[i].String() < keys[j].String()
+	})
+
+	for _, k := range keys {
+		if err := c.Compile(k); err != nil {
+			return err
+		}
+		if err := c.Compile(node.Pairs[k]); err != nil {
+			return err
+		}
+	}
+
+	c.emitAt(node.Pos(), code.OpHash, len(node.Pairs)*2)
+	return nil
+}
+
+func (c *Compiler) loadSymbol(s Symbol, pos token.Position) {
+	switch s.Scope {
+	case GlobalScope:
+		c.emitAt(pos, code.OpGetGlobal, s.Index)
+	case LocalScope:
+		c.emitAt(pos, code.OpGetLocal, s.Index)
+	case BuiltinScope:
+		c.emit(code.OpGetBuiltin, s.Index)
+	case FreeScope:
+		c.emitAt(pos, code.OpGetFree, s.Index)
+	case FunctionScope:
+		c.emit(code.OpCurrentClosure)
+	}
+}
+
+// captureSymbol loads the variable for a closure, locals and free variables are loaded
+// as cells, so that the closure shares them with the enclosing function.
+func (c *Compiler) captureSymbol(s Symbol, pos token.Position) {
+	switch s.Scope {
+	case LocalScope:
+		c.emit(code.OpCaptureLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpCaptureFree, s.Index)
+	default:
+		c.loadSymbol(s, pos)
+	}
+}
+
+// assignSymbol stores the value on top of the stack in a variable which has to be bound already.
+func (c *Compiler) assignSymbol(s Symbol, pos token.Position) error {
+	switch s.Scope {
+	case GlobalScope:
+		c.emitAt(pos, code.OpAssignGlobal, s.Index)
+	case LocalScope:
+		c.emit(code.OpSetLocal, s.Index)
+	case FreeScope:
+		c.emit(code.OpSetFree, s.Index)
+	default:
+		return errorAt(pos, "cannot assign to builtin: %s", s.Name)
+	}
+
+	return nil
+}
+
+func (c *Compiler) storeSymbol(s Symbol) {
+	if s.Scope == GlobalScope {
+		c.emit(code.OpSetGlobal, s.Index)
+	} else {
+		c.emit(code.OpSetLocal, s.Index)
+	}
+}
+
+func (c *Compiler) addConstant(obj object.Object) int {
+	c.constants = append(c.constants, obj)
+	return len(c.constants) - 1
+}
+
+func (c *Compiler) emit(op code.Opcode, operands ...int) int {
+	ins := c.makeInstruction(op, operands...)
+	pos := c.addInstruction(ins)
+
+	c.setLastInstruction(op, pos)
+
+	return pos
+}
+
+// emitAt emits the instruction and remembers its source position, so that runtime errors can point to it.
+func (c *Compiler) emitAt(pos token.Position, op code.Opcode, operands ...int) int {
+	ins := c.emit(op, operands...)
+	c.scopes[c.scopeIndex].positions[ins] = pos
+
+	return ins
+}
+
+// makeInstruction makes the instruction, an operand which doesn't fit in it, e.g. the index of the 257th local,
+// fails the compilation of the current node.
+func (c *Compiler) makeInstruction(op code.Opcode, operands ...int) []byte {
+	if err := code.CheckOperands(op, operands...); err != nil && c.err == nil {
+		c.err = errorAt(c.node.Pos(), "%v", err)
+	}
+
+	return code.Make(op, operands...)
+}
+
+func (c *Compiler) addInstruction(ins []byte) int {
+	posNewInstruction := len(c.currentInstructions())
+	c.scopes[c.scopeIndex].instructions = append(c.currentInstructions(), ins...)
+
+	return posNewInstruction
+}
+
+func (c *Compiler) setLastInstruction(op code.Opcode, pos int) {
+	previous := c.scopes[c.scopeIndex].lastInstruction
+	last := EmittedInstruction{Opcode: op, Position: pos}
+
+	c.scopes[c.scopeIndex].previousInstruction = previous
+	c.scopes[c.scopeIndex].lastInstruction = last
+}
+
+func (c *Compiler) currentInstructions() code.Instructions {
+	return c.scopes[c.scopeIndex].instructions
+}
+
+func (c *Compiler) lastInstructionIs(op code.Opcode) bool {
+	if len(c.currentInstructions()) == 0 {
+		return false
+	}
+
+	return c.scopes[c.scopeIndex].lastInstruction.Opcode == op
+}
+
+func (c *Compiler) removeLastPop() {
+	last := c.scopes[c.scopeIndex].lastInstruction
+	previous := c.scopes[c.scopeIndex].previousInstruction
+
+	c.scopes[c.scopeIndex].instructions = c.currentInstructions()[:last.Position]
+	c.scopes[c.scopeIndex].lastInstruction = previous
+}
+
+func (c *Compiler) replaceLastPopWithReturn() {
+	lastPos := c.scopes[c.scopeIndex].lastInstruction.Position
+	c.replaceInstruction(lastPos, code.Make(code.OpReturnValue))
+
+	c.scopes[c.scopeIndex].lastInstruction.Opcode = code.OpReturnValue
+}
+
+func (c *Compiler) replaceInstruction(pos int, newInstruction []byte) {
+	ins := c.currentInstructions()
+
+	for i := 0; i < len(newInstruction); i++ {
+		ins[pos+i] = newInstruction[i]
+	}
+}
+
+func (c *Compiler) changeOperand(opPos int, operand int) {
+	op := code.Opcode(c.currentInstructions()[opPos])
+	newInstruction := c.makeInstruction(op, operand)
+
+	c.replaceInstruction(opPos, newInstruction)
+}
+
+func (c *Compiler) enterScope() {
+	c.scopes = append(c.scopes, newCompilationScope())
+	c.scopeIndex++
+
+	c.symbolTable = NewEnclosedSymbolTable(c.symbolTable)
+}
+
+func (c *Compiler) leaveScope() code.Instructions {
+	instructions := c.currentInstructions()
+
+	c.scopes = c.scopes[:len(c.scopes)-1]
+	c.scopeIndex--
+
+	c.symbolTable = c.symbolTable.Outer
+
+	return instructions
+}
@@ -0,0 +1,370 @@
+package compiler
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/code"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCompileExpressions(t *testing.T) {
+	testData := map[string]struct {
+		input        string
+		constants    []interface{}
+		instructions []code.Instructions
+	}{
+		"infix": {
+			"1 + 2",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpPop),
+			},
+		},
+		"lower than keeps operands order": {
+			"1 < 2",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpLowerThan),
+				code.Make(code.OpPop),
+			},
+		},
+		"prefix": {
+			"!true",
+			[]interface{}{},
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpBang),
+				code.Make(code.OpPop),
+			},
+		},
+		"if without else": {
+			"if (true) { 10 }; 3333;",
+			[]interface{}{10, 3333},
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJump, 11),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		"while with break": {
+			"while (true) { break; }",
+			[]interface{}{},
+			[]code.Instructions{
+				code.Make(code.OpTrue),
+				code.Make(code.OpJumpNotTruthy, 10),
+				code.Make(code.OpJump, 10),
+				code.Make(code.OpJump, 0),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		"for in with continue": {
+			"for (x in [1]) { continue; }",
+			[]interface{}{1},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpIter),
+				code.Make(code.OpIterNext, 20, 1),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpJump, 7),
+				code.Make(code.OpJump, 7),
+				code.Make(code.OpPop),
+				code.Make(code.OpNull),
+				code.Make(code.OpPop),
+			},
+		},
+		"globals": {
+			"let one = 1; one;",
+			[]interface{}{1},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		"builtins": {
+			"len([])",
+			[]interface{}{},
+			[]code.Instructions{
+				code.Make(code.OpGetBuiltin, 0),
+				code.Make(code.OpArray, 0),
+				code.Make(code.OpCall, 1),
+				code.Make(code.OpPop),
+			},
+		},
+		"compound assignment": {
+			"let x = 1; x += 2;",
+			[]interface{}{1, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpSetGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpAdd),
+				code.Make(code.OpAssignGlobal, 0),
+				code.Make(code.OpGetGlobal, 0),
+				code.Make(code.OpPop),
+			},
+		},
+		"index assignment": {
+			"[1][0] *= 2",
+			[]interface{}{1, 0, 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpArray, 1),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpSetIndex, int(code.OpMul)),
+				code.Make(code.OpPop),
+			},
+		},
+		"logical operators": {
+			"1 || 2 && 3",
+			[]interface{}{1, 2, 3},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpJumpTruthyOrPop, 15),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpJumpNotTruthyOrPop, 15),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpPop),
+			},
+		},
+		"hash keys are sorted": {
+			`{"b": 2, "a": 1}`,
+			[]interface{}{"a", 1, "b", 2},
+			[]code.Instructions{
+				code.Make(code.OpConstant, 0),
+				code.Make(code.OpConstant, 1),
+				code.Make(code.OpConstant, 2),
+				code.Make(code.OpConstant, 3),
+				code.Make(code.OpHash, 4),
+				code.Make(code.OpPop),
+			},
+		},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			bytecode := compile(t, data.input)
+
+			assertInstructions(t, data.instructions, bytecode.Instructions)
+			assertConstants(t, data.constants, bytecode.Constants)
+		})
+	}
+}
+
+func TestCompileFunctions(t *testing.T) {
+	input := `let newAdder = fn(a) { fn(b) { a + b } };`
+
+	bytecode := compile(t, input)
+
+	assertInstructions(t, []code.Instructions{
+		code.Make(code.OpClosure, 1, 0),
+		code.Make(code.OpSetGlobal, 0),
+	}, bytecode.Instructions)
+
+	inner := bytecode.Constants[0].(*object.CompiledFunction)
+	assertInstructions(t, []code.Instructions{
+		code.Make(code.OpGetFree, 0),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpAdd),
+		code.Make(code.OpReturnValue),
+	}, inner.Instructions)
+
+	outer := bytecode.Constants[1].(*object.CompiledFunction)
+	assertInstructions(t, []code.Instructions{
+		code.Make(code.OpCaptureLocal, 0),
+		code.Make(code.OpClosure, 0, 1),
+		code.Make(code.OpReturnValue),
+	}, outer.Instructions)
+	assert.Equal(t, 1, outer.NumParameters)
+	assert.Equal(t, []string{"a"}, outer.LocalNames)
+}
+
+func TestCompileRecursiveFunction(t *testing.T) {
+	input := `let wrapper = fn() { let countDown = fn(x) { countDown(x - 1); }; countDown(1); };`
+
+	bytecode := compile(t, input)
+
+	countDown := bytecode.Constants[1].(*object.CompiledFunction)
+	assertInstructions(t, []code.Instructions{
+		code.Make(code.OpCurrentClosure),
+		code.Make(code.OpGetLocal, 0),
+		code.Make(code.OpConstant, 0),
+		code.Make(code.OpSub),
+		code.Make(code.OpCall, 1),
+		code.Make(code.OpReturnValue),
+	}, countDown.Instructions)
+}
+
+func TestCompileUnboundIdentifier(t *testing.T) {
+	bytecode := compile(t, "let f = fn() { g }; let g = 1;")
+
+	assert.Equal(t, []string{"g", "f"}, bytecode.GlobalNames)
+}
+
+func TestCompileQuote(t *testing.T) {
+	for _, input := range []string{"quote(1)", "let f = fn(x) { unquote(x) };"} {
+		err := New().Compile(parse(t, input))
+		assert.Error(t, err, input)
+	}
+}
+
+func TestCompileErrors(t *testing.T) {
+	locals := "let f = fn() {\n"
+	for i := 0; i < 257; i++ {
+		locals += "let x" + string(rune('a'+i/26)) + string(rune('a'+i%26)) + " = 1;\n"
+	}
+	locals += "};"
+
+	testData := map[string]struct {
+		input string
+		err   string
+	}{
+		"assign to builtin": {"let a = 1;\nlen = a", "2:1: cannot assign to builtin: len"},
+		"quote":             {"let f = fn(x) {\n  unquote(x) };", "2:3: unquote is not supported by the vm engine"},
+		"too many locals":   {locals, "258:1: operand 1 of OpSetLocal is out of range: 256, the maximum is 255"},
+		"too many arguments": {
+			"len(" + strings.Repeat("1, ", 256) + "1)",
+			"1:1: operand 1 of OpCall is out of range: 257, the maximum is 255",
+		},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			err := New().Compile(parse(t, data.input))
+
+			compileErr, ok := err.(*Error)
+			if assert.True(t, ok, "expected *Error, got %T", err) {
+				assert.EqualError(t, compileErr, data.err)
+			}
+		})
+	}
+}
+
+func TestSymbolTableResolve(t *testing.T) {
+	global := NewSymbolTable()
+	global.Define("a")
+	global.DefineBuiltin(0, "len")
+
+	first := NewEnclosedSymbolTable(global)
+	first.Define("b")
+
+	second := NewEnclosedSymbolTable(first)
+	second.Define("c")
+
+	expected := map[string]Symbol{
+		"a":   {Name: "a", Scope: GlobalScope, Index: 0},
+		"len": {Name: "len", Scope: BuiltinScope, Index: 0},
+		"b":   {Name: "b", Scope: FreeScope, Index: 0},
+		"c":   {Name: "c", Scope: LocalScope, Index: 0},
+	}
+
+	for name, symbol := range expected {
+		result, ok := second.Resolve(name)
+		assert.True(t, ok, "name %s not resolvable", name)
+		assert.Equal(t, symbol, result)
+	}
+
+	assert.Equal(t, []Symbol{{Name: "b", Scope: LocalScope, Index: 0}}, second.FreeSymbols)
+
+	_, ok := second.Resolve("d")
+	assert.False(t, ok)
+}
+
+func TestSymbolTableDeclare(t *testing.T) {
+	outer := NewEnclosedSymbolTable(NewSymbolTable())
+	outer.Declare("a")
+
+	_, ok := outer.Resolve("a")
+	assert.False(t, ok)
+
+	inner := NewEnclosedSymbolTable(outer)
+	symbol, ok := inner.Resolve("a")
+	assert.True(t, ok)
+	assert.Equal(t, Symbol{Name: "a", Scope: FreeScope, Index: 0}, symbol)
+	assert.Equal(t, []Symbol{{Name: "a", Scope: LocalScope, Index: 0}}, inner.FreeSymbols)
+
+	assert.Equal(t, Symbol{Name: "a", Scope: LocalScope, Index: 0}, outer.Define("a"))
+}
+
+func TestSymbolTableRedefinition(t *testing.T) {
+	global := NewSymbolTable()
+
+	first := global.Define("a")
+	second := global.Define("a")
+
+	assert.Equal(t, first, second)
+	assert.Equal(t, 1, global.NumDefinitions())
+}
+
+func compile(t *testing.T, input string) *Bytecode {
+	program := parse(t, input)
+
+	compiler := New()
+	if err := compiler.Compile(program); err != nil {
+		t.Fatalf("compiler error: %v", err)
+	}
+
+	return compiler.Bytecode()
+}
+
+func parse(t *testing.T, input string) *ast.Program {
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("cannot parse input, error: %v", err)
+	}
+
+	return program
+}
+
+func assertInstructions(t *testing.T, expected []code.Instructions, actual code.Instructions) {
+	concatted := code.Instructions{}
+	for _, ins := range expected {
+		concatted = append(concatted, ins...)
+	}
+
+	assert.Equal(t, concatted.String(), actual.String())
+}
+
+func assertConstants(t *testing.T, expected []interface{}, actual []object.Object) {
+	assert.Len(t, actual, len(expected))
+
+	for i, constant := range expected {
+		switch constant := constant.(type) {
+		case int:
+			integer, ok := actual[i].(*object.Integer)
+			if assert.True(t, ok, "constant %d is not Integer, got=%T", i, actual[i]) {
+				assert.Equal(t, int64(constant), integer.Value)
+			}
+		case string:
+			str, ok := actual[i].(*object.String)
+			if assert.True(t, ok, "constant %d is not String, got=%T", i, actual[i]) {
+				assert.Equal(t, constant, str.Value)
+			}
+		}
+	}
+}
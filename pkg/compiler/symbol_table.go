@@ -0,0 +1,148 @@
+package compiler
+
+type SymbolScope string
+
+const (
+	GlobalScope   SymbolScope = "GLOBAL"
+	LocalScope    SymbolScope = "LOCAL"
+	BuiltinScope  SymbolScope = "BUILTIN"
+	FreeScope     SymbolScope = "FREE"
+	FunctionScope SymbolScope = "FUNCTION"
+)
+
+type Symbol struct {
+	Name  string
+	Scope SymbolScope
+	Index int
+}
+
+type SymbolTable struct {
+	Outer *SymbolTable
+
+	FreeSymbols []Symbol
+
+	store          map[string]Symbol
+	numDefinitions int
+	declared       map[string]bool // names bound with 'let' in the function, they're defined when they're bound
+}
+
+func NewSymbolTable() *SymbolTable {
+	return &SymbolTable{
+		store:       make(map[string]Symbol),
+		FreeSymbols: []Symbol{},
+		declared:    make(map[string]bool),
+	}
+}
+
+func NewEnclosedSymbolTable(outer *SymbolTable) *SymbolTable {
+	s := NewSymbolTable()
+	s.Outer = outer
+	return s
+}
+
+// Define binds the name in the current scope. Defining a name which is already bound
+// in the same scope reuses its slot, just like 'let' overwrites a binding in an environment.
+func (s *SymbolTable) Define(name string) Symbol {
+	scope := LocalScope
+	if s.Outer == nil {
+		scope = GlobalScope
+	}
+
+	if symbol, ok := s.store[name]; ok && symbol.Scope == scope {
+		return symbol
+	}
+
+	symbol := Symbol{Name: name, Index: s.numDefinitions, Scope: scope}
+	s.store[name] = symbol
+	s.numDefinitions++
+
+	return symbol
+}
+
+// Declare makes the name resolvable by enclosed functions before it's defined, so that closures
+// may refer to locals bound later in the enclosing function.
+func (s *SymbolTable) Declare(name string) {
+	s.declared[name] = true
+}
+
+func (s *SymbolTable) DefineBuiltin(index int, name string) Symbol {
+	symbol := Symbol{Name: name, Index: index, Scope: BuiltinScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+func (s *SymbolTable) DefineFunctionName(name string) Symbol {
+	symbol := Symbol{Name: name, Index: 0, Scope: FunctionScope}
+	s.store[name] = symbol
+	return symbol
+}
+
+// unbindFunctionName makes the name resolve to the binding of the enclosing scope.
+func (s *SymbolTable) unbindFunctionName(name string) {
+	if symbol, ok := s.store[name]; ok && symbol.Scope == FunctionScope {
+		delete(s.store, name)
+	}
+}
+
+func (s *SymbolTable) defineFree(original Symbol) Symbol {
+	s.FreeSymbols = append(s.FreeSymbols, original)
+
+	symbol := Symbol{Name: original.Name, Index: len(s.FreeSymbols) - 1, Scope: FreeScope}
+	s.store[original.Name] = symbol
+
+	return symbol
+}
+
+func (s *SymbolTable) Resolve(name string) (Symbol, bool) {
+	obj, ok := s.store[name]
+	if ok || s.Outer == nil {
+		return obj, ok
+	}
+
+	obj, ok = s.Outer.resolveEnclosed(name)
+	if !ok {
+		return obj, ok
+	}
+
+	if obj.Scope == GlobalScope || obj.Scope == BuiltinScope {
+		return obj, ok
+	}
+
+	return s.defineFree(obj), true
+}
+
+// resolveEnclosed resolves the name used by an enclosed function. A declared name is defined
+// on its first use, the enclosed function is called once the name is bound.
+func (s *SymbolTable) resolveEnclosed(name string) (Symbol, bool) {
+	if _, ok := s.store[name]; !ok && s.declared[name] {
+		s.Define(name)
+	}
+
+	return s.Resolve(name)
+}
+
+// Global returns the outermost symbol table.
+func (s *SymbolTable) Global() *SymbolTable {
+	if s.Outer == nil {
+		return s
+	}
+
+	return s.Outer.Global()
+}
+
+// Names returns names of the symbols defined in this table ordered by their index.
+func (s *SymbolTable) Names() []string {
+	names := make([]string, s.numDefinitions)
+
+	for name, symbol := range s.store {
+		if symbol.Scope == GlobalScope || symbol.Scope == LocalScope {
+			names[symbol.Index] = name
+		}
+	}
+
+	return names
+}
+
+func (s *SymbolTable) NumDefinitions() int {
+	return s.numDefinitions
+}
@@ -0,0 +1,587 @@
+// Package dap implements the Debug Adapter Protocol for Monkey programs run by the evaluator,
+// see package debugger.
+package dap
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"sync"
+
+	"github.com/adrian83/monkey/pkg/debugger"
+	"github.com/adrian83/monkey/pkg/framing"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/monkey"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+)
+
+// threadID identifies the only thread of Monkey programs.
+const threadID = 1
+
+var errNotStopped = errors.New("the program is not stopped")
+
+// Server is a Debug Adapter Protocol server, it debugs a single program launched by the client.
+type Server struct {
+	out *framing.Writer
+
+	seqMu sync.Mutex
+	seq   int
+
+	debugger *debugger.Debugger
+	resume   chan debugger.Action
+	after    func() // run after the response to the current request is sent
+
+	// the launched program
+	path        string
+	src         string
+	interpreter *monkey.Interpreter
+	stopOnEntry bool
+	configured  bool
+	done        chan struct{} // closed when the program ends, nil if it hasn't started
+
+	mu         sync.Mutex // guards the state of the stopped program
+	stop       *debugger.Stop
+	references []func() []Variable // variables of scopes and values, the reference is the index plus one
+}
+
+func NewServer() *Server {
+	s := &Server{resume: make(chan debugger.Action)}
+	s.debugger = debugger.New(s.stopped)
+
+	return s
+}
+
+type handler func(s *Server, args json.RawMessage) (interface{}, error)
+
+var handlers = map[string]handler{
+	"initialize":        (*Server).initialize,
+	"launch":            (*Server).launch,
+	"setBreakpoints":    (*Server).setBreakpoints,
+	"configurationDone": (*Server).configurationDone,
+	"threads":           (*Server).threads,
+	"stackTrace":        (*Server).stackTrace,
+	"scopes":            (*Server).scopes,
+	"variables":         (*Server).variables,
+	"evaluate":          (*Server).evaluate,
+	"continue":          (*Server).continueRequest,
+	"next":              (*Server).next,
+	"stepIn":            (*Server).stepIn,
+	"stepOut":           (*Server).stepOut,
+	"pause":             (*Server).pause,
+	"terminate":         (*Server).terminateRequest,
+	"disconnect":        (*Server).disconnect,
+}
+
+// Serve reads requests from the input and writes responses and events to the output until
+// the client disconnects or the input ends, the program is terminated then.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	r := framing.NewReader(in)
+	s.out = framing.NewWriter(out)
+	defer s.terminate()
+
+	for {
+		data, err := r.Read()
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+
+		var req message
+		if err := json.Unmarshal(data, &req); err != nil || req.Type != "request" {
+			// there is no request to respond to
+			continue
+		}
+
+		s.after = nil
+
+		handle, ok := handlers[req.Command]
+		if !ok {
+			err = fmt.Errorf("unsupported request: %s", req.Command)
+		}
+
+		var body interface{}
+		if ok {
+			body, err = handle(s, req.Arguments)
+		}
+
+		if writeErr := s.respond(&req, body, err); writeErr != nil {
+			return writeErr
+		}
+
+		if err == nil && s.after != nil {
+			s.after()
+		}
+
+		if req.Command == "disconnect" {
+			return nil
+		}
+	}
+}
+
+func (s *Server) send(msg *message) error {
+	s.seqMu.Lock()
+	s.seq++
+	msg.Seq = s.seq
+	s.seqMu.Unlock()
+
+	data, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return s.out.Write(data)
+}
+
+func (s *Server) respond(req *message, body interface{}, err error) error {
+	success := err == nil
+	msg := &message{Type: "response", Command: req.Command, RequestSeq: req.Seq, Success: &success, Body: body}
+	if err != nil {
+		msg.Message = err.Error()
+	}
+
+	return s.send(msg)
+}
+
+// event sends the event, events are sent also by the program, errors of the output are reported
+// by the responses.
+func (s *Server) event(name string, body interface{}) {
+	s.send(&message{Type: "event", Event: name, Body: body})
+}
+
+func decode(args json.RawMessage, v interface{}) error {
+	if len(args) == 0 {
+		return nil
+	}
+
+	if err := json.Unmarshal(args, v); err != nil {
+		return fmt.Errorf("invalid arguments: %v", err)
+	}
+
+	return nil
+}
+
+func (s *Server) initialize(json.RawMessage) (interface{}, error) {
+	s.after = func() { s.event("initialized", nil) }
+
+	return Capabilities{
+		SupportsConfigurationDoneRequest: true,
+		SupportsConditionalBreakpoints:   true,
+		SupportsEvaluateForHovers:        true,
+		SupportsTerminateRequest:         true,
+	}, nil
+}
+
+// launch prepares the program, it starts when the configuration is done.
+func (s *Server) launch(args json.RawMessage) (interface{}, error) {
+	var launch LaunchArguments
+	if err := decode(args, &launch); err != nil {
+		return nil, err
+	}
+
+	if s.interpreter != nil {
+		return nil, errors.New("the program is already launched")
+	}
+
+	path, err := filepath.Abs(launch.Program)
+	if err != nil {
+		return nil, err
+	}
+
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	if _, err := parser.New(lexer.NewFile(path, string(src))).ParseProgram(); err != nil {
+		var out bytes.Buffer
+		parser.Render(&out, string(src), err)
+		return nil, errors.New(out.String())
+	}
+
+	interpreter, err := s.newInterpreter(launch.Args)
+	if err != nil {
+		return nil, err
+	}
+
+	s.path, s.src, s.interpreter, s.stopOnEntry = path, string(src), interpreter, launch.StopOnEntry
+	s.after = s.start
+
+	return nil, nil
+}
+
+// newInterpreter creates the interpreter which sends the output of the program as events.
+func (s *Server) newInterpreter(args []string) (*monkey.Interpreter, error) {
+	interpreter, err := monkey.New(monkey.WithHook(s.debugger.Hook))
+	if err != nil {
+		return nil, err
+	}
+
+	if args == nil {
+		args = []string{}
+	}
+	if err := interpreter.Define("args", args); err != nil {
+		return nil, err
+	}
+
+	puts := &object.Builtin{Doc: object.GetBuiltinByName("puts").Doc, Fn: func(args ...object.Object) object.Object {
+		for _, arg := range args {
+			s.event("output", OutputEvent{Category: "stdout", Output: arg.Inspect() + "\n"})
+		}
+
+		return object.NullValue
+	}}
+
+	if err := interpreter.Register("puts", puts); err != nil {
+		return nil, err
+	}
+
+	s.debugger.SetBuiltins(interpreter.Registry())
+
+	return interpreter, nil
+}
+
+func (s *Server) configurationDone(json.RawMessage) (interface{}, error) {
+	s.configured = true
+	s.after = s.start
+
+	return nil, nil
+}
+
+// start runs the program once it's launched and configured.
+func (s *Server) start() {
+	if s.interpreter == nil || !s.configured || s.done != nil {
+		return
+	}
+
+	action := debugger.Continue
+	if s.stopOnEntry {
+		action = debugger.StepIn
+	}
+	s.debugger.Start(action)
+
+	s.done = make(chan struct{})
+	go s.run()
+}
+
+func (s *Server) run() {
+	defer close(s.done)
+
+	exitCode := 0
+
+	_, err := s.interpreter.Eval(context.Background(), s.path, s.src)
+	if err != nil {
+		exitCode = 1
+		if runtimeErr, ok := err.(*monkey.RuntimeError); !ok || runtimeErr.Message != debugger.ErrTerminated.Error() {
+			s.event("output", OutputEvent{Category: "stderr", Output: describeError(err)})
+		}
+	}
+
+	s.event("exited", ExitedEvent{ExitCode: exitCode})
+	s.event("terminated", nil)
+}
+
+func describeError(err error) string {
+	description := err.Error() + "\n"
+	if runtimeErr, ok := err.(*monkey.RuntimeError); ok && len(runtimeErr.Trace) > 0 {
+		description += runtimeErr.StackTrace()
+	}
+
+	return description
+}
+
+// stopped is called by the program when it stops, it waits until the client tells how to continue.
+func (s *Server) stopped(stop *debugger.Stop) debugger.Action {
+	s.mu.Lock()
+	s.stop, s.references = stop, nil
+	s.mu.Unlock()
+
+	event := StoppedEvent{Reason: string(stop.Reason), ThreadID: threadID, AllThreadsStopped: true}
+	if stop.Breakpoint != nil {
+		event.HitBreakpointIDs = []int{stop.Breakpoint.ID}
+	}
+	s.event("stopped", event)
+
+	return <-s.resume
+}
+
+// setBreakpoints replaces the breakpoints of the source.
+func (s *Server) setBreakpoints(args json.RawMessage) (interface{}, error) {
+	var params SetBreakpointsArguments
+	if err := decode(args, &params); err != nil {
+		return nil, err
+	}
+
+	path, err := filepath.Abs(params.Source.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	s.debugger.ClearBreakpoints(path)
+
+	breakpoints := []Breakpoint{}
+	for _, requested := range params.Breakpoints {
+		breakpoint := Breakpoint{Source: params.Source, Line: requested.Line}
+
+		bp, err := s.debugger.SetBreakpoint(path, requested.Line, requested.Condition)
+		if err != nil {
+			breakpoint.Message = err.Error()
+		} else {
+			breakpoint.ID, breakpoint.Verified = bp.ID, true
+		}
+
+		breakpoints = append(breakpoints, breakpoint)
+	}
+
+	return SetBreakpointsResponse{Breakpoints: breakpoints}, nil
+}
+
+func (s *Server) threads(json.RawMessage) (interface{}, error) {
+	return ThreadsResponse{Threads: []Thread{{ID: threadID, Name: "main"}}}, nil
+}
+
+// stackTrace returns the frames of the stopped program, their ids are their indexes plus one.
+func (s *Server) stackTrace(json.RawMessage) (interface{}, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop == nil {
+		return nil, errNotStopped
+	}
+
+	frames := []StackFrame{}
+	for i, frame := range s.stop.Frames {
+		frames = append(frames, StackFrame{
+			ID:     i + 1,
+			Name:   frame.Name(),
+			Source: source(frame.Pos.Filename),
+			Line:   frame.Pos.Line,
+			Column: frame.Pos.Column,
+		})
+	}
+
+	return StackTraceResponse{StackFrames: frames, TotalFrames: len(frames)}, nil
+}
+
+func source(path string) Source {
+	return Source{Name: filepath.Base(path), Path: path}
+}
+
+// frame returns the frame with the id, the innermost one if the id is 0. It has to be called
+// with the mutex locked.
+func (s *Server) frame(id int) (debugger.Frame, error) {
+	if s.stop == nil {
+		return debugger.Frame{}, errNotStopped
+	}
+
+	if id == 0 {
+		id = 1
+	}
+	if id < 1 || id > len(s.stop.Frames) {
+		return debugger.Frame{}, fmt.Errorf("no frame %d", id)
+	}
+
+	return s.stop.Frames[id-1], nil
+}
+
+// scopes returns the local bindings of the function and the global bindings, the outermost
+// frame has only the latter.
+func (s *Server) scopes(args json.RawMessage) (interface{}, error) {
+	var params FrameArguments
+	if err := decode(args, &params); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frame, err := s.frame(params.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	global := s.stop.Frames[len(s.stop.Frames)-1]
+
+	scopes := []Scope{}
+	if frame.Env != global.Env {
+		scopes = append(scopes, Scope{Name: "Locals", VariablesReference: s.reference(s.bindings(frame))})
+	}
+	scopes = append(scopes, Scope{Name: "Globals", VariablesReference: s.reference(s.bindings(global))})
+
+	return ScopesResponse{Scopes: scopes}, nil
+}
+
+// reference registers the variables, they are computed when the client asks for them.
+// It has to be called with the mutex locked.
+func (s *Server) reference(variables func() []Variable) int {
+	s.references = append(s.references, variables)
+	return len(s.references)
+}
+
+func (s *Server) bindings(frame debugger.Frame) func() []Variable {
+	return func() []Variable {
+		variables := []Variable{}
+		for _, local := range frame.Locals() {
+			variables = append(variables, s.variable(local.Name, local.Value))
+		}
+
+		return variables
+	}
+}
+
+// variable describes the value, arrays and hashes can be expanded to their elements.
+// It has to be called with the mutex locked.
+func (s *Server) variable(name string, value object.Object) Variable {
+	v := Variable{Name: name, Value: value.Inspect(), Type: string(value.Type())}
+
+	switch value := value.(type) {
+	case *object.Array:
+		if len(value.Elements) > 0 {
+			v.VariablesReference = s.reference(func() []Variable {
+				elements := []Variable{}
+				for i, element := range value.Elements {
+					elements = append(elements, s.variable("["+strconv.Itoa(i)+"]", element))
+				}
+				return elements
+			})
+		}
+	case *object.Hash:
+		if len(value.Pairs) > 0 {
+			v.VariablesReference = s.reference(func() []Variable {
+				pairs := []Variable{}
+				for _, pair := range value.Pairs {
+					pairs = append(pairs, s.variable(pair.Key.Inspect(), pair.Value))
+				}
+				sort.Slice(pairs, func(i, j int) bool { return pairs[i].Name < pairs[j].Name })
+				return pairs
+			})
+		}
+	}
+
+	return v
+}
+
+func (s *Server) variables(args json.RawMessage) (interface{}, error) {
+	var params VariablesArguments
+	if err := decode(args, &params); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop == nil {
+		return nil, errNotStopped
+	}
+	if params.VariablesReference < 1 || params.VariablesReference > len(s.references) {
+		return nil, fmt.Errorf("no variables %d", params.VariablesReference)
+	}
+
+	return VariablesResponse{Variables: s.references[params.VariablesReference-1]()}, nil
+}
+
+// evaluate evaluates the expression in the frame, errors of the evaluation are errors of the request.
+func (s *Server) evaluate(args json.RawMessage) (interface{}, error) {
+	var params EvaluateArguments
+	if err := decode(args, &params); err != nil {
+		return nil, err
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	frame, err := s.frame(params.FrameID)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := s.debugger.Evaluate(params.Expression, frame)
+	if err != nil {
+		return nil, err
+	}
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, errors.New(errObj.Message)
+	}
+
+	v := s.variable("", result)
+	return EvaluateResponse{Result: v.Value, Type: v.Type, VariablesReference: v.VariablesReference}, nil
+}
+
+// continueWith makes the stopped program continue after the response is sent.
+func (s *Server) continueWith(action debugger.Action) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.stop == nil {
+		return errNotStopped
+	}
+	s.stop, s.references = nil, nil
+
+	s.after = func() { s.resume <- action }
+	return nil
+}
+
+func (s *Server) continueRequest(json.RawMessage) (interface{}, error) {
+	if err := s.continueWith(debugger.Continue); err != nil {
+		return nil, err
+	}
+
+	return ContinueResponse{AllThreadsContinued: true}, nil
+}
+
+func (s *Server) next(json.RawMessage) (interface{}, error) {
+	return nil, s.continueWith(debugger.StepOver)
+}
+
+func (s *Server) stepIn(json.RawMessage) (interface{}, error) {
+	return nil, s.continueWith(debugger.StepIn)
+}
+
+func (s *Server) stepOut(json.RawMessage) (interface{}, error) {
+	return nil, s.continueWith(debugger.StepOut)
+}
+
+func (s *Server) pause(json.RawMessage) (interface{}, error) {
+	s.debugger.Pause()
+	return nil, nil
+}
+
+func (s *Server) terminateRequest(json.RawMessage) (interface{}, error) {
+	s.after = s.terminate
+	return nil, nil
+}
+
+func (s *Server) disconnect(json.RawMessage) (interface{}, error) {
+	s.terminate()
+	return nil, nil
+}
+
+// terminate stops the program and waits until it ends.
+func (s *Server) terminate() {
+	if s.done == nil {
+		return
+	}
+
+	s.debugger.Terminate()
+
+	s.mu.Lock()
+	s.stop, s.references = nil, nil
+	s.mu.Unlock()
+
+	for {
+		select {
+		case <-s.done:
+			return
+		case s.resume <- debugger.Terminate:
+		}
+	}
+}
@@ -0,0 +1,407 @@
+package dap
+
+import (
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/adrian83/monkey/pkg/framing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testProgram = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let data = {"list": [x, 4]};
+puts(add(x, 3));
+`
+
+// incoming is a response or an event received by the client.
+type incoming struct {
+	Seq        int             `json:"seq"`
+	Type       string          `json:"type"`
+	Command    string          `json:"command"`
+	RequestSeq int             `json:"request_seq"`
+	Success    bool            `json:"success"`
+	Message    string          `json:"message"`
+	Body       json.RawMessage `json:"body"`
+	Event      string          `json:"event"`
+}
+
+// client talks to a server running in the same process, like an editor would.
+type client struct {
+	t         *testing.T
+	out       *framing.Writer
+	seq       int
+	responses chan *incoming
+	events    chan *incoming
+	output    strings.Builder
+	close     func() error
+	done      chan error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{
+		t:         t,
+		out:       framing.NewWriter(clientOut),
+		responses: make(chan *incoming, 10),
+		events:    make(chan *incoming, 100),
+		close:     clientOut.Close,
+		done:      make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer().Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	go func() {
+		r := framing.NewReader(clientIn)
+		for {
+			data, err := r.Read()
+			if err != nil {
+				close(c.events)
+				return
+			}
+
+			var msg incoming
+			if json.Unmarshal(data, &msg) != nil {
+				continue
+			}
+			if msg.Type == "event" {
+				c.events <- &msg
+			} else {
+				c.responses <- &msg
+			}
+		}
+	}()
+
+	return c
+}
+
+// call sends the request and decodes the body of the response, the response is returned.
+func (c *client) call(command string, args, body interface{}) *incoming {
+	c.seq++
+
+	req := map[string]interface{}{"seq": c.seq, "type": "request", "command": command}
+	if args != nil {
+		req["arguments"] = args
+	}
+	data, err := json.Marshal(req)
+	require.NoError(c.t, err)
+	require.NoError(c.t, c.out.Write(data))
+
+	select {
+	case resp := <-c.responses:
+		assert.Equal(c.t, c.seq, resp.RequestSeq)
+		assert.Equal(c.t, command, resp.Command)
+		if body != nil && resp.Success {
+			require.NoError(c.t, json.Unmarshal(resp.Body, body))
+		}
+		return resp
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("no response to %s", command)
+		return nil
+	}
+}
+
+// succeed sends the request which has to succeed.
+func (c *client) succeed(command string, args, body interface{}) {
+	resp := c.call(command, args, body)
+	require.True(c.t, resp.Success, "%s failed: %s", command, resp.Message)
+}
+
+// waitFor returns the body of the event, the output events received before are collected.
+func (c *client) waitFor(name string, body interface{}) {
+	for {
+		select {
+		case event, ok := <-c.events:
+			require.True(c.t, ok, "no %s event", name)
+
+			if event.Event == "output" {
+				var output OutputEvent
+				require.NoError(c.t, json.Unmarshal(event.Body, &output))
+				c.output.WriteString(output.Category + ": " + output.Output)
+			}
+
+			if event.Event == name {
+				if body != nil {
+					require.NoError(c.t, json.Unmarshal(event.Body, body))
+				}
+				return
+			}
+		case <-time.After(5 * time.Second):
+			c.t.Fatalf("no %s event", name)
+		}
+	}
+}
+
+func writeProgram(t *testing.T, src string) (string, func()) {
+	dir, err := ioutil.TempDir("", "monkey")
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, "test.mk")
+	require.NoError(t, ioutil.WriteFile(path, []byte(src), 0644))
+
+	return path, func() { os.RemoveAll(dir) }
+}
+
+// launch starts debugging of the program with the breakpoints on the lines.
+func launch(t *testing.T, src string, stopOnEntry bool, breakpoints ...SourceBreakpoint) (*client, string, func()) {
+	path, remove := writeProgram(t, src)
+	c := newClient(t)
+
+	var capabilities Capabilities
+	c.succeed("initialize", map[string]interface{}{"adapterID": "monkey"}, &capabilities)
+	assert.True(t, capabilities.SupportsConditionalBreakpoints)
+	c.waitFor("initialized", nil)
+
+	c.succeed("launch", LaunchArguments{Program: path, StopOnEntry: stopOnEntry}, nil)
+
+	var set SetBreakpointsResponse
+	c.succeed("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}, Breakpoints: breakpoints}, &set)
+	require.Len(t, set.Breakpoints, len(breakpoints))
+
+	c.succeed("configurationDone", nil, nil)
+
+	return c, path, func() {
+		c.close()
+		remove()
+	}
+}
+
+func (c *client) stopped() StoppedEvent {
+	var event StoppedEvent
+	c.waitFor("stopped", &event)
+	return event
+}
+
+func (c *client) stackTrace() []StackFrame {
+	var trace StackTraceResponse
+	c.succeed("stackTrace", map[string]interface{}{"threadId": threadID}, &trace)
+	return trace.StackFrames
+}
+
+func (c *client) location() string {
+	frames := c.stackTrace()
+	require.NotEmpty(c.t, frames)
+
+	var names []string
+	for _, frame := range frames {
+		names = append(names, frame.Name)
+	}
+
+	return strings.Join(names, " < ") + " at " + frameLine(frames[0])
+}
+
+func frameLine(frame StackFrame) string {
+	return frame.Source.Name + ":" + itoa(frame.Line)
+}
+
+func itoa(i int) string {
+	b, _ := json.Marshal(i)
+	return string(b)
+}
+
+func (c *client) exited() int {
+	var exited ExitedEvent
+	c.waitFor("exited", &exited)
+	c.waitFor("terminated", nil)
+	return exited.ExitCode
+}
+
+func TestRunWithoutBreakpoints(t *testing.T) {
+	c, _, cleanup := launch(t, testProgram, false)
+	defer cleanup()
+
+	assert.Equal(t, 0, c.exited())
+	assert.Equal(t, "stdout: 6\n", c.output.String())
+
+	c.succeed("disconnect", nil, nil)
+
+	select {
+	case err := <-c.done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't exit")
+	}
+}
+
+func TestBreakpointsAndStepping(t *testing.T) {
+	c, path, cleanup := launch(t, testProgram, false, SourceBreakpoint{Line: 2, Condition: "a == 3"}, SourceBreakpoint{Line: 5})
+	defer cleanup()
+
+	event := c.stopped()
+	assert.Equal(t, "breakpoint", event.Reason)
+	assert.Equal(t, []int{2}, event.HitBreakpointIDs)
+	assert.Equal(t, "main() at test.mk:5", c.location())
+	assert.Equal(t, path, c.stackTrace()[0].Source.Path)
+
+	c.succeed("stepIn", map[string]interface{}{"threadId": threadID}, nil)
+	assert.Equal(t, "step", c.stopped().Reason)
+	assert.Equal(t, "add() < main() at test.mk:2", c.location())
+
+	c.succeed("stepOut", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped()
+	assert.Equal(t, "main() at test.mk:6", c.location())
+
+	c.succeed("next", map[string]interface{}{"threadId": threadID}, nil)
+	c.stopped()
+	assert.Equal(t, "main() at test.mk:7", c.location())
+
+	var resp ContinueResponse
+	c.succeed("continue", map[string]interface{}{"threadId": threadID}, &resp)
+	assert.True(t, resp.AllThreadsContinued)
+
+	event = c.stopped()
+	assert.Equal(t, []int{1}, event.HitBreakpointIDs)
+	assert.Equal(t, "add() < main() at test.mk:2", c.location())
+
+	c.succeed("continue", map[string]interface{}{"threadId": threadID}, nil)
+	assert.Equal(t, 0, c.exited())
+	assert.Equal(t, "stdout: 6\n", c.output.String())
+}
+
+func TestVariablesAndEvaluate(t *testing.T) {
+	c, _, cleanup := launch(t, testProgram, false, SourceBreakpoint{Line: 3})
+	defer cleanup()
+
+	c.stopped()
+	c.succeed("continue", nil, nil)
+	c.stopped()
+
+	frames := c.stackTrace()
+	require.Len(t, frames, 2)
+
+	var scopes ScopesResponse
+	c.succeed("scopes", FrameArguments{FrameID: frames[0].ID}, &scopes)
+	require.Len(t, scopes.Scopes, 2)
+	assert.Equal(t, "Locals", scopes.Scopes[0].Name)
+	assert.Equal(t, "Globals", scopes.Scopes[1].Name)
+
+	var locals VariablesResponse
+	c.succeed("variables", VariablesArguments{VariablesReference: scopes.Scopes[0].VariablesReference}, &locals)
+	assert.Equal(t, []Variable{
+		{Name: "a", Value: "3", Type: "INTEGER"},
+		{Name: "b", Value: "3", Type: "INTEGER"},
+		{Name: "sum", Value: "6", Type: "INTEGER"},
+	}, locals.Variables)
+
+	var globals VariablesResponse
+	c.succeed("variables", VariablesArguments{VariablesReference: scopes.Scopes[1].VariablesReference}, &globals)
+
+	var names []string
+	var data Variable
+	for _, v := range globals.Variables {
+		names = append(names, v.Name)
+		if v.Name == "data" {
+			data = v
+		}
+	}
+	assert.Equal(t, []string{"add", "args", "data", "x"}, names)
+
+	var pairs VariablesResponse
+	c.succeed("variables", VariablesArguments{VariablesReference: data.VariablesReference}, &pairs)
+	require.Len(t, pairs.Variables, 1)
+	assert.Equal(t, "list", pairs.Variables[0].Name)
+
+	var elements VariablesResponse
+	c.succeed("variables", VariablesArguments{VariablesReference: pairs.Variables[0].VariablesReference}, &elements)
+	assert.Equal(t, []Variable{{Name: "[0]", Value: "3", Type: "INTEGER"}, {Name: "[1]", Value: "4", Type: "INTEGER"}}, elements.Variables)
+
+	var result EvaluateResponse
+	c.succeed("evaluate", EvaluateArguments{Expression: "sum * 2", FrameID: frames[0].ID}, &result)
+	assert.Equal(t, EvaluateResponse{Result: "12", Type: "INTEGER"}, result)
+
+	c.succeed("scopes", FrameArguments{FrameID: frames[1].ID}, &scopes)
+	assert.Len(t, scopes.Scopes, 1)
+
+	resp := c.call("evaluate", EvaluateArguments{Expression: "sum", FrameID: frames[1].ID}, nil)
+	assert.False(t, resp.Success)
+	assert.Equal(t, "identifier not found: sum", resp.Message)
+
+	// the output of evaluated code is sent as events instead of corrupting the protocol
+	c.succeed("evaluate", EvaluateArguments{Expression: `puts("evaluated")`, FrameID: frames[0].ID}, &result)
+	assert.Equal(t, "null", result.Result)
+
+	c.succeed("continue", nil, nil)
+	assert.Equal(t, 0, c.exited())
+	assert.Equal(t, "stdout: evaluated\nstdout: 6\n", c.output.String())
+
+	resp = c.call("stackTrace", nil, nil)
+	assert.False(t, resp.Success)
+	assert.Equal(t, errNotStopped.Error(), resp.Message)
+}
+
+func TestStopOnEntryAndTerminate(t *testing.T) {
+	c, _, cleanup := launch(t, testProgram, true)
+	defer cleanup()
+
+	assert.Equal(t, "entry", c.stopped().Reason)
+	assert.Equal(t, "main() at test.mk:1", c.location())
+
+	c.succeed("terminate", nil, nil)
+	assert.Equal(t, 1, c.exited())
+	assert.Empty(t, c.output.String())
+}
+
+func TestPause(t *testing.T) {
+	c, _, cleanup := launch(t, "let i = 0;\nwhile (true) {\n  i += 1;\n}\n", false)
+	defer cleanup()
+
+	c.succeed("pause", map[string]interface{}{"threadId": threadID}, nil)
+	assert.Equal(t, "pause", c.stopped().Reason)
+
+	c.succeed("disconnect", nil, nil)
+	assert.Equal(t, 1, c.exited())
+}
+
+func TestRuntimeError(t *testing.T) {
+	c, _, cleanup := launch(t, "let f = fn() { 1 / 0 };\nf();\n", false)
+	defer cleanup()
+
+	assert.Equal(t, 1, c.exited())
+	assert.Contains(t, c.output.String(), "stderr: ")
+	assert.Contains(t, c.output.String(), "test.mk:1:18: division by zero\n")
+}
+
+func TestRequestErrors(t *testing.T) {
+	path, remove := writeProgram(t, "let = 1;")
+	defer remove()
+
+	c := newClient(t)
+	defer c.close()
+
+	resp := c.call("launch", LaunchArguments{Program: path}, nil)
+	assert.False(t, resp.Success)
+	assert.Contains(t, resp.Message, "expected next token to be IDENT")
+
+	resp = c.call("launch", LaunchArguments{Program: filepath.Join(filepath.Dir(path), "missing.mk")}, nil)
+	assert.False(t, resp.Success)
+
+	resp = c.call("continue", nil, nil)
+	assert.False(t, resp.Success)
+	assert.Equal(t, errNotStopped.Error(), resp.Message)
+
+	resp = c.call("restartFrame", nil, nil)
+	assert.False(t, resp.Success)
+	assert.Equal(t, "unsupported request: restartFrame", resp.Message)
+
+	var set SetBreakpointsResponse
+	c.succeed("setBreakpoints", SetBreakpointsArguments{Source: Source{Path: path}, Breakpoints: []SourceBreakpoint{{Line: 1, Condition: "x >"}}}, &set)
+	require.Len(t, set.Breakpoints, 1)
+	assert.False(t, set.Breakpoints[0].Verified)
+	assert.Contains(t, set.Breakpoints[0].Message, "invalid condition")
+}
@@ -0,0 +1,367 @@
+// Package debugger stops programs run by the evaluator at breakpoints and steps through them,
+// see evaluator.Hook.
+package debugger
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/evaluator"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+)
+
+// ErrTerminated is returned by the hook when the program is terminated by the debugger.
+var ErrTerminated = errors.New("terminated by the debugger")
+
+// evaluationLimits bound conditions of breakpoints and code evaluated in frames, so that they
+// can't hang the stopped program.
+var evaluationLimits = evaluator.Options{MaxDepth: 100, MaxSteps: 1000000}
+
+// Action tells how the stopped program continues.
+type Action int
+
+const (
+	Continue  Action = iota // run until a breakpoint
+	StepIn                  // stop at the next statement
+	StepOver                // stop at the next statement of the current function or of its callers
+	StepOut                 // stop at the next statement of the callers of the current function
+	Terminate               // stop the program
+)
+
+// Reason tells why the program stopped.
+type Reason string
+
+const (
+	ReasonEntry      Reason = "entry"
+	ReasonBreakpoint Reason = "breakpoint"
+	ReasonStep       Reason = "step"
+	ReasonPause      Reason = "pause"
+)
+
+// Breakpoint stops the program before the statements starting on the line of the file.
+type Breakpoint struct {
+	ID        int
+	File      string
+	Line      int
+	Condition string // the program stops only if the condition is true, it always stops if empty
+	Hits      int    // number of times the program stopped at the breakpoint
+
+	condition ast.Node
+}
+
+// Frame is a call active when the program stopped, the position is the position of the statement
+// being executed in the function.
+type Frame struct {
+	object.StackFrame
+	Env *object.Environment
+}
+
+// Name returns the name of the function like stack traces show it.
+func (f Frame) Name() string {
+	switch f.Function {
+	case object.MainFrame:
+		return object.MainFrame + "()"
+	case "":
+		return "fn(...)"
+	default:
+		return f.Function + "()"
+	}
+}
+
+// Variable is a binding visible in a frame.
+type Variable struct {
+	Name  string
+	Value object.Object
+}
+
+// Locals returns the bindings created by the call, for the outermost frame these are the global bindings.
+func (f Frame) Locals() []Variable {
+	names := f.Env.LocalNames()
+
+	variables := make([]Variable, 0, len(names))
+	for _, name := range names {
+		value, _ := f.Env.Get(name)
+		variables = append(variables, Variable{Name: name, Value: value})
+	}
+
+	return variables
+}
+
+// Stop describes the stopped program.
+type Stop struct {
+	Reason     Reason
+	Node       ast.Node    // the statement about to be executed
+	Frames     []Frame     // the innermost call first
+	Breakpoint *Breakpoint // the breakpoint the program stopped at
+}
+
+// Handler is called when the program stops, the program continues as the returned action tells.
+type Handler func(stop *Stop) Action
+
+// Debugger decides when the program stops. Its Hook has to be passed to the evaluator, other methods
+// may be called concurrently with the program, e.g. to set breakpoints or to pause it.
+type Debugger struct {
+	handler Handler
+
+	mu          sync.Mutex
+	breakpoints []*Breakpoint
+	lastID      int
+	pause       bool
+	terminate   bool
+
+	// state of the run, used only by the hook
+	action Action
+	entry  bool
+	depth  int      // number of calls when the stepping started
+	last   ast.Node // the statement the hook was called for before
+	global *object.Environment
+
+	builtins *object.Registry // builtins of conditions and evaluated code, the standard ones if nil
+}
+
+func New(handler Handler) *Debugger {
+	return &Debugger{handler: handler}
+}
+
+// SetBuiltins makes conditions of breakpoints and evaluated code use the builtins of the debugged program,
+// it has to be called before the program starts.
+func (d *Debugger) SetBuiltins(builtins *object.Registry) {
+	d.builtins = builtins
+}
+
+// SetBreakpoint adds a breakpoint, the condition has to be a valid expression if it's not empty.
+func (d *Debugger) SetBreakpoint(file string, line int, condition string) (*Breakpoint, error) {
+	bp := &Breakpoint{File: file, Line: line, Condition: condition}
+
+	if condition != "" {
+		program, err := parser.New(lexer.New(condition)).ParseProgram()
+		if err != nil {
+			return nil, fmt.Errorf("invalid condition: %v", err)
+		}
+		bp.condition = program
+	}
+
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	d.lastID++
+	bp.ID = d.lastID
+	d.breakpoints = append(d.breakpoints, bp)
+
+	return bp, nil
+}
+
+// RemoveBreakpoint removes the breakpoint, it returns false if there is no breakpoint with the id.
+func (d *Debugger) RemoveBreakpoint(id int) bool {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	for i, bp := range d.breakpoints {
+		if bp.ID == id {
+			d.breakpoints = append(d.breakpoints[:i], d.breakpoints[i+1:]...)
+			return true
+		}
+	}
+
+	return false
+}
+
+// ClearBreakpoints removes the breakpoints of the file.
+func (d *Debugger) ClearBreakpoints(file string) {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	kept := d.breakpoints[:0]
+	for _, bp := range d.breakpoints {
+		if bp.File != file {
+			kept = append(kept, bp)
+		}
+	}
+	d.breakpoints = kept
+}
+
+// Breakpoints returns the breakpoints ordered by their ids.
+func (d *Debugger) Breakpoints() []*Breakpoint {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+
+	breakpoints := make([]*Breakpoint, len(d.breakpoints))
+	copy(breakpoints, d.breakpoints)
+	sort.Slice(breakpoints, func(i, j int) bool { return breakpoints[i].ID < breakpoints[j].ID })
+
+	return breakpoints
+}
+
+// Start prepares the debugger for a new run of a program, the program stops at its first statement
+// if the action is StepIn.
+func (d *Debugger) Start(action Action) {
+	d.mu.Lock()
+	d.pause, d.terminate = false, false
+	d.mu.Unlock()
+
+	d.action, d.entry = action, action == StepIn
+	d.depth, d.last, d.global = 0, nil, nil
+}
+
+// Pause stops the running program at its next statement.
+func (d *Debugger) Pause() {
+	d.mu.Lock()
+	d.pause = true
+	d.mu.Unlock()
+}
+
+// Terminate stops the running program before its next statement.
+func (d *Debugger) Terminate() {
+	d.mu.Lock()
+	d.terminate = true
+	d.mu.Unlock()
+}
+
+// Hook is the evaluator.Hook stopping the program.
+func (d *Debugger) Hook(n ast.Node, env *object.Environment, calls []evaluator.Frame) error {
+	if len(calls) == 0 && d.global == nil {
+		d.global = env
+	}
+
+	if !isStatement(n) {
+		return nil
+	}
+
+	d.mu.Lock()
+	pause, terminate := d.pause, d.terminate
+	d.pause = false
+	d.mu.Unlock()
+
+	if terminate {
+		return ErrTerminated
+	}
+
+	reason, bp := d.reason(n, env, len(calls), pause)
+	d.last = n
+
+	if reason == "" {
+		return nil
+	}
+
+	if bp != nil {
+		d.mu.Lock()
+		bp.Hits++
+		d.mu.Unlock()
+	}
+
+	stop := &Stop{Reason: reason, Node: n, Frames: d.frames(n, env, calls), Breakpoint: bp}
+
+	action := d.handler(stop)
+	if action == Terminate {
+		return ErrTerminated
+	}
+
+	d.action, d.entry, d.depth = action, false, len(calls)
+	return nil
+}
+
+// reason returns the reason to stop before the statement or an empty string if the program
+// doesn't have to stop.
+func (d *Debugger) reason(n ast.Node, env *object.Environment, depth int, pause bool) (Reason, *Breakpoint) {
+	switch {
+	case d.entry:
+		return ReasonEntry, nil
+	case pause:
+		return ReasonPause, nil
+	case d.action == StepIn,
+		d.action == StepOver && depth <= d.depth,
+		d.action == StepOut && depth < d.depth:
+		return ReasonStep, nil
+	}
+
+	if bp := d.breakpointAt(n, env); bp != nil {
+		return ReasonBreakpoint, bp
+	}
+
+	return "", nil
+}
+
+// breakpointAt returns the breakpoint the program has to stop at before the statement. A statement
+// nested in the previous one on the same line doesn't stop the program again, so that e.g.
+// 'if (x) { y }' stops once, but each iteration of a loop written on one line stops.
+func (d *Debugger) breakpointAt(n ast.Node, env *object.Environment) *Breakpoint {
+	pos := n.Pos()
+
+	if d.last != nil && d.last != n && d.last.Pos().Line == pos.Line && contains(d.last, n) {
+		return nil
+	}
+
+	for _, bp := range d.Breakpoints() {
+		if bp.File != pos.Filename || bp.Line != pos.Line {
+			continue
+		}
+
+		if bp.condition == nil {
+			return bp
+		}
+
+		// a condition which can't be evaluated stops the program, so that mistakes in it are noticed
+		result := d.evaluate(bp.condition, env)
+		if _, failed := result.(*object.Error); failed || evaluator.IsTruthy(result) {
+			return bp
+		}
+	}
+
+	return nil
+}
+
+func contains(outer, inner ast.Node) bool {
+	return outer.Pos().Offset <= inner.Pos().Offset && inner.End().Offset <= outer.End().Offset
+}
+
+// frames returns the calls active at the statement, the innermost first.
+func (d *Debugger) frames(n ast.Node, env *object.Environment, calls []evaluator.Frame) []Frame {
+	frames := make([]Frame, 0, len(calls)+1)
+	pos := n.Pos()
+
+	for i := len(calls) - 1; i >= 0; i-- {
+		frames = append(frames, Frame{StackFrame: object.StackFrame{Function: calls[i].Function, Pos: pos}, Env: calls[i].Env})
+		pos = calls[i].CallPos
+	}
+
+	global := d.global
+	if global == nil {
+		global = env
+	}
+
+	return append(frames, Frame{StackFrame: object.StackFrame{Function: object.MainFrame, Pos: pos}, Env: global})
+}
+
+func isStatement(n ast.Node) bool {
+	switch n.(type) {
+	case *ast.ExpressionStatement, *ast.LetStatement, *ast.ReturnStatement, *ast.WhileStatement, *ast.ForStatement,
+		*ast.BreakStatement, *ast.ContinueStatement:
+		return true
+	default:
+		return false
+	}
+}
+
+// Evaluate evaluates the code in a scope enclosed by the environment of the frame, bindings it creates
+// aren't added to the frame. Errors of the evaluation are returned as *object.Error.
+func (d *Debugger) Evaluate(code string, frame Frame) (object.Object, error) {
+	program, err := parser.New(lexer.New(code)).ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+
+	return d.evaluate(program, frame.Env), nil
+}
+
+func (d *Debugger) evaluate(n ast.Node, env *object.Environment) object.Object {
+	opts := evaluationLimits
+	opts.Builtins = d.builtins
+
+	return evaluator.EvalContext(context.Background(), n, object.NewEnclosedEnvironment(env), opts)
+}
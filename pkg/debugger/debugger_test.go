@@ -0,0 +1,316 @@
+package debugger
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/evaluator"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testFile = "test.mk"
+
+const testProgram = `let add = fn(a, b) {
+  let sum = a + b;
+  sum
+};
+let x = add(1, 2);
+let y = add(x, 3);
+for (i in [1, 2, 3]) {
+  let last = i;
+}
+y
+`
+
+type breakpoint struct {
+	line      int
+	condition string
+}
+
+// debug runs the program and returns the stops described as "reason line function", the program
+// continues with the actions in turn, Continue is used after they run out.
+func debug(t *testing.T, src string, start Action, breakpoints []breakpoint, actions []Action) ([]string, object.Object) {
+	var stops []string
+
+	d := New(func(stop *Stop) Action {
+		description := fmt.Sprintf("%s %d %s", stop.Reason, stop.Frames[0].Pos.Line, stop.Frames[0].Name())
+		if stop.Breakpoint != nil {
+			description += fmt.Sprintf(" #%d", stop.Breakpoint.ID)
+		}
+		stops = append(stops, description)
+
+		if len(stops) > 20 {
+			return Terminate
+		}
+		if len(stops) <= len(actions) {
+			return actions[len(stops)-1]
+		}
+		return Continue
+	})
+
+	for _, bp := range breakpoints {
+		_, err := d.SetBreakpoint(testFile, bp.line, bp.condition)
+		require.NoError(t, err)
+	}
+
+	program, err := parser.New(lexer.NewFile(testFile, src)).ParseProgram()
+	require.NoError(t, err)
+
+	d.Start(start)
+	result := evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), evaluator.Options{Hook: d.Hook})
+
+	return stops, result
+}
+
+func TestDebugger(t *testing.T) {
+	testData := map[string]struct {
+		start       Action
+		breakpoints []breakpoint
+		actions     []Action
+		stops       []string
+	}{
+		"no breakpoints": {
+			start: Continue,
+			stops: nil,
+		},
+		"entry": {
+			start: StepIn,
+			stops: []string{"entry 1 main()"},
+		},
+		"breakpoint in function": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 2}},
+			stops:       []string{"breakpoint 2 add() #1", "breakpoint 2 add() #1"},
+		},
+		"conditional breakpoint": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 2, condition: "a == 3"}},
+			stops:       []string{"breakpoint 2 add() #1"},
+		},
+		"condition with error": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 5, condition: "unknown > 1"}},
+			stops:       []string{"breakpoint 5 main() #1"},
+		},
+		"condition with binding": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 6, condition: "let x = 100; false"}},
+			stops:       nil,
+		},
+		"condition with endless loop": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 5, condition: "while (true) { }"}},
+			stops:       []string{"breakpoint 5 main() #1"},
+		},
+		"breakpoint in loop": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 8}},
+			stops:       []string{"breakpoint 8 main() #1", "breakpoint 8 main() #1", "breakpoint 8 main() #1"},
+		},
+		"step in": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 5}},
+			actions:     []Action{StepIn, StepIn, StepIn, Continue},
+			stops:       []string{"breakpoint 5 main() #1", "step 2 add()", "step 3 add()", "step 6 main()"},
+		},
+		"step over": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 5}},
+			actions:     []Action{StepOver, StepOver, Continue},
+			stops:       []string{"breakpoint 5 main() #1", "step 6 main()", "step 7 main()"},
+		},
+		"step over stops at breakpoints": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 5}, {line: 3}},
+			actions:     []Action{StepOver, StepOver},
+			stops:       []string{"breakpoint 5 main() #1", "breakpoint 3 add() #2", "step 6 main()", "breakpoint 3 add() #2"},
+		},
+		"step out": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 2}},
+			actions:     []Action{StepOut, Continue},
+			stops:       []string{"breakpoint 2 add() #1", "step 6 main()", "breakpoint 2 add() #1"},
+		},
+		"step into loop body": {
+			start:       Continue,
+			breakpoints: []breakpoint{{line: 7}},
+			actions:     []Action{StepOver, StepOver},
+			stops:       []string{"breakpoint 7 main() #1", "step 8 main()", "step 8 main()"},
+		},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			stops, result := debug(t, testProgram, data.start, data.breakpoints, data.actions)
+
+			assert.Equal(t, data.stops, stops)
+			assert.Equal(t, "6", result.Inspect())
+		})
+	}
+}
+
+func TestBreakpointOnOneLine(t *testing.T) {
+	testData := map[string]struct {
+		input string
+		stops int
+	}{
+		"nested statements":    {"if (true) { 1 }", 1},
+		"loop on one line":     {"let i = 0; while (i < 3) { i += 1 }", 4},
+		"statements on line":   {"let a = 1; let b = 2;", 2},
+		"function on one line": {"let f = fn() { 1 }; f(); f();", 5},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			stops, _ := debug(t, data.input, Continue, []breakpoint{{line: 1}}, nil)
+			assert.Len(t, stops, data.stops)
+		})
+	}
+}
+
+func TestTerminate(t *testing.T) {
+	stops, result := debug(t, testProgram, Continue, []breakpoint{{line: 6}}, []Action{Terminate})
+
+	assert.Equal(t, []string{"breakpoint 6 main() #1"}, stops)
+
+	errObj, ok := result.(*object.Error)
+	require.True(t, ok)
+	assert.Equal(t, object.ErrorCancelled, errObj.Kind)
+	assert.Equal(t, ErrTerminated.Error(), errObj.Message)
+}
+
+func TestPauseAndTerminateRunningProgram(t *testing.T) {
+	var d *Debugger
+	var stopped *Stop
+
+	d = New(func(stop *Stop) Action {
+		stopped = stop
+		d.Terminate()
+		return Continue
+	})
+
+	program, err := parser.New(lexer.NewFile(testFile, "let i = 0;\nwhile (true) { i += 1 }")).ParseProgram()
+	require.NoError(t, err)
+
+	d.Start(Continue)
+	d.Pause()
+	result := evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), evaluator.Options{Hook: d.Hook})
+
+	require.NotNil(t, stopped)
+	assert.Equal(t, ReasonPause, stopped.Reason)
+	assert.Equal(t, ErrTerminated.Error(), result.(*object.Error).Message)
+}
+
+func TestFramesAndEvaluate(t *testing.T) {
+	var frames []Frame
+
+	d := New(func(stop *Stop) Action {
+		frames = stop.Frames
+		return Continue
+	})
+	_, err := d.SetBreakpoint(testFile, 3, "")
+	require.NoError(t, err)
+
+	program, err := parser.New(lexer.NewFile(testFile, testProgram)).ParseProgram()
+	require.NoError(t, err)
+
+	d.Start(Continue)
+	evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), evaluator.Options{Hook: d.Hook})
+
+	// the frames of the last stop, add(x, 3)
+	require.Len(t, frames, 2)
+	assert.Equal(t, "add()", frames[0].Name())
+	assert.Equal(t, "test.mk:3:3", frames[0].Pos.String())
+	assert.Equal(t, "main()", frames[1].Name())
+	assert.Equal(t, "test.mk:6:9", frames[1].Pos.String())
+
+	var locals []string
+	for _, v := range frames[0].Locals() {
+		locals = append(locals, v.Name+"="+v.Value.Inspect())
+	}
+	assert.Equal(t, "a=3 b=3 sum=6", strings.Join(locals, " "))
+
+	result, err := d.Evaluate("sum * x", frames[0])
+	require.NoError(t, err)
+	assert.Equal(t, "18", result.Inspect())
+
+	result, err = d.Evaluate("sum", frames[1])
+	require.NoError(t, err)
+	assert.Equal(t, "identifier not found: sum", result.(*object.Error).Message)
+
+	_, err = d.Evaluate("let", frames[0])
+	assert.Error(t, err)
+
+	result, err = d.Evaluate("let sum = 0; let f = fn() { f() }; f()", frames[0])
+	require.NoError(t, err)
+	assert.Equal(t, object.ErrorDepthExceeded, result.(*object.Error).Kind)
+
+	result, err = d.Evaluate("sum", frames[0])
+	require.NoError(t, err)
+	assert.Equal(t, "6", result.Inspect())
+}
+
+func TestBuiltinsOfProgram(t *testing.T) {
+	builtins := object.NewRegistry()
+	require.NoError(t, builtins.RegisterValue("double", &object.Builtin{Fn: func(args ...object.Object) object.Object {
+		return object.NewInteger(2 * args[0].(*object.Integer).Value)
+	}}))
+
+	var frames []Frame
+
+	d := New(func(stop *Stop) Action {
+		frames = stop.Frames
+		return Continue
+	})
+	d.SetBuiltins(builtins)
+
+	_, err := d.SetBreakpoint(testFile, 3, "double(sum) == 6")
+	require.NoError(t, err)
+
+	program, err := parser.New(lexer.NewFile(testFile, testProgram)).ParseProgram()
+	require.NoError(t, err)
+
+	d.Start(Continue)
+	evaluator.EvalContext(context.Background(), program, object.NewEnvironment(), evaluator.Options{Hook: d.Hook, Builtins: builtins})
+
+	// the condition holds only in the first call, add(1, 2)
+	require.Len(t, frames, 2)
+	assert.Equal(t, "test.mk:5:9", frames[1].Pos.String())
+
+	result, err := d.Evaluate("double(sum)", frames[0])
+	require.NoError(t, err)
+	assert.Equal(t, "6", result.Inspect())
+}
+
+func TestBreakpoints(t *testing.T) {
+	d := New(nil)
+
+	first, err := d.SetBreakpoint("a.mk", 1, "")
+	require.NoError(t, err)
+	_, err = d.SetBreakpoint("b.mk", 2, "x > 1")
+	require.NoError(t, err)
+	third, err := d.SetBreakpoint("a.mk", 3, "")
+	require.NoError(t, err)
+
+	_, err = d.SetBreakpoint("a.mk", 4, "x >")
+	assert.Error(t, err)
+
+	assert.Equal(t, []int{1, 2, 3}, []int{first.ID, d.Breakpoints()[1].ID, third.ID})
+
+	assert.True(t, d.RemoveBreakpoint(first.ID))
+	assert.False(t, d.RemoveBreakpoint(first.ID))
+
+	d.ClearBreakpoints("b.mk")
+	assert.Equal(t, []*Breakpoint{third}, d.Breakpoints())
+}
@@ -0,0 +1,49 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	testData := map[string]struct {
+		oldText  string
+		newText  string
+		expected string
+	}{
+		"equal": {"a\nb\n", "a\nb\n", ""},
+		"changed line": {
+			"a\nb\nc\n", "a\nB\nc\n",
+			"--- old\n+++ new\n@@ -1,3 +1,3 @@\n a\n-b\n+B\n c\n",
+		},
+		"inserted into empty": {
+			"", "a\n",
+			"--- old\n+++ new\n@@ -0,0 +1 @@\n+a\n",
+		},
+		"deleted line": {
+			"1\n2\n3\n4\n5\n6\n", "1\n2\n3\n5\n6\n",
+			"--- old\n+++ new\n@@ -1,6 +1,5 @@\n 1\n 2\n 3\n-4\n 5\n 6\n",
+		},
+		"missing newline": {
+			"a\nb", "a\nb\n",
+			"--- old\n+++ new\n@@ -1,2 +1,2 @@\n a\n-b\n\\ No newline at end of file\n+b\n",
+		},
+		"separate hunks": {
+			"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n12\n", "0\n2\n3\n4\n5\n6\n7\n8\n9\n10\n11\n13\n",
+			"--- old\n+++ new\n@@ -1,4 +1,4 @@\n-1\n+0\n 2\n 3\n 4\n@@ -9,4 +9,4 @@\n 9\n 10\n 11\n-12\n+13\n",
+		},
+		"merged hunks": {
+			"1\n2\n3\n4\n5\n6\n7\n8\n", "0\n2\n3\n4\n5\n6\n7\n9\n",
+			"--- old\n+++ new\n@@ -1,8 +1,8 @@\n-1\n+0\n 2\n 3\n 4\n 5\n 6\n 7\n-8\n+9\n",
+		},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.expected, Unified("old", "new", data.oldText, data.newText))
+		})
+	}
+}
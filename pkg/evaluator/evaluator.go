@@ -1,7 +1,9 @@
 package evaluator
 
 import (
+	"context"
 	"fmt"
+	"math"
 
 	"github.com/adrian83/monkey/pkg/ast"
 	"github.com/adrian83/monkey/pkg/object"
@@ -9,42 +11,236 @@ import (
 )
 
 var (
-	objTrue  = object.NewBoolean(true)
-	objFalse = object.NewBoolean(false)
-	objNull  = &object.Null{}
+	objTrue  = object.TrueValue
+	objFalse = object.FalseValue
+	objNull  = object.NullValue
 )
 
+// ApplyInfix, ApplyPrefix, ApplyIndex and IsTruthy expose the operator semantics
+// of the evaluator, so that other backends (see package vm) behave identically.
+
+func ApplyInfix(operator string, left, right object.Object) object.Object {
+	return evalInfixExpression(operator, left, right)
+}
+
+func ApplyPrefix(operator string, right object.Object) object.Object {
+	return evalPrefixExpression(operator, right)
+}
+
+func ApplyIndex(left, index object.Object) object.Object {
+	return evalIndexExpression(left, index)
+}
+
+// ApplyIndexAssign stores the value in the array or hash under the index, if operator isn't
+// empty the value is combined with the current one first, e.g. for h["k"] += 1 it's +.
+func ApplyIndexAssign(operator string, left, index, value object.Object) object.Object {
+	return evalIndexAssignment(operator, left, index, value)
+}
+
+func IsTruthy(obj object.Object) bool {
+	return isTruthy(obj)
+}
+
+// cancellationCheckInterval is the number of steps between checks of the context.
+const cancellationCheckInterval = 256
+
+// DefaultMaxDepth is the maximum number of nested function calls if Options don't set one, deeper
+// recursion would exhaust the stack of the goroutine. It's also the number of frames of the vm,
+// see vm.MaxFrames.
+const DefaultMaxDepth = 1024
+
+// Options restricts resources used by an evaluation, zero values mean no limit except for MaxDepth
+// which is DefaultMaxDepth then.
+type Options struct {
+	MaxDepth  int // maximum number of nested function calls
+	MaxSteps  int // maximum number of evaluated nodes
+	MaxAllocs int // maximum number of created objects
+
+	Builtins *object.Registry // builtins available to the code, the standard ones if nil
+
+	Hook Hook // called before the evaluation of each statement and expression
+}
+
+// Hook is called with the node about to be evaluated, the environment it is evaluated in and
+// the calls being evaluated, the outermost first. The calls must not be modified nor retained.
+// If the hook returns an error, the evaluation stops with an error of kind ErrorCancelled.
+type Hook func(n ast.Node, env *object.Environment, calls []Frame) error
+
 func Eval(n ast.Node, env *object.Environment) object.Object {
+	return newEvaluator(context.Background(), Options{}).eval(n, env)
+}
+
+// EvalContext evaluates the node until it's done, the context is cancelled or one of
+// the limits is reached. In the last two cases an error of kind ErrorCancelled,
+// ErrorDepthExceeded or ErrorBudgetExhausted is returned.
+func EvalContext(ctx context.Context, n ast.Node, env *object.Environment, opts Options) object.Object {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	return newEvaluator(ctx, opts).eval(n, env)
+}
+
+type evaluator struct {
+	ctx    context.Context
+	opts   Options
+	steps  int
+	allocs int
+	stack  []Frame
+}
+
+// Frame describes a call of a Monkey function which is being evaluated.
+type Frame struct {
+	Function string
+	CallPos  token.Position
+	Env      *object.Environment // environment holding the arguments of the call
+}
+
+func newEvaluator(ctx context.Context, opts Options) *evaluator {
+	if opts.Builtins == nil {
+		opts.Builtins = builtins
+	}
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = DefaultMaxDepth
+	}
+
+	return &evaluator{ctx: ctx, opts: opts}
+}
+
+func (e *evaluator) eval(n ast.Node, env *object.Environment) object.Object {
+	if err := e.step(n); err != nil {
+		return err
+	}
+
+	if err := e.hook(n, env); err != nil {
+		return err
+	}
+
+	result := e.evalNode(n, env)
+
+	if allocates(n) {
+		return e.withPosition(e.track(result), n.Pos())
+	}
+
+	return result
+}
+
+// step counts evaluated nodes and checks whether the evaluation may continue.
+func (e *evaluator) step(n ast.Node) object.Object {
+	e.steps++
+
+	var err *object.Error
+	switch {
+	case e.opts.MaxSteps > 0 && e.steps > e.opts.MaxSteps:
+		err = &object.Error{Kind: object.ErrorBudgetExhausted, Message: fmt.Sprintf("step budget of %d exhausted", e.opts.MaxSteps)}
+	case e.steps%cancellationCheckInterval == 0 && e.ctx.Err() != nil:
+		err = &object.Error{Kind: object.ErrorCancelled, Message: fmt.Sprintf("evaluation cancelled: %v", e.ctx.Err())}
+	default:
+		return nil
+	}
+
+	if n == nil {
+		return err
+	}
+
+	return e.withPosition(err, n.Pos())
+}
+
+func (e *evaluator) hook(n ast.Node, env *object.Environment) object.Object {
+	if e.opts.Hook == nil {
+		return nil
+	}
+	if _, ok := n.(*ast.Program); ok {
+		return nil
+	}
+
+	if err := e.opts.Hook(n, env, e.stack); err != nil {
+		return e.withPosition(&object.Error{Kind: object.ErrorCancelled, Message: err.Error()}, n.Pos())
+	}
+
+	return nil
+}
+
+// track counts objects created by the evaluation against the allocation budget.
+func (e *evaluator) track(obj object.Object) object.Object {
+	switch obj.(type) {
+	case nil, *object.Error, *object.Boolean, *object.Null, *object.ReturnValue:
+		return obj
+	}
+
+	if err := e.allocate(); err != nil {
+		return err
+	}
+
+	return obj
+}
+
+func (e *evaluator) allocate() *object.Error {
+	e.allocs++
+	if e.opts.MaxAllocs > 0 && e.allocs > e.opts.MaxAllocs {
+		return &object.Error{Kind: object.ErrorBudgetExhausted, Message: fmt.Sprintf("allocation budget of %d exhausted", e.opts.MaxAllocs)}
+	}
+
+	return nil
+}
+
+// allocates reports whether evaluation of the node creates a new object.
+func allocates(n ast.Node) bool {
+	switch n.(type) {
+	case *ast.IntegerLiteral, *ast.FloatLiteral, *ast.StringLiteral, *ast.ArrayLiteral, *ast.HashLiteral,
+		*ast.FunctionLiteral, *ast.PrefixExpression, *ast.InfixExpression:
+		return true
+	default:
+		return false
+	}
+}
+
+func (e *evaluator) evalNode(n ast.Node, env *object.Environment) object.Object {
 	switch node := n.(type) {
 
 	// Statements
 	case *ast.Program:
-		return evalProgram(node, env)
+		return e.evalProgram(node, env)
 
 	case *ast.Identifier:
-		return evalIdentifier(node, env)
+		return e.withPosition(e.evalIdentifier(node, env), node.Pos())
 
 	case *ast.ExpressionStatement:
-		return Eval(node.Expression, env)
+		return e.eval(node.Expression, env)
 
 	case *ast.ReturnStatement:
-		val := Eval(node.ReturnValue, env)
+		val := e.eval(node.ReturnValue, env)
 		if isError(val) {
 			return val
 		}
 		return &object.ReturnValue{Value: val}
 
 	case *ast.LetStatement:
-		val := Eval(node.Value, env)
+		val := e.eval(node.Value, env)
 		if isError(val) {
 			return val
 		}
 		env.Set(node.Name.Value, val)
 
+	case *ast.WhileStatement:
+		return e.evalWhileStatement(node, env)
+
+	case *ast.ForStatement:
+		return e.evalForStatement(node, env)
+
+	case *ast.BreakStatement:
+		return object.BreakValue
+
+	case *ast.ContinueStatement:
+		return object.ContinueValue
+
 	// Expressions
 	case *ast.IntegerLiteral:
 		return object.NewInteger(node.Value)
 
+	case *ast.FloatLiteral:
+		return object.NewFloat(node.Value)
+
 	case *ast.StringLiteral:
 		return object.NewString(node.Value)
 
@@ -52,54 +248,71 @@ func Eval(n ast.Node, env *object.Environment) object.Object {
 		return nativeBoolToBooleanObject(node.Value)
 
 	case *ast.HashLiteral:
-		return evalHashLiteral(node, env)
+		return e.withPosition(e.evalHashLiteral(node, env), node.Pos())
 
 	case *ast.FunctionLiteral:
 		params := node.Parameters
 		body := node.Body
-		return &object.Function{Parameters: params, Env: env, Body: body}
+		return &object.Function{Name: node.Name, Parameters: params, Env: env, Body: body}
+
+	case *ast.MacroLiteral:
+		return e.withPosition(newError("macro can be defined only by a top level let statement"), node.Pos())
 
 	case *ast.PrefixExpression:
-		right := Eval(node.Right, env)
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
-		return evalPrefixExpression(node.Operator, right)
+		return e.withPosition(evalPrefixExpression(node.Operator, right), node.Token.Pos)
+
+	case *ast.AssignExpression:
+		return e.evalAssignExpression(node, env)
 
 	case *ast.InfixExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		right := Eval(node.Right, env)
+		if isLogicalOperator(node.Operator) {
+			if isTruthy(left) == (node.Operator == token.OperatorOr) {
+				return left
+			}
+			return e.eval(node.Right, env)
+		}
+
+		right := e.eval(node.Right, env)
 		if isError(right) {
 			return right
 		}
 
-		return evalInfixExpression(node.Operator, left, right)
+		return e.withPosition(evalInfixExpression(node.Operator, left, right), node.Token.Pos)
 
 	case *ast.BlockStatement:
-		return evalBlockStatement(node, env)
+		return e.evalBlockStatement(node, env)
 
 	case *ast.IfExpression:
-		return evalIfExpression(node, env)
+		return e.evalIfExpression(node, env)
 
 	case *ast.CallExpression:
-		function := Eval(node.Function, env)
+		if isCallOf(node, quoteName) {
+			return e.withPosition(e.quote(node, env), node.Pos())
+		}
+
+		function := e.eval(node.Function, env)
 		if isError(function) {
 			return function
 		}
 
-		args := evalExpressions(node.Arguments, env)
+		args := e.evalExpressions(node.Arguments, env)
 		if len(args) == 1 && isError(args[0]) {
 			return args[0]
 		}
 
-		return applyFunction(function, args)
+		return e.withPosition(e.applyFunction(function, args, node.Pos()), node.Pos())
 
 	case *ast.ArrayLiteral:
-		elements := evalExpressions(node.Elements, env)
+		elements := e.evalExpressions(node.Elements, env)
 		if len(elements) == 1 && isError(elements[0]) {
 			return elements[0]
 		}
@@ -107,27 +320,50 @@ func Eval(n ast.Node, env *object.Environment) object.Object {
 		return &object.Array{Elements: elements}
 
 	case *ast.IndexExpression:
-		left := Eval(node.Left, env)
+		left := e.eval(node.Left, env)
 		if isError(left) {
 			return left
 		}
 
-		index := Eval(node.Index, env)
+		index := e.eval(node.Index, env)
 		if isError(index) {
 			return index
 		}
 
-		return evalIndexExpression(left, index)
+		return e.withPosition(evalIndexExpression(left, index), node.Token.Pos)
 	}
 
 	return nil
 }
 
-func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
+// withPosition sets the position and the stack trace of the error unless it
+// was already set when the error was returned by a nested expression.
+func (e *evaluator) withPosition(obj object.Object, pos token.Position) object.Object {
+	if err, ok := obj.(*object.Error); ok && !err.Pos.IsValid() {
+		err.Pos = pos
+		err.Trace = e.stackTrace(pos)
+	}
+
+	return obj
+}
+
+// stackTrace returns the current call stack, the innermost call first.
+func (e *evaluator) stackTrace(pos token.Position) []object.StackFrame {
+	trace := make([]object.StackFrame, 0, len(e.stack)+1)
+
+	for i := len(e.stack) - 1; i >= 0; i-- {
+		trace = append(trace, object.StackFrame{Function: e.stack[i].Function, Pos: pos})
+		pos = e.stack[i].CallPos
+	}
+
+	return append(trace, object.StackFrame{Function: object.MainFrame, Pos: pos})
+}
+
+func (e *evaluator) evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Object {
 	pairs := make(map[object.HashKey]object.HashPair)
 
 	for keyNode, valueNode := range node.Pairs {
-		key := Eval(keyNode, env)
+		key := e.eval(keyNode, env)
 		if isError(key) {
 			return key
 		}
@@ -137,7 +373,7 @@ func evalHashLiteral(node *ast.HashLiteral, env *object.Environment) object.Obje
 			return newError("unusable as hash key: %s", key.Type())
 		}
 
-		value := Eval(valueNode, env)
+		value := e.eval(valueNode, env)
 		if isError(value) {
 			return value
 		}
@@ -153,6 +389,8 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	switch {
 	case left.Type() == object.TypeArray && index.Type() == object.TypeInteger:
 		return evalArrayIndexExpression(left, index)
+	case left.Type() == object.TypeString && index.Type() == object.TypeInteger:
+		return evalStringIndexExpression(left, index)
 	case left.Type() == object.TypeHash:
 		return evalHashIndexExpression(left, index)
 	default:
@@ -160,6 +398,111 @@ func evalIndexExpression(left, index object.Object) object.Object {
 	}
 }
 
+func (e *evaluator) evalAssignExpression(node *ast.AssignExpression, env *object.Environment) object.Object {
+	switch target := node.Target.(type) {
+	case *ast.Identifier:
+		var current object.Object
+		if node.BinaryOperator() != "" {
+			current = e.eval(target, env)
+			if isError(current) {
+				return current
+			}
+		}
+
+		value := e.eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		if current != nil {
+			value = evalInfixExpression(node.BinaryOperator(), current, value)
+			if isError(value) {
+				return e.withPosition(value, node.Token.Pos)
+			}
+		}
+
+		if !env.Assign(target.Value, value) {
+			return e.withPosition(e.undefinedAssignmentError(target.Value), target.Pos())
+		}
+
+		return value
+
+	case *ast.IndexExpression:
+		left := e.eval(target.Left, env)
+		if isError(left) {
+			return left
+		}
+
+		index := e.eval(target.Index, env)
+		if isError(index) {
+			return index
+		}
+
+		value := e.eval(node.Value, env)
+		if isError(value) {
+			return value
+		}
+
+		return e.withPosition(evalIndexAssignment(node.BinaryOperator(), left, index, value), node.Token.Pos)
+
+	default:
+		return e.withPosition(newError("cannot assign to %s", node.Target.String()), node.Pos())
+	}
+}
+
+func (e *evaluator) undefinedAssignmentError(name string) *object.Error {
+	if _, ok := e.opts.Builtins.Lookup(name); ok {
+		return newError("cannot assign to builtin: %s", name)
+	}
+
+	return newError("identifier not found: %s", name)
+}
+
+func evalIndexAssignment(operator string, left, index, value object.Object) object.Object {
+	if operator != "" {
+		current := evalIndexExpression(left, index)
+		if isError(current) {
+			return current
+		}
+
+		value = evalInfixExpression(operator, current, value)
+		if isError(value) {
+			return value
+		}
+	}
+
+	switch left := left.(type) {
+	case *object.Array:
+		i, ok := index.(*object.Integer)
+		if !ok {
+			return newError("array index must be INTEGER, got %s", index.Type())
+		}
+
+		if i.Value < 0 || i.Value >= int64(len(left.Elements)) {
+			return newError("index out of range: %d", i.Value)
+		}
+
+		left.Elements[i.Value] = value
+
+	case *object.Hash:
+		if left.Frozen {
+			return newError("cannot assign to a frozen hash")
+		}
+
+		key, ok := index.(object.Hashable)
+		if !ok {
+			return newError("unusable as hash key: %s", index.Type())
+		}
+
+		left.Pairs[key.HashKey()] = object.HashPair{Key: index, Value: value}
+
+	default:
+		return newError("index assignment not supported: %s", left.Type())
+	}
+
+	return value
+}
+
 func evalHashIndexExpression(hash, index object.Object) object.Object {
 	hashObject := hash.(*object.Hash)
 
@@ -169,7 +512,7 @@ func evalHashIndexExpression(hash, index object.Object) object.Object {
 	}
 
 	pair, ok := hashObject.Pairs[key.HashKey()]
-	if !ok {
+	if !ok || !object.Equal(pair.Key, index) {
 		return objNull
 	}
 
@@ -188,14 +531,49 @@ func evalArrayIndexExpression(array, index object.Object) object.Object {
 	return arrayObject.Elements[idx]
 }
 
-func applyFunction(fn object.Object, args []object.Object) object.Object {
+// evalStringIndexExpression returns the character (not the byte) at the index.
+func evalStringIndexExpression(str, index object.Object) object.Object {
+	value := str.(*object.String).Value
+	idx := index.(*object.Integer).Value
+
+	if idx < 0 {
+		return objNull
+	}
+
+	for _, ch := range value {
+		if idx == 0 {
+			return object.NewString(string(ch))
+		}
+		idx--
+	}
+
+	return objNull
+}
+
+func (e *evaluator) applyFunction(fn object.Object, args []object.Object, callPos token.Position) object.Object {
 	switch fn := fn.(type) {
 	case *object.Function:
+		if len(args) != len(fn.Parameters) {
+			return newError("wrong number of arguments: want=%d, got=%d", len(fn.Parameters), len(args))
+		}
+
+		if len(e.stack) >= e.opts.MaxDepth {
+			return &object.Error{Kind: object.ErrorDepthExceeded, Message: fmt.Sprintf("maximum call depth of %d exceeded", e.opts.MaxDepth)}
+		}
+
+		if err := e.allocate(); err != nil {
+			return err
+		}
+
 		extendedEnv := extendFunctionEnv(fn, args)
-		evaluated := Eval(fn.Body, extendedEnv)
+
+		e.stack = append(e.stack, Frame{Function: fn.Name, CallPos: callPos, Env: extendedEnv})
+		evaluated := e.eval(fn.Body, extendedEnv)
+		e.stack = e.stack[:len(e.stack)-1]
+
 		return unwrapReturnValue(evaluated)
 	case *object.Builtin:
-		return fn.Fn(args...)
+		return e.track(fn.Fn(args...))
 	default:
 		return newError("not a function: %s", fn.Type())
 	}
@@ -218,11 +596,11 @@ func unwrapReturnValue(obj object.Object) object.Object {
 	return obj
 }
 
-func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
+func (e *evaluator) evalExpressions(exps []ast.Expression, env *object.Environment) []object.Object {
 	var result []object.Object
 
-	for _, e := range exps {
-		evaluated := Eval(e, env)
+	for _, exp := range exps {
+		evaluated := e.eval(exp, env)
 		if isError(evaluated) {
 			return []object.Object{evaluated}
 		}
@@ -232,12 +610,12 @@ func evalExpressions(exps []ast.Expression, env *object.Environment) []object.Ob
 	return result
 }
 
-func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
+func (e *evaluator) evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object {
 	if val, ok := env.Get(node.Value); ok {
 		return val
 	}
 
-	if builtin, ok := builtins[node.Value]; ok {
+	if builtin, ok := e.opts.Builtins.Lookup(node.Value); ok {
 		return builtin
 	}
 
@@ -245,18 +623,18 @@ func evalIdentifier(node *ast.Identifier, env *object.Environment) object.Object
 }
 
 func newError(format string, a ...interface{}) *object.Error {
-	return &object.Error{Message: fmt.Sprintf(format, a...)}
+	return object.NewError(format, a...)
 }
 
-func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
+func (e *evaluator) evalBlockStatement(block *ast.BlockStatement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range block.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		if result != nil {
 			rt := result.Type()
-			if rt == object.ReturnVal || rt == object.TypeError {
+			if rt == object.ReturnVal || rt == object.TypeError || rt == object.BreakVal || rt == object.ContinueVal {
 				return result
 			}
 		}
@@ -265,6 +643,74 @@ func evalBlockStatement(block *ast.BlockStatement, env *object.Environment) obje
 	return result
 }
 
+func (e *evaluator) evalWhileStatement(node *ast.WhileStatement, env *object.Environment) object.Object {
+	for {
+		condition := e.eval(node.Condition, env)
+		if isError(condition) {
+			return condition
+		}
+
+		if !isTruthy(condition) {
+			return objNull
+		}
+
+		if result, done := e.evalLoopBody(node.Body, env); done {
+			return result
+		}
+	}
+}
+
+func (e *evaluator) evalForStatement(node *ast.ForStatement, env *object.Environment) object.Object {
+	iterable := e.eval(node.Iterable, env)
+	if isError(iterable) {
+		return iterable
+	}
+
+	iterator, err := object.NewIterator(iterable)
+	if err != nil {
+		return e.withPosition(newError("%v", err), node.Iterable.Pos())
+	}
+
+	for {
+		var key, value object.Object
+		var ok bool
+
+		if node.Key != nil {
+			key, value, ok = iterator.Next()
+		} else {
+			value, ok = iterator.NextValue()
+		}
+
+		if !ok {
+			return objNull
+		}
+
+		if node.Key != nil {
+			env.Set(node.Key.Value, key)
+		}
+		env.Set(node.Value.Value, value)
+
+		if result, done := e.evalLoopBody(node.Body, env); done {
+			return result
+		}
+	}
+}
+
+// evalLoopBody evaluates a single iteration of a loop and reports whether the loop
+// has to stop, either because of a break statement, a return statement or an error.
+func (e *evaluator) evalLoopBody(body *ast.BlockStatement, env *object.Environment) (object.Object, bool) {
+	switch result := e.eval(body, env).(type) {
+	case *object.LoopControl:
+		if result.Break {
+			return objNull, true
+		}
+	case *object.ReturnValue, *object.Error:
+		return result, true
+	}
+
+	return nil, false
+}
+
 func isError(obj object.Object) bool {
 	if obj != nil {
 		return obj.Type() == object.TypeError
@@ -272,11 +718,11 @@ func isError(obj object.Object) bool {
 	return false
 }
 
-func evalProgram(program *ast.Program, env *object.Environment) object.Object {
+func (e *evaluator) evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range program.Statements {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		switch result := result.(type) {
 		case *object.ReturnValue:
@@ -293,11 +739,11 @@ func evalProgram(program *ast.Program, env *object.Environment) object.Object {
 	return result
 }
 
-func evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
+func (e *evaluator) evalStatements(stmts []ast.Statement, env *object.Environment) object.Object {
 	var result object.Object
 
 	for _, statement := range stmts {
-		result = Eval(statement, env)
+		result = e.eval(statement, env)
 
 		if returnValue, ok := result.(*object.ReturnValue); ok {
 			return returnValue.Value
@@ -307,16 +753,16 @@ func evalStatements(stmts []ast.Statement, env *object.Environment) object.Objec
 	return result
 }
 
-func evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
-	condition := Eval(ie.Condition, env)
+func (e *evaluator) evalIfExpression(ie *ast.IfExpression, env *object.Environment) object.Object {
+	condition := e.eval(ie.Condition, env)
 	if isError(condition) {
 		return condition
 	}
 
 	if isTruthy(condition) {
-		return Eval(ie.Consequence, env)
+		return e.eval(ie.Consequence, env)
 	} else if ie.Alternative != nil {
-		return Eval(ie.Alternative, env)
+		return e.eval(ie.Alternative, env)
 	} else {
 		return objNull
 	}
@@ -367,22 +813,26 @@ func evalBangOperatorExpression(right object.Object) object.Object {
 }
 
 func evalMinusPrefixOperatorExpression(right object.Object) object.Object {
-	if right.Type() != object.TypeInteger {
+	switch right := right.(type) {
+	case *object.Integer:
+		return object.NewInteger(-right.Value)
+	case *object.Float:
+		return object.NewFloat(-right.Value)
+	default:
 		return newError("unknown operator: -%s", right.Type())
 	}
-
-	value := right.(*object.Integer).Value
-	return object.NewInteger(-value)
 }
 
 func evalInfixExpression(operator string, left, right object.Object) object.Object {
 	switch {
 	case left.Type() == object.TypeInteger && right.Type() == object.TypeInteger:
 		return evalIntegerInfixExpression(operator, left, right)
+	case isNumber(left) && isNumber(right):
+		return evalFloatInfixExpression(operator, left, right)
 	case operator == token.OperatorEqual:
-		return nativeBoolToBooleanObject(left == right)
+		return nativeBoolToBooleanObject(object.Equal(left, right))
 	case operator == token.OperatorNotEqual:
-		return nativeBoolToBooleanObject(left != right)
+		return nativeBoolToBooleanObject(!object.Equal(left, right))
 	case left.Type() == object.TypeString && right.Type() == object.TypeString:
 		return evalStringInfixExpression(operator, left, right)
 	case left.Type() != right.Type():
@@ -392,14 +842,93 @@ func evalInfixExpression(operator string, left, right object.Object) object.Obje
 	}
 }
 
+// isLogicalOperator reports whether the operator is && or ||, which evaluate the right
+// operand only if the left one doesn't decide the result. The result is the deciding operand.
+func isLogicalOperator(operator string) bool {
+	return operator == token.OperatorAnd || operator == token.OperatorOr
+}
+
 func evalStringInfixExpression(operator string, left, right object.Object) object.Object {
-	if operator != token.OperatorPlus {
+	leftVal := left.(*object.String).Value
+	rightVal := right.(*object.String).Value
+
+	switch operator {
+	case "+":
+		return object.NewString(leftVal + rightVal)
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	default:
 		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
 	}
+}
 
-	leftVal := left.(*object.String).Value
-	rightVal := right.(*object.String).Value
-	return object.NewString(leftVal + rightVal)
+// evalFloatInfixExpression evaluates expressions with two floats or a float and an integer,
+// which is converted to a float.
+func evalFloatInfixExpression(operator string, left, right object.Object) object.Object {
+	leftVal := toFloat(left)
+	rightVal := toFloat(right)
+
+	switch operator {
+	case "+":
+		return object.NewFloat(leftVal + rightVal)
+	case "-":
+		return object.NewFloat(leftVal - rightVal)
+	case "*":
+		return object.NewFloat(leftVal * rightVal)
+	case "/":
+		return object.NewFloat(leftVal / rightVal)
+	case "%":
+		return object.NewFloat(math.Mod(leftVal, rightVal))
+	case "**":
+		return object.NewFloat(math.Pow(leftVal, rightVal))
+	case "<":
+		return nativeBoolToBooleanObject(leftVal < rightVal)
+	case ">":
+		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
+	case "==":
+		return nativeBoolToBooleanObject(leftVal == rightVal)
+	case "!=":
+		return nativeBoolToBooleanObject(leftVal != rightVal)
+	default:
+		return newError("unknown operator: %s %s %s", left.Type(), operator, right.Type())
+	}
+}
+
+func isNumber(obj object.Object) bool {
+	return obj.Type() == object.TypeInteger || obj.Type() == object.TypeFloat
+}
+
+func toFloat(obj object.Object) float64 {
+	if i, ok := obj.(*object.Integer); ok {
+		return float64(i.Value)
+	}
+
+	return obj.(*object.Float).Value
+}
+
+// intPow raises the base to the non negative exponent by squaring, overflows wrap around
+// just like for the other integer operators.
+func intPow(base, exp int64) int64 {
+	result := int64(1)
+	for exp > 0 {
+		if exp&1 == 1 {
+			result *= base
+		}
+		base *= base
+		exp >>= 1
+	}
+
+	return result
 }
 
 func evalIntegerInfixExpression(operator string, left, right object.Object) object.Object {
@@ -414,11 +943,28 @@ func evalIntegerInfixExpression(operator string, left, right object.Object) obje
 	case "*":
 		return object.NewInteger(leftVal * rightVal)
 	case "/":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
 		return object.NewInteger(leftVal / rightVal)
+	case "%":
+		if rightVal == 0 {
+			return newError("division by zero")
+		}
+		return object.NewInteger(leftVal % rightVal)
+	case "**":
+		if rightVal < 0 {
+			return object.NewFloat(math.Pow(float64(leftVal), float64(rightVal)))
+		}
+		return object.NewInteger(intPow(leftVal, rightVal))
 	case "<":
 		return nativeBoolToBooleanObject(leftVal < rightVal)
 	case ">":
 		return nativeBoolToBooleanObject(leftVal > rightVal)
+	case "<=":
+		return nativeBoolToBooleanObject(leftVal <= rightVal)
+	case ">=":
+		return nativeBoolToBooleanObject(leftVal >= rightVal)
 	case "==":
 		return nativeBoolToBooleanObject(leftVal == rightVal)
 	case "!=":
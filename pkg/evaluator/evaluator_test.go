@@ -1,8 +1,11 @@
 package evaluator
 
 import (
+	"context"
+	"errors"
 	"testing"
 
+	"github.com/adrian83/monkey/pkg/ast"
 	"github.com/adrian83/monkey/pkg/lexer"
 	"github.com/adrian83/monkey/pkg/object"
 	"github.com/adrian83/monkey/pkg/parser"
@@ -28,6 +31,14 @@ func TestEvalIntegerExpression(t *testing.T) {
 		{"3 * 3 * 3 + 10", 37},
 		{"3 * (3 * 3) + 10", 37},
 		{"(5 + 10 * 2 + 15 / 3) * 2 + -10", 50},
+		{"7 % 3", 1},
+		{"-7 % 3", -1},
+		{"2 + 10 % 4 * 3", 8},
+		{"2 ** 10", 1024},
+		{"2 ** 3 ** 2", 512},
+		{"-2 ** 2", -4},
+		{"(-2) ** 3", -8},
+		{"5 ** 0", 1},
 	}
 
 	for _, tt := range tests {
@@ -36,6 +47,41 @@ func TestEvalIntegerExpression(t *testing.T) {
 	}
 }
 
+func TestEvalFloatExpression(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"3.14", "3.14"},
+		{"-2.5", "-2.5"},
+		{"1e-9", "1e-09"},
+		{"1.5 + 1.5", "3.0"},
+		{"1 + 0.5", "1.5"},
+		{"0.5 * 4", "2.0"},
+		{"7 / 2.0", "3.5"},
+		{"1 / 0.0", "+Inf"},
+		{"2.5 - 3", "-0.5"},
+		{"1.5 < 2", "true"},
+		{"2 > 2.5", "false"},
+		{"1 == 1.0", "true"},
+		{"1.0 != 1", "false"},
+		{"[1.5] == [1.5]", "true"},
+		{`{1: "a"}[1.0]`, "a"},
+		{`{1.5: "a"}[1.5]`, "a"},
+		{"-true", "ERROR: unknown operator: -BOOLEAN"},
+		{"1.5 + true", "ERROR: type mismatch: FLOAT + BOOLEAN"},
+		{"1 / 0", "ERROR: division by zero"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", tt.input, tt.expected, inspect(evaluated))
+		}
+	}
+}
+
 func TestEvalBooleanExpression(t *testing.T) {
 	tests := []struct {
 		input    string
@@ -60,6 +106,36 @@ func TestEvalBooleanExpression(t *testing.T) {
 		{"(1 < 2) == false", false},
 		{"(1 > 2) == true", false},
 		{"(1 > 2) == false", true},
+		{`"a" == "a"`, true},
+		{`"a" != "a"`, false},
+		{`"a" == "b"`, false},
+		{`[1, [2, "x"]] == [1, [2, "x"]]`, true},
+		{`[1, 2] == [1, 2, 3]`, false},
+		{`[1, 2] != [2, 1]`, true},
+		{`{"a": [1], 2: true} == {2: true, "a": [1]}`, true},
+		{`{"a": 1} == {"a": 2}`, false},
+		{`{"a": 1} == {"b": 1}`, false},
+		{"if (false) { 1 } == if (false) { 2 }", true},
+		{"1 == true", false},
+		{`"1" != 1`, true},
+		{"let f = fn() { 1 }; f == f", true},
+		{"fn() { 1 } == fn() { 1 }", false},
+		{"len == len", true},
+		{"len == first", false},
+		{"1 <= 2", true},
+		{"2 <= 2", true},
+		{"3 <= 2", false},
+		{"1 >= 2", false},
+		{"2 >= 2", true},
+		{"2.5 >= 2", true},
+		{"1 <= 0.5", false},
+		{`"a" < "b"`, true},
+		{`"b" > "abc"`, true},
+		{`"ab" <= "ab"`, true},
+		{`"ab" >= "b"`, false},
+		{"1 < 2 && 2 < 3", true},
+		{"1 > 2 || 2 > 3", false},
+		{"!(true && false)", true},
 	}
 
 	for _, tt := range tests {
@@ -201,6 +277,7 @@ func TestLetStatements(t *testing.T) {
 		{"let a = 5 * 5; a;", 25},
 		{"let a = 5; let b = a; b;", 5},
 		{"let a = 5; let b = a; let c = a + b + 5; c;", 15},
+		{"// five\nlet a = 5; /* ten\n */ let b = a * 2; // done\nb / /* two */ 2", 5},
 	}
 
 	for _, tt := range tests {
@@ -298,6 +375,16 @@ func TestBuiltinFunctions(t *testing.T) {
 		{`len("")`, 0},
 		{`len("four")`, 4},
 		{`len("hello world")`, 11},
+		{`len("a\tb\n\"\\")`, 6},
+		{"len(`a\\tb`)", 4},
+		{`len("héllo")`, 5},
+		{`len("日本語")`, 3},
+		{`len(bytes("héllo"))`, 6},
+		{`len(runes("héllo"))`, 5},
+		{`bytes("é")[1]`, 169},
+		{`runes("é")[0]`, 233},
+		{`bytes(1)`, "argument to `bytes` must be STRING, got INTEGER"},
+		{`runes("a", "b")`, "wrong number of arguments. got=2, want=1"},
 		{`len(1)`, "argument to `len` not supported, got INTEGER"},
 		{`len("one", "two")`, "wrong number of arguments. got=2, want=1"},
 	}
@@ -323,6 +410,161 @@ func TestBuiltinFunctions(t *testing.T) {
 	}
 }
 
+func TestNumberConversionBuiltins(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"int(3.99)", "3"},
+		{"int(-3.99)", "-3"},
+		{"int(7)", "7"},
+		{`int("42")`, "42"},
+		{`int("4.2")`, `ERROR: could not parse "4.2" as integer`},
+		{"int(1e300)", "ERROR: cannot convert 1e+300 to INTEGER"},
+		{"int(true)", "ERROR: argument to `int` not supported, got BOOLEAN"},
+		{"float(2)", "2.0"},
+		{`float("2.5")`, "2.5"},
+		{`float("x")`, `ERROR: could not parse "x" as float`},
+		{"round(2.5)", "3.0"},
+		{"round(-2.4)", "-2.0"},
+		{"floor(2.7)", "2.0"},
+		{"ceil(2.1)", "3.0"},
+		{"ceil(2)", "2"},
+		{`floor("a")`, "ERROR: argument to `floor` must be INTEGER or FLOAT, got STRING"},
+		{"round(1, 2)", "ERROR: wrong number of arguments. got=2, want=1"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", tt.input, tt.expected, inspect(evaluated))
+		}
+	}
+}
+
+func TestLoops(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let i = 0; while (i < 5) { let i = i + 1; }; i", "5"},
+		{"while (false) { 1 }", "null"},
+		{"let s = 0; for (x in [1, 2, 3]) { let s = s + x; }; s", "6"},
+		{"let s = 0; for (i, x in [5, 5]) { let s = s + i * x; }; s", "5"},
+		{`let s = ""; for (k, v in {"b": 2, "a": 1, 3: 0}) { let s = s + k + ":" + v + " "; }; s`,
+			"ERROR: type mismatch: STRING + INTEGER"},
+		{`let s = ""; for (k, v in {"b": "2", "a": "1"}) { let s = s + k + v; }; s`, "a1b2"},
+		{`let s = ""; for (k in {"b": 2, "a": 1, "c": 0}) { let s = s + k; }; s`, "abc"},
+		{`let s = ""; for (c in "héllo") { if (c == "l") { break; } let s = s + c; }; s`, "hé"},
+		{"let s = 0; for (x in range(10)) { if (x == 5) { continue; } let s = s + x; }; s", "40"},
+		{"let s = []; for (x in range(10, 0, -3)) { let s = push(s, x); }; s", "[10, 7, 4, 1]"},
+		{"let s = 0; for (x in range(3)) { for (y in range(3)) { if (y > x) { break } let s = s + 1; } }; s", "6"},
+		{"let f = fn() { for (x in range(10)) { if (x == 3) { return x * 10; } } 99 }; f()", "30"},
+		{"let f = fn() { while (true) { return 1; } }; f()", "1"},
+		{"let f = fn() { for (x in []) { } }; f()", "null"},
+		{"let n = 0; for (x in range(100000)) { let n = x; }; n", "99999"},
+		{"for (x in 5) { x }", "ERROR: cannot iterate over INTEGER"},
+		{"while (y) { 1 }", "ERROR: identifier not found: y"},
+		{"for (x in [1]) { x + true }", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"len(range(1, 10, 2))", "5"},
+		{"range(1, 2, 0)", "ERROR: step of `range` must not be zero"},
+		{"len(range(0, 9223372036854775807))", "9223372036854775807"},
+		{"let s = []; for (x in range(9223372036854775807, -9223372036854775807 - 1, -9223372036854775807 - 1)) { s = push(s, x) }; s",
+			"[9223372036854775807, -1]"},
+		{"range(-9223372036854775807 - 1, 9223372036854775807)",
+			"ERROR: range(-9223372036854775808, 9223372036854775807) has more than 9223372036854775807 elements"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", tt.input, tt.expected, inspect(evaluated))
+		}
+	}
+}
+
+func TestOperators(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"2 ** -1", "0.5"},
+		{"2.0 ** 3", "8.0"},
+		{"7.5 % 2", "1.5"},
+		{"7 % 0", "ERROR: division by zero"},
+		{"1 && 2", "2"},
+		{"0 && 2", "2"},
+		{"false && 2", "false"},
+		{`"" || "default"`, ""},
+		{"if (false) { 1 } || 5", "5"},
+		{"false || x", "ERROR: identifier not found: x"},
+		{"true || x", "true"},
+		{"false && x", "false"},
+		{"let n = 0; let inc = fn() { n += 1; true }; false && inc(); true || inc(); n", "0"},
+		{"let n = 0; let inc = fn() { n += 1; true }; true && inc(); false || inc(); n", "2"},
+		{"let i = 0; while (i < 10 && i * i < 20) { i += 1 }; i", "5"},
+		{`"a" <= 1`, "ERROR: type mismatch: STRING <= INTEGER"},
+		{`"a" % "b"`, "ERROR: unknown operator: STRING % STRING"},
+		{"true ** 2", "ERROR: type mismatch: BOOLEAN ** INTEGER"},
+		{"let x = 10; x = x % 4; x", "2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", tt.input, tt.expected, inspect(evaluated))
+		}
+	}
+}
+
+func TestAssignments(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"let x = 1; x = 2; x", "2"},
+		{"let x = 1; x = 2", "2"},
+		{"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x", "6"},
+		{`let s = "a"; s += "b"; s`, "ab"},
+		{"let x = 1.5; x *= 2; x", "3.0"},
+		{"let a = 1; let b = 2; a = b = 3; a + b", "6"},
+		{"let i = 0; while (i < 5) { i += 1 }; i", "5"},
+		{"let s = 0; for (x in range(5)) { s += x }; s", "10"},
+		{"let x = 1; let f = fn() { x = 2 }; f(); x", "2"},
+		{"let x = 1; let f = fn() { let x = 5; x = 2 }; f(); x", "1"},
+		{"let f = fn(x) { x += 1; x }; f(1)", "2"},
+		{"let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next(); next()", "3"},
+		{"let f = fn() { let v = 1; let g = fn() { fn() { v *= 10 }() }; g(); v }; f()", "10"},
+		{"let f = fn() { let fs = []; for (i in range(3)) { let fs = push(fs, fn() { i }) }; fs[0]() }; f()", "2"},
+		{"let a = [1, 2, 3]; a[0] = 10; a[2] += 5; a", "[10, 2, 8]"},
+		{"let a = [1]; let b = a; b[0] = 2; a", "[2]"},
+		{`let h = {"k": 1}; h["k"] = 2; h["n"] = 3; [h["k"], h["n"]]`, "[2, 3]"},
+		{`let h = {}; h[1] = "a"; h[1.0] += "b"; h[1]`, "ab"},
+		{"let a = [[1]]; a[0][0] = 5; a", "[[5]]"},
+		{"x = 1", "ERROR: identifier not found: x"},
+		{"let f = fn() { y += 1 }; f()", "ERROR: identifier not found: y"},
+		{"len = 1", "ERROR: cannot assign to builtin: len"},
+		{"let x = 1; x += true", "ERROR: type mismatch: INTEGER + BOOLEAN"},
+		{"let x = 1; x /= 0", "ERROR: division by zero"},
+		{"let a = [1]; a[1] = 2", "ERROR: index out of range: 1"},
+		{`let a = [1]; a["0"] = 2`, "ERROR: array index must be INTEGER, got STRING"},
+		{"let h = {}; h[fn() { 1 }] = 2", "ERROR: unusable as hash key: FUNCTION"},
+		{`let h = {}; h["k"] += 1`, "ERROR: type mismatch: NULL + INTEGER"},
+		{`let s = "ab"; s[0] = "c"`, "ERROR: index assignment not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", tt.input, tt.expected, inspect(evaluated))
+		}
+	}
+}
+
 func TestArrayLiterals(t *testing.T) {
 	input := "[1, 2 * 2, 3 + 3]"
 
@@ -402,6 +644,30 @@ func TestArrayIndexExpressions(t *testing.T) {
 	}
 }
 
+func TestStringIndexExpressions(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{`"abc"[0]`, "a"},
+		{`"héllo"[1]`, "é"},
+		{`"héllo"[4]`, "o"},
+		{`"日本語"[2]`, "語"},
+		{`"abc"[3]`, "null"},
+		{`"abc"[-1]`, "null"},
+		{`let 名前 = "zoë"; 名前[len(名前) - 1]`, "ë"},
+		{`"abc"["a"]`, "ERROR: index operator not supported: STRING"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", tt.input, tt.expected, inspect(evaluated))
+		}
+	}
+}
+
 func TestHashLiterals(t *testing.T) {
 	input := `let two = "two";
 	{
@@ -524,6 +790,15 @@ func testEval(t *testing.T, input string) object.Object {
 	return Eval(program, env)
 }
 
+// inspect formats the object like Inspect does, but without position of errors.
+func inspect(obj object.Object) string {
+	if errObj, ok := obj.(*object.Error); ok {
+		return "ERROR: " + errObj.Message
+	}
+
+	return obj.Inspect()
+}
+
 func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 	result, ok := obj.(*object.Integer)
 	if !ok {
@@ -538,3 +813,251 @@ func testIntegerObject(t *testing.T, obj object.Object, expected int64) bool {
 
 	return true
 }
+
+func TestErrorPositions(t *testing.T) {
+	tests := []struct {
+		input       string
+		expectedPos string
+	}{
+		{"5 + true;", "1:3"},
+		{"let x = 1;\nlet y = -true;", "2:9"},
+		{"let f = fn() {\n  foobar;\n};\nf();", "2:3"},
+		{`len(1)`, "1:1"},
+		{`[1, 2][true]`, "1:7"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		errObj, ok := evaluated.(*object.Error)
+		if !ok {
+			t.Errorf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			continue
+		}
+
+		if errObj.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error position. expected=%q, got=%q", tt.expectedPos, errObj.Pos)
+		}
+	}
+}
+
+func TestErrorStackTrace(t *testing.T) {
+	input := `let inner = fn() {
+  foobar;
+};
+let outer = fn(x) {
+  fn() { inner() }();
+};
+outer(1);`
+
+	evaluated := testEval(t, input)
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	expected := []struct {
+		function string
+		pos      string
+	}{
+		{"inner", "2:3"},
+		{"", "5:10"},
+		{"outer", "5:3"},
+		{object.MainFrame, "7:1"},
+	}
+
+	if len(errObj.Trace) != len(expected) {
+		t.Fatalf("wrong number of frames. expected=%d, got=%d", len(expected), len(errObj.Trace))
+	}
+
+	for i, frame := range expected {
+		if errObj.Trace[i].Function != frame.function || errObj.Trace[i].Pos.String() != frame.pos {
+			t.Errorf("wrong frame %d. expected=%s %s, got=%s %s", i, frame.function, frame.pos, errObj.Trace[i].Function, errObj.Trace[i].Pos)
+		}
+	}
+}
+
+func TestEvalContextLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	tests := map[string]struct {
+		input        string
+		ctx          context.Context
+		opts         Options
+		expectedKind object.ErrorKind
+		expectedMsg  string
+	}{
+		"infinite recursion": {
+			"let f = fn() { f() }; f()",
+			context.Background(),
+			Options{MaxDepth: 100},
+			object.ErrorDepthExceeded,
+			"maximum call depth of 100 exceeded",
+		},
+		"steps": {
+			"let f = fn(x) { if (x > 0) { f(x - 1) } }; f(50)",
+			context.Background(),
+			Options{MaxSteps: 100},
+			object.ErrorBudgetExhausted,
+			"step budget of 100 exhausted",
+		},
+		"allocations": {
+			"let f = fn(x) { if (x > 0) { f(x - 1) } }; f(50)",
+			context.Background(),
+			Options{MaxAllocs: 100},
+			object.ErrorBudgetExhausted,
+			"allocation budget of 100 exhausted",
+		},
+		"cancellation": {
+			"let f = fn(x) { f(x + 1) }; f(0)",
+			cancelled,
+			Options{},
+			object.ErrorCancelled,
+			"evaluation cancelled: context canceled",
+		},
+	}
+
+	for name, tData := range tests {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			program, err := parser.New(lexer.New(data.input)).ParseProgram()
+			if err != nil {
+				t.Fatalf("cannot parse program, error: %v", err)
+			}
+
+			evaluated := EvalContext(data.ctx, program, object.NewEnvironment(), data.opts)
+
+			errObj, ok := evaluated.(*object.Error)
+			if !ok {
+				t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+			}
+
+			if errObj.Kind != data.expectedKind {
+				t.Errorf("wrong error kind. expected=%s, got=%s", data.expectedKind, errObj.Kind)
+			}
+
+			if errObj.Message != data.expectedMsg {
+				t.Errorf("wrong error message. expected=%q, got=%q", data.expectedMsg, errObj.Message)
+			}
+		})
+	}
+}
+
+func TestEvalContextWithinLimits(t *testing.T) {
+	program, err := parser.New(lexer.New("let f = fn(x) { if (x > 0) { f(x - 1) } else { 7 } }; f(10)")).ParseProgram()
+	if err != nil {
+		t.Fatalf("cannot parse program, error: %v", err)
+	}
+
+	opts := Options{MaxDepth: 11, MaxSteps: 1000, MaxAllocs: 1000}
+	testIntegerObject(t, EvalContext(context.Background(), program, object.NewEnvironment(), opts), 7)
+}
+
+func TestHook(t *testing.T) {
+	program, err := parser.New(lexer.New("let f = fn(x) { x * 2 };\nf(3)")).ParseProgram()
+	if err != nil {
+		t.Fatalf("cannot parse program, error: %v", err)
+	}
+
+	var visited []string
+	var depths []int
+	var argument object.Object
+
+	hook := func(n ast.Node, env *object.Environment, calls []Frame) error {
+		visited = append(visited, n.String())
+		depths = append(depths, len(calls))
+
+		if len(calls) > 0 {
+			if calls[0].Function != "f" || calls[0].CallPos.String() != "2:1" || calls[0].Env != env {
+				t.Errorf("wrong frame of the call: %+v", calls[0])
+			}
+			argument, _ = env.Get("x")
+		}
+
+		return nil
+	}
+
+	testIntegerObject(t, EvalContext(context.Background(), program, object.NewEnvironment(), Options{Hook: hook}), 6)
+	testIntegerObject(t, argument, 3)
+
+	expected := []string{"let f = fn(x) (x * 2);", "fn(x) (x * 2)", "f(3)", "f(3)", "f", "3", "(x * 2)", "(x * 2)", "(x * 2)", "x", "2"}
+	expectedDepths := []int{0, 0, 0, 0, 0, 0, 1, 1, 1, 1, 1}
+
+	if len(visited) != len(expected) {
+		t.Fatalf("wrong visited nodes. expected=%q, got=%q", expected, visited)
+	}
+
+	for i := range expected {
+		if visited[i] != expected[i] || depths[i] != expectedDepths[i] {
+			t.Errorf("wrong node %d. expected=%q at depth %d, got=%q at depth %d", i, expected[i], expectedDepths[i], visited[i], depths[i])
+		}
+	}
+}
+
+func TestHookError(t *testing.T) {
+	program, err := parser.New(lexer.New("let x = 1;\nputs(x);")).ParseProgram()
+	if err != nil {
+		t.Fatalf("cannot parse program, error: %v", err)
+	}
+
+	hook := func(n ast.Node, env *object.Environment, calls []Frame) error {
+		if n.Pos().Line == 2 {
+			return errors.New("stopped")
+		}
+		return nil
+	}
+
+	env := object.NewEnvironment()
+	evaluated := EvalContext(context.Background(), program, env, Options{Hook: hook})
+
+	errObj, ok := evaluated.(*object.Error)
+	if !ok {
+		t.Fatalf("no error object returned. got=%T(%+v)", evaluated, evaluated)
+	}
+
+	if errObj.Kind != object.ErrorCancelled || errObj.Message != "stopped" || errObj.Pos.String() != "2:1" {
+		t.Errorf("wrong error. got=%s %q at %s", errObj.Kind, errObj.Message, errObj.Pos)
+	}
+
+	if _, ok := env.Get("x"); !ok {
+		t.Errorf("statement before the error not evaluated")
+	}
+}
+
+func TestEvalProgramDecodedFromJSON(t *testing.T) {
+	inputs := []string{
+		`let fib = fn(n) { if (n < 2) { return n; } fib(n - 1) + fib(n - 2) }; fib(15)`,
+		`let h = {"a": [1, 2.5, true], 3: "x"}; h["a"][1] * 2 + len(h[3])`,
+		`let s = 0; for (i, x in [4, 5, 6]) { if (x == 5) { continue; } s += i * x; }; s`,
+		`let i = 0; while (true) { i = i + 1; if (i ** 2 >= 50 || i % 100 == 0) { break; } }; [i, -i, !true]`,
+		`let f = fn(x) { quote(unquote(x) + 1) }; f(2)`,
+		`"unterminated" + 1`,
+	}
+
+	for _, input := range inputs {
+		program, err := parser.New(lexer.New(input)).ParseProgram()
+		if err != nil {
+			t.Fatalf("cannot parse program, error: %v", err)
+		}
+
+		data, err := ast.MarshalJSON(program)
+		if err != nil {
+			t.Fatalf("cannot marshal %q: %v", input, err)
+		}
+
+		decoded, err := ast.UnmarshalJSON(data)
+		if err != nil {
+			t.Fatalf("cannot unmarshal %q: %v", input, err)
+		}
+
+		expected := Eval(program, object.NewEnvironment())
+		actual := Eval(decoded, object.NewEnvironment())
+
+		if expected.Inspect() != actual.Inspect() {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", input, expected.Inspect(), actual.Inspect())
+		}
+	}
+}
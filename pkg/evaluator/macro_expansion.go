@@ -0,0 +1,109 @@
+package evaluator
+
+import (
+	"context"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/object"
+)
+
+// DefineMacros binds the macros defined by top level let statements in the environment
+// and removes their definitions from the program.
+func DefineMacros(program *ast.Program, env *object.Environment) {
+	statements := program.Statements[:0]
+
+	for _, stmt := range program.Statements {
+		let, ok := stmt.(*ast.LetStatement)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		literal, ok := let.Value.(*ast.MacroLiteral)
+		if !ok {
+			statements = append(statements, stmt)
+			continue
+		}
+
+		env.Set(let.Name.Value, &object.Macro{Parameters: literal.Parameters, Body: literal.Body, Env: env})
+	}
+
+	program.Statements = statements
+}
+
+// ExpandMacros replaces calls of the macros defined in the environment with the quoted code
+// returned by the macros. Arguments are passed to the macros quoted, without being evaluated.
+func ExpandMacros(program ast.Node, env *object.Environment) (ast.Node, *object.Error) {
+	return ExpandMacrosContext(context.Background(), program, env, Options{})
+}
+
+// ExpandMacrosContext expands the macros like ExpandMacros, the evaluation of the macros
+// is restricted by the context and the options like in EvalContext.
+func ExpandMacrosContext(ctx context.Context, program ast.Node, env *object.Environment, opts Options) (ast.Node, *object.Error) {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	e := newEvaluator(ctx, opts)
+
+	var failure *object.Error
+	expanded := ast.Modify(program, func(node ast.Node) ast.Node {
+		call, ok := node.(*ast.CallExpression)
+		if !ok || failure != nil {
+			return node
+		}
+
+		macro, ok := macroOf(call, env)
+		if !ok {
+			return node
+		}
+
+		result := e.expandMacro(macro, call)
+		if err, ok := result.(*object.Error); ok {
+			failure = err
+			return node
+		}
+
+		quote, ok := result.(*object.Quote)
+		if !ok {
+			failure = e.withPosition(newError("macro %s must return QUOTE, got %s", call.Function.String(), result.Type()), call.Pos()).(*object.Error)
+			return node
+		}
+
+		return quote.Node
+	})
+
+	return expanded, failure
+}
+
+func macroOf(call *ast.CallExpression, env *object.Environment) (*object.Macro, bool) {
+	ident, ok := call.Function.(*ast.Identifier)
+	if !ok {
+		return nil, false
+	}
+
+	obj, ok := env.Get(ident.Value)
+	if !ok {
+		return nil, false
+	}
+
+	macro, ok := obj.(*object.Macro)
+	return macro, ok
+}
+
+func (e *evaluator) expandMacro(macro *object.Macro, call *ast.CallExpression) object.Object {
+	if len(call.Arguments) != len(macro.Parameters) {
+		return e.withPosition(newError("wrong number of arguments: want=%d, got=%d", len(macro.Parameters), len(call.Arguments)), call.Pos())
+	}
+
+	env := object.NewEnclosedEnvironment(macro.Env)
+	for i, param := range macro.Parameters {
+		env.Set(param.Value, &object.Quote{Node: call.Arguments[i]})
+	}
+
+	e.stack = append(e.stack, Frame{Function: call.Function.String(), CallPos: call.Pos(), Env: env})
+	result := unwrapReturnValue(e.eval(macro.Body, env))
+	e.stack = e.stack[:len(e.stack)-1]
+
+	return e.withPosition(result, call.Pos())
+}
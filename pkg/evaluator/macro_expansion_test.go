@@ -0,0 +1,148 @@
+package evaluator
+
+import (
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+)
+
+func TestDefineMacros(t *testing.T) {
+	input := `
+	let number = 1;
+	let function = fn(x, y) { x + y };
+	let mymacro = macro(x, y) { x + y; };
+	`
+
+	env := object.NewEnvironment()
+	program := testParseProgram(t, input)
+
+	DefineMacros(program, env)
+
+	if len(program.Statements) != 2 {
+		t.Fatalf("wrong number of statements. got=%d", len(program.Statements))
+	}
+
+	if _, ok := env.Get("number"); ok {
+		t.Fatalf("number should not be defined")
+	}
+	if _, ok := env.Get("function"); ok {
+		t.Fatalf("function should not be defined")
+	}
+
+	obj, ok := env.Get("mymacro")
+	if !ok {
+		t.Fatalf("macro not in environment.")
+	}
+
+	macro, ok := obj.(*object.Macro)
+	if !ok {
+		t.Fatalf("object is not Macro. got=%T (%+v)", obj, obj)
+	}
+
+	if len(macro.Parameters) != 2 || macro.Parameters[0].String() != "x" || macro.Parameters[1].String() != "y" {
+		t.Fatalf("wrong macro parameters. got=%v", macro.Parameters)
+	}
+
+	if macro.Body.String() != "(x + y)" {
+		t.Fatalf("body is not %q. got=%q", "(x + y)", macro.Body.String())
+	}
+}
+
+func TestExpandMacros(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{
+			`let infixExpression = macro() { quote(1 + 2); }; infixExpression();`,
+			`(1 + 2)`,
+		},
+		{
+			`let reverse = macro(a, b) { quote(unquote(b) - unquote(a)); }; reverse(2 + 2, 10 - 5);`,
+			`(10 - 5) - (2 + 2)`,
+		},
+		{
+			`
+			let unless = macro(condition, consequence, alternative) {
+				quote(if (!(unquote(condition))) {
+					unquote(consequence);
+				} else {
+					unquote(alternative);
+				});
+			};
+
+			unless(10 > 5, puts("not greater"), puts("greater"));
+			`,
+			`if (!(10 > 5)) { puts("not greater") } else { puts("greater") }`,
+		},
+		{
+			`let twice = macro(x) { quote(unquote(x) + unquote(x)) }; let f = fn() { twice(y) };`,
+			`let f = fn() { y + y };`,
+		},
+	}
+
+	for _, tt := range tests {
+		expected := testParseProgram(t, tt.expected)
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+
+		expanded, err := ExpandMacros(program, env)
+		if err != nil {
+			t.Fatalf("expansion of %q failed: %s", tt.input, err.Inspect())
+		}
+
+		if expanded.String() != expected.String() {
+			t.Errorf("not equal. want=%q, got=%q", expected.String(), expanded.String())
+		}
+	}
+}
+
+func TestExpandMacrosErrors(t *testing.T) {
+	tests := []struct {
+		input       string
+		expected    string
+		expectedPos string
+	}{
+		{"let m = macro(x) { 1 };\nm(2);", "macro m must return QUOTE, got INTEGER", "2:1"},
+		{"let m = macro(x) { quote(x) };\nlet a = m();", "wrong number of arguments: want=1, got=0", "2:9"},
+		{"let m = macro() {\n  foo\n};\nm();", "identifier not found: foo", "2:3"},
+	}
+
+	for _, tt := range tests {
+		program := testParseProgram(t, tt.input)
+
+		env := object.NewEnvironment()
+		DefineMacros(program, env)
+
+		_, err := ExpandMacros(program, env)
+		if err == nil {
+			t.Fatalf("expansion of %q should fail", tt.input)
+		}
+
+		if err.Message != tt.expected || err.Pos.String() != tt.expectedPos {
+			t.Errorf("wrong error of %q. want=%s: %q, got=%s: %q", tt.input, tt.expectedPos, tt.expected, err.Pos, err.Message)
+		}
+	}
+}
+
+func TestEvalMacroLiteral(t *testing.T) {
+	evaluated := testEval(t, "let f = fn() { let m = macro() { quote(1) }; }; f()")
+
+	if inspect(evaluated) != "ERROR: macro can be defined only by a top level let statement" {
+		t.Errorf("wrong result. got=%q", inspect(evaluated))
+	}
+}
+
+func testParseProgram(t *testing.T, input string) *ast.Program {
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("cannot parse program, error: %v", err)
+	}
+
+	return program
+}
@@ -0,0 +1,128 @@
+package evaluator
+
+import (
+	"strconv"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+// quote and unquote are not builtins, their arguments are not evaluated before the call.
+const (
+	quoteName   = "quote"
+	unquoteName = "unquote"
+)
+
+// isCallOf reports whether the node is a call of the function with the given name.
+func isCallOf(node ast.Node, name string) bool {
+	call, ok := node.(*ast.CallExpression)
+	if !ok {
+		return false
+	}
+
+	ident, ok := call.Function.(*ast.Identifier)
+	return ok && ident.Value == name
+}
+
+// quote returns the argument of the call unevaluated, except for the arguments of unquote calls
+// which are evaluated and replaced with the code of their values.
+func (e *evaluator) quote(call *ast.CallExpression, env *object.Environment) object.Object {
+	if len(call.Arguments) != 1 {
+		return newError("wrong number of arguments to `%s`: want=1, got=%d", quoteName, len(call.Arguments))
+	}
+
+	// the quoted code is modified, so it must not be shared with the function which may run again
+	quoted := ast.Copy(call.Arguments[0])
+
+	var failure object.Object
+	node := ast.Modify(quoted, func(node ast.Node) ast.Node {
+		if failure != nil || !isCallOf(node, unquoteName) {
+			return node
+		}
+
+		unquote := node.(*ast.CallExpression)
+		if len(unquote.Arguments) != 1 {
+			failure = e.withPosition(newError("wrong number of arguments to `%s`: want=1, got=%d", unquoteName, len(unquote.Arguments)), unquote.Pos())
+			return node
+		}
+
+		value := e.eval(unquote.Arguments[0], env)
+		if isError(value) {
+			failure = value
+			return node
+		}
+
+		if object.ContainsItself(value) {
+			failure = e.withPosition(newError("cannot unquote %s which contains itself", value.Type()), unquote.Pos())
+			return node
+		}
+
+		converted := objectToNode(value, unquote.NodeToken())
+		if converted == nil {
+			failure = e.withPosition(newError("cannot unquote %s", value.Type()), unquote.Pos())
+			return node
+		}
+
+		return converted
+	})
+
+	if failure != nil {
+		return failure
+	}
+
+	return &object.Quote{Node: node}
+}
+
+// objectToNode returns the expression which evaluates to the object or nil if there isn't any.
+// The created tokens have the position of the given token.
+func objectToNode(obj object.Object, at token.Token) ast.Node {
+	tok := func(tokenType token.TokenType, literal string) token.Token {
+		return token.Token{Type: tokenType, Literal: literal, Pos: at.Pos, End: at.End}
+	}
+
+	switch obj := obj.(type) {
+	case *object.Integer:
+		return &ast.IntegerLiteral{Token: tok(token.TypeInteger, strconv.FormatInt(obj.Value, 10)), Value: obj.Value}
+
+	case *object.Float:
+		return &ast.FloatLiteral{Token: tok(token.TypeFloat, strconv.FormatFloat(obj.Value, 'g', -1, 64)), Value: obj.Value}
+
+	case *object.String:
+		return &ast.StringLiteral{Token: tok(token.TypeString, obj.Value), Value: obj.Value}
+
+	case *object.Boolean:
+		if obj.Value {
+			return &ast.BooleanLiteral{Token: tok(token.KeywordTrue, "true"), Value: true}
+		}
+		return &ast.BooleanLiteral{Token: tok(token.KeywordFalse, "false"), Value: false}
+
+	case *object.Quote:
+		return ast.Copy(obj.Node)
+
+	case *object.Array:
+		array := &ast.ArrayLiteral{Token: tok(token.DelimiterLeftBracket, "[")}
+		for _, element := range obj.Elements {
+			node := objectToNode(element, at)
+			if node == nil {
+				return nil
+			}
+			array.Elements = append(array.Elements, node)
+		}
+		return array
+
+	case *object.Hash:
+		hash := &ast.HashLiteral{Token: tok(token.DelimiterLeftBrace, "{"), Pairs: map[ast.Expression]ast.Expression{}}
+		for _, pair := range object.SortedPairs(obj) {
+			key, value := objectToNode(pair.Key, at), objectToNode(pair.Value, at)
+			if key == nil || value == nil {
+				return nil
+			}
+			hash.Pairs[key] = value
+		}
+		return hash
+
+	default:
+		return nil
+	}
+}
@@ -0,0 +1,73 @@
+package evaluator
+
+import (
+	"testing"
+)
+
+func TestQuote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(5)", "QUOTE(5)"},
+		{"quote(5 + 8)", "QUOTE((5 + 8))"},
+		{"quote(foobar)", "QUOTE(foobar)"},
+		{"quote(foobar + barfoo)", "QUOTE((foobar + barfoo))"},
+		{"quote(fn(x) { x })", "QUOTE(fn(x) x)"},
+		{"quote()", "ERROR: wrong number of arguments to `quote`: want=1, got=0"},
+		{"quote(1, 2)", "ERROR: wrong number of arguments to `quote`: want=1, got=2"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", tt.input, tt.expected, inspect(evaluated))
+		}
+	}
+}
+
+func TestQuoteUnquote(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected string
+	}{
+		{"quote(unquote(4))", "QUOTE(4)"},
+		{"quote(unquote(4 + 4))", "QUOTE(8)"},
+		{"quote(8 + unquote(4 + 4))", "QUOTE((8 + 8))"},
+		{"quote(unquote(4 + 4) + 8)", "QUOTE((8 + 8))"},
+		{"let foobar = 8; quote(foobar)", "QUOTE(foobar)"},
+		{"let foobar = 8; quote(unquote(foobar))", "QUOTE(8)"},
+		{"quote(unquote(true))", "QUOTE(true)"},
+		{"quote(unquote(true == false))", "QUOTE(false)"},
+		{"quote(unquote(2.5 * 2.0))", "QUOTE(5)"},
+		{`quote(unquote("a" + "b"))`, "QUOTE(ab)"},
+		{"quote(unquote([1, 1 + 1]))", "QUOTE([1, 2])"},
+		{`quote(unquote({"a": 1}))`, "QUOTE({a:1})"},
+		{"quote(unquote(quote(4 + 4)))", "QUOTE((4 + 4))"},
+		{"let quotedInfixExpression = quote(4 + 4); quote(unquote(4 + 4) + unquote(quotedInfixExpression))", "QUOTE((8 + (4 + 4)))"},
+		{"quote(unquote(fn() { 1 }))", "ERROR: cannot unquote FUNCTION"},
+		{"let a = [1]; a[0] = a; quote(unquote(a))", "ERROR: cannot unquote ARRAY which contains itself"},
+		{"quote(unquote(foo))", "ERROR: identifier not found: foo"},
+		{"quote(unquote(1, 2))", "ERROR: wrong number of arguments to `unquote`: want=1, got=2"},
+		{"unquote(1)", "ERROR: identifier not found: unquote"},
+	}
+
+	for _, tt := range tests {
+		evaluated := testEval(t, tt.input)
+
+		if inspect(evaluated) != tt.expected {
+			t.Errorf("wrong result of %q. expected=%q, got=%q", tt.input, tt.expected, inspect(evaluated))
+		}
+	}
+}
+
+func TestQuoteDoesNotModifyFunctionBody(t *testing.T) {
+	input := "let f = fn(x) { quote(unquote(x) + 1) }; f(1); f(2)"
+
+	evaluated := testEval(t, input)
+
+	if inspect(evaluated) != "QUOTE((2 + 1))" {
+		t.Errorf("wrong result of %q. got=%q", input, inspect(evaluated))
+	}
+}
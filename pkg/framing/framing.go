@@ -0,0 +1,66 @@
+// Package framing reads and writes messages preceded by the Content-Length header,
+// like the Language Server Protocol and the Debug Adapter Protocol do over stdio.
+package framing
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+type Reader struct {
+	in *textproto.Reader
+}
+
+func NewReader(in io.Reader) *Reader {
+	return &Reader{in: textproto.NewReader(bufio.NewReader(in))}
+}
+
+// Read returns the body of the next message, io.EOF is returned if the input ends between messages.
+func (r *Reader) Read() ([]byte, error) {
+	header, err := r.in.ReadMIMEHeader()
+	if err == io.EOF && len(header) == 0 {
+		return nil, io.EOF
+	}
+	if err != nil {
+		return nil, fmt.Errorf("invalid header: %v", err)
+	}
+
+	length, err := strconv.Atoi(strings.TrimSpace(header.Get("Content-Length")))
+	if err != nil || length < 0 {
+		return nil, fmt.Errorf("invalid Content-Length: %q", header.Get("Content-Length"))
+	}
+
+	body := make([]byte, length)
+	if _, err := io.ReadFull(r.in.R, body); err != nil {
+		return nil, err
+	}
+
+	return body, nil
+}
+
+// Writer writes whole messages, it may be used concurrently.
+type Writer struct {
+	mu  sync.Mutex
+	out io.Writer
+}
+
+func NewWriter(out io.Writer) *Writer {
+	return &Writer{out: out}
+}
+
+func (w *Writer) Write(body []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if _, err := fmt.Fprintf(w.out, "Content-Length: %d\r\n\r\n", len(body)); err != nil {
+		return err
+	}
+
+	_, err := w.out.Write(body)
+	return err
+}
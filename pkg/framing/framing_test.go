@@ -0,0 +1,53 @@
+package framing
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReadWrite(t *testing.T) {
+	var buf bytes.Buffer
+	w := NewWriter(&buf)
+
+	assert.NoError(t, w.Write([]byte(`{"a":1}`)))
+	assert.NoError(t, w.Write([]byte(`ąę`)))
+	assert.Equal(t, "Content-Length: 7\r\n\r\n{\"a\":1}Content-Length: 4\r\n\r\nąę", buf.String())
+
+	r := NewReader(&buf)
+
+	body, err := r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, `{"a":1}`, string(body))
+
+	body, err = r.Read()
+	assert.NoError(t, err)
+	assert.Equal(t, "ąę", string(body))
+
+	_, err = r.Read()
+	assert.Equal(t, io.EOF, err)
+}
+
+func TestReadErrors(t *testing.T) {
+	testData := map[string]struct {
+		input string
+		err   string
+	}{
+		"missing length":   {"Content-Type: json\r\n\r\n{}", `invalid Content-Length: ""`},
+		"negative length":  {"Content-Length: -1\r\n\r\n{}", `invalid Content-Length: "-1"`},
+		"truncated body":   {"Content-Length: 10\r\n\r\n{}", "unexpected EOF"},
+		"truncated header": {"Content-Length: 10\r\n", "invalid header: EOF"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			_, err := NewReader(strings.NewReader(data.input)).Read()
+			assert.EqualError(t, err, data.err)
+		})
+	}
+}
@@ -1,63 +1,171 @@
 package lexer
 
 import (
-	"github.com/adrian83/monkey/pkg/token"
-)
+	"fmt"
+	"strconv"
+	"strings"
+	"unicode/utf8"
 
-const (
-	lowerCaseA = 'a'
-	upperCaseA = 'A'
-	lowerCaseZ = 'z'
-	upperCaseZ = 'Z'
+	"github.com/adrian83/monkey/pkg/token"
 )
 
 func New(input string) *Lexer {
+	return NewFile("", input)
+}
+
+// NewFile creates a lexer which puts the name of the file into positions of the tokens.
+func NewFile(filename, input string) *Lexer {
 	l := &Lexer{
-		input: input,
+		filename: filename,
+		input:    input,
+		line:     1,
 	}
 	l.readChar()
 	return l
 }
 
+// Mode controls which tokens the lexer returns.
+type Mode uint
+
+const (
+	// ScanComments makes the lexer return comments as Comment tokens, they are skipped otherwise.
+	ScanComments Mode = 1 << iota
+)
+
+// ErrorHandler is called for every problem found in the input, tok is the token
+// containing the problem (an Illegal token if the token couldn't be read at all).
+type ErrorHandler func(tok token.Token, pos token.Position, msg string)
+
 type Lexer struct {
+	filename     string
 	input        string
 	position     int  // current position in input (points to current char)
 	readPosition int  // current reading position in input (after current char)
-	ch           byte // current char under examination
+	ch           rune // current char under examination
+	line         int  // line of the current char
+	column       int  // column of the current char
+
+	mode         Mode
+	errorHandler ErrorHandler
+	errors       []lexerError // problems found in the token being read
+}
+
+type lexerError struct {
+	pos token.Position
+	msg string
+}
+
+func (l *Lexer) SetMode(mode Mode) {
+	l.mode = mode
+}
+
+// SetErrorHandler sets the function called for problems found in the input, e.g. unterminated strings.
+func (l *Lexer) SetErrorHandler(handler ErrorHandler) {
+	l.errorHandler = handler
+}
+
+func (l *Lexer) errorAt(pos token.Position, format string, a ...interface{}) {
+	l.errors = append(l.errors, lexerError{pos: pos, msg: fmt.Sprintf(format, a...)})
 }
 
 func (l *Lexer) readChar() {
+	if l.readPosition > len(l.input) {
+		return
+	}
+
+	if l.ch == '\n' {
+		l.line++
+		l.column = 0
+	}
+
+	width := 1
 	if l.readPosition >= len(l.input) {
 		l.ch = 0
 	} else {
-		l.ch = l.input[l.readPosition]
+		l.ch, width = utf8.DecodeRuneInString(l.input[l.readPosition:])
 	}
 	l.position = l.readPosition
-	l.readPosition++
+	l.readPosition += width
+	l.column++
+}
+
+func (l *Lexer) currentPosition() token.Position {
+	return token.Position{
+		Filename: l.filename,
+		Offset:   l.position,
+		Line:     l.line,
+		Column:   l.column,
+	}
 }
 
 func (l *Lexer) NextToken() token.Token {
 	var tok token.Token
 
-	l.skipWhitespace()
+	for {
+		l.skipWhitespace()
+
+		start := l.currentPosition()
+		tok = l.nextToken()
+		tok.Pos = start
+		tok.End = l.currentPosition()
+
+		if tok.Type != token.Comment || l.mode&ScanComments != 0 {
+			break
+		}
+	}
+
+	for _, err := range l.errors {
+		if l.errorHandler != nil {
+			l.errorHandler(tok, err.pos, err.msg)
+		}
+	}
+	l.errors = nil
+
+	return tok
+}
+
+func (l *Lexer) nextToken() token.Token {
+	var tok token.Token
 
 	switch l.ch {
 	case '"':
-		tok.Type = token.TypeString
-		tok.Literal = l.readString()
+		tok = l.readString()
+	case '`':
+		tok = l.readRawString()
 
 	case '+':
-		tok = newToken(token.OperatorPlus, l.ch)
+		tok = l.operatorOrAssign(token.OperatorPlus, token.OperatorPlusAssign)
 	case '-':
-		tok = newToken(token.OperatorMinus, l.ch)
+		tok = l.operatorOrAssign(token.OperatorMinus, token.OperatorMinusAssign)
+	case '#':
+		if l.position == 0 && l.peekChar() == '!' {
+			// the shebang line of an executable script
+			tok = l.readLineComment()
+		} else {
+			tok = l.illegal()
+		}
 	case '/':
-		tok = newToken(token.OperatorSlash, l.ch)
+		if l.peekChar() == '/' || l.peekChar() == '*' {
+			tok = l.readComment()
+		} else {
+			tok = l.operatorOrAssign(token.OperatorSlash, token.OperatorSlashAssign)
+		}
 	case '*':
-		tok = newToken(token.OperatorAsterisk, l.ch)
+		if l.peekChar() == '*' {
+			tok = l.twoCharOperator('*', token.OperatorPower, token.OperatorAsterisk)
+		} else {
+			tok = l.operatorOrAssign(token.OperatorAsterisk, token.OperatorAsteriskAssign)
+		}
+	case '%':
+		tok = newToken(token.OperatorPercent, l.ch)
 	case '<':
-		tok = newToken(token.OperatorLowerThan, l.ch)
+		tok = l.operatorOrAssign(token.OperatorLowerThan, token.OperatorLowerEqual)
 	case '>':
-		tok = newToken(token.OperatorGreaterThan, l.ch)
+		tok = l.operatorOrAssign(token.OperatorGreaterThan, token.OperatorGreaterEqual)
+	case '&':
+		tok = l.doubledOperator(token.OperatorAnd)
+	case '|':
+		tok = l.doubledOperator(token.OperatorOr)
 	case ';':
 		tok = newToken(token.DelimiterSemicolon, l.ch)
 	case '(':
@@ -81,34 +189,19 @@ func (l *Lexer) NextToken() token.Token {
 		tok.Type = token.Eof
 
 	case '=':
-		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.OperatorEqual, Literal: literal}
-		} else {
-			tok = newToken(token.OperatorAssign, l.ch)
-		}
+		tok = l.operatorOrAssign(token.OperatorAssign, token.OperatorEqual)
 	case '!':
-		if l.peekChar() == '=' {
-			ch := l.ch
-			l.readChar()
-			literal := string(ch) + string(l.ch)
-			tok = token.Token{Type: token.OperatorNotEqual, Literal: literal}
-		} else {
-			tok = newToken(token.OperatorBang, l.ch)
-		}
+		tok = l.operatorOrAssign(token.OperatorBang, token.OperatorNotEqual)
 	default:
-		if isLetter(l.ch) {
+		if token.IsLetter(l.ch) {
 			tok.Literal = l.readIdentifier()
 			tok.Type = token.LookupIdent(tok.Literal)
 			return tok
 		} else if isDigit(l.ch) {
-			tok.Type = token.TypeInteger
-			tok.Literal = l.readNumber()
+			tok.Literal, tok.Type = l.readNumber()
 			return tok
 		} else {
-			tok = newToken(token.Illegal, l.ch)
+			tok = l.illegal()
 		}
 	}
 
@@ -116,60 +209,255 @@ func (l *Lexer) NextToken() token.Token {
 	return tok
 }
 
-func newToken(tokenType token.TokenType, ch byte) token.Token {
+func (l *Lexer) illegal() token.Token {
+	if l.ch == utf8.RuneError {
+		l.errorAt(l.currentPosition(), "invalid UTF-8 encoding")
+	} else {
+		l.errorAt(l.currentPosition(), "illegal character %q", l.ch)
+	}
+
+	return token.Token{Type: token.Illegal, Literal: l.input[l.position:l.readPosition]}
+}
+
+// operatorOrAssign returns the assign token (e.g. += or <=) if the operator is followed by '='.
+func (l *Lexer) operatorOrAssign(operator, assign token.TokenType) token.Token {
+	return l.twoCharOperator('=', assign, operator)
+}
+
+// twoCharOperator returns the operator if the current character is followed by the second one,
+// otherwise a token of the fallback type made of the current character.
+func (l *Lexer) twoCharOperator(second rune, operator, fallback token.TokenType) token.Token {
+	if l.peekChar() != second {
+		return newToken(fallback, l.ch)
+	}
+
+	ch := l.ch
+	l.readChar()
+	return token.Token{Type: operator, Literal: string(ch) + string(l.ch)}
+}
+
+// doubledOperator returns the operator made of the current character repeated (&& or ||), the single
+// character is illegal.
+func (l *Lexer) doubledOperator(operator token.TokenType) token.Token {
+	if l.peekChar() != l.ch {
+		return l.illegal()
+	}
+
+	return l.twoCharOperator(l.ch, operator, token.Illegal)
+}
+
+func newToken(tokenType token.TokenType, ch rune) token.Token {
 	return token.Token{
 		Type:    tokenType,
 		Literal: string(ch),
 	}
 }
 
-func (l *Lexer) readString() string {
-	position := l.position + 1
+// readString reads a string in double quotes and replaces escape sequences with the characters
+// they represent. An unterminated string is returned as an Illegal token.
+func (l *Lexer) readString() token.Token {
+	start := l.currentPosition()
+	var value strings.Builder
+
 	for {
 		l.readChar()
-		if l.ch == '"' || l.ch == 0 {
-			break
+
+		switch l.ch {
+		case '"':
+			return token.Token{Type: token.TypeString, Literal: value.String()}
+		case 0:
+			l.errorAt(start, "unterminated string")
+			return token.Token{Type: token.Illegal, Literal: l.input[start.Offset:l.position]}
+		case '\\':
+			l.readEscape(&value)
+		default:
+			// invalid UTF-8 is kept as it is
+			value.WriteString(l.input[l.position:l.readPosition])
 		}
 	}
-	return l.input[position:l.position]
+}
+
+func (l *Lexer) readEscape(value *strings.Builder) {
+	pos := l.currentPosition()
+	l.readChar()
+
+	switch l.ch {
+	case 'n':
+		value.WriteByte('\n')
+	case 't':
+		value.WriteByte('\t')
+	case 'r':
+		value.WriteByte('\r')
+	case '"', '\\':
+		value.WriteRune(l.ch)
+	case 'u':
+		l.readUnicodeEscape(pos, value)
+	case 0:
+		// the string is unterminated
+	default:
+		l.errorAt(pos, "invalid escape sequence \\%c", l.ch)
+		value.WriteByte('\\')
+		value.WriteString(l.input[l.position:l.readPosition])
+	}
+}
+
+// readUnicodeEscape reads the code point of \u{...}, which is written as 1 to 6 hex digits.
+func (l *Lexer) readUnicodeEscape(pos token.Position, value *strings.Builder) {
+	if l.peekChar() != '{' {
+		l.errorAt(pos, "invalid unicode escape, expected \\u{...}")
+		return
+	}
+	l.readChar()
+
+	start := l.readPosition
+	for isHexDigit(l.peekChar()) {
+		l.readChar()
+	}
+	digits := l.input[start:l.readPosition]
+
+	if l.peekChar() != '}' || len(digits) == 0 || len(digits) > 6 {
+		l.errorAt(pos, "invalid unicode escape, expected \\u{...} with 1 to 6 hex digits")
+		return
+	}
+	l.readChar()
+
+	code, _ := strconv.ParseUint(digits, 16, 32)
+	if !utf8.ValidRune(rune(code)) {
+		l.errorAt(pos, "invalid unicode code point U+%s", strings.ToUpper(digits))
+		return
+	}
+
+	value.WriteRune(rune(code))
+}
+
+// readRawString reads a string in backticks, which may span multiple lines and contain no escapes.
+func (l *Lexer) readRawString() token.Token {
+	start := l.currentPosition()
+
+	for {
+		l.readChar()
+
+		switch l.ch {
+		case '`':
+			return token.Token{Type: token.TypeString, Literal: l.input[start.Offset+1 : l.position]}
+		case 0:
+			l.errorAt(start, "unterminated raw string")
+			return token.Token{Type: token.Illegal, Literal: l.input[start.Offset:l.position]}
+		}
+	}
+}
+
+// readComment reads a // comment up to the end of the line or a /* */ comment,
+// the literal of the token includes the comment markers.
+func (l *Lexer) readComment() token.Token {
+	start := l.currentPosition()
+
+	if l.peekChar() == '/' {
+		return l.readLineComment()
+	}
+
+	l.readChar()
+	l.readChar()
+
+	for l.ch != '*' || l.peekChar() != '/' {
+		if l.ch == 0 {
+			l.errorAt(start, "unterminated comment")
+			return token.Token{Type: token.Comment, Literal: l.input[start.Offset:l.position]}
+		}
+		l.readChar()
+	}
+	l.readChar()
+
+	return token.Token{Type: token.Comment, Literal: l.input[start.Offset:l.readPosition]}
+}
+
+// readLineComment reads a comment up to the end of the line.
+func (l *Lexer) readLineComment() token.Token {
+	start := l.position
+
+	for l.peekChar() != '\n' && l.peekChar() != 0 {
+		l.readChar()
+	}
+
+	literal := strings.TrimSuffix(l.input[start:l.readPosition], "\r")
+	return token.Token{Type: token.Comment, Literal: literal}
 }
 
 func (l *Lexer) readIdentifier() string {
 	position := l.position
-	for isLetter(l.ch) {
+	for token.IsLetter(l.ch) {
 		l.readChar()
 	}
 
 	return l.input[position:l.position]
 }
 
-func isLetter(ch byte) bool {
-	return lowerCaseA <= ch && ch <= lowerCaseZ || upperCaseA <= ch && ch <= upperCaseZ || ch == '_'
-}
-
 func (l *Lexer) skipWhitespace() {
 	for l.ch == ' ' || l.ch == '\t' || l.ch == '\n' || l.ch == '\r' {
 		l.readChar()
 	}
 }
 
-func (l *Lexer) readNumber() string {
+// readNumber reads an integer or a float literal, e.g. 42, 3.14 or 1e-9.
+func (l *Lexer) readNumber() (string, token.TokenType) {
 	position := l.position
+	tokenType := token.TokenType(token.TypeInteger)
+
+	l.readDigits()
+
+	if l.ch == '.' && isDigit(l.peekChar()) {
+		tokenType = token.TypeFloat
+		l.readChar()
+		l.readDigits()
+	}
+
+	if (l.ch == 'e' || l.ch == 'E') && l.atExponent() {
+		tokenType = token.TypeFloat
+		l.readChar()
+		if l.ch == '+' || l.ch == '-' {
+			l.readChar()
+		}
+		l.readDigits()
+	}
+
+	return l.input[position:l.position], tokenType
+}
+
+func (l *Lexer) readDigits() {
 	for isDigit(l.ch) {
 		l.readChar()
 	}
+}
 
-	return l.input[position:l.position]
+// atExponent reports whether the current 'e' starts an exponent, i.e. it is followed
+// by digits optionally preceded by a sign.
+func (l *Lexer) atExponent() bool {
+	next := l.peekChar()
+	if next == '+' || next == '-' {
+		next = l.peekCharAt(1)
+	}
+
+	return isDigit(next)
 }
 
-func isDigit(ch byte) bool {
+func isDigit(ch rune) bool {
 	return '0' <= ch && ch <= '9'
 }
 
-func (l *Lexer) peekChar() byte {
-	if l.readPosition >= len(l.input) {
+func isHexDigit(ch rune) bool {
+	return isDigit(ch) || 'a' <= ch && ch <= 'f' || 'A' <= ch && ch <= 'F'
+}
+
+func (l *Lexer) peekChar() rune {
+	return l.peekCharAt(0)
+}
+
+// peekCharAt returns the char starting at the given distance in bytes after the next char.
+func (l *Lexer) peekCharAt(distance int) rune {
+	if l.readPosition+distance >= len(l.input) {
 		return 0
 	}
 
-	return l.input[l.readPosition]
+	ch, _ := utf8.DecodeRuneInString(l.input[l.readPosition+distance:])
+	return ch
 }
@@ -4,6 +4,8 @@ import (
 	"testing"
 
 	"github.com/adrian83/monkey/pkg/token"
+
+	"github.com/stretchr/testify/assert"
 )
 
 func TestNextToken(t *testing.T) {
@@ -11,7 +13,7 @@ func TestNextToken(t *testing.T) {
 	let ten = 10;
 	let add = fn(x, y) { x + y; };
 	let result = add(five, ten);
-	!-/*5;
+	!-/ *5;
 	5 < 10 > 5;
 	
 	if (5 < 10) {
@@ -26,6 +28,7 @@ func TestNextToken(t *testing.T) {
 	"foo bar"
 	[1, 2];
 	{"foo": "bar"}
+	macro(x, y) { x + y; };
 	`
 
 	tests := []struct {
@@ -118,6 +121,19 @@ func TestNextToken(t *testing.T) {
 		{token.DelimiterColon, ":"},
 		{token.TypeString, "bar"},
 		{token.DelimiterRightBrace, "}"},
+		{token.KeywordMacro, "macro"},
+		{token.DelimiterLeftParenthesis, "("},
+		{token.Ident, "x"},
+		{token.DelimiterComma, ","},
+		{token.Ident, "y"},
+		{token.DelimiterRightParenthesis, ")"},
+		{token.DelimiterLeftBrace, "{"},
+		{token.Ident, "x"},
+		{token.OperatorPlus, "+"},
+		{token.Ident, "y"},
+		{token.DelimiterSemicolon, ";"},
+		{token.DelimiterRightBrace, "}"},
+		{token.DelimiterSemicolon, ";"},
 		{token.Eof, ""}}
 
 	l := New(input)
@@ -135,3 +151,282 @@ func TestNextToken(t *testing.T) {
 		}
 	}
 }
+
+func TestTokenPositions(t *testing.T) {
+	input := "let x = 10;\n  x == \"ab\""
+
+	tests := []struct {
+		expectedType token.TokenType
+		expectedPos  token.Position
+		expectedEnd  token.Position
+	}{
+		{token.KeywordLet, token.Position{Filename: "a.mk", Offset: 0, Line: 1, Column: 1}, token.Position{Filename: "a.mk", Offset: 3, Line: 1, Column: 4}},
+		{token.Ident, token.Position{Filename: "a.mk", Offset: 4, Line: 1, Column: 5}, token.Position{Filename: "a.mk", Offset: 5, Line: 1, Column: 6}},
+		{token.OperatorAssign, token.Position{Filename: "a.mk", Offset: 6, Line: 1, Column: 7}, token.Position{Filename: "a.mk", Offset: 7, Line: 1, Column: 8}},
+		{token.TypeInteger, token.Position{Filename: "a.mk", Offset: 8, Line: 1, Column: 9}, token.Position{Filename: "a.mk", Offset: 10, Line: 1, Column: 11}},
+		{token.DelimiterSemicolon, token.Position{Filename: "a.mk", Offset: 10, Line: 1, Column: 11}, token.Position{Filename: "a.mk", Offset: 11, Line: 1, Column: 12}},
+		{token.Ident, token.Position{Filename: "a.mk", Offset: 14, Line: 2, Column: 3}, token.Position{Filename: "a.mk", Offset: 15, Line: 2, Column: 4}},
+		{token.OperatorEqual, token.Position{Filename: "a.mk", Offset: 16, Line: 2, Column: 5}, token.Position{Filename: "a.mk", Offset: 18, Line: 2, Column: 7}},
+		{token.TypeString, token.Position{Filename: "a.mk", Offset: 19, Line: 2, Column: 8}, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}},
+		{token.Eof, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}},
+		{token.Eof, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}, token.Position{Filename: "a.mk", Offset: 23, Line: 2, Column: 12}},
+	}
+
+	l := NewFile("a.mk", input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType {
+			t.Fatalf("tests[%d] - tokentype wrong. expected=%q, got=%q", i, tt.expectedType, tok.Type)
+		}
+
+		if tok.Pos != tt.expectedPos {
+			t.Fatalf("tests[%d] - start position wrong. expected=%+v, got=%+v", i, tt.expectedPos, tok.Pos)
+		}
+
+		if tok.End != tt.expectedEnd {
+			t.Fatalf("tests[%d] - end position wrong. expected=%+v, got=%+v", i, tt.expectedEnd, tok.End)
+		}
+	}
+}
+
+func TestNumberTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"42", []token.Token{{Type: token.TypeInteger, Literal: "42"}}},
+		{"3.14", []token.Token{{Type: token.TypeFloat, Literal: "3.14"}}},
+		{"1e-9", []token.Token{{Type: token.TypeFloat, Literal: "1e-9"}}},
+		{"2.5E+3", []token.Token{{Type: token.TypeFloat, Literal: "2.5E+3"}}},
+		{"1e9", []token.Token{{Type: token.TypeFloat, Literal: "1e9"}}},
+		{"1.", []token.Token{{Type: token.TypeInteger, Literal: "1"}, {Type: token.Illegal, Literal: "."}}},
+		{"1else", []token.Token{{Type: token.TypeInteger, Literal: "1"}, {Type: token.KeywordElse, Literal: "else"}}},
+		{"2e+", []token.Token{{Type: token.TypeInteger, Literal: "2"}, {Type: token.Ident, Literal: "e"}, {Type: token.OperatorPlus, Literal: "+"}}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+
+		for j, expected := range append(tt.expected, token.Token{Type: token.Eof, Literal: ""}) {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d][%d] - wrong token. expected=%q %q, got=%q %q",
+					i, j, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestOperatorTokens(t *testing.T) {
+	tests := []struct {
+		input    string
+		expected []token.Token
+	}{
+		{"x = 1", []token.Token{{Type: token.Ident, Literal: "x"}, {Type: token.OperatorAssign, Literal: "="}, {Type: token.TypeInteger, Literal: "1"}}},
+		{"+= -= *= /=", []token.Token{
+			{Type: token.OperatorPlusAssign, Literal: "+="}, {Type: token.OperatorMinusAssign, Literal: "-="},
+			{Type: token.OperatorAsteriskAssign, Literal: "*="}, {Type: token.OperatorSlashAssign, Literal: "/="}}},
+		{"a==b", []token.Token{{Type: token.Ident, Literal: "a"}, {Type: token.OperatorEqual, Literal: "=="}, {Type: token.Ident, Literal: "b"}}},
+		{"+ =", []token.Token{{Type: token.OperatorPlus, Literal: "+"}, {Type: token.OperatorAssign, Literal: "="}}},
+		{"<= >= < >", []token.Token{
+			{Type: token.OperatorLowerEqual, Literal: "<="}, {Type: token.OperatorGreaterEqual, Literal: ">="},
+			{Type: token.OperatorLowerThan, Literal: "<"}, {Type: token.OperatorGreaterThan, Literal: ">"}}},
+		{"a&&!b||c", []token.Token{
+			{Type: token.Ident, Literal: "a"}, {Type: token.OperatorAnd, Literal: "&&"}, {Type: token.OperatorBang, Literal: "!"},
+			{Type: token.Ident, Literal: "b"}, {Type: token.OperatorOr, Literal: "||"}, {Type: token.Ident, Literal: "c"}}},
+		{"% ** *= *", []token.Token{
+			{Type: token.OperatorPercent, Literal: "%"}, {Type: token.OperatorPower, Literal: "**"},
+			{Type: token.OperatorAsteriskAssign, Literal: "*="}, {Type: token.OperatorAsterisk, Literal: "*"}}},
+		{"& |", []token.Token{{Type: token.Illegal, Literal: "&"}, {Type: token.Illegal, Literal: "|"}}},
+	}
+
+	for i, tt := range tests {
+		l := New(tt.input)
+
+		for j, expected := range append(tt.expected, token.Token{Type: token.Eof, Literal: ""}) {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d][%d] - wrong token. expected=%q %q, got=%q %q",
+					i, j, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+	}
+}
+
+func TestStringTokens(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected token.Token
+		errors   []string
+	}{
+		"plain":             {`"foo bar"`, token.Token{Type: token.TypeString, Literal: "foo bar"}, nil},
+		"empty":             {`""`, token.Token{Type: token.TypeString, Literal: ""}, nil},
+		"escapes":           {`"a\n\tb\r\"c\"\\"`, token.Token{Type: token.TypeString, Literal: "a\n\tb\r\"c\"\\"}, nil},
+		"unicode escape":    {`"\u{41}\u{e9}\u{1F600}"`, token.Token{Type: token.TypeString, Literal: "Aé😀"}, nil},
+		"multi line":        {"\"a\nb\"", token.Token{Type: token.TypeString, Literal: "a\nb"}, nil},
+		"raw":               {"`a\\n\"b\"\nc`", token.Token{Type: token.TypeString, Literal: "a\\n\"b\"\nc"}, nil},
+		"invalid escape":    {`"a\qb"`, token.Token{Type: token.TypeString, Literal: `a\qb`}, []string{"1:3: invalid escape sequence \\q"}},
+		"unicode no braces": {`"\u41"`, token.Token{Type: token.TypeString, Literal: "41"}, []string{"1:2: invalid unicode escape, expected \\u{...}"}},
+		"unicode too long": {`"\u{1234567}"`, token.Token{Type: token.TypeString, Literal: "}"},
+			[]string{"1:2: invalid unicode escape, expected \\u{...} with 1 to 6 hex digits"}},
+		"unicode invalid":   {`"x\u{D800}"`, token.Token{Type: token.TypeString, Literal: "x"}, []string{"1:3: invalid unicode code point U+D800"}},
+		"unterminated":      {`"abc`, token.Token{Type: token.Illegal, Literal: `"abc`}, []string{"1:1: unterminated string"}},
+		"escaped quote":     {`"abc\"`, token.Token{Type: token.Illegal, Literal: `"abc\"`}, []string{"1:1: unterminated string"}},
+		"unterminated raw":  {"`abc\n", token.Token{Type: token.Illegal, Literal: "`abc\n"}, []string{"1:1: unterminated raw string"}},
+		"illegal character": {"@", token.Token{Type: token.Illegal, Literal: "@"}, []string{"1:1: illegal character '@'"}},
+		"single ampersand":  {"&", token.Token{Type: token.Illegal, Literal: "&"}, []string{"1:1: illegal character '&'"}},
+		"single bar":        {"|", token.Token{Type: token.Illegal, Literal: "|"}, []string{"1:1: illegal character '|'"}},
+		"illegal unicode":   {"→", token.Token{Type: token.Illegal, Literal: "→"}, []string{"1:1: illegal character '→'"}},
+		"invalid utf8":      {"\xff", token.Token{Type: token.Illegal, Literal: "\xff"}, []string{"1:1: invalid UTF-8 encoding"}},
+		"utf8 in string":    {`"zażółć"`, token.Token{Type: token.TypeString, Literal: "zażółć"}, nil},
+		"invalid in string": {"\"a\xffb\"", token.Token{Type: token.TypeString, Literal: "a\xffb"}, nil},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			var errors []string
+
+			l := New(data.input)
+			l.SetErrorHandler(func(tok token.Token, pos token.Position, msg string) {
+				assert.Equal(t, data.expected.Type, tok.Type)
+				errors = append(errors, pos.String()+": "+msg)
+			})
+
+			tok := l.NextToken()
+
+			assert.Equal(t, data.expected.Type, tok.Type)
+			assert.Equal(t, data.expected.Literal, tok.Literal)
+			assert.Equal(t, data.errors, errors)
+			assert.Equal(t, token.TokenType(token.Eof), l.NextToken().Type)
+		})
+	}
+}
+
+func TestUnicodeTokens(t *testing.T) {
+	input := "let größe = \"日本\"; 名前 + é"
+
+	tests := []struct {
+		expectedType    token.TokenType
+		expectedLiteral string
+		expectedColumn  int
+		expectedOffset  int
+	}{
+		{token.KeywordLet, "let", 1, 0},
+		{token.Ident, "größe", 5, 4},
+		{token.OperatorAssign, "=", 11, 12},
+		{token.TypeString, "日本", 13, 14},
+		{token.DelimiterSemicolon, ";", 17, 22},
+		{token.Ident, "名前", 19, 24},
+		{token.OperatorPlus, "+", 22, 31},
+		{token.Ident, "é", 24, 33},
+		{token.Eof, "", 25, 35},
+	}
+
+	l := New(input)
+
+	for i, tt := range tests {
+		tok := l.NextToken()
+
+		if tok.Type != tt.expectedType || tok.Literal != tt.expectedLiteral {
+			t.Fatalf("tests[%d] - wrong token. expected=%q %q, got=%q %q",
+				i, tt.expectedType, tt.expectedLiteral, tok.Type, tok.Literal)
+		}
+
+		if tok.Pos.Column != tt.expectedColumn || tok.Pos.Offset != tt.expectedOffset {
+			t.Fatalf("tests[%d] - wrong position. expected=%d (offset %d), got=%d (offset %d)",
+				i, tt.expectedColumn, tt.expectedOffset, tok.Pos.Column, tok.Pos.Offset)
+		}
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := "// header\nlet x = 1; // trailing\r\n/* block\n * comment */ x /= 2 / /**/ 3\n/* open"
+
+	tests := []struct {
+		mode     Mode
+		expected []token.Token
+	}{
+		{0, []token.Token{
+			{Type: token.KeywordLet, Literal: "let"}, {Type: token.Ident, Literal: "x"},
+			{Type: token.OperatorAssign, Literal: "="}, {Type: token.TypeInteger, Literal: "1"},
+			{Type: token.DelimiterSemicolon, Literal: ";"}, {Type: token.Ident, Literal: "x"},
+			{Type: token.OperatorSlashAssign, Literal: "/="}, {Type: token.TypeInteger, Literal: "2"},
+			{Type: token.OperatorSlash, Literal: "/"}, {Type: token.TypeInteger, Literal: "3"},
+		}},
+		{ScanComments, []token.Token{
+			{Type: token.Comment, Literal: "// header"},
+			{Type: token.KeywordLet, Literal: "let"}, {Type: token.Ident, Literal: "x"},
+			{Type: token.OperatorAssign, Literal: "="}, {Type: token.TypeInteger, Literal: "1"},
+			{Type: token.DelimiterSemicolon, Literal: ";"}, {Type: token.Comment, Literal: "// trailing"},
+			{Type: token.Comment, Literal: "/* block\n * comment */"}, {Type: token.Ident, Literal: "x"},
+			{Type: token.OperatorSlashAssign, Literal: "/="}, {Type: token.TypeInteger, Literal: "2"},
+			{Type: token.OperatorSlash, Literal: "/"}, {Type: token.Comment, Literal: "/**/"},
+			{Type: token.TypeInteger, Literal: "3"}, {Type: token.Comment, Literal: "/* open"},
+		}},
+	}
+
+	for i, tt := range tests {
+		var errors []string
+
+		l := New(input)
+		l.SetMode(tt.mode)
+		l.SetErrorHandler(func(tok token.Token, pos token.Position, msg string) {
+			errors = append(errors, pos.String()+": "+msg)
+		})
+
+		for j, expected := range append(tt.expected, token.Token{Type: token.Eof, Literal: ""}) {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d][%d] - wrong token. expected=%q %q, got=%q %q",
+					i, j, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+
+		assert.Equal(t, []string{"5:1: unterminated comment"}, errors)
+	}
+}
+
+func TestShebang(t *testing.T) {
+	input := "#!/usr/bin/env monkey\r\nx # 1"
+
+	tests := []struct {
+		mode     Mode
+		expected []token.Token
+	}{
+		{0, []token.Token{
+			{Type: token.Ident, Literal: "x"}, {Type: token.Illegal, Literal: "#"}, {Type: token.TypeInteger, Literal: "1"},
+		}},
+		{ScanComments, []token.Token{
+			{Type: token.Comment, Literal: "#!/usr/bin/env monkey"},
+			{Type: token.Ident, Literal: "x"}, {Type: token.Illegal, Literal: "#"}, {Type: token.TypeInteger, Literal: "1"},
+		}},
+	}
+
+	for i, tt := range tests {
+		var errors []string
+
+		l := New(input)
+		l.SetMode(tt.mode)
+		l.SetErrorHandler(func(tok token.Token, pos token.Position, msg string) {
+			errors = append(errors, pos.String()+": "+msg)
+		})
+
+		for j, expected := range append(tt.expected, token.Token{Type: token.Eof, Literal: ""}) {
+			tok := l.NextToken()
+
+			if tok.Type != expected.Type || tok.Literal != expected.Literal {
+				t.Fatalf("tests[%d][%d] - wrong token. expected=%q %q, got=%q %q",
+					i, j, expected.Type, expected.Literal, tok.Type, tok.Literal)
+			}
+		}
+
+		assert.Equal(t, []string{"2:3: illegal character '#'"}, errors)
+	}
+}
@@ -0,0 +1,104 @@
+package lsp
+
+import (
+	"sort"
+	"unicode/utf16"
+	"unicode/utf8"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/parser"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+// document is an open text document with the results of its analysis.
+type document struct {
+	uri        string
+	version    int
+	text       string
+	lineStarts []int // offsets of the beginnings of the lines
+
+	program *ast.Program
+	errors  parser.ErrorList
+	index   *index
+}
+
+func newDocument(uri string, version int, text string) *document {
+	d := &document{uri: uri, version: version, text: text, lineStarts: []int{0}}
+
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\n' {
+			d.lineStarts = append(d.lineStarts, i+1)
+		}
+	}
+
+	p := parser.New(lexer.NewFile(uri, text))
+	d.program, _ = p.ParseProgram()
+	d.errors = p.Errors()
+	d.index = resolve(d.program)
+
+	return d
+}
+
+// position converts the byte offset to the position in the document.
+func (d *document) position(offset int) Position {
+	if offset > len(d.text) {
+		offset = len(d.text)
+	}
+	if offset < 0 {
+		offset = 0
+	}
+
+	line := sort.Search(len(d.lineStarts), func(i int) bool { return d.lineStarts[i] > offset }) - 1
+
+	character := 0
+	for _, r := range d.text[d.lineStarts[line]:offset] {
+		character += utf16Len(r)
+	}
+
+	return Position{Line: line, Character: character}
+}
+
+// offset converts the position to the byte offset, positions beyond the end of a line
+// refer to the end of the line.
+func (d *document) offset(pos Position) int {
+	if pos.Line < 0 {
+		return 0
+	}
+	if pos.Line >= len(d.lineStarts) {
+		return len(d.text)
+	}
+
+	offset := d.lineStarts[pos.Line]
+	for character := 0; character < pos.Character && offset < len(d.text); {
+		r, size := utf8.DecodeRuneInString(d.text[offset:])
+		if r == '\n' {
+			break
+		}
+
+		character += utf16Len(r)
+		offset += size
+	}
+
+	return offset
+}
+
+func (d *document) rangeOf(start, end token.Position) Range {
+	return Range{Start: d.position(start.Offset), End: d.position(end.Offset)}
+}
+
+func (d *document) nodeRange(node ast.Node) Range {
+	return d.rangeOf(node.Pos(), node.End())
+}
+
+func (d *document) location(node ast.Node) Location {
+	return Location{URI: d.uri, Range: d.nodeRange(node)}
+}
+
+// utf16Len returns the number of UTF-16 code units encoding the rune.
+func utf16Len(r rune) int {
+	if r1, _ := utf16.EncodeRune(r); r1 != utf8.RuneError {
+		return 2
+	}
+	return 1
+}
@@ -0,0 +1,42 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestPositionAndOffset(t *testing.T) {
+	testData := map[string]struct {
+		offset   int
+		position Position
+	}{
+		"start":               {0, Position{Line: 0, Character: 0}},
+		"ascii":               {5, Position{Line: 0, Character: 5}},
+		"after two bytes":     {11, Position{Line: 0, Character: 10}},
+		"end of line":         {13, Position{Line: 0, Character: 12}},
+		"next line":           {14, Position{Line: 1, Character: 0}},
+		"after surrogate":     {24, Position{Line: 1, Character: 8}},
+		"end of the document": {27, Position{Line: 1, Character: 11}},
+	}
+
+	// é takes 2 bytes and 1 UTF-16 code unit, 😀 takes 4 bytes and 2 code units
+	doc := newDocument(testURI, 1, "let s = \"é\";\nputs(\"😀\");")
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.position, doc.position(data.offset))
+			assert.Equal(t, data.offset, doc.offset(data.position))
+		})
+	}
+}
+
+func TestOffsetBeyondLine(t *testing.T) {
+	doc := newDocument(testURI, 1, "let x = 1;\nx")
+
+	assert.Equal(t, 10, doc.offset(Position{Line: 0, Character: 50}))
+	assert.Equal(t, 12, doc.offset(Position{Line: 5, Character: 0}))
+	assert.Equal(t, 0, doc.offset(Position{Line: -1, Character: 0}))
+}
@@ -0,0 +1,309 @@
+package lsp
+
+import (
+	"sort"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+type definitionKind string
+
+const (
+	kindLet          definitionKind = "let"
+	kindParameter    definitionKind = "parameter"
+	kindLoopVariable definitionKind = "loop variable"
+)
+
+// definition is a binding created by a let statement, a parameter of a function
+// or a macro or a variable of a for loop.
+type definition struct {
+	name       *ast.Identifier
+	kind       definitionKind
+	value      ast.Expression // value of the let statement
+	function   string         // name of the function declaring the parameter
+	references []*ast.Identifier
+}
+
+// scope holds the bindings of the program or of a function, blocks don't create scopes
+// like they don't create environments in the evaluator.
+type scope struct {
+	parent     *scope
+	start, end int // offsets of the code belonging to the scope
+	names      map[string]*definition
+}
+
+func (s *scope) lookup(name string) *definition {
+	for ; s != nil; s = s.parent {
+		if def, ok := s.names[name]; ok {
+			return def
+		}
+	}
+
+	return nil
+}
+
+// index tells which definitions the identifiers of a program refer to.
+type index struct {
+	identifiers []*ast.Identifier // in the order of appearance
+	definitions map[*ast.Identifier]*definition
+	scopes      []*scope
+}
+
+// identifierAt returns the identifier at the offset or nil if there isn't any.
+func (idx *index) identifierAt(offset int) *ast.Identifier {
+	i := sort.Search(len(idx.identifiers), func(i int) bool {
+		return idx.identifiers[i].End().Offset >= offset
+	})
+
+	if i < len(idx.identifiers) && idx.identifiers[i].Pos().Offset <= offset {
+		return idx.identifiers[i]
+	}
+
+	return nil
+}
+
+// visible returns the definitions visible at the offset, inner ones shadow outer ones.
+func (idx *index) visible(offset int) map[string]*definition {
+	innermost := idx.scopes[0]
+	for _, s := range idx.scopes {
+		if s.start <= offset && offset <= s.end && s.start >= innermost.start {
+			innermost = s
+		}
+	}
+
+	names := map[string]*definition{}
+	for s := innermost; s != nil; s = s.parent {
+		for name, def := range s.names {
+			if _, ok := names[name]; !ok {
+				names[name] = def
+			}
+		}
+	}
+
+	return names
+}
+
+// resolve finds the definitions referred to by the identifiers of the program. An identifier
+// refers to the latest definition preceding it in the scope, except for identifiers in functions
+// which are not defined yet, they refer to the definitions following the function, because they
+// may be defined before the function is called.
+func resolve(program *ast.Program) *index {
+	idx := &index{definitions: map[*ast.Identifier]*definition{}}
+
+	global := &scope{start: 0, end: program.End().Offset, names: map[string]*definition{}}
+	idx.scopes = append(idx.scopes, global)
+
+	r := &resolver{idx: idx, scope: global}
+	ast.Walk(r, program)
+
+	for _, pending := range r.pending {
+		if def := pending.scope.lookup(pending.ident.Value); def != nil {
+			r.refer(pending.ident, def)
+		}
+	}
+
+	sort.Slice(idx.identifiers, func(i, j int) bool {
+		return idx.identifiers[i].Pos().Offset < idx.identifiers[j].Pos().Offset
+	})
+
+	return idx
+}
+
+type unresolved struct {
+	ident *ast.Identifier
+	scope *scope
+}
+
+type resolver struct {
+	idx     *index
+	scope   *scope
+	pending []unresolved // shared by the resolvers of all scopes
+	parent  *resolver
+}
+
+func (r *resolver) Visit(node ast.Node) ast.Visitor {
+	switch n := node.(type) {
+	case *ast.LetStatement:
+		switch n.Value.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			// functions may call themselves
+			r.define(n.Name, kindLet, n.Value, "")
+			ast.Walk(r, n.Value)
+		default:
+			ast.Walk(r, n.Value)
+			r.define(n.Name, kindLet, n.Value, "")
+		}
+		return nil
+
+	case *ast.FunctionLiteral:
+		r.function(n, n.Name, n.Parameters, n.Body)
+		return nil
+
+	case *ast.MacroLiteral:
+		r.function(n, "", n.Parameters, n.Body)
+		return nil
+
+	case *ast.ForStatement:
+		ast.Walk(r, n.Iterable)
+		if n.Key != nil {
+			r.define(n.Key, kindLoopVariable, nil, "")
+		}
+		r.define(n.Value, kindLoopVariable, nil, "")
+		ast.Walk(r, n.Body)
+		return nil
+
+	case *ast.Identifier:
+		r.use(n)
+		return nil
+	}
+
+	return r
+}
+
+func (r *resolver) function(node ast.Node, name string, params []*ast.Identifier, body *ast.BlockStatement) {
+	inner := &resolver{
+		idx:    r.idx,
+		scope:  &scope{parent: r.scope, start: node.Pos().Offset, end: node.End().Offset, names: map[string]*definition{}},
+		parent: r,
+	}
+	r.idx.scopes = append(r.idx.scopes, inner.scope)
+
+	for _, param := range params {
+		inner.define(param, kindParameter, nil, name)
+	}
+	ast.Walk(inner, body)
+
+	r.root().pending = append(r.root().pending, inner.pending...)
+}
+
+func (r *resolver) root() *resolver {
+	for r.parent != nil {
+		r = r.parent
+	}
+	return r
+}
+
+func (r *resolver) define(ident *ast.Identifier, kind definitionKind, value ast.Expression, function string) {
+	def := &definition{name: ident, kind: kind, value: value, function: function}
+	r.scope.names[ident.Value] = def
+
+	r.idx.identifiers = append(r.idx.identifiers, ident)
+	r.idx.definitions[ident] = def
+}
+
+func (r *resolver) use(ident *ast.Identifier) {
+	r.idx.identifiers = append(r.idx.identifiers, ident)
+
+	if def := r.scope.lookup(ident.Value); def != nil {
+		r.refer(ident, def)
+		return
+	}
+
+	if r.parent != nil {
+		r.pending = append(r.pending, unresolved{ident: ident, scope: r.scope})
+	}
+}
+
+func (r *resolver) refer(ident *ast.Identifier, def *definition) {
+	def.references = append(def.references, ident)
+	r.idx.definitions[ident] = def
+}
+
+// inferKind returns the kind of the value of the expression if it can be told without evaluation,
+// an empty string otherwise.
+func inferKind(expr ast.Expression, idx *index, depth int) string {
+	if depth > 10 {
+		return ""
+	}
+
+	switch e := expr.(type) {
+	case *ast.IntegerLiteral:
+		return "integer"
+	case *ast.FloatLiteral:
+		return "float"
+	case *ast.StringLiteral:
+		return "string"
+	case *ast.BooleanLiteral:
+		return "boolean"
+	case *ast.ArrayLiteral:
+		return "array"
+	case *ast.HashLiteral:
+		return "hash"
+	case *ast.FunctionLiteral:
+		return "function"
+	case *ast.MacroLiteral:
+		return "macro"
+
+	case *ast.Identifier:
+		if def := idx.definitions[e]; def != nil && def.value != nil {
+			return inferKind(def.value, idx, depth+1)
+		}
+		if _, ok := builtinDoc(e.Value); ok && idx.definitions[e] == nil {
+			return "builtin function"
+		}
+
+	case *ast.PrefixExpression:
+		if e.Operator == token.OperatorBang {
+			return "boolean"
+		}
+		if kind := inferKind(e.Right, idx, depth+1); kind == "integer" || kind == "float" {
+			return kind
+		}
+
+	case *ast.InfixExpression:
+		return inferInfixKind(e, idx, depth)
+
+	case *ast.CallExpression:
+		if ident, ok := e.Function.(*ast.Identifier); ok && idx.definitions[ident] == nil {
+			return builtinResults[ident.Value]
+		}
+	}
+
+	return ""
+}
+
+func inferInfixKind(e *ast.InfixExpression, idx *index, depth int) string {
+	switch e.Operator {
+	case token.OperatorEqual, token.OperatorNotEqual, token.OperatorLowerThan, token.OperatorGreaterThan,
+		token.OperatorLowerEqual, token.OperatorGreaterEqual:
+		return "boolean"
+	}
+
+	left, right := inferKind(e.Left, idx, depth+1), inferKind(e.Right, idx, depth+1)
+
+	switch {
+	case left == "integer" && right == "integer":
+		return "integer"
+	case (left == "integer" || left == "float") && (right == "integer" || right == "float"):
+		return "float"
+	case left == "string" && right == "string" && e.Operator == token.OperatorPlus:
+		return "string"
+	case left == "boolean" && right == "boolean":
+		return "boolean"
+	}
+
+	return ""
+}
+
+// builtinResults are the kinds of the results of the builtins which always return the same kind.
+var builtinResults = map[string]string{
+	"len":   "integer",
+	"int":   "integer",
+	"float": "float",
+	"range": "range",
+	"bytes": "array",
+	"runes": "array",
+	"push":  "array",
+	"puts":  "null",
+}
+
+func builtinDoc(name string) (string, bool) {
+	builtin := object.GetBuiltinByName(name)
+	if builtin == nil {
+		return "", false
+	}
+
+	return builtin.Doc, true
+}
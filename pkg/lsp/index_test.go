@@ -0,0 +1,84 @@
+package lsp
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	testData := map[string]struct {
+		input      string
+		offset     int
+		definition int // offset of the definition, -1 if there is none
+	}{
+		"let":                        {"let x = 1; x", 11, 4},
+		"shadowing":                  {"let x = 1; let x = x; x", 22, 15},
+		"value refers to previous":   {"let x = 1; let x = x; x", 19, 4},
+		"recursion":                  {"let f = fn() { f() }; f", 15, 4},
+		"defined after function":     {"let f = fn() { g() }; let g = 1;", 15, 26},
+		"undefined at top level":     {"x; let x = 1;", 0, -1},
+		"parameter shadows global":   {"let a = 1; let f = fn(a) { a };", 27, 22},
+		"builtin":                    {"len([])", 0, -1},
+		"loop variable":              {"for (k, v in {}) { v }", 19, 8},
+		"macro parameter":            {"let m = macro(q) { q };", 19, 14},
+		"block doesn't create scope": {"if (true) { let y = 1; } y", 25, 16},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			doc := newDocument(testURI, 1, data.input)
+
+			ident := doc.index.identifierAt(data.offset)
+			require.NotNil(t, ident)
+
+			def := doc.index.definitions[ident]
+			if data.definition < 0 {
+				assert.Nil(t, def)
+				return
+			}
+
+			require.NotNil(t, def)
+			assert.Equal(t, data.definition, def.name.Pos().Offset)
+		})
+	}
+}
+
+func TestInferKind(t *testing.T) {
+	testData := map[string]struct {
+		input string
+		kind  string
+	}{
+		"integer":         {"let x = 1 + 2 * 3;", "integer"},
+		"float":           {"let x = 1 + 2.5;", "float"},
+		"string":          {`let x = "a" + "b";`, "string"},
+		"comparison":      {"let x = a < b;", "boolean"},
+		"negation":        {"let x = !a;", "boolean"},
+		"through binding": {"let y = [1]; let x = y;", "array"},
+		"builtin":         {"let x = len;", "builtin function"},
+		"builtin result":  {"let x = len([]);", "integer"},
+		"unknown":         {"let x = a + 1;", ""},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			doc := newDocument(testURI, 1, data.input)
+			require.Empty(t, doc.errors)
+
+			var def *definition
+			for _, d := range doc.index.visible(len(data.input)) {
+				if d.name.Value == "x" {
+					def = d
+				}
+			}
+			require.NotNil(t, def)
+
+			assert.Equal(t, data.kind, inferKind(def.value, doc.index, 0))
+		})
+	}
+}
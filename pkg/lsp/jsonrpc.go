@@ -0,0 +1,111 @@
+package lsp
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	"github.com/adrian83/monkey/pkg/framing"
+)
+
+// error codes defined by JSON-RPC and LSP
+const (
+	codeParseError           = -32700
+	codeInvalidRequest       = -32600
+	codeMethodNotFound       = -32601
+	codeInvalidParams        = -32602
+	codeInternalError        = -32603
+	codeServerNotInitialized = -32002
+)
+
+// message is a JSON-RPC request, response or notification, notifications have no id.
+type message struct {
+	JSONRPC string           `json:"jsonrpc"`
+	ID      *json.RawMessage `json:"id,omitempty"`
+	Method  string           `json:"method,omitempty"`
+	Params  json.RawMessage  `json:"params,omitempty"`
+	Result  json.RawMessage  `json:"result,omitempty"`
+	Error   *ResponseError   `json:"error,omitempty"`
+}
+
+// ResponseError is the error of a request.
+type ResponseError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("%s (%d)", e.Message, e.Code)
+}
+
+func newResponseError(code int, format string, a ...interface{}) *ResponseError {
+	return &ResponseError{Code: code, Message: fmt.Sprintf(format, a...)}
+}
+
+// conn reads and writes messages preceded by the Content-Length header, like LSP does over stdio.
+type conn struct {
+	in  *framing.Reader
+	out *framing.Writer
+}
+
+func newConn(in io.Reader, out io.Writer) *conn {
+	return &conn{in: framing.NewReader(in), out: framing.NewWriter(out)}
+}
+
+// read returns the next message, io.EOF is returned if the input ends between messages.
+func (c *conn) read() (*message, error) {
+	body, err := c.in.Read()
+	if err != nil {
+		return nil, err
+	}
+
+	var msg message
+	if err := json.Unmarshal(body, &msg); err != nil {
+		return nil, newResponseError(codeParseError, "invalid message: %v", err)
+	}
+
+	return &msg, nil
+}
+
+func (c *conn) write(msg *message) error {
+	msg.JSONRPC = "2.0"
+
+	body, err := json.Marshal(msg)
+	if err != nil {
+		return err
+	}
+
+	return c.out.Write(body)
+}
+
+// reply sends the response to the request, the result is ignored if there is an error.
+func (c *conn) reply(id *json.RawMessage, result interface{}, err error) error {
+	msg := &message{ID: id}
+
+	if err != nil {
+		respErr, ok := err.(*ResponseError)
+		if !ok {
+			respErr = newResponseError(codeInternalError, "%v", err)
+		}
+		msg.Error = respErr
+		return c.write(msg)
+	}
+
+	data, err := json.Marshal(result)
+	if err != nil {
+		return err
+	}
+	msg.Result = data
+
+	return c.write(msg)
+}
+
+// notify sends the notification.
+func (c *conn) notify(method string, params interface{}) error {
+	data, err := json.Marshal(params)
+	if err != nil {
+		return err
+	}
+
+	return c.write(&message{Method: method, Params: data})
+}
@@ -0,0 +1,471 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+	"github.com/adrian83/monkey/pkg/printer"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+const serverName = "monkey-lsp"
+
+// Server is a Language Server Protocol server for Monkey. It keeps the open documents
+// and answers requests about them one at a time.
+type Server struct {
+	conn        *conn
+	documents   map[string]*document
+	initialized bool
+	shutdown    bool
+}
+
+func NewServer() *Server {
+	return &Server{documents: map[string]*document{}}
+}
+
+type requestHandler func(s *Server, params json.RawMessage) (interface{}, error)
+
+type notificationHandler func(s *Server, params json.RawMessage) error
+
+var requestHandlers = map[string]requestHandler{
+	"initialize":                  (*Server).initialize,
+	"shutdown":                    (*Server).shutdownRequest,
+	"textDocument/hover":          (*Server).hover,
+	"textDocument/definition":     (*Server).definition,
+	"textDocument/references":     (*Server).references,
+	"textDocument/documentSymbol": (*Server).documentSymbols,
+	"textDocument/completion":     (*Server).completion,
+	"textDocument/formatting":     (*Server).formatting,
+}
+
+var notificationHandlers = map[string]notificationHandler{
+	"textDocument/didOpen":   (*Server).didOpen,
+	"textDocument/didChange": (*Server).didChange,
+	"textDocument/didClose":  (*Server).didClose,
+}
+
+// Serve reads requests from the input and writes responses and notifications to the output
+// until the exit notification is received or the input ends.
+func (s *Server) Serve(in io.Reader, out io.Writer) error {
+	s.conn = newConn(in, out)
+
+	for {
+		msg, err := s.conn.read()
+		if err == io.EOF {
+			return nil
+		}
+		if respErr, ok := err.(*ResponseError); ok {
+			if writeErr := s.conn.reply(nil, nil, respErr); writeErr != nil {
+				return writeErr
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+
+		if msg.Method == "exit" {
+			return nil
+		}
+
+		if msg.ID == nil {
+			s.handleNotification(msg)
+			continue
+		}
+
+		result, reqErr := s.handleRequest(msg)
+		if err := s.conn.reply(msg.ID, result, reqErr); err != nil {
+			return err
+		}
+	}
+}
+
+func (s *Server) handleRequest(msg *message) (interface{}, error) {
+	handler, ok := requestHandlers[msg.Method]
+	switch {
+	case !ok:
+		return nil, newResponseError(codeMethodNotFound, "method not found: %s", msg.Method)
+	case s.shutdown:
+		return nil, newResponseError(codeInvalidRequest, "server is shut down")
+	case !s.initialized && msg.Method != "initialize":
+		return nil, newResponseError(codeServerNotInitialized, "server is not initialized")
+	}
+
+	return handler(s, msg.Params)
+}
+
+// handleNotification ignores unknown notifications and errors, there is no response
+// which could carry them.
+func (s *Server) handleNotification(msg *message) {
+	if handler, ok := notificationHandlers[msg.Method]; ok && s.initialized {
+		handler(s, msg.Params)
+	}
+}
+
+func decodeParams(params json.RawMessage, v interface{}) error {
+	if err := json.Unmarshal(params, v); err != nil {
+		return newResponseError(codeInvalidParams, "invalid params: %v", err)
+	}
+	return nil
+}
+
+func (s *Server) initialize(json.RawMessage) (interface{}, error) {
+	s.initialized = true
+
+	return InitializeResult{
+		Capabilities: ServerCapabilities{
+			TextDocumentSync:           TextDocumentSyncKindFull,
+			HoverProvider:              true,
+			DefinitionProvider:         true,
+			ReferencesProvider:         true,
+			DocumentSymbolProvider:     true,
+			CompletionProvider:         &CompletionOptions{},
+			DocumentFormattingProvider: true,
+		},
+		ServerInfo: ServerInfo{Name: serverName},
+	}, nil
+}
+
+func (s *Server) shutdownRequest(json.RawMessage) (interface{}, error) {
+	s.shutdown = true
+	return nil, nil
+}
+
+func (s *Server) didOpen(params json.RawMessage) error {
+	var p DidOpenTextDocumentParams
+	if err := decodeParams(params, &p); err != nil {
+		return err
+	}
+
+	return s.update(newDocument(p.TextDocument.URI, p.TextDocument.Version, p.TextDocument.Text))
+}
+
+func (s *Server) didChange(params json.RawMessage) error {
+	var p DidChangeTextDocumentParams
+	if err := decodeParams(params, &p); err != nil {
+		return err
+	}
+	if len(p.ContentChanges) == 0 {
+		return nil
+	}
+
+	// with full synchronization the last change holds the whole text
+	text := p.ContentChanges[len(p.ContentChanges)-1].Text
+	return s.update(newDocument(p.TextDocument.URI, p.TextDocument.Version, text))
+}
+
+func (s *Server) didClose(params json.RawMessage) error {
+	var p DidCloseTextDocumentParams
+	if err := decodeParams(params, &p); err != nil {
+		return err
+	}
+
+	delete(s.documents, p.TextDocument.URI)
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: p.TextDocument.URI, Diagnostics: []Diagnostic{}})
+}
+
+// update replaces the document and publishes its diagnostics.
+func (s *Server) update(doc *document) error {
+	s.documents[doc.uri] = doc
+
+	diagnostics := []Diagnostic{}
+	for _, e := range doc.errors {
+		diagnostics = append(diagnostics, diagnostic(doc, e))
+	}
+
+	return s.conn.notify("textDocument/publishDiagnostics", PublishDiagnosticsParams{URI: doc.uri, Version: doc.version, Diagnostics: diagnostics})
+}
+
+// diagnostic covers the offending token if the problem is reported at its position,
+// otherwise the character at the position of the problem.
+func diagnostic(doc *document, e *parser.Error) Diagnostic {
+	end := e.Pos
+	switch {
+	case e.Token.Pos.Offset == e.Pos.Offset && e.Token.End.Offset > e.Pos.Offset:
+		end = e.Token.End
+	case e.Pos.Offset < len(doc.text):
+		end.Offset++
+	}
+
+	severity := SeverityError
+	if e.Severity == parser.SeverityWarning {
+		severity = SeverityWarning
+	}
+
+	return Diagnostic{Range: doc.rangeOf(e.Pos, end), Severity: severity, Source: serverName, Message: e.Msg}
+}
+
+// document returns the open document, requests about other documents fail.
+func (s *Server) document(uri string) (*document, error) {
+	doc, ok := s.documents[uri]
+	if !ok {
+		return nil, newResponseError(codeInvalidParams, "document is not open: %s", uri)
+	}
+
+	return doc, nil
+}
+
+// identifierAt returns the document and the identifier at the position, the identifier is nil
+// if there is none.
+func (s *Server) identifierAt(params json.RawMessage, p interface{}, pos *TextDocumentPositionParams) (*document, *ast.Identifier, error) {
+	if err := decodeParams(params, p); err != nil {
+		return nil, nil, err
+	}
+
+	doc, err := s.document(pos.TextDocument.URI)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return doc, doc.index.identifierAt(doc.offset(pos.Position)), nil
+}
+
+func (s *Server) hover(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	doc, ident, err := s.identifierAt(params, &p, &p)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+
+	text := describe(doc.index, ident)
+	if text == "" {
+		return nil, nil
+	}
+
+	r := doc.nodeRange(ident)
+	return Hover{Contents: MarkupContent{Kind: "markdown", Value: text}, Range: &r}, nil
+}
+
+// describe returns the markdown describing the binding the identifier refers to
+// or the documentation of the builtin.
+func describe(idx *index, ident *ast.Identifier) string {
+	def := idx.definitions[ident]
+	if def == nil {
+		if doc, ok := builtinDoc(ident.Value); ok {
+			return "```monkey\n" + ident.Value + "\n```\n\nbuiltin function\n\n" + doc
+		}
+		return ""
+	}
+
+	var text string
+	switch def.kind {
+	case kindLet:
+		text = "```monkey\nlet " + def.name.Value + signature(def.value) + "\n```"
+		if kind := inferKind(def.value, idx, 0); kind != "" {
+			text += "\n\n" + kind
+		}
+	case kindParameter:
+		text = "```monkey\n" + def.name.Value + "\n```\n\nparameter"
+		if def.function != "" {
+			text += " of " + def.function
+		}
+	default:
+		text = "```monkey\n" + def.name.Value + "\n```\n\n" + string(def.kind)
+	}
+
+	return text
+}
+
+// signature returns the parameters of the function or the macro, which are shown after its name.
+func signature(value ast.Expression) string {
+	var keyword string
+	var params []*ast.Identifier
+
+	switch fn := value.(type) {
+	case *ast.FunctionLiteral:
+		keyword, params = "fn", fn.Parameters
+	case *ast.MacroLiteral:
+		keyword, params = "macro", fn.Parameters
+	default:
+		return ""
+	}
+
+	names := make([]string, len(params))
+	for i, param := range params {
+		names[i] = param.Value
+	}
+
+	return " = " + keyword + "(" + strings.Join(names, ", ") + ")"
+}
+
+func (s *Server) definition(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	doc, ident, err := s.identifierAt(params, &p, &p)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+
+	def := doc.index.definitions[ident]
+	if def == nil {
+		return nil, nil
+	}
+
+	return []Location{doc.location(def.name)}, nil
+}
+
+func (s *Server) references(params json.RawMessage) (interface{}, error) {
+	var p ReferenceParams
+	doc, ident, err := s.identifierAt(params, &p, &p.TextDocumentPositionParams)
+	if err != nil || ident == nil {
+		return nil, err
+	}
+
+	def := doc.index.definitions[ident]
+	if def == nil {
+		return nil, nil
+	}
+
+	locations := []Location{}
+	if p.Context.IncludeDeclaration {
+		locations = append(locations, doc.location(def.name))
+	}
+	for _, ref := range def.references {
+		locations = append(locations, doc.location(ref))
+	}
+
+	sort.SliceStable(locations, func(i, j int) bool {
+		a, b := locations[i].Range.Start, locations[j].Range.Start
+		return a.Line < b.Line || (a.Line == b.Line && a.Character < b.Character)
+	})
+
+	return locations, nil
+}
+
+func (s *Server) documentSymbols(params json.RawMessage) (interface{}, error) {
+	var p DocumentSymbolParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	return symbols(doc, doc.program), nil
+}
+
+// symbols returns the symbols of the let statements in the node, the symbols of let statements
+// nested in their values are their children.
+func symbols(doc *document, node ast.Node) []DocumentSymbol {
+	result := []DocumentSymbol{}
+
+	ast.Inspect(node, func(n ast.Node) bool {
+		let, ok := n.(*ast.LetStatement)
+		if !ok || n == node {
+			return true
+		}
+
+		kind := SymbolKindVariable
+		switch let.Value.(type) {
+		case *ast.FunctionLiteral, *ast.MacroLiteral:
+			kind = SymbolKindFunction
+		}
+
+		symbol := DocumentSymbol{
+			Name:           let.Name.Value,
+			Detail:         inferKind(let.Value, doc.index, 0),
+			Kind:           kind,
+			Range:          doc.nodeRange(let),
+			SelectionRange: doc.nodeRange(let.Name),
+		}
+		if children := symbols(doc, let.Value); len(children) > 0 {
+			symbol.Children = children
+		}
+
+		result = append(result, symbol)
+		return false
+	})
+
+	return result
+}
+
+func (s *Server) completion(params json.RawMessage) (interface{}, error) {
+	var p TextDocumentPositionParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	offset := doc.offset(p.Position)
+	prefix := identifierPrefix(doc.text[:offset])
+
+	items := []CompletionItem{}
+	add := func(item CompletionItem) {
+		if strings.HasPrefix(item.Label, prefix) {
+			items = append(items, item)
+		}
+	}
+
+	visible := doc.index.visible(offset)
+	for name, def := range visible {
+		item := CompletionItem{Label: name, Kind: CompletionKindVariable, Detail: string(def.kind)}
+		if kind := inferKind(def.name, doc.index, 0); kind != "" {
+			item.Detail += " " + kind
+		}
+		if kind := inferKind(def.value, doc.index, 0); kind == "function" || kind == "macro" {
+			item.Kind, item.Detail = CompletionKindFunction, "let "+name+signature(def.value)
+		}
+		add(item)
+	}
+
+	for _, keyword := range token.Keywords() {
+		add(CompletionItem{Label: keyword, Kind: CompletionKindKeyword})
+	}
+
+	for _, builtin := range object.Builtins {
+		if _, shadowed := visible[builtin.Name]; !shadowed {
+			add(CompletionItem{Label: builtin.Name, Kind: CompletionKindFunction, Detail: "builtin function", Documentation: builtin.Builtin.Doc})
+		}
+	}
+
+	sort.SliceStable(items, func(i, j int) bool { return items[i].Label < items[j].Label })
+	return items, nil
+}
+
+// identifierPrefix returns the part of the identifier before the cursor.
+func identifierPrefix(text string) string {
+	i := len(text)
+	for i > 0 && isIdentifierByte(text[i-1]) {
+		i--
+	}
+
+	return text[i:]
+}
+
+func isIdentifierByte(b byte) bool {
+	return b == '_' || 'a' <= b && b <= 'z' || 'A' <= b && b <= 'Z' || '0' <= b && b <= '9'
+}
+
+func (s *Server) formatting(params json.RawMessage) (interface{}, error) {
+	var p DocumentFormattingParams
+	if err := decodeParams(params, &p); err != nil {
+		return nil, err
+	}
+
+	doc, err := s.document(p.TextDocument.URI)
+	if err != nil {
+		return nil, err
+	}
+
+	formatted, err := printer.Format(doc.uri, []byte(doc.text))
+	if err != nil {
+		// the document can't be formatted until the errors reported as diagnostics are fixed
+		return nil, nil
+	}
+	if string(formatted) == doc.text {
+		return []TextEdit{}, nil
+	}
+
+	whole := Range{Start: Position{}, End: doc.position(len(doc.text))}
+	return []TextEdit{{Range: whole, NewText: string(formatted)}}, nil
+}
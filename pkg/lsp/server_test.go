@@ -0,0 +1,445 @@
+package lsp
+
+import (
+	"encoding/json"
+	"io"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+const testURI = "file:///test.mk"
+
+// client talks to a server running in the same process, like an editor would.
+type client struct {
+	t             *testing.T
+	conn          *conn
+	nextID        int
+	responses     chan *message
+	notifications chan *message
+	done          chan error
+	close         func() error
+}
+
+func newClient(t *testing.T) *client {
+	serverIn, clientOut := io.Pipe()
+	clientIn, serverOut := io.Pipe()
+
+	c := &client{
+		t:             t,
+		conn:          newConn(clientIn, clientOut),
+		responses:     make(chan *message, 10),
+		notifications: make(chan *message, 10),
+		done:          make(chan error, 1),
+	}
+
+	go func() {
+		c.done <- NewServer().Serve(serverIn, serverOut)
+		serverOut.Close()
+	}()
+
+	go func() {
+		for {
+			msg, err := c.conn.read()
+			if err != nil {
+				close(c.responses)
+				return
+			}
+			if msg.ID == nil {
+				c.notifications <- msg
+			} else {
+				c.responses <- msg
+			}
+		}
+	}()
+
+	c.close = clientOut.Close
+
+	return c
+}
+
+func initializedClient(t *testing.T) *client {
+	c := newClient(t)
+	c.call("initialize", map[string]interface{}{}, nil)
+	c.notify("initialized", map[string]interface{}{})
+	return c
+}
+
+// call sends the request and decodes the result of the response, its error is returned.
+func (c *client) call(method string, params, result interface{}) *ResponseError {
+	c.nextID++
+
+	data, err := json.Marshal(params)
+	require.NoError(c.t, err)
+	id := json.RawMessage(strconv.Itoa(c.nextID))
+	require.NoError(c.t, c.conn.write(&message{ID: &id, Method: method, Params: data}))
+
+	select {
+	case msg := <-c.responses:
+		require.NotNil(c.t, msg, "connection closed")
+		assert.Equal(c.t, string(id), string(*msg.ID))
+		if msg.Error != nil {
+			return msg.Error
+		}
+		if result != nil {
+			require.NoError(c.t, json.Unmarshal(msg.Result, result))
+		}
+		return nil
+	case <-time.After(5 * time.Second):
+		c.t.Fatalf("no response to %s", method)
+		return nil
+	}
+}
+
+func (c *client) notify(method string, params interface{}) {
+	require.NoError(c.t, c.conn.notify(method, params))
+}
+
+// diagnostics returns the diagnostics published after the document was opened or changed.
+func (c *client) diagnostics() PublishDiagnosticsParams {
+	select {
+	case msg := <-c.notifications:
+		require.Equal(c.t, "textDocument/publishDiagnostics", msg.Method)
+		var params PublishDiagnosticsParams
+		require.NoError(c.t, json.Unmarshal(msg.Params, &params))
+		return params
+	case <-time.After(5 * time.Second):
+		c.t.Fatal("no diagnostics published")
+		return PublishDiagnosticsParams{}
+	}
+}
+
+func (c *client) open(text string) PublishDiagnosticsParams {
+	c.notify("textDocument/didOpen", DidOpenTextDocumentParams{
+		TextDocument: TextDocumentItem{URI: testURI, LanguageID: "monkey", Version: 1, Text: text},
+	})
+	return c.diagnostics()
+}
+
+func at(line, character int) TextDocumentPositionParams {
+	return TextDocumentPositionParams{TextDocument: TextDocumentIdentifier{URI: testURI}, Position: Position{Line: line, Character: character}}
+}
+
+func span(line, start, end int) Range {
+	return Range{Start: Position{Line: line, Character: start}, End: Position{Line: line, Character: end}}
+}
+
+func TestLifecycle(t *testing.T) {
+	c := newClient(t)
+	defer c.close()
+
+	err := c.call("textDocument/hover", at(0, 0), nil)
+	require.NotNil(t, err)
+	assert.Equal(t, codeServerNotInitialized, err.Code)
+
+	var result InitializeResult
+	require.Nil(t, c.call("initialize", map[string]interface{}{}, &result))
+	assert.Equal(t, TextDocumentSyncKindFull, result.Capabilities.TextDocumentSync)
+	assert.True(t, result.Capabilities.HoverProvider)
+	assert.Equal(t, serverName, result.ServerInfo.Name)
+
+	err = c.call("workspace/symbol", map[string]interface{}{}, nil)
+	require.NotNil(t, err)
+	assert.Equal(t, codeMethodNotFound, err.Code)
+
+	require.Nil(t, c.call("shutdown", nil, nil))
+
+	err = c.call("textDocument/hover", at(0, 0), nil)
+	require.NotNil(t, err)
+	assert.Equal(t, codeInvalidRequest, err.Code)
+
+	c.notify("exit", nil)
+
+	select {
+	case err := <-c.done:
+		assert.NoError(t, err)
+	case <-time.After(5 * time.Second):
+		t.Fatal("server didn't exit")
+	}
+}
+
+func TestDiagnostics(t *testing.T) {
+	c := initializedClient(t)
+	defer c.close()
+
+	params := c.open("let x = 1;\nlet = 2;")
+	assert.Equal(t, testURI, params.URI)
+	assert.Equal(t, 1, params.Version)
+	require.Len(t, params.Diagnostics, 1)
+	assert.Equal(t, SeverityError, params.Diagnostics[0].Severity)
+	assert.Equal(t, serverName, params.Diagnostics[0].Source)
+	assert.Equal(t, span(1, 4, 5), params.Diagnostics[0].Range)
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: testURI, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = {\"a\": 1, \"a\": 2};"}},
+	})
+	params = c.diagnostics()
+	assert.Equal(t, 2, params.Version)
+	require.Len(t, params.Diagnostics, 1)
+	assert.Equal(t, SeverityWarning, params.Diagnostics[0].Severity)
+
+	c.notify("textDocument/didClose", DidCloseTextDocumentParams{TextDocument: TextDocumentIdentifier{URI: testURI}})
+	params = c.diagnostics()
+	assert.Empty(t, params.Diagnostics)
+
+	err := c.call("textDocument/hover", at(0, 4), nil)
+	require.NotNil(t, err)
+	assert.Equal(t, codeInvalidParams, err.Code)
+}
+
+const testProgram = `let add = fn(a, b) { a + b };
+let x = add(1, 2);
+let s = "héllo" + "!";
+for (i, v in [1, 2]) { puts(i, v, len(s)); }
+`
+
+func TestHover(t *testing.T) {
+	testData := map[string]struct {
+		position TextDocumentPositionParams
+		contents string
+		rng      Range
+	}{
+		"function": {
+			position: at(0, 5),
+			contents: "```monkey\nlet add = fn(a, b)\n```\n\nfunction",
+			rng:      span(0, 4, 7),
+		},
+		"call result": {
+			position: at(1, 4),
+			contents: "```monkey\nlet x\n```",
+			rng:      span(1, 4, 5),
+		},
+		"parameter": {
+			position: at(0, 21),
+			contents: "```monkey\na\n```\n\nparameter of add",
+			rng:      span(0, 21, 22),
+		},
+		"string": {
+			position: at(3, 38),
+			contents: "```monkey\nlet s\n```\n\nstring",
+			rng:      span(3, 38, 39),
+		},
+		"loop variable": {
+			position: at(3, 28),
+			contents: "```monkey\ni\n```\n\nloop variable",
+			rng:      span(3, 28, 29),
+		},
+		"builtin": {
+			position: at(3, 25),
+			contents: "```monkey\nputs\n```\n\nbuiltin function\n\n" + mustBuiltinDoc("puts"),
+			rng:      span(3, 23, 27),
+		},
+	}
+
+	c := initializedClient(t)
+	defer c.close()
+	c.open(testProgram)
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			c.t = t
+
+			var hover Hover
+			require.Nil(t, c.call("textDocument/hover", data.position, &hover))
+			assert.Equal(t, "markdown", hover.Contents.Kind)
+			assert.Equal(t, data.contents, hover.Contents.Value)
+			require.NotNil(t, hover.Range)
+			assert.Equal(t, data.rng, *hover.Range)
+		})
+	}
+}
+
+func mustBuiltinDoc(name string) string {
+	doc, ok := builtinDoc(name)
+	if !ok {
+		panic("unknown builtin " + name)
+	}
+	return doc
+}
+
+func TestHoverOutsideIdentifiers(t *testing.T) {
+	c := initializedClient(t)
+	defer c.close()
+	c.open(testProgram)
+
+	var hover *Hover
+	require.Nil(t, c.call("textDocument/hover", at(1, 12), &hover))
+	assert.Nil(t, hover)
+}
+
+func TestDefinition(t *testing.T) {
+	testData := map[string]struct {
+		position   TextDocumentPositionParams
+		definition []Location
+	}{
+		"let binding": {
+			position:   at(1, 9),
+			definition: []Location{{URI: testURI, Range: span(0, 4, 7)}},
+		},
+		"parameter": {
+			position:   at(0, 25),
+			definition: []Location{{URI: testURI, Range: span(0, 16, 17)}},
+		},
+		"definition itself": {
+			position:   at(2, 4),
+			definition: []Location{{URI: testURI, Range: span(2, 4, 5)}},
+		},
+		"builtin": {
+			position:   at(3, 24),
+			definition: nil,
+		},
+	}
+
+	c := initializedClient(t)
+	defer c.close()
+	c.open(testProgram)
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			c.t = t
+
+			var definition []Location
+			require.Nil(t, c.call("textDocument/definition", data.position, &definition))
+			assert.Equal(t, data.definition, definition)
+		})
+	}
+}
+
+func TestReferences(t *testing.T) {
+	c := initializedClient(t)
+	defer c.close()
+	c.open("let f = fn(n) { if (n < 1) { 0 } else { f(n - 1) } };\nf(3);")
+
+	params := ReferenceParams{TextDocumentPositionParams: at(0, 11)}
+
+	var locations []Location
+	require.Nil(t, c.call("textDocument/references", params, &locations))
+	assert.Equal(t, []Location{{URI: testURI, Range: span(0, 20, 21)}, {URI: testURI, Range: span(0, 42, 43)}}, locations)
+
+	params = ReferenceParams{TextDocumentPositionParams: at(1, 0)}
+	params.Context.IncludeDeclaration = true
+
+	require.Nil(t, c.call("textDocument/references", params, &locations))
+	assert.Equal(t, []Location{
+		{URI: testURI, Range: span(0, 4, 5)},
+		{URI: testURI, Range: span(0, 40, 41)},
+		{URI: testURI, Range: span(1, 0, 1)},
+	}, locations)
+}
+
+func TestDocumentSymbols(t *testing.T) {
+	c := initializedClient(t)
+	defer c.close()
+	c.open("let f = fn() {\n  let y = 2;\n  y\n};\nlet z = f();")
+
+	var symbols []DocumentSymbol
+	require.Nil(t, c.call("textDocument/documentSymbol", DocumentSymbolParams{TextDocument: TextDocumentIdentifier{URI: testURI}}, &symbols))
+
+	assert.Equal(t, []DocumentSymbol{
+		{
+			Name:           "f",
+			Detail:         "function",
+			Kind:           SymbolKindFunction,
+			Range:          Range{Start: Position{Line: 0, Character: 0}, End: Position{Line: 3, Character: 1}},
+			SelectionRange: span(0, 4, 5),
+			Children: []DocumentSymbol{
+				{Name: "y", Detail: "integer", Kind: SymbolKindVariable, Range: span(1, 2, 11), SelectionRange: span(1, 6, 7)},
+			},
+		},
+		{Name: "z", Kind: SymbolKindVariable, Range: span(4, 0, 11), SelectionRange: span(4, 4, 5)},
+	}, symbols)
+}
+
+func TestCompletion(t *testing.T) {
+	testData := map[string]struct {
+		text     string
+		position TextDocumentPositionParams
+		labels   []string
+	}{
+		"builtins and keywords": {
+			text:     "le",
+			position: at(0, 2),
+			labels:   []string{"len", "let"},
+		},
+		"bindings": {
+			text:     "let total = 1;\nlet times = fn() {};\nt",
+			position: at(2, 1),
+			labels:   []string{"times", "total", "true"},
+		},
+		"parameters in functions only": {
+			text:     "let f = fn(count) { c };\nc",
+			position: at(0, 21),
+			labels:   []string{"ceil", "continue", "count"},
+		},
+		"parameters not visible outside": {
+			text:     "let f = fn(count) { c };\nco",
+			position: at(1, 2),
+			labels:   []string{"continue"},
+		},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			c := initializedClient(t)
+			defer c.close()
+			c.open(data.text)
+
+			var items []CompletionItem
+			require.Nil(t, c.call("textDocument/completion", data.position, &items))
+
+			labels := []string{}
+			for _, item := range items {
+				labels = append(labels, item.Label)
+			}
+			assert.Equal(t, data.labels, labels)
+		})
+	}
+}
+
+func TestCompletionDetails(t *testing.T) {
+	c := initializedClient(t)
+	defer c.close()
+	c.open("let greet = fn(name) { name };\nlet greeting = \"hi\";\ngre")
+
+	var items []CompletionItem
+	require.Nil(t, c.call("textDocument/completion", at(2, 3), &items))
+
+	assert.Equal(t, []CompletionItem{
+		{Label: "greet", Kind: CompletionKindFunction, Detail: "let greet = fn(name)"},
+		{Label: "greeting", Kind: CompletionKindVariable, Detail: "let string"},
+	}, items)
+}
+
+func TestFormatting(t *testing.T) {
+	c := initializedClient(t)
+	defer c.close()
+	params := DocumentFormattingParams{TextDocument: TextDocumentIdentifier{URI: testURI}}
+
+	c.open("let x=1;\nputs( x )")
+
+	var edits []TextEdit
+	require.Nil(t, c.call("textDocument/formatting", params, &edits))
+	require.Len(t, edits, 1)
+	assert.Equal(t, Range{End: Position{Line: 1, Character: 9}}, edits[0].Range)
+	assert.Equal(t, "let x = 1;\nputs(x);\n", edits[0].NewText)
+
+	c.notify("textDocument/didChange", DidChangeTextDocumentParams{
+		TextDocument:   VersionedTextDocumentIdentifier{URI: testURI, Version: 2},
+		ContentChanges: []TextDocumentContentChangeEvent{{Text: "let x = ;"}},
+	})
+	c.diagnostics()
+
+	edits = nil
+	require.Nil(t, c.call("textDocument/formatting", params, &edits))
+	assert.Nil(t, edits)
+}
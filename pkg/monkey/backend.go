@@ -0,0 +1,121 @@
+package monkey
+
+import (
+	"context"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/compiler"
+	"github.com/adrian83/monkey/pkg/evaluator"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/vm"
+)
+
+// backend executes programs keeping the global bindings between runs.
+type backend interface {
+	run(ctx context.Context, program *ast.Program, limits Limits) object.Object
+	define(name string, value object.Object)
+	bindings() map[string]object.Object
+}
+
+type evalBackend struct {
+	env      *object.Environment
+	builtins *object.Registry
+	hook     evaluator.Hook
+}
+
+func newEvalBackend(builtins *object.Registry, hook evaluator.Hook) *evalBackend {
+	return &evalBackend{env: object.NewEnvironment(), builtins: builtins, hook: hook}
+}
+
+func (b *evalBackend) run(ctx context.Context, program *ast.Program, limits Limits) object.Object {
+	opts := evaluator.Options{
+		MaxDepth:  limits.MaxDepth,
+		MaxSteps:  limits.MaxSteps,
+		MaxAllocs: limits.MaxAllocs,
+		Builtins:  b.builtins,
+		Hook:      b.hook,
+	}
+
+	return evaluator.EvalContext(ctx, program, b.env, opts)
+}
+
+func (b *evalBackend) define(name string, value object.Object) {
+	b.env.Set(name, value)
+}
+
+func (b *evalBackend) bindings() map[string]object.Object {
+	bindings := map[string]object.Object{}
+	for _, name := range b.env.Names() {
+		bindings[name], _ = b.env.Get(name)
+	}
+
+	return bindings
+}
+
+// vmBackend keeps symbols, constants and globals between runs, so that
+// bindings created in one run are visible in the following ones.
+type vmBackend struct {
+	symbolTable *compiler.SymbolTable
+	constants   []object.Object
+	globals     []object.Object
+	builtins    *object.Registry
+}
+
+func newVMBackend(builtins *object.Registry) *vmBackend {
+	return &vmBackend{
+		symbolTable: compiler.NewWithBuiltins(builtins).SymbolTable(),
+		constants:   []object.Object{},
+		globals:     vm.NewGlobals(),
+		builtins:    builtins,
+	}
+}
+
+func (b *vmBackend) run(ctx context.Context, program *ast.Program, limits Limits) object.Object {
+	b.defineBuiltins()
+
+	comp := compiler.NewWithState(b.symbolTable, b.constants)
+	if err := comp.Compile(program); err != nil {
+		if compileErr, ok := err.(*compiler.Error); ok {
+			return &object.Error{Message: "compilation failed: " + compileErr.Msg, Pos: compileErr.Pos}
+		}
+		return object.NewError("compilation failed: %v", err)
+	}
+
+	bytecode := comp.Bytecode()
+	b.constants = bytecode.Constants
+
+	opts := vm.Options{
+		MaxDepth:  limits.MaxDepth,
+		MaxSteps:  limits.MaxSteps,
+		MaxAllocs: limits.MaxAllocs,
+		Builtins:  b.builtins,
+	}
+
+	return vm.NewWithGlobals(bytecode, b.globals).RunContext(ctx, opts)
+}
+
+func (b *vmBackend) define(name string, value object.Object) {
+	symbol := b.symbolTable.Define(name)
+	b.globals[symbol.Index] = value
+}
+
+func (b *vmBackend) bindings() map[string]object.Object {
+	bindings := map[string]object.Object{}
+	for i, name := range b.symbolTable.Names() {
+		if name != "" && b.globals[i] != nil {
+			bindings[name] = b.globals[i]
+		}
+	}
+
+	return bindings
+}
+
+// defineBuiltins adds builtins registered after the creation of the backend
+// unless they are shadowed by globals.
+func (b *vmBackend) defineBuiltins() {
+	for i, name := range b.builtins.Names() {
+		if symbol, ok := b.symbolTable.Resolve(name); !ok || symbol.Scope == compiler.BuiltinScope {
+			b.symbolTable.DefineBuiltin(i, name)
+		}
+	}
+}
@@ -0,0 +1,232 @@
+// Package monkey allows to embed the Monkey interpreter in Go programs.
+package monkey
+
+import (
+	"context"
+	"fmt"
+	"io/ioutil"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/evaluator"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+// Engine selects the backend which executes parsed programs.
+type Engine string
+
+const (
+	EngineEval Engine = "eval"
+	EngineVM   Engine = "vm"
+)
+
+// Limits restricts resources used by a single run, zero values mean no limit except for MaxDepth
+// which is evaluator.DefaultMaxDepth then.
+type Limits struct {
+	MaxDepth  int // maximum number of nested function calls
+	MaxSteps  int // maximum number of evaluated nodes or executed instructions
+	MaxAllocs int // maximum number of created objects
+}
+
+type Option func(*Interpreter)
+
+func WithEngine(engine Engine) Option {
+	return func(i *Interpreter) {
+		i.engine = engine
+	}
+}
+
+func WithLimits(limits Limits) Option {
+	return func(i *Interpreter) {
+		i.limits = limits
+	}
+}
+
+// WithHook makes the evaluator call the hook before evaluation of each statement and expression,
+// see evaluator.Hook. Hooks are supported only by the eval engine.
+func WithHook(hook evaluator.Hook) Option {
+	return func(i *Interpreter) {
+		i.hook = hook
+	}
+}
+
+// RuntimeError is returned when the execution of a program fails.
+type RuntimeError struct {
+	Kind    object.ErrorKind
+	Message string
+	Pos     token.Position
+	Trace   []object.StackFrame
+}
+
+func newRuntimeError(err *object.Error) *RuntimeError {
+	return &RuntimeError{Kind: err.Kind, Message: err.Message, Pos: err.Pos, Trace: err.Trace}
+}
+
+func (e *RuntimeError) Error() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("%v: %v", e.Pos, e.Message)
+	}
+
+	return e.Message
+}
+
+// StackTrace formats the calls active when the error occurred, see object.Error.
+func (e *RuntimeError) StackTrace() string {
+	err := &object.Error{Kind: e.Kind, Message: e.Message, Pos: e.Pos, Trace: e.Trace}
+	return err.StackTrace()
+}
+
+// Interpreter runs Monkey code. Bindings created by one run are visible in the following ones.
+type Interpreter struct {
+	engine   Engine
+	limits   Limits
+	hook     evaluator.Hook
+	builtins *object.Registry
+	backend  backend
+	macros   *object.Environment // macros defined by the previous runs
+}
+
+func New(options ...Option) (*Interpreter, error) {
+	i := &Interpreter{engine: EngineEval, builtins: object.NewRegistry(), macros: object.NewEnvironment()}
+
+	for _, option := range options {
+		option(i)
+	}
+
+	switch i.engine {
+	case EngineEval:
+		i.backend = newEvalBackend(i.builtins, i.hook)
+	case EngineVM:
+		if i.hook != nil {
+			return nil, fmt.Errorf("hooks are not supported by the %s engine", i.engine)
+		}
+		i.backend = newVMBackend(i.builtins)
+	default:
+		return nil, fmt.Errorf("unknown engine: %s", i.engine)
+	}
+
+	return i, nil
+}
+
+// Register makes the Go function available to the code under the given name, see
+// object.FromGo for conversion of arguments and results and object.Registry.RegisterValue
+// for namespaced names.
+func (i *Interpreter) Register(name string, fn interface{}) error {
+	value, err := object.FromGo(fn)
+	if err != nil {
+		return err
+	}
+
+	if _, ok := value.(*object.Builtin); !ok {
+		return fmt.Errorf("%s is not a function, got %T", name, fn)
+	}
+
+	return i.builtins.RegisterValue(name, value)
+}
+
+// Define creates a global binding holding the Go value converted with object.FromGo.
+func (i *Interpreter) Define(name string, value interface{}) error {
+	obj, err := object.FromGo(value)
+	if err != nil {
+		return err
+	}
+
+	i.backend.define(name, obj)
+
+	return nil
+}
+
+// Bindings returns the values of the global bindings and the macros, they don't include builtins.
+func (i *Interpreter) Bindings() map[string]object.Object {
+	bindings := i.backend.bindings()
+	for _, name := range i.macros.Names() {
+		bindings[name], _ = i.macros.Get(name)
+	}
+
+	return bindings
+}
+
+// Builtins returns the builtin values including the registered ones.
+func (i *Interpreter) Builtins() map[string]object.Object {
+	builtins := map[string]object.Object{}
+	for _, name := range i.builtins.Names() {
+		builtins[name], _ = i.builtins.Lookup(name)
+	}
+
+	return builtins
+}
+
+// Registry returns the registry of the builtins, code evaluated outside of the interpreter, e.g. by
+// a debugger, has to use it to see the registered builtins.
+func (i *Interpreter) Registry() *object.Registry {
+	return i.builtins
+}
+
+// Run executes the source and returns the result converted to a Go value.
+func (i *Interpreter) Run(source string) (interface{}, error) {
+	return i.RunContext(context.Background(), source)
+}
+
+func (i *Interpreter) RunContext(ctx context.Context, source string) (interface{}, error) {
+	result, err := i.Eval(ctx, "", source)
+	if err != nil {
+		return nil, err
+	}
+
+	return native(result)
+}
+
+// RunFile executes the file and returns the result converted to a Go value.
+func (i *Interpreter) RunFile(path string) (interface{}, error) {
+	source, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	result, err := i.Eval(context.Background(), path, string(source))
+	if err != nil {
+		return nil, err
+	}
+
+	return native(result)
+}
+
+// Eval executes the source and returns the result. The error is a parser.ErrorList
+// if the source is not valid or a *RuntimeError if the execution failed.
+func (i *Interpreter) Eval(ctx context.Context, filename, source string) (object.Object, error) {
+	p := parser.New(lexer.NewFile(filename, source))
+
+	program, err := p.ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+
+	result := i.EvalProgram(ctx, program)
+	if errObj, ok := result.(*object.Error); ok {
+		return nil, newRuntimeError(errObj)
+	}
+
+	return result, nil
+}
+
+// EvalProgram expands macros and executes the parsed program, errors are returned as *object.Error.
+// Macro definitions are removed from the program, the macros remain available to the following runs.
+func (i *Interpreter) EvalProgram(ctx context.Context, program *ast.Program) object.Object {
+	evaluator.DefineMacros(program, i.macros)
+
+	opts := evaluator.Options{MaxDepth: i.limits.MaxDepth, MaxSteps: i.limits.MaxSteps, MaxAllocs: i.limits.MaxAllocs, Builtins: i.builtins}
+	expanded, err := evaluator.ExpandMacrosContext(ctx, program, i.macros, opts)
+	if err != nil {
+		return err
+	}
+
+	return i.backend.run(ctx, expanded.(*ast.Program), i.limits)
+}
+
+func native(obj object.Object) (interface{}, error) {
+	var result interface{}
+	err := object.ToGo(obj, &result)
+	return result, err
+}
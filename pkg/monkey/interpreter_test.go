@@ -0,0 +1,339 @@
+package monkey
+
+import (
+	"context"
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/evaluator"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+var engines = []Engine{EngineEval, EngineVM}
+
+func upper(args ...object.Object) object.Object {
+	if len(args) != 1 {
+		return object.NewError("wrong number of arguments. got=%d, want=1", len(args))
+	}
+
+	str, ok := args[0].(*object.String)
+	if !ok {
+		return object.NewError("argument to `upper` must be STRING, got %s", args[0].Type())
+	}
+
+	return object.NewString(strings.ToUpper(str.Value))
+}
+
+func newInterpreter(t *testing.T, engine Engine, options ...Option) *Interpreter {
+	interpreter, err := New(append(options, WithEngine(engine))...)
+	assert.NoError(t, err)
+
+	assert.NoError(t, interpreter.Register("upper", upper))
+	assert.NoError(t, interpreter.Register("strings.upper", upper))
+	assert.NoError(t, interpreter.Define("answer", 42))
+
+	return interpreter
+}
+
+func TestRun(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected interface{}
+	}{
+		"integer":            {"1 + 2", int64(3)},
+		"string":             {`"a" + "b"`, "ab"},
+		"boolean":            {"1 < 2", true},
+		"null":               {"if (false) { 1 }", nil},
+		"array":              {`[1, "a", [true]]`, []interface{}{int64(1), "a", []interface{}{true}}},
+		"hash":               {`{"a": 1, 2: false}`, map[interface{}]interface{}{"a": int64(1), int64(2): false}},
+		"host function":      {`upper("monkey")`, "MONKEY"},
+		"namespace function": {`strings["upper"]("monkey")`, "MONKEY"},
+		"predefined global":  {"answer * 2", int64(84)},
+		"standard builtin":   {`len(upper("abc"))`, int64(3)},
+	}
+
+	for _, engine := range engines {
+		for name, tc := range testData {
+			tcase := tc
+			t.Run(string(engine)+"/"+name, func(t *testing.T) {
+				interpreter := newInterpreter(t, engine)
+
+				result, err := interpreter.Run(tcase.input)
+
+				assert.NoError(t, err)
+				assert.Equal(t, tcase.expected, result)
+			})
+		}
+	}
+}
+
+func TestRunKeepsBindings(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+
+		_, err := interpreter.Run("let double = fn(x) { x * 2 };")
+		assert.NoError(t, err)
+
+		assert.NoError(t, interpreter.Register("triple", func(args ...object.Object) object.Object {
+			return object.NewInteger(args[0].(*object.Integer).Value * 3)
+		}))
+
+		result, err := interpreter.Run("triple(double(answer))")
+		assert.NoError(t, err)
+		assert.Equal(t, int64(252), result, engine)
+	}
+}
+
+func TestBindings(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+
+		_, err := interpreter.Run("let x = 1; let f = fn() { let local = 2; local }; let m = macro() { quote(1) }; f();")
+		assert.NoError(t, err, engine)
+
+		bindings := interpreter.Bindings()
+
+		var names []string
+		for name := range bindings {
+			names = append(names, name)
+		}
+		assert.ElementsMatch(t, []string{"answer", "x", "f", "m"}, names, engine)
+		assert.Equal(t, "1", bindings["x"].Inspect(), engine)
+		assert.IsType(t, &object.Macro{}, bindings["m"], engine)
+	}
+}
+
+func TestRunExpandsMacros(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+
+		_, err := interpreter.Run(`let unless = macro(cond, cons, alt) { quote(if (!(unquote(cond))) { unquote(cons) } else { unquote(alt) }) };`)
+		assert.NoError(t, err, engine)
+
+		result, err := interpreter.Run(`let check = fn(x) { unless(x > 5, upper("small"), "big") }; [check(1), check(10)]`)
+		assert.NoError(t, err, engine)
+		assert.Equal(t, []interface{}{"SMALL", "big"}, result, engine)
+
+		_, err = interpreter.Run("unless(true)")
+		runtimeErr, ok := err.(*RuntimeError)
+		assert.True(t, ok, engine)
+		assert.Equal(t, "1:1: wrong number of arguments: want=3, got=1", runtimeErr.Error(), engine)
+	}
+}
+
+func TestRunErrors(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine, WithLimits(Limits{MaxDepth: 50}))
+
+		_, err := interpreter.Run("let = 5;")
+		assert.IsType(t, parser.ErrorList{}, err, engine)
+
+		_, err = interpreter.Run(`let f = fn() { upper(1) };` + "\nf()")
+		runtimeErr, ok := err.(*RuntimeError)
+		assert.True(t, ok, engine)
+		assert.Equal(t, object.ErrorRuntime, runtimeErr.Kind, engine)
+		assert.Equal(t, "1:16: argument to `upper` must be STRING, got INTEGER", runtimeErr.Error(), engine)
+		assert.Equal(t, "f(...)\n\t1:16\nmain()\n\t2:1\n", runtimeErr.StackTrace(), engine)
+
+		_, err = interpreter.Run("let loop = fn() { loop() }; loop()")
+		runtimeErr, ok = err.(*RuntimeError)
+		assert.True(t, ok, engine)
+		assert.Equal(t, object.ErrorDepthExceeded, runtimeErr.Kind, engine)
+	}
+}
+
+func TestRunUnboundedRecursion(t *testing.T) {
+	for _, engine := range engines {
+		interpreter, err := New(WithEngine(engine))
+		assert.NoError(t, err)
+
+		_, err = interpreter.Run("let f = fn() { f() }; f()")
+		runtimeErr, ok := err.(*RuntimeError)
+		if assert.True(t, ok, engine) {
+			assert.Equal(t, object.ErrorDepthExceeded, runtimeErr.Kind, engine)
+			assert.Equal(t, "maximum call depth of 1024 exceeded", runtimeErr.Message, engine)
+			assert.True(t, len(runtimeErr.Trace) >= evaluator.DefaultMaxDepth, engine)
+			assert.Equal(t, object.MainFrame, runtimeErr.Trace[len(runtimeErr.Trace)-1].Function, engine)
+		}
+	}
+}
+
+func TestRunQuote(t *testing.T) {
+	result, err := newInterpreter(t, EngineEval).Run("quote(1 + 2)")
+	assert.NoError(t, err)
+	assert.Equal(t, "QUOTE((1 + 2))", result.(object.Object).Inspect())
+
+	_, err = newInterpreter(t, EngineVM).Run("quote(1 + 2)")
+	assert.EqualError(t, err, "1:1: compilation failed: quote is not supported by the vm engine")
+}
+
+func TestRunCompilationError(t *testing.T) {
+	_, err := newInterpreter(t, EngineVM).Run("let a = 1;\nlen = a")
+
+	runtimeErr, ok := err.(*RuntimeError)
+	if assert.True(t, ok, "expected *RuntimeError, got %T", err) {
+		assert.Equal(t, 2, runtimeErr.Pos.Line)
+		assert.EqualError(t, runtimeErr, "2:1: compilation failed: cannot assign to builtin: len")
+	}
+}
+
+func TestRunContainerContainingItself(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine, WithLimits(Limits{MaxSteps: 1000, MaxAllocs: 1000}))
+		assert.NoError(t, interpreter.Register("inspect", func(args ...object.Object) object.Object {
+			return object.NewString(args[0].Inspect())
+		}))
+
+		result, err := interpreter.Run("let a = [0]; a[0] = a; inspect(a)")
+		assert.NoError(t, err, engine)
+		assert.Equal(t, "[[...]]", result, engine)
+
+		_, err = interpreter.Run("a")
+		assert.EqualError(t, err, "cannot convert ARRAY which contains itself", engine)
+	}
+}
+
+func TestRunFile(t *testing.T) {
+	file, err := ioutil.TempFile("", "script-*.monkey")
+	assert.NoError(t, err)
+	defer os.Remove(file.Name())
+
+	_, err = file.WriteString("let x = 2;\nx + y")
+	assert.NoError(t, err)
+	assert.NoError(t, file.Close())
+
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+
+		_, err = interpreter.RunFile(file.Name())
+
+		assert.EqualError(t, err, file.Name()+":2:5: identifier not found: y", engine)
+	}
+}
+
+func TestRegisterErrors(t *testing.T) {
+	interpreter, err := New()
+	assert.NoError(t, err)
+
+	assert.Error(t, interpreter.Register("", upper))
+	assert.Error(t, interpreter.Register("1upper", upper))
+	assert.Error(t, interpreter.Register("upper2", upper))
+	assert.Error(t, interpreter.Register("while", upper))
+	assert.Error(t, interpreter.Register("strings.fn", upper))
+	assert.Error(t, interpreter.Register("a.b.c", upper))
+	assert.Error(t, interpreter.Register("len.upper", upper))
+}
+
+func TestRegisterUnicodeNames(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+		assert.NoError(t, interpreter.Register("wielkie", upper), engine)
+		assert.NoError(t, interpreter.Register("łańcuchy.wielkie", upper), engine)
+
+		result, err := interpreter.Run(`wielkie(łańcuchy["wielkie"]("żółw"))`)
+		assert.NoError(t, err, engine)
+		assert.Equal(t, "ŻÓŁW", result, engine)
+	}
+}
+
+func TestRegisterManyBuiltins(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+
+		for i := 0; i < 300; i++ {
+			n := i
+			name := "builtin" + string(rune('a'+n/26)) + string(rune('a'+n%26))
+			assert.NoError(t, interpreter.Register(name, func() int { return n }), engine)
+		}
+
+		result, err := interpreter.Run(`[builtinaa(), builtinjz(), builtinln()]`)
+		assert.NoError(t, err, engine)
+		assert.Equal(t, []interface{}{int64(0), int64(259), int64(299)}, result, engine)
+	}
+}
+
+func TestNamespacesAreFrozen(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+
+		_, err := interpreter.Run(`strings["upper"] = len;`)
+		assert.EqualError(t, err, "1:18: cannot assign to a frozen hash", engine)
+
+		result, err := interpreter.Run(`strings["upper"]("a")`)
+		assert.NoError(t, err, engine)
+		assert.Equal(t, "A", result, engine)
+	}
+}
+
+func TestUnknownEngine(t *testing.T) {
+	_, err := New(WithEngine("jit"))
+	assert.EqualError(t, err, "unknown engine: jit")
+}
+
+func TestHook(t *testing.T) {
+	var lines []int
+	hook := func(n ast.Node, env *object.Environment, calls []evaluator.Frame) error {
+		if _, ok := n.(*ast.LetStatement); ok {
+			lines = append(lines, n.Pos().Line)
+		}
+		return nil
+	}
+
+	interpreter, err := New(WithHook(hook))
+	assert.NoError(t, err)
+
+	result, err := interpreter.Run("let x = 1;\nlet y = x + 1;\ny")
+	assert.NoError(t, err)
+	assert.Equal(t, int64(2), result)
+	assert.Equal(t, []int{1, 2}, lines)
+
+	_, err = New(WithEngine(EngineVM), WithHook(hook))
+	assert.EqualError(t, err, "hooks are not supported by the vm engine")
+}
+
+func TestRunContextCancelled(t *testing.T) {
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+
+		ctx, cancel := context.WithCancel(context.Background())
+		cancel()
+
+		_, err := interpreter.RunContext(ctx, "let f = fn(n) { if (n == 0) { 0 } else { f(n - 1) } }; f(1000)")
+		runtimeErr, ok := err.(*RuntimeError)
+		assert.True(t, ok, engine)
+		assert.Equal(t, object.ErrorCancelled, runtimeErr.Kind, engine)
+	}
+}
+
+func TestGoValues(t *testing.T) {
+	type point struct {
+		X int `monkey:"x"`
+		Y int `monkey:"y"`
+	}
+
+	for _, engine := range engines {
+		interpreter := newInterpreter(t, engine)
+
+		assert.NoError(t, interpreter.Register("repeat", strings.Repeat))
+		assert.NoError(t, interpreter.Register("strings.fields", strings.Fields))
+		assert.NoError(t, interpreter.Register("shift", func(p point, dx int) point {
+			return point{X: p.X + dx, Y: p.Y}
+		}))
+		assert.NoError(t, interpreter.Define("origin", point{}))
+
+		result, err := interpreter.Run(`let p = shift(origin, 2); [p["x"], p["y"], repeat("ab", 2), strings["fields"](" a b ")]`)
+		assert.NoError(t, err, engine)
+		assert.Equal(t, []interface{}{int64(2), int64(0), "abab", []interface{}{"a", "b"}}, result, engine)
+
+		_, err = interpreter.Run(`repeat("ab")`)
+		assert.EqualError(t, err, "1:1: wrong number of arguments. got=1, want=2", engine)
+
+		assert.EqualError(t, interpreter.Register("origin", 1), "origin is not a function, got int")
+	}
+}
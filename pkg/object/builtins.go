@@ -0,0 +1,390 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+// Builtins are the standard builtin functions, NewRegistry registers them in this order.
+var Builtins = []struct {
+	Name    string
+	Builtin *Builtin
+}{
+	{
+		"len",
+		&Builtin{Doc: "len(x) returns the number of elements of an array or a range or the number of characters of a string", Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Array:
+				return NewInteger(int64(len(arg.Elements)))
+			case *String:
+				return NewInteger(int64(utf8.RuneCountInString(arg.Value)))
+			case *Range:
+				return NewInteger(arg.Len())
+			default:
+				return NewError("argument to `len` not supported, got %s", args[0].Type())
+			}
+		}},
+	},
+	{
+		"first",
+		&Builtin{Doc: "first(array) returns the first element of the array or null if it is empty", Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != TypeArray {
+				return NewError("argument to `first` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			if len(arr.Elements) > 0 {
+				return arr.Elements[0]
+			}
+
+			return NullValue
+		}},
+	},
+	{
+		"last",
+		&Builtin{Doc: "last(array) returns the last element of the array or null if it is empty", Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != TypeArray {
+				return NewError("argument to `last` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				return arr.Elements[length-1]
+			}
+
+			return NullValue
+		}},
+	},
+	{
+		"rest",
+		&Builtin{Doc: "rest(array) returns a new array without the first element or null if the array is empty", Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			if args[0].Type() != TypeArray {
+				return NewError("argument to `rest` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+			if length > 0 {
+				newElements := make([]Object, length-1)
+				copy(newElements, arr.Elements[1:length])
+				return &Array{Elements: newElements}
+			}
+
+			return NullValue
+		}},
+	},
+	{
+		"push",
+		&Builtin{Doc: "push(array, x) returns a new array with x appended", Fn: func(args ...Object) Object {
+			if len(args) != 2 {
+				return NewError("wrong number of arguments. got=%d, want=2", len(args))
+			}
+
+			if args[0].Type() != TypeArray {
+				return NewError("argument to `push` must be ARRAY, got %s", args[0].Type())
+			}
+
+			arr := args[0].(*Array)
+			length := len(arr.Elements)
+
+			newElements := make([]Object, length+1)
+			copy(newElements, arr.Elements)
+			newElements[length] = args[1]
+
+			return &Array{Elements: newElements}
+		}},
+	},
+	{
+		"puts",
+		&Builtin{Doc: "puts(x...) prints the values, each on its own line", Fn: func(args ...Object) Object {
+			for _, arg := range args {
+				fmt.Println(arg.Inspect())
+			}
+
+			return NullValue
+		}},
+	},
+	{
+		"int",
+		&Builtin{Doc: "int(x) converts a float, truncating it, or a decimal string to an integer", Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer:
+				return arg
+			case *Float:
+				if math.IsNaN(arg.Value) || arg.Value < math.MinInt64 || arg.Value >= math.MaxInt64 {
+					return NewError("cannot convert %s to %s", arg.Inspect(), TypeInteger)
+				}
+				return NewInteger(int64(arg.Value))
+			case *String:
+				value, err := strconv.ParseInt(arg.Value, 10, 64)
+				if err != nil {
+					return NewError("could not parse %q as integer", arg.Value)
+				}
+				return NewInteger(value)
+			default:
+				return NewError("argument to `int` not supported, got %s", args[0].Type())
+			}
+		}},
+	},
+	{
+		"float",
+		&Builtin{Doc: "float(x) converts an integer or a string to a float", Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			switch arg := args[0].(type) {
+			case *Integer:
+				return NewFloat(float64(arg.Value))
+			case *Float:
+				return arg
+			case *String:
+				value, err := strconv.ParseFloat(arg.Value, 64)
+				if err != nil {
+					return NewError("could not parse %q as float", arg.Value)
+				}
+				return NewFloat(value)
+			default:
+				return NewError("argument to `float` not supported, got %s", args[0].Type())
+			}
+		}},
+	},
+	{"round", roundingBuiltin("round", "to the nearest integer, rounding half away from zero", math.Round)},
+	{"floor", roundingBuiltin("floor", "down to an integer", math.Floor)},
+	{"ceil", roundingBuiltin("ceil", "up to an integer", math.Ceil)},
+	{
+		"range",
+		&Builtin{Doc: "range(start, stop, step) returns integers from start to stop exclusive, start 0 and step 1 are optional", Fn: func(args ...Object) Object {
+			if len(args) < 1 || len(args) > 3 {
+				return NewError("wrong number of arguments. got=%d, want=1..3", len(args))
+			}
+
+			bounds := make([]int64, len(args))
+			for i, arg := range args {
+				integer, ok := arg.(*Integer)
+				if !ok {
+					return NewError("arguments to `range` must be INTEGER, got %s", arg.Type())
+				}
+				bounds[i] = integer.Value
+			}
+
+			r := &Range{Start: 0, Step: 1}
+			switch len(bounds) {
+			case 1:
+				r.Stop = bounds[0]
+			case 2:
+				r.Start, r.Stop = bounds[0], bounds[1]
+			case 3:
+				r.Start, r.Stop, r.Step = bounds[0], bounds[1], bounds[2]
+			}
+
+			if r.Step == 0 {
+				return NewError("step of `range` must not be zero")
+			}
+			if r.count() > math.MaxInt64 {
+				return NewError("%s has more than %d elements", r.Inspect(), int64(math.MaxInt64))
+			}
+
+			return r
+		}},
+	},
+	{
+		"bytes",
+		&Builtin{Doc: "bytes(string) returns the bytes of the UTF-8 encoded string", Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			str, ok := args[0].(*String)
+			if !ok {
+				return NewError("argument to `bytes` must be STRING, got %s", args[0].Type())
+			}
+
+			elements := make([]Object, len(str.Value))
+			for i := 0; i < len(str.Value); i++ {
+				elements[i] = NewInteger(int64(str.Value[i]))
+			}
+
+			return &Array{Elements: elements}
+		}},
+	},
+	{
+		"runes",
+		&Builtin{Doc: "runes(string) returns the code points of the string", Fn: func(args ...Object) Object {
+			if len(args) != 1 {
+				return NewError("wrong number of arguments. got=%d, want=1", len(args))
+			}
+
+			str, ok := args[0].(*String)
+			if !ok {
+				return NewError("argument to `runes` must be STRING, got %s", args[0].Type())
+			}
+
+			elements := make([]Object, 0, len(str.Value))
+			for _, r := range str.Value {
+				elements = append(elements, NewInteger(int64(r)))
+			}
+
+			return &Array{Elements: elements}
+		}},
+	},
+}
+
+// roundingBuiltin creates a builtin which rounds floats with the given function,
+// integers are returned unchanged.
+func roundingBuiltin(name, how string, round func(float64) float64) *Builtin {
+	doc := fmt.Sprintf("%s(x) rounds the float %s, an integer is returned unchanged", name, how)
+
+	return &Builtin{Doc: doc, Fn: func(args ...Object) Object {
+		if len(args) != 1 {
+			return NewError("wrong number of arguments. got=%d, want=1", len(args))
+		}
+
+		switch arg := args[0].(type) {
+		case *Integer:
+			return arg
+		case *Float:
+			return NewFloat(round(arg.Value))
+		default:
+			return NewError("argument to `%s` must be INTEGER or FLOAT, got %s", name, args[0].Type())
+		}
+	}}
+}
+
+func GetBuiltinByName(name string) *Builtin {
+	for _, def := range Builtins {
+		if def.Name == name {
+			return def.Builtin
+		}
+	}
+
+	return nil
+}
+
+// Registry is an ordered collection of builtin values available in every scope. The evaluator
+// looks them up by name, the compiler and the vm refer to them by their index.
+type Registry struct {
+	names  []string
+	values map[string]Object
+}
+
+// NewRegistry creates a registry with the standard builtin functions.
+func NewRegistry() *Registry {
+	r := &Registry{values: make(map[string]Object)}
+	for _, def := range Builtins {
+		r.Register(def.Name, def.Builtin)
+	}
+
+	return r
+}
+
+// Register adds the value under the given name, a value registered under the same name
+// before is replaced and keeps its index.
+func (r *Registry) Register(name string, value Object) int {
+	if _, ok := r.values[name]; !ok {
+		r.names = append(r.names, name)
+	}
+
+	r.values[name] = value
+
+	return r.Index(name)
+}
+
+// RegisterFunction registers a builtin function, see RegisterValue.
+func (r *Registry) RegisterFunction(name string, fn BuiltinFunction) error {
+	return r.RegisterValue(name, &Builtin{Fn: fn})
+}
+
+// RegisterValue registers the value under the name. A name like "strings.upper" registers
+// the value in a namespace, which is a frozen hash available under the name "strings",
+// so that scripts refer to it as strings["upper"].
+func (r *Registry) RegisterValue(name string, value Object) error {
+	parts := strings.Split(name, ".")
+
+	for _, part := range parts {
+		if !token.IsIdentifier(part) {
+			return fmt.Errorf("invalid builtin name: %q", name)
+		}
+	}
+
+	switch len(parts) {
+	case 1:
+		r.Register(name, value)
+		return nil
+	case 2:
+		return r.registerInNamespace(parts[0], parts[1], value)
+	default:
+		return fmt.Errorf("nested namespaces are not supported: %q", name)
+	}
+}
+
+func (r *Registry) registerInNamespace(namespace, name string, member Object) error {
+	value, ok := r.values[namespace]
+	if !ok {
+		value = &Hash{Pairs: make(map[HashKey]HashPair), Frozen: true}
+		r.Register(namespace, value)
+	}
+
+	hash, ok := value.(*Hash)
+	if !ok {
+		return fmt.Errorf("%s is already registered as %s", namespace, value.Type())
+	}
+
+	key := NewString(name)
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: member}
+
+	return nil
+}
+
+func (r *Registry) Lookup(name string) (Object, bool) {
+	value, ok := r.values[name]
+	return value, ok
+}
+
+// Index returns the index of the name or -1 if the name is not registered.
+func (r *Registry) Index(name string) int {
+	for i, n := range r.names {
+		if n == name {
+			return i
+		}
+	}
+
+	return -1
+}
+
+func (r *Registry) Get(index int) Object {
+	return r.values[r.names[index]]
+}
+
+// Names returns the registered names ordered by their index.
+func (r *Registry) Names() []string {
+	names := make([]string, len(r.names))
+	copy(names, r.names)
+	return names
+}
@@ -0,0 +1,490 @@
+package object
+
+import (
+	"fmt"
+	"reflect"
+	"strings"
+)
+
+// tagName is the name of the struct tag which overrides the hash key of a field,
+// e.g. `monkey:"name"`, fields tagged with `monkey:"-"` are skipped.
+const tagName = "monkey"
+
+var (
+	objectType = reflect.TypeOf((*Object)(nil)).Elem()
+	errorType  = reflect.TypeOf((*error)(nil)).Elem()
+)
+
+// FromGo converts the Go value to a Monkey object. Numbers, strings and booleans are converted
+// to their Monkey counterparts, slices and arrays to arrays, maps and structs to hashes, nil values
+// to null and functions to builtins which convert their arguments and results.
+func FromGo(value interface{}) (Object, error) {
+	switch v := value.(type) {
+	case nil:
+		return NullValue, nil
+	case Object:
+		return v, nil
+	case BuiltinFunction:
+		return &Builtin{Fn: v}, nil
+	case func(args ...Object) Object:
+		return &Builtin{Fn: v}, nil
+	}
+
+	return fromValue(reflect.ValueOf(value), make(map[reference]bool))
+}
+
+// reference identifies a pointer, a map or a slice being converted, the type distinguishes
+// e.g. a struct from its first field and the length a slice from its prefix.
+type reference struct {
+	ptr uintptr
+	len int
+	typ reflect.Type
+}
+
+func referenceOf(v reflect.Value) (reference, bool) {
+	switch v.Kind() {
+	case reflect.Ptr, reflect.Map:
+		return reference{ptr: v.Pointer(), typ: v.Type()}, !v.IsNil()
+	case reflect.Slice:
+		return reference{ptr: v.Pointer(), len: v.Len(), typ: v.Type()}, !v.IsNil()
+	default:
+		return reference{}, false
+	}
+}
+
+// fromValue converts the value, visiting holds references being converted, so that values which
+// refer to themselves are reported instead of being converted forever.
+func fromValue(v reflect.Value, visiting map[reference]bool) (Object, error) {
+	if ref, ok := referenceOf(v); ok {
+		if visiting[ref] {
+			return nil, fmt.Errorf("cannot convert %s which refers to itself", v.Type())
+		}
+		visiting[ref] = true
+		defer delete(visiting, ref)
+	}
+
+	if v.IsValid() && v.Type().Implements(objectType) && v.CanInterface() {
+		if v.Kind() == reflect.Ptr && v.IsNil() {
+			return NullValue, nil
+		}
+
+		return v.Interface().(Object), nil
+	}
+
+	switch v.Kind() {
+	case reflect.Invalid:
+		return NullValue, nil
+	case reflect.Bool:
+		if v.Bool() {
+			return TrueValue, nil
+		}
+		return FalseValue, nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		return NewInteger(v.Int()), nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if v.Uint() > 1<<63-1 {
+			return nil, fmt.Errorf("value %d overflows %s", v.Uint(), TypeInteger)
+		}
+		return NewInteger(int64(v.Uint())), nil
+	case reflect.Float32, reflect.Float64:
+		return NewFloat(v.Float()), nil
+	case reflect.String:
+		return NewString(v.String()), nil
+	case reflect.Slice, reflect.Array:
+		if v.Kind() == reflect.Slice && v.IsNil() {
+			return NullValue, nil
+		}
+		return arrayFromValue(v, visiting)
+	case reflect.Map:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return hashFromMap(v, visiting)
+	case reflect.Struct:
+		return hashFromStruct(v, visiting)
+	case reflect.Ptr, reflect.Interface:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return fromValue(v.Elem(), visiting)
+	case reflect.Func:
+		if v.IsNil() {
+			return NullValue, nil
+		}
+		return wrapFunc(v), nil
+	default:
+		return nil, fmt.Errorf("cannot convert %s to a Monkey value", v.Type())
+	}
+}
+
+func arrayFromValue(v reflect.Value, visiting map[reference]bool) (Object, error) {
+	elements := make([]Object, v.Len())
+
+	for i := range elements {
+		element, err := fromValue(v.Index(i), visiting)
+		if err != nil {
+			return nil, fmt.Errorf("index %d: %v", i, err)
+		}
+
+		elements[i] = element
+	}
+
+	return &Array{Elements: elements}, nil
+}
+
+func hashFromMap(v reflect.Value, visiting map[reference]bool) (Object, error) {
+	hash := &Hash{Pairs: make(map[HashKey]HashPair, v.Len())}
+
+	iter := v.MapRange()
+	for iter.Next() {
+		key, err := fromValue(iter.Key(), visiting)
+		if err != nil {
+			return nil, err
+		}
+
+		hashable, ok := key.(Hashable)
+		if !ok {
+			return nil, fmt.Errorf("unusable as hash key: %s", key.Type())
+		}
+
+		value, err := fromValue(iter.Value(), visiting)
+		if err != nil {
+			return nil, fmt.Errorf("key %s: %v", key.Inspect(), err)
+		}
+
+		hash.Pairs[hashable.HashKey()] = HashPair{Key: key, Value: value}
+	}
+
+	return hash, nil
+}
+
+func hashFromStruct(v reflect.Value, visiting map[reference]bool) (Object, error) {
+	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := fieldName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		value, err := fromValue(v.Field(i), visiting)
+		if err != nil {
+			return nil, fmt.Errorf("field %s: %v", name, err)
+		}
+
+		key := NewString(name)
+		hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: value}
+	}
+
+	return hash, nil
+}
+
+// fieldName returns the hash key of the struct field and false if the field is skipped.
+func fieldName(field reflect.StructField) (string, bool) {
+	if field.PkgPath != "" {
+		return "", false
+	}
+
+	tag := strings.Split(field.Tag.Get(tagName), ",")[0]
+	switch tag {
+	case "-":
+		return "", false
+	case "":
+		return field.Name, true
+	default:
+		return tag, true
+	}
+}
+
+// wrapFunc creates a builtin calling the Go function. The number of arguments is checked
+// and they are converted to types of the parameters. A single result is converted to
+// a Monkey value, multiple results to an array. A non nil error returned as the last
+// result becomes an error object.
+func wrapFunc(fn reflect.Value) *Builtin {
+	fnType := fn.Type()
+
+	return &Builtin{Fn: func(args ...Object) Object {
+		numIn := fnType.NumIn()
+
+		if fnType.IsVariadic() {
+			if len(args) < numIn-1 {
+				return NewError("wrong number of arguments. got=%d, want at least %d", len(args), numIn-1)
+			}
+		} else if len(args) != numIn {
+			return NewError("wrong number of arguments. got=%d, want=%d", len(args), numIn)
+		}
+
+		in := make([]reflect.Value, len(args))
+		for i, arg := range args {
+			paramType := variadicParamType(fnType, i)
+
+			param := reflect.New(paramType).Elem()
+			if err := convertTo(arg, param); err != nil {
+				return NewError("argument %d: %v", i+1, err)
+			}
+
+			in[i] = param
+		}
+
+		return fromResults(fn.Call(in))
+	}}
+}
+
+func variadicParamType(fnType reflect.Type, i int) reflect.Type {
+	if fnType.IsVariadic() && i >= fnType.NumIn()-1 {
+		return fnType.In(fnType.NumIn() - 1).Elem()
+	}
+
+	return fnType.In(i)
+}
+
+func fromResults(results []reflect.Value) Object {
+	if n := len(results); n > 0 && results[n-1].Type() == errorType {
+		if err, _ := results[n-1].Interface().(error); err != nil {
+			return NewError("%v", err)
+		}
+
+		results = results[:n-1]
+	}
+
+	visiting := make(map[reference]bool)
+
+	switch len(results) {
+	case 0:
+		return NullValue
+	case 1:
+		result, err := fromValue(results[0], visiting)
+		if err != nil {
+			return NewError("%v", err)
+		}
+		return result
+	default:
+		array, err := arrayFromValue(reflect.ValueOf(valuesToInterfaces(results)), visiting)
+		if err != nil {
+			return NewError("%v", err)
+		}
+		return array
+	}
+}
+
+func valuesToInterfaces(values []reflect.Value) []interface{} {
+	result := make([]interface{}, len(values))
+	for i, value := range values {
+		result[i] = value.Interface()
+	}
+	return result
+}
+
+// ToGo stores the object in the value pointed to by target. Integers are converted to
+// integers and floats of any size, floats to floats, arrays to slices and arrays, hashes
+// to maps and structs, null to nil pointers, slices and maps. If target points to an
+// empty interface the object is converted to int64, float64, string, bool, []interface{},
+// map[interface{}]interface{} or nil, other objects, e.g. functions, are stored unchanged.
+// Arrays and hashes which contain themselves can't be converted.
+func ToGo(obj Object, target interface{}) error {
+	v := reflect.ValueOf(target)
+	if v.Kind() != reflect.Ptr || v.IsNil() {
+		return fmt.Errorf("target must be a non nil pointer, got %T", target)
+	}
+
+	return convertTo(obj, v.Elem())
+}
+
+// convertTo stores the object in the value unless it contains itself, see toValue.
+func convertTo(obj Object, v reflect.Value) error {
+	if obj != nil && !storedUnchanged(obj, v) && ContainsItself(obj) {
+		return fmt.Errorf("cannot convert %s which contains itself", obj.Type())
+	}
+
+	return toValue(obj, v)
+}
+
+// storedUnchanged reports whether the object is stored in the value as it is, without a conversion.
+func storedUnchanged(obj Object, v reflect.Value) bool {
+	emptyInterface := v.Kind() == reflect.Interface && v.NumMethod() == 0
+	return !emptyInterface && reflect.TypeOf(obj).AssignableTo(v.Type())
+}
+
+func toValue(obj Object, v reflect.Value) error {
+	if obj == nil {
+		obj = NullValue
+	}
+
+	if storedUnchanged(obj, v) {
+		v.Set(reflect.ValueOf(obj))
+		return nil
+	}
+
+	if _, ok := obj.(*Null); ok {
+		switch v.Kind() {
+		case reflect.Ptr, reflect.Interface, reflect.Slice, reflect.Map, reflect.Func:
+			v.Set(reflect.Zero(v.Type()))
+			return nil
+		}
+	}
+
+	switch v.Kind() {
+	case reflect.Interface:
+		if v.NumMethod() > 0 {
+			break
+		}
+		native := toNative(obj)
+		if native == nil {
+			v.Set(reflect.Zero(v.Type()))
+		} else {
+			v.Set(reflect.ValueOf(native))
+		}
+		return nil
+	case reflect.Bool:
+		if b, ok := obj.(*Boolean); ok {
+			v.SetBool(b.Value)
+			return nil
+		}
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if i, ok := obj.(*Integer); ok {
+			if v.OverflowInt(i.Value) {
+				return fmt.Errorf("value %d overflows %s", i.Value, v.Type())
+			}
+			v.SetInt(i.Value)
+			return nil
+		}
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if i, ok := obj.(*Integer); ok {
+			if i.Value < 0 || v.OverflowUint(uint64(i.Value)) {
+				return fmt.Errorf("value %d overflows %s", i.Value, v.Type())
+			}
+			v.SetUint(uint64(i.Value))
+			return nil
+		}
+	case reflect.Float32, reflect.Float64:
+		switch n := obj.(type) {
+		case *Float:
+			v.SetFloat(n.Value)
+			return nil
+		case *Integer:
+			v.SetFloat(float64(n.Value))
+			return nil
+		}
+	case reflect.String:
+		if s, ok := obj.(*String); ok {
+			v.SetString(s.Value)
+			return nil
+		}
+	case reflect.Slice:
+		if a, ok := obj.(*Array); ok {
+			slice := reflect.MakeSlice(v.Type(), len(a.Elements), len(a.Elements))
+			if err := elementsToValue(a.Elements, slice); err != nil {
+				return err
+			}
+			v.Set(slice)
+			return nil
+		}
+	case reflect.Array:
+		if a, ok := obj.(*Array); ok {
+			if len(a.Elements) != v.Len() {
+				return fmt.Errorf("cannot convert %s of length %d to %s", obj.Type(), len(a.Elements), v.Type())
+			}
+			return elementsToValue(a.Elements, v)
+		}
+	case reflect.Map:
+		if h, ok := obj.(*Hash); ok {
+			return hashToMap(h, v)
+		}
+	case reflect.Struct:
+		if h, ok := obj.(*Hash); ok {
+			return hashToStruct(h, v)
+		}
+	case reflect.Ptr:
+		elem := reflect.New(v.Type().Elem())
+		if err := toValue(obj, elem.Elem()); err != nil {
+			return err
+		}
+		v.Set(elem)
+		return nil
+	}
+
+	return fmt.Errorf("cannot convert %s to %s", obj.Type(), v.Type())
+}
+
+func elementsToValue(elements []Object, v reflect.Value) error {
+	for i, element := range elements {
+		if err := toValue(element, v.Index(i)); err != nil {
+			return fmt.Errorf("index %d: %v", i, err)
+		}
+	}
+
+	return nil
+}
+
+func hashToMap(h *Hash, v reflect.Value) error {
+	m := reflect.MakeMapWithSize(v.Type(), len(h.Pairs))
+
+	for _, pair := range h.Pairs {
+		key := reflect.New(v.Type().Key()).Elem()
+		if err := toValue(pair.Key, key); err != nil {
+			return fmt.Errorf("key %s: %v", pair.Key.Inspect(), err)
+		}
+
+		value := reflect.New(v.Type().Elem()).Elem()
+		if err := toValue(pair.Value, value); err != nil {
+			return fmt.Errorf("key %s: %v", pair.Key.Inspect(), err)
+		}
+
+		m.SetMapIndex(key, value)
+	}
+
+	v.Set(m)
+
+	return nil
+}
+
+// hashToStruct sets fields of the struct to values stored under their names, keys
+// which don't match any field are ignored.
+func hashToStruct(h *Hash, v reflect.Value) error {
+	for i := 0; i < v.NumField(); i++ {
+		name, ok := fieldName(v.Type().Field(i))
+		if !ok {
+			continue
+		}
+
+		pair, ok := h.Pairs[NewString(name).HashKey()]
+		if !ok {
+			continue
+		}
+
+		if err := toValue(pair.Value, v.Field(i)); err != nil {
+			return fmt.Errorf("field %s: %v", name, err)
+		}
+	}
+
+	return nil
+}
+
+func toNative(obj Object) interface{} {
+	switch obj := obj.(type) {
+	case *Null:
+		return nil
+	case *Integer:
+		return obj.Value
+	case *Float:
+		return obj.Value
+	case *String:
+		return obj.Value
+	case *Boolean:
+		return obj.Value
+	case *Array:
+		elements := make([]interface{}, len(obj.Elements))
+		for i, element := range obj.Elements {
+			elements[i] = toNative(element)
+		}
+		return elements
+	case *Hash:
+		pairs := make(map[interface{}]interface{}, len(obj.Pairs))
+		for _, pair := range obj.Pairs {
+			pairs[toNative(pair.Key)] = toNative(pair.Value)
+		}
+		return pairs
+	default:
+		return obj
+	}
+}
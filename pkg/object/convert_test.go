@@ -0,0 +1,225 @@
+package object
+
+import (
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+type person struct {
+	Name    string
+	Age     int    `monkey:"age"`
+	Email   string `monkey:"-"`
+	private bool
+}
+
+type account struct {
+	ID      int    `monkey:"id"`
+	Secret  string `monkey:"-"`
+	balance int
+}
+
+func TestFromGo(t *testing.T) {
+	var nilPointer *account
+
+	testData := map[string]struct {
+		value    interface{}
+		expected string
+	}{
+		"nil":            {nil, "null"},
+		"int":            {42, "42"},
+		"uint8":          {uint8(7), "7"},
+		"float":          {1.5, "1.5"},
+		"string":         {"monkey", "monkey"},
+		"bool":           {true, "true"},
+		"slice":          {[]int{1, 2}, "[1, 2]"},
+		"array":          {[2]string{"a", "b"}, "[a, b]"},
+		"nested":         {[][]bool{{true}, {}}, "[[true], []]"},
+		"map":            {map[string]int{"a": 1}, "{a: 1}"},
+		"struct":         {account{ID: 1, Secret: "x", balance: 2}, "{id: 1}"},
+		"pointer":        {&account{ID: 1}, "{id: 1}"},
+		"nil pointer":    {nilPointer, "null"},
+		"nil slice":      {[]int(nil), "null"},
+		"object":         {NewInteger(3), "3"},
+		"object in data": {[]Object{NewString("x")}, "[x]"},
+		"function":       {strings.ToUpper, "builtin function"},
+	}
+
+	for name, tc := range testData {
+		tcase := tc
+		t.Run(name, func(t *testing.T) {
+			obj, err := FromGo(tcase.value)
+
+			assert.NoError(t, err)
+			assert.Equal(t, tcase.expected, obj.Inspect())
+		})
+	}
+}
+
+type node struct {
+	Value int
+	Next  *node
+}
+
+func TestFromGoErrors(t *testing.T) {
+	cyclicList := &node{Value: 1}
+	cyclicList.Next = &node{Value: 2, Next: cyclicList}
+	cyclicMap := map[string]interface{}{}
+	cyclicMap["self"] = cyclicMap
+
+	testData := map[string]struct {
+		value    interface{}
+		expected string
+	}{
+		"channel":        {make(chan int), "cannot convert chan int to a Monkey value"},
+		"uint overflow":  {uint64(1 << 63), "value 9223372036854775808 overflows INTEGER"},
+		"map key":        {map[[1]int]int{{1}: 1}, "unusable as hash key: ARRAY"},
+		"nested":         {[]interface{}{1, make(chan int)}, "index 1: cannot convert chan int to a Monkey value"},
+		"cyclic pointer": {cyclicList, "field Next: field Next: cannot convert *object.node which refers to itself"},
+		"cyclic map":     {cyclicMap, "key self: cannot convert map[string]interface {} which refers to itself"},
+	}
+
+	for name, tc := range testData {
+		tcase := tc
+		t.Run(name, func(t *testing.T) {
+			_, err := FromGo(tcase.value)
+
+			assert.EqualError(t, err, tcase.expected)
+		})
+	}
+}
+
+func TestFromGoSharedPointer(t *testing.T) {
+	shared := &node{Value: 1}
+
+	obj, err := FromGo([]*node{shared, shared})
+
+	assert.NoError(t, err)
+	assert.Equal(t, 2, len(obj.(*Array).Elements))
+}
+
+func TestToGo(t *testing.T) {
+	hash, _ := FromGo(map[string]interface{}{"Name": "Ann", "age": 30, "Email": "x", "other": 1})
+
+	var i8 int8
+	assert.NoError(t, ToGo(NewInteger(-3), &i8))
+	assert.Equal(t, int8(-3), i8)
+
+	var f float32
+	assert.NoError(t, ToGo(NewInteger(2), &f))
+	assert.Equal(t, float32(2), f)
+
+	var strs []string
+	assert.NoError(t, ToGo(&Array{Elements: []Object{NewString("a"), NewString("b")}}, &strs))
+	assert.Equal(t, []string{"a", "b"}, strs)
+
+	var m map[string]int
+	assert.NoError(t, ToGo(mustFromGo(t, map[string]int{"a": 1}), &m))
+	assert.Equal(t, map[string]int{"a": 1}, m)
+
+	var p person
+	assert.NoError(t, ToGo(hash, &p))
+	assert.Equal(t, person{Name: "Ann", Age: 30}, p)
+
+	var pp *person
+	assert.NoError(t, ToGo(hash, &pp))
+	assert.Equal(t, &person{Name: "Ann", Age: 30}, pp)
+	assert.NoError(t, ToGo(NullValue, &pp))
+	assert.Nil(t, pp)
+
+	var native interface{}
+	assert.NoError(t, ToGo(mustFromGo(t, []interface{}{1, "a", nil, map[bool]bool{true: false}}), &native))
+	assert.Equal(t, []interface{}{int64(1), "a", nil, map[interface{}]interface{}{true: false}}, native)
+
+	var obj Object
+	assert.NoError(t, ToGo(NewString("s"), &obj))
+	assert.Equal(t, NewString("s"), obj)
+
+	var integer *Integer
+	assert.NoError(t, ToGo(NewInteger(1), &integer))
+	assert.Equal(t, int64(1), integer.Value)
+}
+
+func TestToGoErrors(t *testing.T) {
+	var i8 int8
+	assert.EqualError(t, ToGo(NewInteger(300), &i8), "value 300 overflows int8")
+
+	var u uint
+	assert.EqualError(t, ToGo(NewInteger(-1), &u), "value -1 overflows uint")
+
+	var s string
+	assert.EqualError(t, ToGo(NewInteger(1), &s), "cannot convert INTEGER to string")
+
+	var ints []int
+	assert.EqualError(t, ToGo(mustFromGo(t, []interface{}{1, "a"}), &ints), "index 1: cannot convert STRING to int")
+
+	var arr [3]int
+	assert.EqualError(t, ToGo(mustFromGo(t, []int{1}), &arr), "cannot convert ARRAY of length 1 to [3]int")
+
+	assert.EqualError(t, ToGo(NewInteger(1), s), "target must be a non nil pointer, got string")
+
+	self := &Array{}
+	self.Elements = []Object{NewInteger(1), &Array{Elements: []Object{self}}}
+
+	var native interface{}
+	assert.EqualError(t, ToGo(self, &native), "cannot convert ARRAY which contains itself")
+
+	var obj Object
+	assert.NoError(t, ToGo(self, &obj))
+	assert.Equal(t, self, obj)
+}
+
+func TestWrappedFunction(t *testing.T) {
+	divide := func(a, b int) (int, error) {
+		if b == 0 {
+			return 0, errors.New("division by zero")
+		}
+		return a / b, nil
+	}
+	self := &Array{}
+	self.Elements = []Object{self}
+	cut := func(s, sep string) (string, string, bool) {
+		i := strings.Index(s, sep)
+		if i < 0 {
+			return s, "", false
+		}
+		return s[:i], s[i+len(sep):], true
+	}
+
+	testData := map[string]struct {
+		fn       interface{}
+		args     []Object
+		expected string
+	}{
+		"result":             {strings.Repeat, []Object{NewString("ab"), NewInteger(2)}, "abab"},
+		"no result":          {func(string) {}, []Object{NewString("a")}, "null"},
+		"error result":       {divide, []Object{NewInteger(1), NewInteger(0)}, "ERROR: division by zero"},
+		"value and nil err":  {divide, []Object{NewInteger(6), NewInteger(2)}, "3"},
+		"multiple results":   {cut, []Object{NewString("a=b"), NewString("=")}, "[a, b, true]"},
+		"variadic":           {strings.Join, []Object{mustFromGo(t, []string{"a", "b"}), NewString("-")}, "a-b"},
+		"variadic arguments": {func(xs ...int) int { return len(xs) }, []Object{NewInteger(1), NewInteger(2)}, "2"},
+		"too few arguments":  {strings.Repeat, []Object{NewString("a")}, "ERROR: wrong number of arguments. got=1, want=2"},
+		"too many variadic":  {func(a int, xs ...int) {}, []Object{}, "ERROR: wrong number of arguments. got=0, want at least 1"},
+		"wrong argument":     {strings.Repeat, []Object{NewString("a"), NewString("b")}, "ERROR: argument 2: cannot convert STRING to int"},
+		"builtin function":   {func(args ...Object) Object { return args[0] }, []Object{NewInteger(1)}, "1"},
+		"cyclic argument":    {func(xs []interface{}) int { return len(xs) }, []Object{self}, "ERROR: argument 1: cannot convert ARRAY which contains itself"},
+	}
+
+	for name, tc := range testData {
+		tcase := tc
+		t.Run(name, func(t *testing.T) {
+			builtin, ok := mustFromGo(t, tcase.fn).(*Builtin)
+			assert.True(t, ok)
+
+			assert.Equal(t, tcase.expected, builtin.Fn(tcase.args...).Inspect())
+		})
+	}
+}
+
+func mustFromGo(t *testing.T, value interface{}) Object {
+	obj, err := FromGo(value)
+	assert.NoError(t, err)
+	return obj
+}
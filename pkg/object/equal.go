@@ -0,0 +1,104 @@
+package object
+
+// Equal reports whether the objects are equal. Numbers, strings, booleans and null
+// are compared by value (an integer is equal to a float with the same value), arrays
+// and hashes by their contents and all other objects, e.g. functions, by identity.
+func Equal(a, b Object) bool {
+	return equal(a, b, make(map[[2]Object]bool))
+}
+
+// equal compares the objects, visited holds pairs of arrays and hashes being compared,
+// so that comparison of containers which contain themselves terminates.
+func equal(a, b Object, visited map[[2]Object]bool) bool {
+	if a == nil || b == nil {
+		return a == b
+	}
+
+	if x, y, ok := numbers(a, b); ok {
+		return x == y
+	}
+
+	if a.Type() != b.Type() {
+		return false
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		return a.Value == b.(*Integer).Value
+	case *String:
+		return a.Value == b.(*String).Value
+	case *Boolean:
+		return a.Value == b.(*Boolean).Value
+	case *Null:
+		return true
+	case *Array:
+		return equalArrays(a, b.(*Array), visited)
+	case *Hash:
+		return equalHashes(a, b.(*Hash), visited)
+	default:
+		return a == b
+	}
+}
+
+func equalArrays(a, b *Array, visited map[[2]Object]bool) bool {
+	if a == b || visited[[2]Object{a, b}] {
+		return true
+	}
+
+	if len(a.Elements) != len(b.Elements) {
+		return false
+	}
+
+	visited[[2]Object{a, b}] = true
+
+	for i := range a.Elements {
+		if !equal(a.Elements[i], b.Elements[i], visited) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func equalHashes(a, b *Hash, visited map[[2]Object]bool) bool {
+	if a == b || visited[[2]Object{a, b}] {
+		return true
+	}
+
+	if len(a.Pairs) != len(b.Pairs) {
+		return false
+	}
+
+	visited[[2]Object{a, b}] = true
+
+	for key, pair := range a.Pairs {
+		other, ok := b.Pairs[key]
+		if !ok || !equal(pair.Key, other.Key, visited) || !equal(pair.Value, other.Value, visited) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// numbers returns values of the objects as floats if one of them is a float and
+// the other one is a float or an integer.
+func numbers(a, b Object) (float64, float64, bool) {
+	x, aIsFloat := a.(*Float)
+	y, bIsFloat := b.(*Float)
+
+	switch {
+	case aIsFloat && bIsFloat:
+		return x.Value, y.Value, true
+	case aIsFloat:
+		if i, ok := b.(*Integer); ok {
+			return x.Value, float64(i.Value), true
+		}
+	case bIsFloat:
+		if i, ok := a.(*Integer); ok {
+			return float64(i.Value), y.Value, true
+		}
+	}
+
+	return 0, 0, false
+}
@@ -0,0 +1,173 @@
+package object
+
+import (
+	"fmt"
+	"math"
+	"sort"
+)
+
+// Range is a sequence of integers from Start up to, but not including, Stop.
+type Range struct {
+	Start int64
+	Stop  int64
+	Step  int64
+}
+
+func (r *Range) Type() ObjectType {
+	return TypeRange
+}
+
+func (r *Range) Inspect() string {
+	if r.Step == 1 {
+		return fmt.Sprintf("range(%d, %d)", r.Start, r.Stop)
+	}
+
+	return fmt.Sprintf("range(%d, %d, %d)", r.Start, r.Stop, r.Step)
+}
+
+// Len returns the number of integers in the range, ranges created by the range builtin have at most
+// math.MaxInt64 of them.
+func (r *Range) Len() int64 {
+	if n := r.count(); n <= math.MaxInt64 {
+		return int64(n)
+	}
+
+	return math.MaxInt64
+}
+
+// count returns the number of integers in the range, the differences of the bounds are computed
+// on unsigned integers as they may not fit in int64.
+func (r *Range) count() uint64 {
+	switch {
+	case r.Step > 0 && r.Start < r.Stop:
+		return (uint64(r.Stop)-uint64(r.Start)-1)/uint64(r.Step) + 1
+	case r.Step < 0 && r.Start > r.Stop:
+		return (uint64(r.Start)-uint64(r.Stop)-1)/-uint64(r.Step) + 1
+	default:
+		return 0
+	}
+}
+
+// Iterator yields pairs of keys and values of an iterable object.
+type Iterator struct {
+	next     func() (key, value Object, ok bool)
+	keysOnly bool // loops with a single variable iterate over keys instead of values
+}
+
+func (it *Iterator) Type() ObjectType {
+	return TypeIterator
+}
+
+func (it *Iterator) Inspect() string {
+	return "iterator"
+}
+
+// Next returns the next pair and false when there are no more pairs.
+func (it *Iterator) Next() (key, value Object, ok bool) {
+	return it.next()
+}
+
+// NextValue returns the next element, that is the key of a hash pair and the value otherwise,
+// and false when there are no more elements.
+func (it *Iterator) NextValue() (Object, bool) {
+	key, value, ok := it.next()
+	if it.keysOnly {
+		return key, ok
+	}
+
+	return value, ok
+}
+
+// NewIterator creates an iterator over the object. Arrays yield indexes and elements, strings
+// indexes and characters, ranges indexes and integers and hashes keys and values ordered by keys.
+// A single element of a hash is the key, for other objects it's the value.
+func NewIterator(iterable Object) (*Iterator, error) {
+	switch iterable := iterable.(type) {
+	case *Array:
+		return indexIterator(func(i int64) (Object, bool) {
+			if i >= int64(len(iterable.Elements)) {
+				return nil, false
+			}
+			return iterable.Elements[i], true
+		}), nil
+	case *String:
+		chars := []rune(iterable.Value)
+		return indexIterator(func(i int64) (Object, bool) {
+			if i >= int64(len(chars)) {
+				return nil, false
+			}
+			return NewString(string(chars[i])), true
+		}), nil
+	case *Range:
+		length := iterable.Len()
+		return indexIterator(func(i int64) (Object, bool) {
+			if i >= length {
+				return nil, false
+			}
+			return NewInteger(iterable.Start + i*iterable.Step), true
+		}), nil
+	case *Hash:
+		pairs := SortedPairs(iterable)
+		i := 0
+		return &Iterator{keysOnly: true, next: func() (Object, Object, bool) {
+			if i >= len(pairs) {
+				return nil, nil, false
+			}
+			i++
+			return pairs[i-1].Key, pairs[i-1].Value, true
+		}}, nil
+	default:
+		return nil, fmt.Errorf("cannot iterate over %s", iterable.Type())
+	}
+}
+
+func indexIterator(element func(int64) (Object, bool)) *Iterator {
+	var i int64
+
+	return &Iterator{next: func() (Object, Object, bool) {
+		value, ok := element(i)
+		if !ok {
+			return nil, nil, false
+		}
+		i++
+		return NewInteger(i - 1), value, true
+	}}
+}
+
+// SortedPairs returns pairs of the hash ordered by keys. Numbers are ordered by their values,
+// strings lexicographically, false before true and keys of different types by type names.
+func SortedPairs(hash *Hash) []HashPair {
+	pairs := make([]HashPair, 0, len(hash.Pairs))
+	for _, pair := range hash.Pairs {
+		pairs = append(pairs, pair)
+	}
+
+	sort.Slice(pairs, func(i, j int) bool {
+		return keyLess(pairs[i].Key, pairs[j].Key)
+	})
+
+	return pairs
+}
+
+func keyLess(a, b Object) bool {
+	if x, y, ok := numbers(a, b); ok {
+		return x < y
+	}
+
+	switch a := a.(type) {
+	case *Integer:
+		if b, ok := b.(*Integer); ok {
+			return a.Value < b.Value
+		}
+	case *String:
+		if b, ok := b.(*String); ok {
+			return a.Value < b.Value
+		}
+	case *Boolean:
+		if b, ok := b.(*Boolean); ok {
+			return !a.Value && b.Value
+		}
+	}
+
+	return a.Type() < b.Type()
+}
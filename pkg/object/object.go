@@ -3,13 +3,18 @@ package object
 import (
 	"fmt"
 	"hash/fnv"
+	"math"
+	"strconv"
 	"strings"
 
 	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/code"
+	"github.com/adrian83/monkey/pkg/token"
 )
 
 const (
 	TypeInteger  = "INTEGER"
+	TypeFloat    = "FLOAT"
 	TypeString   = "STRING"
 	TypeBoolean  = "BOOLEAN"
 	TypeArray    = "ARRAY"
@@ -18,8 +23,23 @@ const (
 	TypeFunction = "FUNCTION"
 	TypeBuiltin  = "BUILTIN"
 	TypeHash     = "HASH"
+	TypeRange    = "RANGE"
+	TypeIterator = "ITERATOR"
+	TypeCell     = "CELL"
+	TypeQuote    = "QUOTE"
+	TypeMacro    = "MACRO"
 
-	ReturnVal = "RETURN_VALUE"
+	TypeCompiledFunction = "COMPILED_FUNCTION"
+
+	ReturnVal   = "RETURN_VALUE"
+	BreakVal    = "BREAK"
+	ContinueVal = "CONTINUE"
+)
+
+var (
+	NullValue  = &Null{}
+	TrueValue  = NewBoolean(true)
+	FalseValue = NewBoolean(false)
 )
 
 type ObjectType string
@@ -57,6 +77,38 @@ func (i *Integer) HashKey() HashKey {
 	return HashKey{Type: i.Type(), Value: uint64(i.Value)}
 }
 
+func NewFloat(val float64) *Float {
+	return &Float{
+		TypedObject: &TypedObject{objType: TypeFloat},
+		Value:       val,
+	}
+}
+
+type Float struct {
+	*TypedObject
+	Value float64
+}
+
+// Inspect formats the float so that it's distinguishable from an integer, e.g. 3.0 instead of 3.
+func (f *Float) Inspect() string {
+	s := strconv.FormatFloat(f.Value, 'g', -1, 64)
+	if strings.ContainsAny(s, ".eIN") {
+		return s
+	}
+
+	return s + ".0"
+}
+
+// HashKey of a float with an integral value is the same as the key of the equal integer,
+// so that 1 and 1.0 refer to the same value in a hash.
+func (f *Float) HashKey() HashKey {
+	if f.Value >= math.MinInt64 && f.Value < math.MaxInt64 && f.Value == math.Trunc(f.Value) {
+		return NewInteger(int64(f.Value)).HashKey()
+	}
+
+	return HashKey{Type: f.Type(), Value: math.Float64bits(f.Value)}
+}
+
 func NewString(val string) *String {
 	return &String{
 		TypedObject: &TypedObject{objType: TypeString},
@@ -127,8 +179,75 @@ func (rv *ReturnValue) Inspect() string {
 	return rv.Value.Inspect()
 }
 
+// LoopControl is the result of break and continue statements, it stops evaluation
+// of statements up to the enclosing loop.
+type LoopControl struct {
+	Break bool
+}
+
+var (
+	BreakValue    = &LoopControl{Break: true}
+	ContinueValue = &LoopControl{Break: false}
+)
+
+func (lc *LoopControl) Type() ObjectType {
+	if lc.Break {
+		return BreakVal
+	}
+
+	return ContinueVal
+}
+
+func (lc *LoopControl) Inspect() string {
+	if lc.Break {
+		return "break"
+	}
+
+	return "continue"
+}
+
+// MainFrame is the name of the outermost frame in stack traces, it represents the top level code.
+const MainFrame = "main"
+
+// maxTraceFrames limits the number of frames printed by StackTrace.
+const maxTraceFrames = 100
+
+type StackFrame struct {
+	Function string         // name of the called function, empty if the function is anonymous
+	Pos      token.Position // position of the evaluated expression in the function
+}
+
+type ErrorKind int
+
+const (
+	ErrorRuntime ErrorKind = iota
+	ErrorCancelled
+	ErrorDepthExceeded
+	ErrorBudgetExhausted
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorCancelled:
+		return "cancelled"
+	case ErrorDepthExceeded:
+		return "depth exceeded"
+	case ErrorBudgetExhausted:
+		return "budget exhausted"
+	default:
+		return "runtime"
+	}
+}
+
 type Error struct {
+	Kind    ErrorKind // ErrorRuntime unless the evaluation was interrupted
 	Message string
+	Pos     token.Position // position of the expression which caused the error
+	Trace   []StackFrame   // call stack at the moment of the error, the innermost call first
+}
+
+func NewError(format string, a ...interface{}) *Error {
+	return &Error{Message: fmt.Sprintf(format, a...)}
 }
 
 func (e *Error) Type() ObjectType {
@@ -136,10 +255,40 @@ func (e *Error) Type() ObjectType {
 }
 
 func (e *Error) Inspect() string {
+	if e.Pos.IsValid() {
+		return fmt.Sprintf("ERROR: %s: %s", e.Pos, e.Message)
+	}
+
 	return "ERROR: " + e.Message
 }
 
+// StackTrace formats the call stack of the error similarly to the Go panic traces.
+func (e *Error) StackTrace() string {
+	var out strings.Builder
+
+	for i, frame := range e.Trace {
+		if i == maxTraceFrames {
+			fmt.Fprintf(&out, "...%d additional frames elided...\n", len(e.Trace)-i)
+			break
+		}
+
+		switch {
+		case i == len(e.Trace)-1 && frame.Function == MainFrame:
+			out.WriteString(MainFrame + "()\n")
+		case frame.Function == "":
+			out.WriteString("fn(...)\n")
+		default:
+			out.WriteString(frame.Function + "(...)\n")
+		}
+
+		fmt.Fprintf(&out, "\t%s\n", frame.Pos)
+	}
+
+	return out.String()
+}
+
 type Function struct {
+	Name       string // name of the binding if the function was bound with 'let'
 	Parameters []*ast.Identifier
 	Body       *ast.BlockStatement
 	Env        *Environment
@@ -158,6 +307,40 @@ func (f *Function) Inspect() string {
 	return fmt.Sprintf("fn(%v) {\n%v\n}", strings.Join(params, ", "), f.Body.String())
 }
 
+// Quote is the result of quote(expression), it holds the unevaluated expression.
+type Quote struct {
+	Node ast.Node
+}
+
+func (q *Quote) Type() ObjectType {
+	return TypeQuote
+}
+
+func (q *Quote) Inspect() string {
+	return "QUOTE(" + q.Node.String() + ")"
+}
+
+// Macro is bound to a name by a top level let statement, calls of the macro
+// are replaced with the quoted code returned by it before the program runs.
+type Macro struct {
+	Parameters []*ast.Identifier
+	Body       *ast.BlockStatement
+	Env        *Environment
+}
+
+func (m *Macro) Type() ObjectType {
+	return TypeMacro
+}
+
+func (m *Macro) Inspect() string {
+	params := []string{}
+	for _, p := range m.Parameters {
+		params = append(params, p.String())
+	}
+
+	return fmt.Sprintf("macro(%v) {\n%v\n}", strings.Join(params, ", "), m.Body.String())
+}
+
 type Array struct {
 	Elements []Object
 }
@@ -167,18 +350,89 @@ func (ao *Array) Type() ObjectType {
 }
 
 func (ao *Array) Inspect() string {
-	elements := []string{}
-	for _, e := range ao.Elements {
-		elements = append(elements, e.Inspect())
+	return inspect(ao, make(map[Object]bool))
+}
+
+// inspect describes the object, visiting holds arrays and hashes being described, so that
+// containers which contain themselves are described as [...] or {...} where they appear again.
+func inspect(obj Object, visiting map[Object]bool) string {
+	switch obj := obj.(type) {
+	case *Array:
+		if visiting[obj] {
+			return "[...]"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		elements := []string{}
+		for _, e := range obj.Elements {
+			elements = append(elements, inspect(e, visiting))
+		}
+
+		return fmt.Sprintf("[%v]", strings.Join(elements, ", "))
+	case *Hash:
+		if visiting[obj] {
+			return "{...}"
+		}
+		visiting[obj] = true
+		defer delete(visiting, obj)
+
+		pairs := []string{}
+		for _, pair := range obj.Pairs {
+			pairs = append(pairs, fmt.Sprintf("%s: %s", inspect(pair.Key, visiting), inspect(pair.Value, visiting)))
+		}
+
+		return fmt.Sprintf("{%v}", strings.Join(pairs, ", "))
+	default:
+		return obj.Inspect()
+	}
+}
+
+// ContainsItself reports whether the object is an array or a hash reachable from its own elements,
+// directly or through other containers.
+func ContainsItself(obj Object) bool {
+	return containsItself(obj, make(map[Object]bool), make(map[Object]bool))
+}
+
+// containsItself reports whether the array or hash is reachable from its own elements. Containers
+// on the path from the object are in visiting, the ones known to be acyclic in checked.
+func containsItself(obj Object, visiting, checked map[Object]bool) bool {
+	var children []Object
+	switch obj := obj.(type) {
+	case *Array:
+		children = obj.Elements
+	case *Hash:
+		for _, pair := range obj.Pairs {
+			children = append(children, pair.Value)
+		}
+	default:
+		return false
+	}
+
+	if visiting[obj] {
+		return true
 	}
+	if checked[obj] {
+		return false
+	}
+
+	visiting[obj] = true
+	for _, child := range children {
+		if containsItself(child, visiting, checked) {
+			return true
+		}
+	}
+	delete(visiting, obj)
+	checked[obj] = true
 
-	return fmt.Sprintf("[%v]", strings.Join(elements, ", "))
+	return false
 }
 
 type BuiltinFunction func(args ...Object) Object
 
 type Builtin struct {
-	Fn BuiltinFunction
+	Fn  BuiltinFunction
+	Doc string // signature followed by the description, empty if the builtin isn't documented
 }
 
 func (b *Builtin) Type() ObjectType {
@@ -200,7 +454,8 @@ type HashPair struct {
 }
 
 type Hash struct {
-	Pairs map[HashKey]HashPair
+	Pairs  map[HashKey]HashPair
+	Frozen bool // pairs of frozen hashes, e.g. namespaces of builtins, can't be assigned
 }
 
 func (h *Hash) Type() ObjectType {
@@ -208,15 +463,55 @@ func (h *Hash) Type() ObjectType {
 }
 
 func (h *Hash) Inspect() string {
-	pairs := []string{}
-	for _, pair := range h.Pairs {
-		p := fmt.Sprintf("%s: %s", pair.Key.Inspect(), pair.Value.Inspect())
-		pairs = append(pairs, p)
-	}
-
-	return fmt.Sprintf("{%v}", strings.Join(pairs, ", "))
+	return inspect(h, make(map[Object]bool))
 }
 
 type Hashable interface {
 	HashKey() HashKey
 }
+
+type CompiledFunction struct {
+	Name          string
+	Instructions  code.Instructions
+	NumLocals     int
+	NumParameters int
+	LocalNames    []string
+	FreeNames     []string
+	Positions     map[int]token.Position // source positions of the instructions which may fail
+}
+
+func (cf *CompiledFunction) Type() ObjectType {
+	return TypeCompiledFunction
+}
+
+func (cf *CompiledFunction) Inspect() string {
+	return fmt.Sprintf("CompiledFunction[%p]", cf)
+}
+
+// Closure is the vm counterpart of Function, that's why both have the same type.
+type Closure struct {
+	Fn   *CompiledFunction
+	Free []Object
+}
+
+func (c *Closure) Type() ObjectType {
+	return TypeFunction
+}
+
+func (c *Closure) Inspect() string {
+	return fmt.Sprintf("Closure[%p]", c)
+}
+
+// Cell holds a local variable captured by a closure, so that the function which defines
+// the variable and its closures share it, like they share an environment in the evaluator.
+type Cell struct {
+	Value Object
+}
+
+func (c *Cell) Type() ObjectType {
+	return TypeCell
+}
+
+func (c *Cell) Inspect() string {
+	return fmt.Sprintf("Cell[%p]", c)
+}
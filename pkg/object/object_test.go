@@ -1,6 +1,12 @@
 package object
 
-import "testing"
+import (
+	"math"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/token"
+)
 
 func TestStringHashKey(t *testing.T) {
 	hello1 := NewString("Hello World")
@@ -19,3 +25,147 @@ func TestStringHashKey(t *testing.T) {
 		t.Errorf("strings with different content have same hash keys")
 	}
 }
+
+func TestErrorStackTrace(t *testing.T) {
+	err := &Error{
+		Message: "identifier not found: x",
+		Trace: []StackFrame{
+			{Function: "inner", Pos: token.Position{Line: 2, Column: 3}},
+			{Function: "", Pos: token.Position{Line: 5, Column: 1}},
+			{Function: MainFrame, Pos: token.Position{Line: 7, Column: 1}},
+		},
+	}
+
+	expected := "inner(...)\n\t2:3\nfn(...)\n\t5:1\nmain()\n\t7:1\n"
+
+	if err.StackTrace() != expected {
+		t.Errorf("wrong stack trace. expected=%q, got=%q", expected, err.StackTrace())
+	}
+}
+
+func TestEqual(t *testing.T) {
+	builtin := &Builtin{}
+	self := &Array{}
+	self.Elements = []Object{self}
+	other := &Array{}
+	other.Elements = []Object{other}
+
+	tests := []struct {
+		a, b     Object
+		expected bool
+	}{
+		{NewInteger(1), NewInteger(1), true},
+		{NewInteger(1), NewInteger(2), false},
+		{NewString("a"), NewString("a"), true},
+		{NewString("a"), NewInteger(1), false},
+		{NewBoolean(true), TrueValue, true},
+		{NullValue, &Null{}, true},
+		{&Array{Elements: []Object{NewInteger(1)}}, &Array{Elements: []Object{NewInteger(1)}}, true},
+		{&Array{Elements: []Object{NewInteger(1)}}, &Array{}, false},
+		{builtin, builtin, true},
+		{builtin, &Builtin{}, false},
+		{self, other, true},
+		{nil, nil, true},
+		{nil, NullValue, false},
+	}
+
+	for i, tt := range tests {
+		if got := Equal(tt.a, tt.b); got != tt.expected {
+			t.Errorf("tests[%d] - wrong result. expected=%t, got=%t", i, tt.expected, got)
+		}
+	}
+}
+
+func TestInspectContainersContainingThemselves(t *testing.T) {
+	array := &Array{Elements: []Object{NewInteger(1)}}
+	array.Elements = append(array.Elements, array)
+
+	key := NewString("self")
+	hash := &Hash{Pairs: map[HashKey]HashPair{}}
+	hash.Pairs[key.HashKey()] = HashPair{Key: key, Value: &Array{Elements: []Object{hash}}}
+
+	shared := &Array{Elements: []Object{NewInteger(2)}}
+
+	tests := []struct {
+		obj      Object
+		expected string
+	}{
+		{array, "[1, [...]]"},
+		{hash, "{self: [{...}]}"},
+		{&Array{Elements: []Object{shared, shared}}, "[[2], [2]]"},
+	}
+
+	for i, tt := range tests {
+		if got := tt.obj.Inspect(); got != tt.expected {
+			t.Errorf("tests[%d] - wrong description. expected=%q, got=%q", i, tt.expected, got)
+		}
+	}
+}
+
+func TestRangeLen(t *testing.T) {
+	tests := []struct {
+		r        Range
+		expected int64
+	}{
+		{Range{Start: 0, Stop: 10, Step: 1}, 10},
+		{Range{Start: 0, Stop: 10, Step: 3}, 4},
+		{Range{Start: 10, Stop: 0, Step: -3}, 4},
+		{Range{Start: 5, Stop: 5, Step: 1}, 0},
+		{Range{Start: 5, Stop: 0, Step: 1}, 0},
+		{Range{Start: 0, Stop: math.MaxInt64, Step: 1}, math.MaxInt64},
+		{Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: math.MaxInt64}, 3},
+		{Range{Start: math.MaxInt64, Stop: math.MinInt64, Step: math.MinInt64}, 2},
+		{Range{Start: math.MinInt64, Stop: math.MaxInt64, Step: 1}, math.MaxInt64},
+	}
+
+	for i, tt := range tests {
+		if got := tt.r.Len(); got != tt.expected {
+			t.Errorf("tests[%d] - wrong length. expected=%d, got=%d", i, tt.expected, got)
+		}
+	}
+}
+
+func TestSortedPairs(t *testing.T) {
+	hash := &Hash{Pairs: make(map[HashKey]HashPair)}
+	for _, key := range []Hashable{NewString("b"), NewInteger(10), TrueValue, NewString("a"), NewInteger(9), NewFloat(9.5), FalseValue} {
+		hash.Pairs[key.HashKey()] = HashPair{Key: key.(Object), Value: NullValue}
+	}
+
+	var keys []string
+	for _, pair := range SortedPairs(hash) {
+		keys = append(keys, pair.Key.Inspect())
+	}
+
+	expected := "false true 9 9.5 10 a b"
+	if strings.Join(keys, " ") != expected {
+		t.Errorf("wrong order. expected=%q, got=%q", expected, strings.Join(keys, " "))
+	}
+}
+
+func TestEnvironmentNames(t *testing.T) {
+	outer := NewEnvironment()
+	outer.Set("b", NullValue)
+	outer.Set("a", NullValue)
+
+	inner := NewEnclosedEnvironment(outer)
+	inner.Set("c", NullValue)
+	inner.Set("a", NullValue)
+
+	expected := "a b c"
+	if names := strings.Join(inner.Names(), " "); names != expected {
+		t.Errorf("wrong names. expected=%q, got=%q", expected, names)
+	}
+
+	expected = "a c"
+	if names := strings.Join(inner.LocalNames(), " "); names != expected {
+		t.Errorf("wrong local names. expected=%q, got=%q", expected, names)
+	}
+}
+
+func TestBuiltinsAreDocumented(t *testing.T) {
+	for _, def := range Builtins {
+		if !strings.HasPrefix(def.Builtin.Doc, def.Name+"(") {
+			t.Errorf("documentation of %s must start with its signature, got %q", def.Name, def.Builtin.Doc)
+		}
+	}
+}
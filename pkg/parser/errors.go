@@ -0,0 +1,149 @@
+package parser
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+type Severity int
+
+const (
+	SeverityError Severity = iota
+	SeverityWarning
+)
+
+func (s Severity) String() string {
+	if s == SeverityWarning {
+		return "warning"
+	}
+
+	return "error"
+}
+
+// Error describes a single problem found in the source code.
+type Error struct {
+	Pos      token.Position
+	Msg      string
+	Token    token.Token // the offending token
+	Severity Severity
+}
+
+func (e *Error) Error() string {
+	return fmt.Sprintf("%s: %s", e.Pos, e.Msg)
+}
+
+// ErrorList is a list of problems found by the parser, ordered by their position.
+type ErrorList []*Error
+
+func (l *ErrorList) Add(pos token.Position, tok token.Token, severity Severity, format string, a ...interface{}) {
+	*l = append(*l, &Error{Pos: pos, Msg: fmt.Sprintf(format, a...), Token: tok, Severity: severity})
+}
+
+func (l ErrorList) Len() int {
+	return len(l)
+}
+
+func (l ErrorList) Swap(i, j int) {
+	l[i], l[j] = l[j], l[i]
+}
+
+func (l ErrorList) Less(i, j int) bool {
+	if l[i].Pos.Filename != l[j].Pos.Filename {
+		return l[i].Pos.Filename < l[j].Pos.Filename
+	}
+
+	return l[i].Pos.Offset < l[j].Pos.Offset
+}
+
+func (l ErrorList) Sort() {
+	sort.Stable(l)
+}
+
+// Filter returns problems with the given severity.
+func (l ErrorList) Filter(severity Severity) ErrorList {
+	var filtered ErrorList
+	for _, e := range l {
+		if e.Severity == severity {
+			filtered = append(filtered, e)
+		}
+	}
+
+	return filtered
+}
+
+func (l ErrorList) Error() string {
+	errs := make([]string, len(l))
+	for i, err := range l {
+		errs[i] = err.Error()
+	}
+
+	return strings.Join(errs, ", ")
+}
+
+// Err returns the list as an error if it contains at least one problem with SeverityError, nil otherwise.
+func (l ErrorList) Err() error {
+	if len(l.Filter(SeverityError)) == 0 {
+		return nil
+	}
+
+	return l
+}
+
+// Render writes the problems, each followed by the offending source line and a caret pointing
+// to the column of the problem. Errors which are not produced by the parser are written as they are.
+func Render(w io.Writer, source string, err error) {
+	var list ErrorList
+
+	switch e := err.(type) {
+	case ErrorList:
+		list = e
+	case *Error:
+		list = ErrorList{e}
+	default:
+		if err != nil {
+			fmt.Fprintln(w, err)
+		}
+		return
+	}
+
+	lines := strings.Split(source, "\n")
+
+	for _, e := range list {
+		fmt.Fprintf(w, "%s: %s: %s\n", e.Pos, e.Severity, e.Msg)
+
+		if e.Pos.Line < 1 || e.Pos.Line > len(lines) {
+			continue
+		}
+
+		line := strings.TrimRight(lines[e.Pos.Line-1], "\r")
+		fmt.Fprintln(w, line)
+		fmt.Fprintln(w, caretLine(line, e.Pos.Column))
+	}
+}
+
+// caretLine keeps tabs from the source line, so that the caret is aligned regardless of the tab width.
+func caretLine(line string, column int) string {
+	var out strings.Builder
+
+	// columns count characters, not bytes
+	for _, ch := range []rune(line) {
+		if column <= 1 {
+			break
+		}
+		column--
+
+		if ch == '\t' {
+			out.WriteByte('\t')
+		} else {
+			out.WriteByte(' ')
+		}
+	}
+
+	out.WriteByte('^')
+
+	return out.String()
+}
@@ -0,0 +1,85 @@
+package parser
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/token"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorRecovery(t *testing.T) {
+	input := `let x 5;
+let y = 10;
+let f = fn(a) {
+  let z = ;
+  a + 1;
+};
+let = 3;
+y;`
+
+	p := New(lexer.New(input))
+	program, err := p.ParseProgram()
+
+	list, ok := err.(ErrorList)
+	if !ok {
+		t.Fatalf("expected ErrorList, got %T", err)
+	}
+
+	assert.Len(t, list, 3)
+	assert.Equal(t, "1:7", list[0].Pos.String())
+	assert.Equal(t, token.TokenType(token.TypeInteger), list[0].Token.Type)
+	assert.Equal(t, "4:11", list[1].Pos.String())
+	assert.Equal(t, "no prefix parse function for ; found", list[1].Msg)
+	assert.Equal(t, "7:5", list[2].Pos.String())
+
+	// statements which failed to parse are dropped, the others are kept
+	assert.Len(t, program.Statements, 3)
+
+	fn := toFunctionLiteral(t, toLetStatement(t, program.Statements[1]).Value)
+	assertStatementsCount(t, fn.Body, 1)
+}
+
+func TestWarningsDoNotFailParsing(t *testing.T) {
+	p := New(lexer.New(`{"a": 1, "b": 2, "a": 3}`))
+
+	_, err := p.ParseProgram()
+	assert.NoError(t, err)
+
+	warnings := p.Errors().Filter(SeverityWarning)
+	if assert.Len(t, warnings, 1) {
+		assert.Equal(t, `duplicate key a in hash literal`, warnings[0].Msg)
+		assert.Equal(t, "1:18", warnings[0].Pos.String())
+	}
+}
+
+func TestRender(t *testing.T) {
+	input := "let a = 1;\n\tlet x 5;"
+
+	_, err := New(lexer.New(input)).ParseProgram()
+
+	var out bytes.Buffer
+	Render(&out, input, err)
+
+	expected := "2:8: error: expected next token to be =, got INT instead\n" +
+		"\tlet x 5;\n" +
+		"\t      ^\n"
+
+	assert.Equal(t, expected, out.String())
+}
+
+func TestRenderCountsCharacters(t *testing.T) {
+	input := `let s = "żółw" + ;`
+	_, err := New(lexer.New(input)).ParseProgram()
+
+	var out bytes.Buffer
+	Render(&out, input, err)
+
+	expected := "1:18: error: no prefix parse function for ; found\n" +
+		input + "\n" +
+		"                 ^\n"
+
+	assert.Equal(t, expected, out.String())
+}
@@ -1,10 +1,7 @@
 package parser
 
 import (
-	"errors"
-	"fmt"
 	"strconv"
-	"strings"
 
 	"github.com/adrian83/monkey/pkg/ast"
 	"github.com/adrian83/monkey/pkg/lexer"
@@ -14,24 +11,39 @@ import (
 const (
 	_ int = iota
 	procedenceLowest
+	procedenceAssign  // = or +=
+	procedenceOr      // ||
+	procedenceAnd     // &&
 	procedenceEqual   // ==
 	procedenceLess    // > or <
 	procedenceSum     // +
-	procedenceProduct // *
+	procedenceProduct // * or %
 	procedencePrefix  // -X or !X
+	procedencePower   // **
 	procedenceCall    // myFunction(X)
 	procedenceIndex
 )
 
 var precedences = map[token.TokenType]int{
+	token.OperatorAssign:           procedenceAssign,
+	token.OperatorPlusAssign:       procedenceAssign,
+	token.OperatorMinusAssign:      procedenceAssign,
+	token.OperatorAsteriskAssign:   procedenceAssign,
+	token.OperatorSlashAssign:      procedenceAssign,
 	token.OperatorEqual:            procedenceEqual,
 	token.OperatorNotEqual:         procedenceEqual,
+	token.OperatorOr:               procedenceOr,
+	token.OperatorAnd:              procedenceAnd,
 	token.OperatorLowerThan:        procedenceLess,
 	token.OperatorGreaterThan:      procedenceLess,
+	token.OperatorLowerEqual:       procedenceLess,
+	token.OperatorGreaterEqual:     procedenceLess,
 	token.OperatorPlus:             procedenceSum,
 	token.OperatorMinus:            procedenceSum,
 	token.OperatorSlash:            procedenceProduct,
 	token.OperatorAsterisk:         procedenceProduct,
+	token.OperatorPercent:          procedenceProduct,
+	token.OperatorPower:            procedencePower,
 	token.DelimiterLeftParenthesis: procedenceCall,
 	token.DelimiterLeftBracket:     procedenceIndex,
 }
@@ -43,11 +55,19 @@ type (
 
 type Parser struct {
 	l      *lexer.Lexer
-	errors []error
+	errors ErrorList
+
+	blockDepth int  // number of blocks enclosing the current token
+	loopDepth  int  // number of loops enclosing the current token within the current function
+	panicking  bool // set when an error is found, cleared once the parser recovers
 
 	curToken  token.Token
 	peekToken token.Token
 
+	comments    []*ast.Comment // comments read so far
+	nextComment int            // index of the first comment which isn't attached to a statement
+	commentMap  ast.CommentMap
+
 	prefixParseFns map[token.TokenType]prefixParseFn
 	infixParseFns  map[token.TokenType]infixParseFn
 }
@@ -58,6 +78,10 @@ func New(l *lexer.Lexer) *Parser {
 		//errors: []string{},
 	}
 
+	l.SetErrorHandler(func(tok token.Token, pos token.Position, msg string) {
+		p.errors.Add(pos, tok, SeverityError, "%s", msg)
+	})
+
 	// Read two tokens, so curToken and peekToken are both set
 	p.nextToken()
 	p.nextToken()
@@ -65,6 +89,7 @@ func New(l *lexer.Lexer) *Parser {
 	p.prefixParseFns = make(map[token.TokenType]prefixParseFn)
 	p.registerPrefix(token.Ident, p.parseIdentifier)
 	p.registerPrefix(token.TypeInteger, p.parseIntegerLiteral)
+	p.registerPrefix(token.TypeFloat, p.parseFloatLiteral)
 	p.registerPrefix(token.OperatorBang, p.parsePrefixExpression)
 	p.registerPrefix(token.OperatorMinus, p.parsePrefixExpression)
 	p.registerPrefix(token.KeywordTrue, p.parseBooleanLiteral)
@@ -72,7 +97,9 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerPrefix(token.DelimiterLeftParenthesis, p.parseGroupedExpression)
 	p.registerPrefix(token.KeywordIf, p.parseIfExpression)
 	p.registerPrefix(token.KeywordFunction, p.parseFunctionLiteral)
+	p.registerPrefix(token.KeywordMacro, p.parseMacroLiteral)
 	p.registerPrefix(token.TypeString, p.parseStringLiteral)
+	p.registerPrefix(token.Illegal, p.parseIllegal)
 	p.registerPrefix(token.DelimiterLeftBracket, p.parseArrayLiteral)
 	p.registerPrefix(token.DelimiterLeftBrace, p.parseHashLiteral)
 
@@ -85,9 +112,20 @@ func New(l *lexer.Lexer) *Parser {
 	p.registerInfix(token.OperatorNotEqual, p.parseInfixExpression)
 	p.registerInfix(token.OperatorLowerThan, p.parseInfixExpression)
 	p.registerInfix(token.OperatorGreaterThan, p.parseInfixExpression)
+	p.registerInfix(token.OperatorLowerEqual, p.parseInfixExpression)
+	p.registerInfix(token.OperatorGreaterEqual, p.parseInfixExpression)
+	p.registerInfix(token.OperatorAnd, p.parseInfixExpression)
+	p.registerInfix(token.OperatorOr, p.parseInfixExpression)
+	p.registerInfix(token.OperatorPercent, p.parseInfixExpression)
+	p.registerInfix(token.OperatorPower, p.parseInfixExpression)
 	p.registerInfix(token.DelimiterLeftParenthesis, p.parseCallExpression)
 	p.registerInfix(token.DelimiterLeftBracket, p.parseprocedenceIndexExpression)
 
+	for _, operator := range []token.TokenType{token.OperatorAssign, token.OperatorPlusAssign,
+		token.OperatorMinusAssign, token.OperatorAsteriskAssign, token.OperatorSlashAssign} {
+		p.registerInfix(operator, p.parseAssignExpression)
+	}
+
 	return p
 }
 
@@ -105,6 +143,7 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		p.nextToken()
 		value := p.parseExpression(procedenceLowest)
 
+		p.checkDuplicateKey(hash, key)
 		hash.Pairs[key] = value
 
 		if !p.peekTokenIs(token.DelimiterRightBrace) && !p.expectPeek(token.DelimiterComma) {
@@ -116,9 +155,35 @@ func (p *Parser) parseHashLiteral() ast.Expression {
 		return nil
 	}
 
+	hash.Rbrace = p.curToken.Pos
+
 	return hash
 }
 
+// checkDuplicateKey warns about literal keys which are repeated in a hash literal,
+// only the last of the values is kept in the evaluated hash.
+func (p *Parser) checkDuplicateKey(hash *ast.HashLiteral, key ast.Expression) {
+	if !isLiteral(key) {
+		return
+	}
+
+	for existing := range hash.Pairs {
+		if isLiteral(existing) && existing.String() == key.String() && existing.NodeToken().Type == key.NodeToken().Type {
+			p.errors.Add(key.Pos(), key.NodeToken(), SeverityWarning, "duplicate key %s in hash literal", key.String())
+			return
+		}
+	}
+}
+
+func isLiteral(exp ast.Expression) bool {
+	switch exp.(type) {
+	case *ast.StringLiteral, *ast.IntegerLiteral, *ast.BooleanLiteral:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *Parser) parseprocedenceIndexExpression(left ast.Expression) ast.Expression {
 	exp := &ast.IndexExpression{Token: p.curToken, Left: left}
 
@@ -129,22 +194,43 @@ func (p *Parser) parseprocedenceIndexExpression(left ast.Expression) ast.Express
 		return nil
 	}
 
+	exp.Rbracket = p.curToken.Pos
+
 	return exp
 }
 
-func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
-	return &ast.CallExpression{
-		Token:     p.curToken,
-		Function:  function,
-		Arguments: p.parseExpressionList(token.DelimiterRightParenthesis),
+// parseAssignExpression parses the value with the lowest precedence, so that
+// assignments are right associative, e.g. a = b = c is a = (b = c).
+func (p *Parser) parseAssignExpression(target ast.Expression) ast.Expression {
+	exp := &ast.AssignExpression{Token: p.curToken, Target: target, Operator: p.curToken.Literal}
+
+	switch target.(type) {
+	case *ast.Identifier, *ast.IndexExpression:
+	default:
+		p.error(p.curToken, "cannot assign to %s", target.String())
+		return nil
 	}
+
+	p.nextToken()
+	exp.Value = p.parseExpression(procedenceLowest)
+
+	return exp
+}
+
+func (p *Parser) parseCallExpression(function ast.Expression) ast.Expression {
+	exp := &ast.CallExpression{Token: p.curToken, Function: function}
+	exp.Arguments = p.parseExpressionList(token.DelimiterRightParenthesis)
+	exp.Rparen = p.curToken.Pos
+
+	return exp
 }
 
 func (p *Parser) parseArrayLiteral() ast.Expression {
-	return &ast.ArrayLiteral{
-		Token:    p.curToken,
-		Elements: p.parseExpressionList(token.DelimiterRightBracket),
-	}
+	array := &ast.ArrayLiteral{Token: p.curToken}
+	array.Elements = p.parseExpressionList(token.DelimiterRightBracket)
+	array.Rbracket = p.curToken.Pos
+
+	return array
 }
 
 func (p *Parser) parseExpressionList(end token.TokenType) []ast.Expression {
@@ -224,7 +310,31 @@ func (p *Parser) parseFunctionLiteral() ast.Expression {
 		return nil
 	}
 
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
 	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
+
+	return lit
+}
+
+func (p *Parser) parseMacroLiteral() ast.Expression {
+	lit := &ast.MacroLiteral{Token: p.curToken}
+
+	if !p.expectPeek(token.DelimiterLeftParenthesis) {
+		return nil
+	}
+
+	lit.Parameters = p.parseFunctionParameters()
+
+	if !p.expectPeek(token.DelimiterLeftBrace) {
+		return nil
+	}
+
+	loopDepth := p.loopDepth
+	p.loopDepth = 0
+	lit.Body = p.parseBlockStatement()
+	p.loopDepth = loopDepth
 
 	return lit
 }
@@ -261,6 +371,7 @@ func (p *Parser) parseStringLiteral() ast.Expression {
 }
 
 func (p *Parser) parseIfExpression() ast.Expression {
+	tok := p.curToken
 
 	if !p.expectPeek(token.DelimiterLeftParenthesis) {
 		return nil
@@ -291,7 +402,7 @@ func (p *Parser) parseIfExpression() ast.Expression {
 	}
 
 	return &ast.IfExpression{
-		Token:       p.curToken,
+		Token:       tok,
 		Condition:   condition,
 		Consequence: consequence,
 		Alternative: alternative,
@@ -299,12 +410,16 @@ func (p *Parser) parseIfExpression() ast.Expression {
 }
 
 func (p *Parser) parseBlockStatement() *ast.BlockStatement {
+	tok := p.curToken
 	stmts := make([]ast.Statement, 0)
 
+	p.blockDepth++
+	defer func() { p.blockDepth-- }()
+
 	p.nextToken()
 
 	for !p.curTokenIs(token.DelimiterRightBrace) && !p.curTokenIs(token.Eof) {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementWithRecovery()
 		if stmt != nil {
 			stmts = append(stmts, stmt)
 		}
@@ -312,8 +427,9 @@ func (p *Parser) parseBlockStatement() *ast.BlockStatement {
 	}
 
 	return &ast.BlockStatement{
-		Token:      p.curToken,
+		Token:      tok,
 		Statements: stmts,
+		Rbrace:     p.curToken.Pos,
 	}
 }
 
@@ -330,13 +446,55 @@ func (p *Parser) parseGroupedExpression() ast.Expression {
 }
 
 func (p *Parser) peekError(t token.TokenType) {
-	err := fmt.Errorf("expected next token to be %s, got %s instead", t, p.peekToken.Type)
-	p.errors = append(p.errors, err)
+	p.error(p.peekToken, "expected next token to be %s, got %s instead", t, p.peekToken.Type)
+}
+
+// error reports the problem unless the parser is already recovering from an error
+// in the current statement, which would likely cause the problem.
+func (p *Parser) error(tok token.Token, format string, a ...interface{}) {
+	if !p.panicking {
+		p.errors.Add(tok.Pos, tok, SeverityError, format, a...)
+	}
+	p.panicking = true
 }
 
 func (p *Parser) nextToken() {
 	p.curToken = p.peekToken
 	p.peekToken = p.l.NextToken()
+
+	for p.peekToken.Type == token.Comment {
+		p.comments = append(p.comments, &ast.Comment{Token: p.peekToken})
+		p.peekToken = p.l.NextToken()
+	}
+}
+
+// takeComments returns comments which are not attached yet, as long as they satisfy the predicate.
+func (p *Parser) takeComments(predicate func(*ast.Comment) bool) []*ast.Comment {
+	start := p.nextComment
+	for p.nextComment < len(p.comments) && predicate(p.comments[p.nextComment]) {
+		p.nextComment++
+	}
+
+	return p.comments[start:p.nextComment]
+}
+
+// attachComments attaches comments found before the statement and comments which follow it
+// on the line where it ends. The statement has been just parsed, so the current token is its last one.
+func (p *Parser) attachComments(stmt ast.Statement, leading []*ast.Comment) {
+	end := p.curToken
+	trailing := p.takeComments(func(c *ast.Comment) bool {
+		return c.Pos().Line == end.Pos.Line && c.Pos().Offset > end.Pos.Offset
+	})
+
+	comments := append(append([]*ast.Comment{}, leading...), trailing...)
+	if len(comments) == 0 {
+		return
+	}
+
+	if p.commentMap == nil {
+		p.commentMap = make(ast.CommentMap)
+	}
+	p.commentMap[stmt] = comments
 }
 
 func (p *Parser) ParseProgram() (*ast.Program, error) {
@@ -344,38 +502,216 @@ func (p *Parser) ParseProgram() (*ast.Program, error) {
 	program.Statements = []ast.Statement{}
 
 	for p.curToken.Type != token.Eof {
-		stmt := p.parseStatement()
+		stmt := p.parseStatementWithRecovery()
 		if stmt != nil {
 			program.Statements = append(program.Statements, stmt)
 		}
 		p.nextToken()
 	}
 
-	return program, p.hasError()
+	p.errors.Sort()
+
+	program.Comments = p.comments
+	program.CommentMap = p.commentMap
+
+	return program, p.errors.Err()
+}
+
+// Errors returns all problems found by ParseProgram, including warnings.
+func (p *Parser) Errors() ErrorList {
+	return p.errors
 }
 
-func (p *Parser) hasError() error {
-	if len(p.errors) > 0 {
-		errs := make([]string, len(p.errors))
-		for i, err := range p.errors {
-			errs[i] = err.Error()
+// parseStatementWithRecovery drops the statement if it can't be parsed and skips
+// to the beginning of the next one, so that parsing can continue.
+func (p *Parser) parseStatementWithRecovery() ast.Statement {
+	enclosing := p.panicking
+	p.panicking = false
+
+	start := p.curToken.Pos.Offset
+	leading := p.takeComments(func(c *ast.Comment) bool { return c.Pos().Offset < start })
+
+	stmt := p.parseStatement()
+
+	failed := p.panicking
+	p.panicking = enclosing
+
+	if !failed {
+		if stmt != nil {
+			p.attachComments(stmt, leading)
 		}
-		return errors.New(strings.Join(errs, ", "))
+		return stmt
 	}
+
+	p.synchronize()
 	return nil
 }
 
+// synchronize skips tokens until the end of the current statement, that is a semicolon,
+// a token starting a new statement or the end of the enclosing block.
+func (p *Parser) synchronize() {
+	depth := 0
+
+	for !p.curTokenIs(token.Eof) {
+		switch p.curToken.Type {
+		case token.DelimiterLeftBrace:
+			depth++
+		case token.DelimiterRightBrace:
+			if depth > 0 {
+				depth--
+			}
+		case token.DelimiterSemicolon:
+			if depth == 0 {
+				return
+			}
+		}
+
+		if depth == 0 {
+			if startsStatement(p.peekToken.Type) || p.peekTokenIs(token.Eof) {
+				return
+			}
+
+			if p.blockDepth > 0 && p.peekTokenIs(token.DelimiterRightBrace) {
+				return
+			}
+		}
+
+		p.nextToken()
+	}
+}
+
 func (p *Parser) parseStatement() ast.Statement {
 	switch p.curToken.Type {
 	case token.KeywordLet:
 		return p.parseLetStatement()
 	case token.KeywordReturn:
 		return p.parseReturnStatement()
+	case token.KeywordWhile:
+		return p.parseWhileStatement()
+	case token.KeywordFor:
+		return p.parseForStatement()
+	case token.KeywordBreak:
+		return p.parseBreakStatement()
+	case token.KeywordContinue:
+		return p.parseContinueStatement()
 	default:
 		return p.parseExpressionStatement()
 	}
 }
 
+func (p *Parser) parseWhileStatement() ast.Statement {
+	stmt := &ast.WhileStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.DelimiterLeftParenthesis) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Condition = p.parseExpression(procedenceLowest)
+
+	if !p.expectPeek(token.DelimiterRightParenthesis) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.DelimiterSemicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+// parseForStatement parses 'for (value in iterable) { ... }' and 'for (key, value in iterable) { ... }'.
+func (p *Parser) parseForStatement() ast.Statement {
+	stmt := &ast.ForStatement{Token: p.curToken}
+
+	if !p.expectPeek(token.DelimiterLeftParenthesis) {
+		return nil
+	}
+
+	if !p.expectPeek(token.Ident) {
+		return nil
+	}
+
+	stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+
+	if p.peekTokenIs(token.DelimiterComma) {
+		p.nextToken()
+
+		if !p.expectPeek(token.Ident) {
+			return nil
+		}
+
+		stmt.Key = stmt.Value
+		stmt.Value = &ast.Identifier{Token: p.curToken, Value: p.curToken.Literal}
+	}
+
+	if !p.expectPeek(token.KeywordIn) {
+		return nil
+	}
+
+	p.nextToken()
+	stmt.Iterable = p.parseExpression(procedenceLowest)
+
+	if !p.expectPeek(token.DelimiterRightParenthesis) {
+		return nil
+	}
+
+	stmt.Body = p.parseLoopBody()
+	if stmt.Body == nil {
+		return nil
+	}
+
+	if p.peekTokenIs(token.DelimiterSemicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseLoopBody() *ast.BlockStatement {
+	if !p.expectPeek(token.DelimiterLeftBrace) {
+		return nil
+	}
+
+	p.loopDepth++
+	defer func() { p.loopDepth-- }()
+
+	return p.parseBlockStatement()
+}
+
+func (p *Parser) parseBreakStatement() ast.Statement {
+	stmt := &ast.BreakStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.error(p.curToken, "break outside of a loop")
+	}
+
+	if p.peekTokenIs(token.DelimiterSemicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
+func (p *Parser) parseContinueStatement() ast.Statement {
+	stmt := &ast.ContinueStatement{Token: p.curToken}
+
+	if p.loopDepth == 0 {
+		p.error(p.curToken, "continue outside of a loop")
+	}
+
+	if p.peekTokenIs(token.DelimiterSemicolon) {
+		p.nextToken()
+	}
+
+	return stmt
+}
+
 func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	stmt := &ast.ReturnStatement{Token: p.curToken}
 
@@ -390,6 +726,16 @@ func (p *Parser) parseReturnStatement() *ast.ReturnStatement {
 	return stmt
 }
 
+// startsStatement reports whether the token always starts a statement.
+func startsStatement(t token.TokenType) bool {
+	switch t {
+	case token.KeywordLet, token.KeywordReturn, token.KeywordWhile, token.KeywordFor, token.KeywordBreak, token.KeywordContinue:
+		return true
+	default:
+		return false
+	}
+}
+
 func (p *Parser) parseLetStatement() *ast.LetStatement {
 	stmt := &ast.LetStatement{Token: p.curToken}
 
@@ -407,6 +753,10 @@ func (p *Parser) parseLetStatement() *ast.LetStatement {
 
 	stmt.Value = p.parseExpression(procedenceLowest)
 
+	if fl, ok := stmt.Value.(*ast.FunctionLiteral); ok {
+		fl.Name = stmt.Name.Value
+	}
+
 	if p.peekTokenIs(token.DelimiterSemicolon) {
 		p.nextToken()
 	}
@@ -447,8 +797,7 @@ func (p *Parser) parseExpressionStatement() *ast.ExpressionStatement {
 func (p *Parser) parseIntegerLiteral() ast.Expression {
 	value, err := strconv.ParseInt(p.curToken.Literal, 0, 64)
 	if err != nil {
-		err := fmt.Errorf("could not parse %q as integer", p.curToken.Literal)
-		p.errors = append(p.errors, err)
+		p.error(p.curToken, "could not parse %q as integer", p.curToken.Literal)
 		return nil
 	}
 
@@ -458,9 +807,28 @@ func (p *Parser) parseIntegerLiteral() ast.Expression {
 	}
 }
 
+func (p *Parser) parseFloatLiteral() ast.Expression {
+	value, err := strconv.ParseFloat(p.curToken.Literal, 64)
+	if err != nil {
+		p.error(p.curToken, "could not parse %q as float", p.curToken.Literal)
+		return nil
+	}
+
+	return &ast.FloatLiteral{
+		Token: p.curToken,
+		Value: value,
+	}
+}
+
+// parseIllegal skips the statement containing the illegal token, the problem is
+// already reported by the lexer.
+func (p *Parser) parseIllegal() ast.Expression {
+	p.panicking = true
+	return nil
+}
+
 func (p *Parser) noPrefixParseFnError(t token.TokenType) {
-	err := fmt.Errorf("no prefix parse function for %s found", t)
-	p.errors = append(p.errors, err)
+	p.error(p.curToken, "no prefix parse function for %s found", t)
 }
 
 func (p *Parser) parsePrefixExpression() ast.Expression {
@@ -500,6 +868,12 @@ func (p *Parser) parseInfixExpression(left ast.Expression) ast.Expression {
 	}
 
 	precedence := p.curPrecedence()
+	if expression.Operator == token.OperatorPower {
+		// power is right associative and binds tighter than a prefix operator on its left,
+		// but not on its right, i.e. -2 ** -2 is -(2 ** (-2))
+		precedence = procedencePrefix
+	}
+
 	p.nextToken()
 	expression.Right = p.parseExpression(precedence)
 
@@ -1,6 +1,7 @@
 package parser
 
 import (
+	"strconv"
 	"strings"
 	"testing"
 
@@ -119,6 +120,131 @@ func TestIntegerLiteralExpression(t *testing.T) {
 	}
 }
 
+func TestFloatLiteralExpression(t *testing.T) {
+	testData := map[string]struct {
+		input   string
+		value   float64
+		literal string
+	}{
+		"fraction": {"3.14", 3.14, "3.14"},
+		"exponent": {"1e-9;", 1e-9, "1e-9"},
+		"both":     {"2.5E+3", 2500, "2.5E+3"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			program := parseProgram(t, data.input)
+
+			assertStatementsCount(t, program, 1)
+
+			stmt := toExpressionStatement(t, program.Statements[0])
+
+			assertLiteral(t, stmt.Expression, data.value)
+			assertTokenLiteral(t, data.literal, stmt.NodeToken().Literal)
+		})
+	}
+}
+
+func TestLoopStatements(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"while":            {"while (x < 10) { x; break; }", "while (x < 10) xbreak;"},
+		"for":              {"for (x in xs) { continue }", "for (x in xs) continue;"},
+		"for key value":    {"for (k, v in {}) { k }", "for (k, v in {}) k"},
+		"nested in fn":     {"while (true) { fn() { while (false) { break } } }", "while true fn() while false break;"},
+		"call as iterable": {"for (i in range(3)) { i }", "for (i in range(3)) i"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			program := parseProgram(t, data.input)
+
+			assertStatementsCount(t, program, 1)
+			assert.Equal(t, data.expected, program.String())
+		})
+	}
+}
+
+func TestAssignExpressions(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"assign":            {"x = 5", "(x = 5)"},
+		"compound":          {"x += y * 2", "(x += (y * 2))"},
+		"right associative": {"x = y -= 1", "(x = (y -= 1))"},
+		"index":             {`h["k"] /= 2`, "((h[k]) /= 2)"},
+		"lower than equal":  {"x = a == b", "(x = (a == b))"},
+		"in call argument":  {"f(x *= 2)", "f((x *= 2))"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			program := parseProgram(t, data.input)
+
+			assertStatementsCount(t, program, 1)
+			assert.Equal(t, data.expected, program.String())
+		})
+	}
+}
+
+func TestComments(t *testing.T) {
+	input := `// add returns the sum
+// of its arguments
+let add = fn(a, b) {
+	/* the result */
+	a + b // no return needed
+};
+
+add(1, 2); /* trailing */ /* both */
+// dangling`
+
+	l := lexer.New(input)
+	l.SetMode(lexer.ScanComments)
+
+	program, err := New(l).ParseProgram()
+	if err != nil {
+		t.Fatalf("cannot parse input, error: %v", err)
+	}
+
+	assertStatementsCount(t, program, 2)
+	assert.Equal(t, "let add = fn(a, b) (a + b);add(1, 2)", program.String())
+	assert.Len(t, program.Comments, 7)
+
+	texts := func(node ast.Node) []string {
+		var result []string
+		for _, c := range program.CommentMap[node] {
+			result = append(result, c.Text())
+		}
+		return result
+	}
+
+	let := toLetStatement(t, program.Statements[0])
+	body := toFunctionLiteral(t, let.Value).Body
+
+	assert.Equal(t, []string{"add returns the sum", "of its arguments"}, texts(let))
+	assert.Equal(t, []string{"the result", "no return needed"}, texts(body.Statements[0]))
+	assert.Equal(t, []string{"trailing", "both"}, texts(program.Statements[1]))
+	assert.Equal(t, "// dangling", program.Comments[6].String())
+	assert.Len(t, program.CommentMap, 3)
+}
+
+func TestCommentsAreSkippedByDefault(t *testing.T) {
+	program := parseProgram(t, "let x = 1; // one\n/* two */ x")
+
+	assertStatementsCount(t, program, 2)
+	assert.Empty(t, program.Comments)
+	assert.Empty(t, program.CommentMap)
+}
+
 func TestStringLiteralExpression(t *testing.T) {
 	testData := map[string]struct {
 		input string
@@ -221,6 +347,12 @@ func TestParsingInfixExpressions(t *testing.T) {
 		"case 9":  {"true != false;", true, "!=", false},
 		"case 10": {"true == true;", true, "==", true},
 		"case 11": {"false == false;", false, "==", false},
+		"case 12": {"5 <= 5;", 5, "<=", 5},
+		"case 13": {"5 >= 5;", 5, ">=", 5},
+		"case 14": {"5 % 5;", 5, "%", 5},
+		"case 15": {"5 ** 5;", 5, "**", 5},
+		"case 16": {"true && false;", true, "&&", false},
+		"case 17": {"true || false;", true, "||", false},
 	}
 
 	for name, tData := range testData {
@@ -273,6 +405,16 @@ func TestOperatorPrecedenceParsing(t *testing.T) {
 		"case 24": {"add(a + b + c * d / f + g)", "add((((a + b) + ((c * d) / f)) + g))", 1},
 		"case 25": {"a * [1, 2, 3, 4][b * c] * d", "((a * ([1, 2, 3, 4][(b * c)])) * d)", 1},
 		"case 26": {"add(a * b[2], b[1], 2 * [1, 2][1])", "add((a * (b[2])), (b[1]), (2 * ([1, 2][1])))", 1},
+		"case 27": {"a || b && c", "(a || (b && c))", 1},
+		"case 28": {"a && b || c && d", "((a && b) || (c && d))", 1},
+		"case 29": {"a <= b && !done", "((a <= b) && (!done))", 1},
+		"case 30": {"a == b || a >= c", "((a == b) || (a >= c))", 1},
+		"case 31": {"a + b % c", "(a + (b % c))", 1},
+		"case 32": {"a * b ** c", "(a * (b ** c))", 1},
+		"case 33": {"a ** b ** c", "(a ** (b ** c))", 1},
+		"case 34": {"-a ** -b", "(-(a ** (-b)))", 1},
+		"case 35": {"a ** b[0]", "(a ** (b[0]))", 1},
+		"case 36": {"x = a || b", "(x = (a || b))", 1},
 	}
 
 	for name, tData := range testData {
@@ -375,6 +517,25 @@ func TestIfElseExpression(t *testing.T) {
 	}
 }
 
+func TestMacroLiteralParsing(t *testing.T) {
+	program := parseProgram(t, `macro(x, y) { x + y; }`)
+
+	assertStatementsCount(t, program, 1)
+
+	expStmt := toExpressionStatement(t, program.Statements[0])
+	macro := toMacroLiteral(t, expStmt.Expression)
+
+	assertParameters(t, macro.Parameters, []string{"x", "y"})
+	assertStatementsCount(t, macro.Body, 1)
+
+	bodyExpStmt := toExpressionStatement(t, macro.Body.Statements[0])
+	infixExp := toInfixExpression(t, bodyExpStmt.Expression)
+
+	assertOperator(t, token.OperatorPlus, infixExp.Operator)
+	assertLiteral(t, infixExp.Left, "x")
+	assertLiteral(t, infixExp.Right, "y")
+}
+
 func TestFunctionLiteralParsing(t *testing.T) {
 	testData := map[string]struct {
 		input   string
@@ -526,7 +687,7 @@ func TestParsingArrayLiterals(t *testing.T) {
 			arrayLit := toArrayLiteral(t, expStmt.Expression)
 
 			for i, elem := range arrayLit.Elements {
-				t.Run("element "+string(i), func(t *testing.T) {
+				t.Run("element "+strconv.Itoa(i), func(t *testing.T) {
 					infixExp := toInfixExpression(t, elem)
 					expected := data.expectedParams[i]
 					assertInfixExpression(t, infixExp, expected.operator, expected.leftVal, expected.rightVal)
@@ -716,6 +877,14 @@ func toFunctionLiteral(t *testing.T, exp ast.Expression) *ast.FunctionLiteral {
 	return funcLit
 }
 
+func toMacroLiteral(t *testing.T, exp ast.Expression) *ast.MacroLiteral {
+	macroLit, ok := exp.(*ast.MacroLiteral)
+	if !ok {
+		t.Fatalf("invalid ast.Expression type, expected: *ast.MacroLiteral, actual: %T", exp)
+	}
+	return macroLit
+}
+
 func toIfExpression(t *testing.T, exp ast.Expression) *ast.IfExpression {
 	ifExp, ok := exp.(*ast.IfExpression)
 	if !ok {
@@ -772,6 +941,8 @@ func assertLiteral(t *testing.T, il ast.Expression, value interface{}) {
 			t.Errorf("unsuported number type: %T", numbVal)
 		}
 
+	case *ast.FloatLiteral:
+		assert.Equal(t, value, expVal.Value)
 	case *ast.Identifier:
 		assert.Equal(t, value, expVal.Value)
 	case *ast.StringLiteral:
@@ -821,3 +992,66 @@ func assertInfixExpression(t *testing.T, exp *ast.InfixExpression, operator stri
 	assertLiteral(t, exp.Left, leftVal)
 	assertLiteral(t, exp.Right, rightVal)
 }
+
+func TestNodePositions(t *testing.T) {
+	testData := map[string]struct {
+		input string
+		pos   string
+		end   string
+	}{
+		"infix":    {"  a + b * c", "1:3", "1:12"},
+		"call":     {"add(1, 2)", "1:1", "1:10"},
+		"index":    {"arr[1 + 1]", "1:1", "1:11"},
+		"array":    {"[1, 2]", "1:1", "1:7"},
+		"hash":     {`{"a": 1}`, "1:1", "1:9"},
+		"if":       {"if (x) {\n  1\n} else {\n  2\n}", "1:1", "5:2"},
+		"function": {"fn(x) {\n  x\n}", "1:1", "3:2"},
+		"let":      {"let x = 5;", "1:1", "1:10"},
+		"return":   {"return -x;", "1:1", "1:10"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			program := parseProgram(t, data.input)
+
+			assertStatementsCount(t, program, 1)
+
+			stmt := program.Statements[0]
+			assert.Equal(t, data.pos, stmt.Pos().String())
+			assert.Equal(t, data.end, stmt.End().String())
+		})
+	}
+}
+
+func TestErrorPositions(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"missing assign":       {"let x 5;", "1:7: expected next token to be =, got INT instead"},
+		"missing prefix fn":    {"\nlet x = ;", "2:9: no prefix parse function for ; found"},
+		"break outside loop":   {"if (true) { break; }", "1:13: break outside of a loop"},
+		"continue in function": {"while (true) { fn() { continue; } }", "1:23: continue outside of a loop"},
+		"missing in":           {"for (x of y) { }", "1:8: expected next token to be IN, got IDENT instead"},
+		"assign to literal":    {"1 = 2", "1:3: cannot assign to 1"},
+		"unterminated string":  {"puts(\"abc);", "1:6: unterminated string"},
+		"invalid escape":       {`let s = "a\qb";`, "1:11: invalid escape sequence \\q"},
+		"illegal character":    {"let x = 5 # 3;", "1:11: illegal character '#'"},
+		"single ampersand":     {"1 & 2", "1:3: illegal character '&'"},
+		"single bar":           {"1 | 2", "1:3: illegal character '|'"},
+		"assign to call":       {"let y = f() += 2;", "1:13: cannot assign to f()"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			_, err := New(lexer.New(data.input)).ParseProgram()
+			if assert.Error(t, err) {
+				assert.Contains(t, err.Error(), data.expected)
+			}
+		})
+	}
+}
@@ -0,0 +1,512 @@
+// Package printer formats Monkey programs. The output is indented with tabs, contains
+// only the parentheses required by the precedence of the operators and keeps the comments.
+package printer
+
+import (
+	"bytes"
+	"io"
+	"math"
+	"strconv"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/parser"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+// Format parses the source and returns it formatted. Source containing syntax errors
+// can't be formatted, the errors are returned as parser.ErrorList.
+func Format(filename string, src []byte) ([]byte, error) {
+	l := lexer.NewFile(filename, string(src))
+	l.SetMode(lexer.ScanComments)
+
+	program, err := parser.New(l).ParseProgram()
+	if err != nil {
+		return nil, err
+	}
+
+	var out bytes.Buffer
+	if err := Fprint(&out, program); err != nil {
+		return nil, err
+	}
+
+	return out.Bytes(), nil
+}
+
+// Fprint writes the formatted program. Comments are written only if the program
+// has been parsed with a lexer scanning comments.
+func Fprint(w io.Writer, program *ast.Program) error {
+	p := &printer{comments: program.Comments, lastLine: noLine}
+
+	p.statementList(program.Statements, token.Position{Offset: math.MaxInt32})
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+
+	_, err := w.Write(p.out.Bytes())
+	return err
+}
+
+// noLine is used as the line of the last printed node when an empty line
+// mustn't be kept before the next one, e.g. at the beginning of a block.
+const noLine = math.MaxInt32
+
+type printer struct {
+	out    bytes.Buffer
+	indent int
+
+	comments    []*ast.Comment
+	nextComment int // index of the first comment which isn't printed yet
+	lastLine    int // line in the source of the last printed statement or comment
+}
+
+// beginLine starts a new line for a node which is on the given line in the source,
+// a single empty line is kept if there are empty lines before the node in the source.
+func (p *printer) beginLine(line int) {
+	if p.out.Len() > 0 && line > p.lastLine+1 {
+		p.out.WriteByte('\n')
+	}
+
+	p.newline()
+}
+
+// newline ends the current line, if there is any, and indents the next one.
+func (p *printer) newline() {
+	if p.out.Len() > 0 {
+		p.out.WriteByte('\n')
+	}
+
+	for i := 0; i < p.indent; i++ {
+		p.out.WriteByte('\t')
+	}
+}
+
+// commentsBefore prints comments which start before the position, each on its own line.
+func (p *printer) commentsBefore(pos token.Position) {
+	for p.nextComment < len(p.comments) && p.comments[p.nextComment].Pos().Offset < pos.Offset {
+		comment := p.comments[p.nextComment]
+
+		p.beginLine(comment.Pos().Line)
+		p.out.WriteString(comment.Token.Literal)
+
+		p.lastLine = comment.End().Line
+		p.nextComment++
+	}
+}
+
+// trailingComments prints comments which start on the given line before the position at the end of the current line.
+func (p *printer) trailingComments(line int, before token.Position) {
+	for p.nextComment < len(p.comments) && p.comments[p.nextComment].Pos().Line == line &&
+		p.comments[p.nextComment].Pos().Offset < before.Offset {
+		comment := p.comments[p.nextComment]
+
+		p.out.WriteString(" ")
+		p.out.WriteString(comment.Token.Literal)
+
+		p.lastLine = comment.End().Line
+		p.nextComment++
+	}
+}
+
+// statementList prints the statements each on its own line, followed by the comments found before the end.
+func (p *printer) statementList(stmts []ast.Statement, end token.Position) {
+	for i, stmt := range stmts {
+		var next ast.Statement
+		nextPos := end
+		if i+1 < len(stmts) {
+			next = stmts[i+1]
+			nextPos = next.Pos()
+		}
+
+		p.commentsBefore(stmt.Pos())
+		p.beginLine(stmt.Pos().Line)
+		p.statement(stmt, next)
+
+		p.lastLine = stmt.End().Line
+		p.trailingComments(p.lastLine, nextPos)
+	}
+
+	p.commentsBefore(end)
+}
+
+func (p *printer) statement(stmt ast.Statement, next ast.Statement) {
+	switch stmt := stmt.(type) {
+	case *ast.LetStatement:
+		p.out.WriteString("let ")
+		p.out.WriteString(stmt.Name.Value)
+		p.out.WriteString(" = ")
+		p.expression(stmt.Value)
+		p.out.WriteString(";")
+
+	case *ast.ReturnStatement:
+		p.out.WriteString("return")
+		if stmt.ReturnValue != nil {
+			p.out.WriteString(" ")
+			p.expression(stmt.ReturnValue)
+		}
+		p.out.WriteString(";")
+
+	case *ast.ExpressionStatement:
+		p.expression(stmt.Expression)
+		if _, ok := stmt.Expression.(*ast.IfExpression); !ok || continuesExpression(next) {
+			p.out.WriteString(";")
+		}
+
+	case *ast.WhileStatement:
+		p.out.WriteString("while (")
+		p.expression(stmt.Condition)
+		p.out.WriteString(") ")
+		p.block(stmt.Body)
+
+	case *ast.ForStatement:
+		p.out.WriteString("for (")
+		if stmt.Key != nil {
+			p.out.WriteString(stmt.Key.Value)
+			p.out.WriteString(", ")
+		}
+		p.out.WriteString(stmt.Value.Value)
+		p.out.WriteString(" in ")
+		p.expression(stmt.Iterable)
+		p.out.WriteString(") ")
+		p.block(stmt.Body)
+
+	case *ast.BreakStatement:
+		p.out.WriteString("break;")
+
+	case *ast.ContinueStatement:
+		p.out.WriteString("continue;")
+
+	default:
+		p.out.WriteString(stmt.String())
+	}
+}
+
+// continuesExpression reports whether the statement would become a part of the preceding
+// expression if they weren't separated by a semicolon, e.g. an if expression followed by (a).
+func continuesExpression(stmt ast.Statement) bool {
+	exprStmt, ok := stmt.(*ast.ExpressionStatement)
+	if !ok {
+		return false
+	}
+
+	switch firstToken(exprStmt.Expression) {
+	case token.DelimiterLeftParenthesis, token.DelimiterLeftBracket, token.OperatorMinus:
+		return true
+	default:
+		return false
+	}
+}
+
+// firstToken returns the first token of the formatted expression.
+func firstToken(expr ast.Expression) string {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		if leftNeedsParens(expr.Operator, expr.Left) {
+			return token.DelimiterLeftParenthesis
+		}
+		return firstToken(expr.Left)
+	case *ast.AssignExpression:
+		return firstToken(expr.Target)
+	case *ast.CallExpression:
+		if precedence(expr.Function) < precedenceCall {
+			return token.DelimiterLeftParenthesis
+		}
+		return firstToken(expr.Function)
+	case *ast.IndexExpression:
+		if precedence(expr.Left) < precedenceCall {
+			return token.DelimiterLeftParenthesis
+		}
+		return firstToken(expr.Left)
+	case *ast.PrefixExpression:
+		return expr.Operator
+	case *ast.ArrayLiteral:
+		return token.DelimiterLeftBracket
+	default:
+		return ""
+	}
+}
+
+func (p *printer) block(block *ast.BlockStatement) {
+	p.out.WriteString("{")
+	start := p.out.Len()
+
+	p.indent++
+	p.lastLine = noLine
+	p.statementList(block.Statements, block.Rbrace)
+	p.indent--
+
+	if p.out.Len() > start {
+		p.newline()
+	}
+	p.out.WriteString("}")
+}
+
+// Precedences of the operators, they have to match the precedences used by the parser.
+const (
+	precedenceLowest = iota
+	precedenceAssign
+	precedenceOr
+	precedenceAnd
+	precedenceEqual
+	precedenceLess
+	precedenceSum
+	precedenceProduct
+	precedencePrefix
+	precedencePower
+	precedenceCall
+	precedenceOperand // literals, identifiers and other expressions which never need parentheses
+)
+
+var infixPrecedences = map[string]int{
+	token.OperatorOr:           precedenceOr,
+	token.OperatorAnd:          precedenceAnd,
+	token.OperatorEqual:        precedenceEqual,
+	token.OperatorNotEqual:     precedenceEqual,
+	token.OperatorLowerThan:    precedenceLess,
+	token.OperatorGreaterThan:  precedenceLess,
+	token.OperatorLowerEqual:   precedenceLess,
+	token.OperatorGreaterEqual: precedenceLess,
+	token.OperatorPlus:         precedenceSum,
+	token.OperatorMinus:        precedenceSum,
+	token.OperatorAsterisk:     precedenceProduct,
+	token.OperatorSlash:        precedenceProduct,
+	token.OperatorPercent:      precedenceProduct,
+	token.OperatorPower:        precedencePower,
+}
+
+func precedence(expr ast.Expression) int {
+	switch expr := expr.(type) {
+	case *ast.InfixExpression:
+		return infixPrecedences[expr.Operator]
+	case *ast.AssignExpression:
+		return precedenceAssign
+	case *ast.PrefixExpression:
+		return precedencePrefix
+	case *ast.CallExpression, *ast.IndexExpression:
+		return precedenceCall
+	default:
+		return precedenceOperand
+	}
+}
+
+// leftNeedsParens reports whether the left operand of the infix operator has to be parenthesized,
+// all operators are left associative except for **.
+func leftNeedsParens(operator string, left ast.Expression) bool {
+	if operator == token.OperatorPower {
+		return precedence(left) <= precedencePower
+	}
+
+	return precedence(left) < infixPrecedences[operator]
+}
+
+func rightNeedsParens(operator string, right ast.Expression) bool {
+	if operator == token.OperatorPower {
+		// the right operand of ** is parsed like the operand of a prefix operator
+		return precedence(right) < precedencePrefix
+	}
+
+	return precedence(right) <= infixPrecedences[operator]
+}
+
+func (p *printer) operand(expr ast.Expression, parens bool) {
+	if parens {
+		p.out.WriteString("(")
+		p.expression(expr)
+		p.out.WriteString(")")
+		return
+	}
+
+	p.expression(expr)
+}
+
+func (p *printer) expression(expr ast.Expression) {
+	switch expr := expr.(type) {
+	case *ast.Identifier:
+		p.out.WriteString(expr.Value)
+
+	case *ast.IntegerLiteral:
+		p.literal(expr.Token, strconv.FormatInt(expr.Value, 10))
+
+	case *ast.FloatLiteral:
+		p.literal(expr.Token, strconv.FormatFloat(expr.Value, 'g', -1, 64))
+
+	case *ast.StringLiteral:
+		p.out.WriteString(quote(expr.Value))
+
+	case *ast.BooleanLiteral:
+		p.out.WriteString(strconv.FormatBool(expr.Value))
+
+	case *ast.PrefixExpression:
+		p.out.WriteString(expr.Operator)
+		p.operand(expr.Right, precedence(expr.Right) < precedencePrefix)
+
+	case *ast.InfixExpression:
+		p.operand(expr.Left, leftNeedsParens(expr.Operator, expr.Left))
+		p.out.WriteString(" " + expr.Operator + " ")
+		p.operand(expr.Right, rightNeedsParens(expr.Operator, expr.Right))
+
+	case *ast.AssignExpression:
+		p.expression(expr.Target)
+		p.out.WriteString(" " + expr.Operator + " ")
+		p.expression(expr.Value)
+
+	case *ast.IfExpression:
+		p.out.WriteString("if (")
+		p.expression(expr.Condition)
+		p.out.WriteString(") ")
+		p.block(expr.Consequence)
+		if expr.Alternative != nil {
+			p.out.WriteString(" else ")
+			p.block(expr.Alternative)
+		}
+
+	case *ast.FunctionLiteral:
+		p.out.WriteString("fn")
+		p.parameters(expr.Parameters)
+		p.block(expr.Body)
+
+	case *ast.MacroLiteral:
+		p.out.WriteString("macro")
+		p.parameters(expr.Parameters)
+		p.block(expr.Body)
+
+	case *ast.CallExpression:
+		p.operand(expr.Function, precedence(expr.Function) < precedenceCall)
+		p.list("(", ")", expr.Token.Pos, expr.Rparen, positions(expr.Arguments), func(i int) token.Position {
+			p.expression(expr.Arguments[i])
+			return expr.Arguments[i].End()
+		})
+
+	case *ast.IndexExpression:
+		p.operand(expr.Left, precedence(expr.Left) < precedenceCall)
+		p.out.WriteString("[")
+		p.expression(expr.Index)
+		p.out.WriteString("]")
+
+	case *ast.ArrayLiteral:
+		p.list("[", "]", expr.Token.Pos, expr.Rbracket, positions(expr.Elements), func(i int) token.Position {
+			p.expression(expr.Elements[i])
+			return expr.Elements[i].End()
+		})
+
+	case *ast.HashLiteral:
+		keys := expr.SortedKeys()
+		p.list("{", "}", expr.Token.Pos, expr.Rbrace, positions(keys), func(i int) token.Position {
+			p.expression(keys[i])
+			p.out.WriteString(": ")
+			p.expression(expr.Pairs[keys[i]])
+			return expr.Pairs[keys[i]].End()
+		})
+
+	default:
+		p.out.WriteString(expr.String())
+	}
+}
+
+func (p *printer) parameters(params []*ast.Identifier) {
+	p.out.WriteString("(")
+	for i, param := range params {
+		if i > 0 {
+			p.out.WriteString(", ")
+		}
+		p.out.WriteString(param.Value)
+	}
+	p.out.WriteString(") ")
+}
+
+// literal writes the number as it's written in the source, e.g. 1e9 isn't changed to 1000000000.
+func (p *printer) literal(tok token.Token, value string) {
+	if tok.Literal != "" {
+		value = tok.Literal
+	}
+
+	p.out.WriteString(value)
+}
+
+// list writes comma separated items between the delimiters. The items are written one per line
+// if the first one is not on the line of the opening delimiter in the source, otherwise all of them
+// are written on a single line. The item function writes the i-th item and returns its end position.
+func (p *printer) list(open, close string, openPos, closePos token.Position, starts []token.Position, item func(int) token.Position) {
+	p.out.WriteString(open)
+
+	if len(starts) == 0 || starts[0].Line <= openPos.Line {
+		for i := range starts {
+			if i > 0 {
+				p.out.WriteString(", ")
+			}
+			item(i)
+		}
+
+		p.out.WriteString(close)
+		return
+	}
+
+	p.indent++
+	p.lastLine = noLine
+	for i, start := range starts {
+		p.commentsBefore(start)
+		p.beginLine(start.Line)
+		end := item(i)
+		nextPos := closePos
+		if i+1 < len(starts) {
+			p.out.WriteString(",")
+			nextPos = starts[i+1]
+		}
+
+		p.lastLine = end.Line
+		p.trailingComments(p.lastLine, nextPos)
+	}
+	p.commentsBefore(closePos)
+	p.indent--
+
+	p.newline()
+	p.out.WriteString(close)
+}
+
+func positions(exprs []ast.Expression) []token.Position {
+	starts := make([]token.Position, len(exprs))
+	for i, expr := range exprs {
+		starts[i] = expr.Pos()
+	}
+
+	return starts
+}
+
+// quote returns the string as a Monkey string literal, characters which can't be
+// written directly are escaped.
+func quote(s string) string {
+	var out strings.Builder
+	out.WriteByte('"')
+
+	for i := 0; i < len(s); {
+		ch, width := utf8.DecodeRuneInString(s[i:])
+
+		switch {
+		case ch == utf8.RuneError && width == 1:
+			// invalid UTF-8 is kept as it is, just like the lexer does
+			out.WriteByte(s[i])
+		case ch == '"':
+			out.WriteString(`\"`)
+		case ch == '\\':
+			out.WriteString(`\\`)
+		case ch == '\n':
+			out.WriteString(`\n`)
+		case ch == '\t':
+			out.WriteString(`\t`)
+		case ch == '\r':
+			out.WriteString(`\r`)
+		case unicode.IsPrint(ch):
+			out.WriteRune(ch)
+		default:
+			out.WriteString(`\u{` + strings.ToUpper(strconv.FormatInt(int64(ch), 16)) + `}`)
+		}
+
+		i += width
+	}
+
+	out.WriteByte('"')
+	return out.String()
+}
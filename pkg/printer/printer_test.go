@@ -0,0 +1,128 @@
+package printer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestFormat(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"let":                  {"let   x=5", "let x = 5;\n"},
+		"return":               {"fn(){return   1}", "fn() {\n\treturn 1;\n};\n"},
+		"empty program":        {"", ""},
+		"empty block":          {"if(x){}else{ }", "if (x) {} else {}\n"},
+		"redundant parens":     {"((a+b))*(c)", "(a + b) * c;\n"},
+		"left associative":     {"a-(b-c); (a-b)-c", "a - (b - c);\na - b - c;\n"},
+		"power":                {"(a**b)**c; a**(b**c); (-a)**b; a**-b", "(a ** b) ** c;\na ** b ** c;\n(-a) ** b;\na ** -b;\n"},
+		"logical":              {"(a||b)&&c; a||(b&&c)", "(a || b) && c;\na || b && c;\n"},
+		"prefix":               {"-(a+b); !(!a); -(f(x))", "-(a + b);\n!!a;\n-f(x);\n"},
+		"call":                 {"(fn(x){x})(1); (a+b)[0]; f(1,2)[0](3)", "fn(x) {\n\tx;\n}(1);\n(a + b)[0];\nf(1, 2)[0](3);\n"},
+		"assign":               {"x=y=1; a[0]+=(1+2)", "x = y = 1;\na[0] += 1 + 2;\n"},
+		"numbers":              {"1e9+0.50+007", "1e9 + 0.50 + 007;\n"},
+		"strings":              {`"a\"b\\c\td\u{1F600}"; ` + "`raw\n`", `"a\"b\\c` + `\t` + "d\U0001F600\";\n\"raw\\n\";\n"},
+		"control characters":   {`"\u{7}"`, `"\u{7}";` + "\n"},
+		"hash order":           {`{"b":1,"a":2, 3:[]}`, `{"b": 1, "a": 2, 3: []};` + "\n"},
+		"loops":                {"while(x<3){x+=1;if(x==2){break}else{continue}}", "while (x < 3) {\n\tx += 1;\n\tif (x == 2) {\n\t\tbreak;\n\t} else {\n\t\tcontinue;\n\t}\n}\n"},
+		"for":                  {"for(k,v in h){puts(k)} for(v in [1]){}", "for (k, v in h) {\n\tputs(k);\n}\nfor (v in [1]) {}\n"},
+		"blank lines":          {"let a=1;\n\n\n\nlet b=2;\nlet c=3;", "let a = 1;\n\nlet b = 2;\nlet c = 3;\n"},
+		"blank lines in block": {"fn(){\n\n  a;\n\n  b;\n\n}", "fn() {\n\ta;\n\n\tb;\n};\n"},
+		"if followed by group": {"if(a){b};\n(c+d)*2; if(a){b}; -c; if(a){b}; [c]", "if (a) {\n\tb;\n};\n(c + d) * 2;\nif (a) {\n\tb;\n};\n-c;\nif (a) {\n\tb;\n};\n[c];\n"},
+		"if followed by ident": {"if(a){b}; c", "if (a) {\n\tb;\n}\nc;\n"},
+		"macro":                {"let m=macro(a,b){quote(unquote(a)+unquote(b))}", "let m = macro(a, b) {\n\tquote(unquote(a) + unquote(b));\n};\n"},
+		"multiline array":      {"[\n1,\n2\n]", "[\n\t1,\n\t2\n];\n"},
+		"multiline call":       {"f(a, fn(){\nb\n})", "f(a, fn() {\n\tb;\n});\n"},
+		"multiline hash":       {"let h = {\n\"a\": 1, \"b\": 2}", "let h = {\n\t\"a\": 1,\n\t\"b\": 2\n};\n"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			output, err := Format("test.mk", []byte(data.input))
+			assert.NoError(t, err)
+			assert.Equal(t, data.expected, string(output))
+		})
+	}
+}
+
+func TestFormatComments(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"leading":              {"// header\n\n// doc\nlet x = 1;", "// header\n\n// doc\nlet x = 1;\n"},
+		"trailing":             {"let x = 1; // one\nlet y = 2;   /* two */", "let x = 1; // one\nlet y = 2; /* two */\n"},
+		"in block":             {"fn(){\n// first\na; // a\n// last\n}", "fn() {\n\t// first\n\ta; // a\n\t// last\n};\n"},
+		"in list":              {"[\n// one\n1, // 1\n2\n// end\n]", "[\n\t// one\n\t1, // 1\n\t2\n\t// end\n];\n"},
+		"only":                 {"/* a\n  b */", "/* a\n  b */\n"},
+		"at end":               {"a;\n\n// end", "a;\n\n// end\n"},
+		"after next statement": {"let a = [1,\n2]; b; // b", "let a = [1, 2];\nb; // b\n"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			output, err := Format("test.mk", []byte(data.input))
+			assert.NoError(t, err)
+			assert.Equal(t, data.expected, string(output))
+		})
+	}
+}
+
+func TestFormatIsIdempotentAndKeepsMeaning(t *testing.T) {
+	inputs := []string{
+		"let fib = fn(n){if(n<2){return n}; fib(n-1)+fib(n-2)}; puts(fib(10))",
+		"let a = [1, 2 + 3, (4 - 5) * 6, -(7 ** 2) ** 3, !true == false];",
+		"let h = {\"a\": fn(x) { x % 2 }, 1: [\n1,\n2\n]}; h[\"a\"](3) >= 1 || h[1][0] <= 2 && false",
+		"let i = 0; while (i < 10) { i += 1; // step\nif (i % 2 == 0) { continue } /* odd */ }",
+		"for (k, v in {\"a\": 1}) { puts(k, v) }\n\n\n// done\n",
+		"if (x) { 1 } else { 2 }\n-1;\nif (y) { 3 }\n[4]",
+		"let s = \"tab\\tquote\\\" \\u{1F600} \\u{0}\";",
+		"let f = fn(a, b) { a = b = a * (b + 1); a[0] -= (a / b) % 3; };",
+	}
+
+	for _, input := range inputs {
+		first, err := Format("test.mk", []byte(input))
+		assert.NoError(t, err, input)
+
+		second, err := Format("test.mk", first)
+		assert.NoError(t, err, string(first))
+		assert.Equal(t, string(first), string(second))
+
+		assert.Equal(t, programString(t, input), programString(t, string(first)))
+	}
+}
+
+func TestFormatReturnsParseErrors(t *testing.T) {
+	_, err := Format("test.mk", []byte("let = 5;"))
+
+	errs, ok := err.(parser.ErrorList)
+	assert.True(t, ok)
+	assert.NotEmpty(t, errs)
+	assert.True(t, strings.HasPrefix(errs.Error(), "test.mk:1:5"), errs.Error())
+}
+
+func TestFprintWithoutComments(t *testing.T) {
+	program, err := parser.New(lexer.New("let x = 1; // comment")).ParseProgram()
+	assert.NoError(t, err)
+
+	var out strings.Builder
+	assert.NoError(t, Fprint(&out, program))
+	assert.Equal(t, "let x = 1;\n", out.String())
+}
+
+func programString(t *testing.T, input string) string {
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	assert.NoError(t, err, input)
+
+	return program.String()
+}
@@ -0,0 +1,208 @@
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+// commandPrefix starts the input which is a command of the REPL rather than code.
+const commandPrefix = ":"
+
+// metaCommand is a command of the REPL, e.g. ":env".
+type metaCommand struct {
+	args  string // arguments shown in the help, the command has no arguments if empty, they are optional if in brackets
+	usage string
+	run   func(s *session, arg string) error
+}
+
+var metaCommands = map[string]metaCommand{
+	"ast":    {"<code>", "print the syntax tree of the code", (*session).printAST},
+	"break":  {"[[file:]line [cond]]", "list the breakpoints or set one stopping before the statements on the line", (*session).setBreakpoint},
+	"clear":  {"<breakpoint>", "remove the breakpoint", (*session).clearBreakpoint},
+	"env":    {"", "print the global bindings", (*session).printEnv},
+	"load":   {"<file>", "evaluate the file", (*session).load},
+	"reset":  {"", "forget the bindings and the inputs", (*session).reset},
+	"save":   {"<file>", "write the inputs evaluated without errors to the file", (*session).save},
+	"step":   {"<code>", "evaluate the code stopping before its first statement", (*session).stepInto},
+	"time":   {"<code>", "evaluate the code and print how long it took", (*session).time},
+	"tokens": {"<code>", "print the tokens of the code", (*session).printTokens},
+	"type":   {"<code>", "evaluate the code and print the type of the result", (*session).printType},
+}
+
+// isCommand reports whether the input is a command of the REPL.
+func isCommand(input string) bool {
+	return strings.HasPrefix(strings.TrimSpace(input), commandPrefix)
+}
+
+// command runs the command of the REPL, the input is the command name with the prefix
+// followed by the argument.
+func (s *session) command(input string) {
+	s.runCommand(metaCommands, input)
+}
+
+func (s *session) runCommand(commands map[string]metaCommand, input string) {
+	name, arg := splitCommand(input)
+
+	if name == "help" {
+		s.help(commands)
+		return
+	}
+
+	cmd, ok := commands[name]
+	if !ok {
+		fmt.Fprintf(s.out, "unknown command %s%s, try %shelp%s", commandPrefix, name, commandPrefix, lineBreak)
+		return
+	}
+
+	optional := strings.HasPrefix(cmd.args, "[")
+	if !optional && (cmd.args == "") != (arg == "") {
+		fmt.Fprintf(s.out, "usage: %s%s %s%s", commandPrefix, name, cmd.args, lineBreak)
+		return
+	}
+
+	if err := cmd.run(s, arg); err != nil {
+		fmt.Fprintf(s.out, "%s%s: %v%s", commandPrefix, name, err, lineBreak)
+	}
+}
+
+// splitCommand returns the name of the command without the prefix and its argument.
+func splitCommand(input string) (string, string) {
+	input = strings.TrimPrefix(strings.TrimSpace(input), commandPrefix)
+
+	if i := strings.IndexAny(input, " \t"); i >= 0 {
+		return input[:i], strings.TrimSpace(input[i:])
+	}
+
+	return input, ""
+}
+
+func (s *session) help(commands map[string]metaCommand) {
+	names := make([]string, 0, len(commands))
+	for name := range commands {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		cmd := commands[name]
+		fmt.Fprintf(s.out, "%-20s %s%s", commandPrefix+name+" "+cmd.args, cmd.usage, lineBreak)
+	}
+}
+
+func (s *session) printTokens(src string) error {
+	var errs parser.ErrorList
+
+	l := lexer.New(src)
+	l.SetMode(lexer.ScanComments)
+	l.SetErrorHandler(func(tok token.Token, pos token.Position, msg string) {
+		errs.Add(pos, tok, parser.SeverityError, "%s", msg)
+	})
+
+	for tok := l.NextToken(); tok.Type != token.Eof; tok = l.NextToken() {
+		fmt.Fprintf(s.out, "%s %s %q%s", tok.Pos, tok.Type, tok.Literal, lineBreak)
+	}
+
+	if len(errs) > 0 {
+		parser.Render(s.out, src, errs)
+	}
+
+	return nil
+}
+
+func (s *session) printAST(src string) error {
+	program, err := parser.New(lexer.New(src)).ParseProgram()
+	if err != nil {
+		parser.Render(s.out, src, err)
+		return nil
+	}
+
+	ast.Outline(s.out, program)
+	return nil
+}
+
+// printEnv prints the global bindings, values of functions are not printed.
+func (s *session) printEnv(string) error {
+	bindings := s.interpreter.Bindings()
+
+	names := make([]string, 0, len(bindings))
+	for name := range bindings {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		s.printBinding(name, bindings[name])
+	}
+
+	return nil
+}
+
+func (s *session) printBinding(name string, value object.Object) {
+	switch value.Type() {
+	case object.TypeFunction, object.TypeBuiltin, object.TypeMacro:
+		fmt.Fprintf(s.out, "%s: %s%s", name, value.Type(), lineBreak)
+	default:
+		fmt.Fprintf(s.out, "%s: %s = %s%s", name, value.Type(), value.Inspect(), lineBreak)
+	}
+}
+
+func (s *session) printType(src string) error {
+	result := s.evaluate("", src)
+	if result == nil || isError(result) {
+		s.print(result)
+		return nil
+	}
+
+	fmt.Fprintf(s.out, "%s%s", result.Type(), lineBreak)
+	return nil
+}
+
+func (s *session) time(src string) error {
+	start := time.Now()
+	result := s.evaluate("", src)
+	elapsed := time.Since(start)
+
+	s.print(result)
+	if result != nil {
+		fmt.Fprintf(s.out, "time: %v%s", elapsed.Round(time.Microsecond), lineBreak)
+	}
+
+	return nil
+}
+
+// load evaluates the file and prints the result only if it is an error.
+func (s *session) load(path string) error {
+	src, err := ioutil.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	if result := s.evaluate(path, string(src)); isError(result) {
+		s.print(result)
+	}
+
+	return nil
+}
+
+func (s *session) reset(string) error {
+	return s.newInterpreter()
+}
+
+func (s *session) save(path string) error {
+	var content strings.Builder
+	for _, input := range s.inputs {
+		content.WriteString(input)
+		content.WriteString(lineBreak)
+	}
+
+	return ioutil.WriteFile(path, []byte(content.String()), 0644)
+}
@@ -0,0 +1,89 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/monkey"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func runSession(t *testing.T, input string) string {
+	var out bytes.Buffer
+
+	err := Start(strings.NewReader(input), &out, monkey.EngineEval)
+	assert.NoError(t, err)
+
+	return strings.Replace(out.String(), prompt, "", -1)
+}
+
+func TestCommands(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"tokens":           {":tokens let x = 1; // one\n", "1:1 LET \"let\"\n1:5 IDENT \"x\"\n1:7 = \"=\"\n1:9 INT \"1\"\n1:10 ; \";\"\n1:12 COMMENT \"// one\"\n"},
+		"tokens errors":    {":tokens x # y\n", "1:3 ILLEGAL \"#\"\n1:5 IDENT \"y\"\n1:3: error: illegal character '#'\n"},
+		"ast":              {":ast -x\n", "Program 1:1 \"-\"\n  ExpressionStatement 1:1 \"-\"\n    PrefixExpression 1:1 \"-\"\n      Identifier 1:2 \"x\"\n"},
+		"ast parse error":  {":ast let\n", "1:4: error: expected next token to be IDENT"},
+		"ast incomplete":   {":ast fn(x) {\n1\n", "FunctionLiteral 1:1 \"fn\"\n"},
+		"env":              {"let x = 1; let f = fn() { x }; let s = \"a\";\n:env\n", "f: FUNCTION\ns: STRING = a\nx: INTEGER = 1\n"},
+		"env empty":        {":env\n", ""},
+		"type":             {":type [1, 2]\n", "ARRAY\n"},
+		"type error":       {":type 1 / 0\n", "ERROR: 1:3: division by zero\n"},
+		"type binds":       {":type let x = 2; x\nx * 2\n", "INTEGER\n4\n"},
+		"time":             {":time 1 + 2\n", "3\ntime: "},
+		"reset":            {"let x = 1;\n:reset\nx\n", "ERROR: 1:1: identifier not found: x\n"},
+		"help":             {":help\n", ":ast <code>          print the syntax tree of the code\n"},
+		"unknown":          {":quit\n", "unknown command :quit, try :help\n"},
+		"missing argument": {":type\n", "usage: :type <code>\n"},
+		"extra argument":   {":env x\n", "usage: :env \n"},
+		"load missing":     {":load /nonexistent/file.mk\n", ":load: open /nonexistent/file.mk: no such file or directory\n"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			assert.Contains(t, runSession(t, data.input), data.expected)
+		})
+	}
+}
+
+func TestLoadAndSave(t *testing.T) {
+	dir, err := ioutil.TempDir("", "monkey")
+	assert.NoError(t, err)
+	defer os.RemoveAll(dir)
+
+	script := filepath.Join(dir, "lib.mk")
+	assert.NoError(t, ioutil.WriteFile(script, []byte("let double = fn(x) {\n  x * 2\n};\n"), 0644))
+
+	broken := filepath.Join(dir, "broken.mk")
+	assert.NoError(t, ioutil.WriteFile(broken, []byte("let y = 1 / 0;\n"), 0644))
+
+	saved := filepath.Join(dir, "session.mk")
+
+	input := ":load " + script + "\n" +
+		":load " + broken + "\n" +
+		"let a = double(2);\n" +
+		"a / 0\n" +
+		"let b = fn() {\n  a\n};\n" +
+		"let x = ;\n" +
+		":save " + saved + "\n"
+
+	out := runSession(t, input)
+	assert.Contains(t, out, "ERROR: "+broken+":1:11: division by zero\n")
+
+	content, err := ioutil.ReadFile(saved)
+	assert.NoError(t, err)
+	assert.Equal(t, "let double = fn(x) {\n  x * 2\n};\n\nlet a = double(2);\nlet b = fn() {\n  a\n};\n", string(content))
+
+	// the saved session evaluates to the same bindings
+	out = runSession(t, ":load "+saved+"\nb()\n")
+	assert.Equal(t, "4\n", out)
+}
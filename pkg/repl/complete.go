@@ -0,0 +1,190 @@
+package repl
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/adrian83/monkey/pkg/ast"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+var (
+	// identifierSuffix matches the identifier before the cursor
+	identifierSuffix = regexp.MustCompile(`[A-Za-z_][A-Za-z0-9_]*$`)
+	// hashKeySuffix matches the index expression before the cursor, e.g. h["ke
+	hashKeySuffix = regexp.MustCompile(`([A-Za-z_][A-Za-z0-9_]*)\[(?:"((?:[^"\\]|\\.)*))?$`)
+
+	keyEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`, "\t", `\t`, "\r", `\r`)
+)
+
+// completer suggests how to complete the text before the cursor.
+type completer interface {
+	// complete returns the candidates which replace the text from start to the end of the line.
+	complete(line string) (start int, candidates []string)
+	// hint returns the signature or the documentation of the function with the given name.
+	hint(name string) string
+}
+
+// complete suggests keys of the hash after an opening bracket or a quote following it, otherwise
+// the keywords, the bound names and the builtins starting with the identifier before the cursor.
+// Nothing is suggested in strings, comments and for names of new bindings.
+func (s *session) complete(line string) (int, []string) {
+	if match := hashKeySuffix.FindStringSubmatchIndex(line); match != nil {
+		name := line[match[2]:match[3]]
+		if hash, ok := s.lookup(name).(*object.Hash); ok {
+			return match[3] + 1, hashKeys(hash, line[match[3]+1:])
+		}
+	}
+
+	if !completesName(line) {
+		return len(line), nil
+	}
+
+	loc := identifierSuffix.FindStringIndex(line)
+	if loc == nil {
+		return len(line), nil
+	}
+	prefix := line[loc[0]:]
+
+	seen := map[string]bool{}
+	var candidates []string
+	add := func(name string) {
+		if strings.HasPrefix(name, prefix) && !seen[name] {
+			seen[name] = true
+			candidates = append(candidates, name)
+		}
+	}
+
+	for _, keyword := range token.Keywords() {
+		add(keyword)
+	}
+	for name := range s.interpreter.Bindings() {
+		add(name)
+	}
+	for name := range s.interpreter.Builtins() {
+		add(name)
+	}
+	sort.Strings(candidates)
+
+	return loc[0], candidates
+}
+
+// completesName reports whether the text ends with a name which refers to a binding, it is false
+// inside strings and comments and after keywords introducing new bindings.
+func completesName(line string) bool {
+	insideLiteral := false
+
+	l := lexer.New(line)
+	l.SetMode(lexer.ScanComments)
+	l.SetErrorHandler(func(tok token.Token, pos token.Position, msg string) {
+		if strings.HasPrefix(msg, "unterminated") {
+			insideLiteral = true
+		}
+	})
+
+	var tokens []token.Token
+	for tok := l.NextToken(); tok.Type != token.Eof; tok = l.NextToken() {
+		tokens = append(tokens, tok)
+	}
+
+	if insideLiteral {
+		return false
+	}
+	if len(tokens) == 0 || tokens[len(tokens)-1].Type == token.Comment {
+		// a line comment continues to the end of the line
+		return len(tokens) == 0
+	}
+
+	return !declaresName(tokens[:len(tokens)-1])
+}
+
+// declaresName reports whether the tokens are followed by a name of a new binding, which is
+// the case after let and in lists of parameters and loop variables.
+func declaresName(tokens []token.Token) bool {
+	i := len(tokens) - 1
+	if i >= 0 && tokens[i].Type == token.KeywordLet {
+		return true
+	}
+
+	for i >= 0 && (tokens[i].Type == token.Ident || tokens[i].Type == token.DelimiterComma) {
+		i--
+	}
+
+	if i < 1 || tokens[i].Type != token.DelimiterLeftParenthesis {
+		return false
+	}
+
+	switch tokens[i-1].Type {
+	case token.KeywordFunction, token.KeywordMacro, token.KeywordFor:
+		return true
+	default:
+		return false
+	}
+}
+
+// hashKeys returns the string keys of the hash as string literals followed by a closing bracket
+// which start with the prefix, the prefix is empty or an unterminated string literal.
+func hashKeys(hash *object.Hash, prefix string) []string {
+	var keys []string
+	for _, pair := range object.SortedPairs(hash) {
+		key, ok := pair.Key.(*object.String)
+		if !ok {
+			continue
+		}
+
+		if literal := `"` + keyEscaper.Replace(key.Value) + `"]`; strings.HasPrefix(literal, prefix) {
+			keys = append(keys, literal)
+		}
+	}
+
+	return keys
+}
+
+// hint returns the signature of the function bound to the name or the documentation of the builtin.
+func (s *session) hint(name string) string {
+	switch fn := s.lookup(name).(type) {
+	case *object.Builtin:
+		if fn.Doc != "" {
+			return fn.Doc
+		}
+		return name + "(...) builtin function"
+
+	case *object.Function:
+		return name + "(" + identifiers(fn.Parameters) + ")"
+
+	case *object.Closure:
+		params := fn.Fn.LocalNames
+		if len(params) < fn.Fn.NumParameters {
+			return fmt.Sprintf("%s(...) function with %d parameters", name, fn.Fn.NumParameters)
+		}
+		return name + "(" + strings.Join(params[:fn.Fn.NumParameters], ", ") + ")"
+
+	case *object.Macro:
+		return "macro " + name + "(" + identifiers(fn.Parameters) + ")"
+
+	default:
+		return ""
+	}
+}
+
+func identifiers(idents []*ast.Identifier) string {
+	names := make([]string, len(idents))
+	for i, ident := range idents {
+		names[i] = ident.Value
+	}
+
+	return strings.Join(names, ", ")
+}
+
+// lookup returns the value bound to the name or the builtin with the name, nil if there isn't any.
+func (s *session) lookup(name string) object.Object {
+	if value, ok := s.interpreter.Bindings()[name]; ok {
+		return value
+	}
+
+	return s.interpreter.Builtins()[name]
+}
@@ -0,0 +1,135 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/monkey"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func newTestSession(t *testing.T, engine monkey.Engine, src string) *session {
+	s := newSession(&bytes.Buffer{}, engine)
+	assert.NoError(t, s.newInterpreter())
+
+	s.evaluate("", src)
+	assert.Empty(t, s.out.(*bytes.Buffer).String())
+
+	return s
+}
+
+func TestComplete(t *testing.T) {
+	src := `let length = 1; let lengths = [1]; let config = {"port": 80, "path": "/", "say \"hi\"": 1, 2: 3}; let f = fn(a) { let local = a; local };`
+
+	testData := map[string]struct {
+		line       string
+		start      int
+		candidates []string
+	}{
+		"keyword and builtins": {"x + l", 4, []string{"last", "len", "length", "lengths", "let"}},
+		"bindings":             {"leng", 0, []string{"length", "lengths"}},
+		"single":               {"puts(conf", 5, []string{"config"}},
+		"locals are not bound": {"loc", 0, nil},
+		"nothing typed":        {"x + ", 4, nil},
+		"in a string":          {`"le`, 3, nil},
+		"in a comment":         {"1 // le", 7, nil},
+		"after a comment":      {"/* x */ le", 8, []string{"len", "length", "lengths", "let"}},
+		"new binding":          {"let le", 6, nil},
+		"loop variable":        {"for (le", 7, nil},
+		"loop key and value":   {"for (k, le", 10, nil},
+		"parameter":            {"fn(a, le", 8, nil},
+		"iterable":             {"for (k in le", 10, []string{"len", "length", "lengths", "let"}},
+		"argument":             {"f(a, le", 5, []string{"len", "length", "lengths", "let"}},
+		"hash keys":            {"config[", 7, []string{`"path"]`, `"port"]`, `"say \"hi\""]`}},
+		"hash key prefix":      {`puts(config["p`, 12, []string{`"path"]`, `"port"]`}},
+		"escaped hash key":     {`config["say \"`, 7, []string{`"say \"hi\""]`}},
+		"not a hash":           {`lengths["`, 9, nil},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			for _, engine := range []monkey.Engine{monkey.EngineEval, monkey.EngineVM} {
+				s := newTestSession(t, engine, src)
+
+				start, candidates := s.complete(data.line)
+
+				assert.Equal(t, data.start, start, "engine %s", engine)
+				assert.Equal(t, data.candidates, candidates, "engine %s", engine)
+			}
+		})
+	}
+}
+
+func TestHint(t *testing.T) {
+	src := `let add = fn(a, b) { a + b }; let unless = macro(cond, body) { quote(1) }; let x = 1;`
+
+	for _, engine := range []monkey.Engine{monkey.EngineEval, monkey.EngineVM} {
+		s := newTestSession(t, engine, src)
+		assert.NoError(t, s.interpreter.Register("upper", strings.ToUpper))
+
+		assert.Equal(t, "add(a, b)", s.hint("add"), engine)
+		assert.Equal(t, "macro unless(cond, body)", s.hint("unless"), engine)
+		assert.Equal(t, "push(array, x) returns a new array with x appended", s.hint("push"), engine)
+		assert.Equal(t, "upper(...) builtin function", s.hint("upper"), engine)
+		assert.Equal(t, "", s.hint("x"), engine)
+		assert.Equal(t, "", s.hint("missing"), engine)
+	}
+}
+
+type fakeCompleter struct {
+	candidates []string
+}
+
+func (c fakeCompleter) complete(line string) (int, []string) {
+	start := strings.LastIndex(line, " ") + 1
+
+	var candidates []string
+	for _, candidate := range c.candidates {
+		if strings.HasPrefix(candidate, line[start:]) {
+			candidates = append(candidates, candidate)
+		}
+	}
+
+	return start, candidates
+}
+
+func (c fakeCompleter) hint(name string) string {
+	return "hint of " + name
+}
+
+func TestEditorComplete(t *testing.T) {
+	completer := fakeCompleter{candidates: []string{"first", "float", "floor", "żółw"}}
+
+	testData := map[string]struct {
+		input    string
+		expected string
+		shown    string
+	}{
+		"single":            {"x fi\t\r", "x first", "\r\nhint of first\r\n"},
+		"common prefix":     {"fl\t\r", "flo", ""},
+		"list":              {"flo\t\r", "flo", "\r\nfloat  floor\r\n"},
+		"before the cursor": {"fi)" + left + "\t\r", "first)", "hint of first"},
+		"unicode":           {"ż\t\r", "żółw", "hint of żółw"},
+		"no candidates":     {"x\t\r", "x", ""},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			e := newEditor(strings.NewReader(data.input), &out, &history{})
+			e.completer = completer
+
+			line, err := e.readLine(">> ")
+
+			assert.NoError(t, err)
+			assert.Equal(t, data.expected, line)
+			assert.Contains(t, out.String(), data.shown)
+		})
+	}
+}
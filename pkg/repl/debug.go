@@ -0,0 +1,215 @@
+package repl
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+
+	"github.com/adrian83/monkey/pkg/debugger"
+	"github.com/adrian83/monkey/pkg/monkey"
+)
+
+const debugPrompt = "debug>>"
+
+var errNotDebuggable = errors.New("debugging requires the eval engine")
+
+// resumeCommand continues the program stopped by the debugger.
+type resumeCommand struct {
+	action debugger.Action
+	usage  string
+}
+
+var resumeCommands = map[string]resumeCommand{
+	"continue": {debugger.Continue, "continue until a breakpoint"},
+	"next":     {debugger.StepOver, "stop before the next statement, without stopping in the called functions"},
+	"out":      {debugger.StepOut, "stop before the next statement of the caller"},
+	"quit":     {debugger.Terminate, "terminate the program"},
+	"step":     {debugger.StepIn, "stop before the next statement"},
+}
+
+// stopCommands are available while the program is stopped, besides the resume commands.
+// Other input is evaluated in the selected frame.
+var stopCommands = map[string]metaCommand{
+	"break": metaCommands["break"],
+	"clear": metaCommands["clear"],
+	"frame": {"<number>", "select the frame of the call stack", (*session).selectFrame},
+	"stack": {"", "print the call stack", (*session).printStack},
+	"vars":  {"", "print the bindings of the selected frame", (*session).printVars},
+}
+
+// setBreakpoint lists the breakpoints if the argument is empty, otherwise it sets the breakpoint. Lines
+// without the file refer to the code entered in the REPL.
+func (s *session) setBreakpoint(arg string) error {
+	if s.engine != monkey.EngineEval {
+		return errNotDebuggable
+	}
+
+	if arg == "" {
+		for _, bp := range s.debugger.Breakpoints() {
+			fmt.Fprintf(s.out, "%d: %s%s", bp.ID, describeBreakpoint(bp), lineBreak)
+		}
+		return nil
+	}
+
+	location, condition := arg, ""
+	if i := strings.IndexAny(arg, " \t"); i >= 0 {
+		location, condition = arg[:i], strings.TrimSpace(arg[i:])
+	}
+
+	file, line := "", location
+	if i := strings.LastIndex(location, ":"); i >= 0 {
+		file, line = location[:i], location[i+1:]
+	}
+
+	number, err := strconv.Atoi(line)
+	if err != nil || number < 1 {
+		return fmt.Errorf("invalid line: %s", line)
+	}
+
+	bp, err := s.debugger.SetBreakpoint(file, number, condition)
+	if err != nil {
+		return err
+	}
+
+	fmt.Fprintf(s.out, "breakpoint %d at %s%s", bp.ID, describeBreakpoint(bp), lineBreak)
+	return nil
+}
+
+func describeBreakpoint(bp *debugger.Breakpoint) string {
+	description := strconv.Itoa(bp.Line)
+	if bp.File != "" {
+		description = bp.File + ":" + description
+	}
+	if bp.Condition != "" {
+		description += " if " + bp.Condition
+	}
+
+	return description
+}
+
+func (s *session) clearBreakpoint(arg string) error {
+	id, err := strconv.Atoi(arg)
+	if err != nil || !s.debugger.RemoveBreakpoint(id) {
+		return fmt.Errorf("no breakpoint %s", arg)
+	}
+
+	return nil
+}
+
+// stepInto evaluates the code stopping before its first statement.
+func (s *session) stepInto(src string) error {
+	if s.engine != monkey.EngineEval {
+		return errNotDebuggable
+	}
+
+	s.print(s.debug("", src, debugger.StepIn))
+	return nil
+}
+
+// stopped reads commands and expressions until the input tells how the program has to continue.
+func (s *session) stopped(stop *debugger.Stop) debugger.Action {
+	s.stop, s.frame = stop, 0
+	defer func() { s.stop = nil }()
+
+	reason := string(stop.Reason)
+	if stop.Breakpoint != nil {
+		reason = fmt.Sprintf("breakpoint %d", stop.Breakpoint.ID)
+	}
+	fmt.Fprintf(s.out, "%s at %v in %s%s  %s%s", reason, stop.Frames[0].Pos, stop.Frames[0].Name(), lineBreak, stop.Node, lineBreak)
+
+	for {
+		input, err := s.lines.readLine(debugPrompt)
+		switch {
+		case err == io.EOF:
+			return debugger.Terminate
+		case err == errInterrupted:
+			continue
+		case err != nil:
+			fmt.Fprintf(s.out, "%v%s", err, lineBreak)
+			return debugger.Terminate
+		}
+
+		s.remember(input)
+
+		if strings.TrimSpace(input) == "" {
+			continue
+		}
+
+		if !isCommand(input) {
+			s.evaluateInFrame(input)
+			continue
+		}
+
+		name, arg := splitCommand(input)
+		if resume, ok := resumeCommands[name]; ok && arg == "" {
+			return resume.action
+		}
+
+		if name == "help" {
+			s.helpStopped()
+			continue
+		}
+
+		s.runCommand(stopCommands, input)
+	}
+}
+
+func (s *session) helpStopped() {
+	s.help(stopCommands)
+
+	commands := map[string]metaCommand{}
+	for name, resume := range resumeCommands {
+		commands[name] = metaCommand{usage: resume.usage}
+	}
+	s.help(commands)
+}
+
+func (s *session) evaluateInFrame(src string) {
+	result, err := s.debugger.Evaluate(src, s.stop.Frames[s.frame])
+	if err != nil {
+		fmt.Fprintf(s.out, "%v%s", err, lineBreak)
+		return
+	}
+
+	s.print(result)
+}
+
+func (s *session) selectFrame(arg string) error {
+	number, err := strconv.Atoi(arg)
+	if err != nil || number < 0 || number >= len(s.stop.Frames) {
+		return fmt.Errorf("no frame %s", arg)
+	}
+
+	s.frame = number
+	s.printFrame(number)
+	return nil
+}
+
+// printStack prints the frames, the innermost first, the selected one is marked with an asterisk.
+func (s *session) printStack(string) error {
+	for i := range s.stop.Frames {
+		s.printFrame(i)
+	}
+
+	return nil
+}
+
+func (s *session) printFrame(i int) {
+	marker := " "
+	if i == s.frame {
+		marker = "*"
+	}
+
+	frame := s.stop.Frames[i]
+	fmt.Fprintf(s.out, "%s%d %s at %v%s", marker, i, frame.Name(), frame.Pos, lineBreak)
+}
+
+func (s *session) printVars(string) error {
+	for _, variable := range s.stop.Frames[s.frame].Locals() {
+		s.printBinding(variable.Name, variable.Value)
+	}
+
+	return nil
+}
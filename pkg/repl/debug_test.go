@@ -0,0 +1,57 @@
+package repl
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/monkey"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const debugFunction = "let add = fn(a, b) {\n  let sum = a + b;\n  sum\n};\n"
+
+func TestDebugCommands(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"set breakpoint":        {":break 2\n", "breakpoint 1 at 2\n"},
+		"conditional":           {":break f.mk:3 x > 1\n:break\n", "breakpoint 1 at f.mk:3 if x > 1\n1: f.mk:3 if x > 1\n"},
+		"invalid line":          {":break x\n", ":break: invalid line: x\n"},
+		"invalid condition":     {":break 1 x >\n", ":break: invalid condition:"},
+		"clear":                 {":break 2\n:break 3\n:clear 1\n:break\n", "2: 3\n"},
+		"clear missing":         {":clear 7\n", ":clear: no breakpoint 7\n"},
+		"stop at breakpoint":    {debugFunction + ":break 2\nadd(1, 2)\n:continue\n", "breakpoint 1 at 2:3 in add()\n  let sum = (a + b);\ndebug3\n"},
+		"evaluate in frame":     {debugFunction + ":break 2\nadd(1, 2)\na * 10\n:continue\n", "debug10\ndebug3\n"},
+		"vars":                  {debugFunction + ":break 3\nadd(1, 2)\n:vars\n:continue\n", "a: INTEGER = 1\nb: INTEGER = 2\nsum: INTEGER = 3\n"},
+		"stack":                 {debugFunction + ":break 3\nadd(1, 2)\n:stack\n:continue\n", "*0 add() at 3:3\n 1 main() at 1:1\n"},
+		"frame":                 {debugFunction + ":break 3\nlet x = 5;\nadd(1, 2)\n:frame 1\nx\n:frame 2\n:continue\n", "*1 main() at 1:1\ndebug5\ndebug:frame: no frame 2\n"},
+		"step":                  {":step let x = 1; let y = 2;\n:step\n:vars\n:continue\n", "entry at 1:1 in main()\n  let x = 1;\ndebugstep at 1:12 in main()\n  let y = 2;\ndebugx: INTEGER = 1\n"},
+		"next over call":        {debugFunction + ":step add(1, 2); add(3, 4)\n:next\n:continue\n", "step at 1:12 in main()\n"},
+		"step into call":        {debugFunction + ":step add(1, 2)\n:step\n:continue\n", "step at 2:3 in add()\n"},
+		"out":                   {debugFunction + ":break 2\nadd(1, 2); add(3, 4)\n:out\n:continue\n:continue\n", "step at 1:12 in main()\n  add(3, 4)\n"},
+		"quit":                  {":step puts(1);\n:quit\n", "ERROR: 1:1: terminated by the debugger\n"},
+		"help":                  {":step 1\n:help\n:continue\n", ":vars                print the bindings of the selected frame\n"},
+		"unknown while stopped": {":step 1\n:env\n:continue\n", "unknown command :env, try :help\n"},
+		"end of input":          {":step 1\n", "ERROR: 1:1: terminated by the debugger\n"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			assert.Contains(t, runSession(t, data.input), data.expected)
+		})
+	}
+}
+
+func TestDebugRequiresEvalEngine(t *testing.T) {
+	var out bytes.Buffer
+
+	err := Start(strings.NewReader(":break 1\n:step 1\n"), &out, monkey.EngineVM)
+	assert.NoError(t, err)
+
+	assert.Equal(t, ":break: debugging requires the eval engine\n:step: debugging requires the eval engine\n", strings.Replace(out.String(), prompt, "", -1))
+}
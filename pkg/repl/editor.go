@@ -0,0 +1,396 @@
+package repl
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"unicode"
+)
+
+// errInterrupted is returned when the input of a line is interrupted with Ctrl-C.
+var errInterrupted = errors.New("interrupted")
+
+// control characters
+const (
+	keyCtrlA     = 1
+	keyCtrlB     = 2
+	keyCtrlC     = 3
+	keyCtrlD     = 4
+	keyCtrlE     = 5
+	keyCtrlF     = 6
+	keyCtrlG     = 7
+	keyCtrlH     = 8
+	keyTab       = 9
+	keyCtrlK     = 11
+	keyCtrlL     = 12
+	keyEnter     = 13
+	keyCtrlN     = 14
+	keyCtrlP     = 16
+	keyCtrlR     = 18
+	keyCtrlU     = 21
+	keyCtrlW     = 23
+	keyEscape    = 27
+	keyBackspace = 127
+)
+
+// keys sent as escape sequences, they are negative so that they don't collide with characters
+const (
+	keyUp rune = -(iota + 1)
+	keyDown
+	keyRight
+	keyLeft
+	keyHome
+	keyEnd
+	keyDelete
+	keyUnknown
+)
+
+var escapeSequences = map[string]rune{
+	"[A": keyUp, "OA": keyUp,
+	"[B": keyDown, "OB": keyDown,
+	"[C": keyRight, "OC": keyRight,
+	"[D": keyLeft, "OD": keyLeft,
+	"[H": keyHome, "OH": keyHome, "[1~": keyHome, "[7~": keyHome,
+	"[F": keyEnd, "OF": keyEnd, "[4~": keyEnd, "[8~": keyEnd,
+	"[3~": keyDelete,
+}
+
+// editor reads lines from a terminal in raw mode, it echoes the input and lets the user move
+// the cursor, edit the line, recall lines from the history and search the history in reverse
+// with Ctrl-R and complete the word before the cursor with Tab, like readline does.
+type editor struct {
+	in        *bufio.Reader
+	out       io.Writer
+	history   *history
+	completer completer                                // nil if there is no completion
+	rawMode   func() (restore func() error, err error) // nil if the terminal is already in raw mode
+
+	prompt       string
+	line         []rune
+	pos          int    // position of the cursor in the line
+	historyIndex int    // index of the shown history entry, len(entries) for the new line
+	draft        []rune // the new line while a history entry is shown
+}
+
+func newEditor(in io.Reader, out io.Writer, h *history) *editor {
+	return &editor{in: bufio.NewReader(in), out: out, history: h}
+}
+
+// readLine shows the prompt and reads the line, io.EOF is returned if Ctrl-D is pressed
+// on an empty line and errInterrupted if Ctrl-C is pressed.
+func (e *editor) readLine(prompt string) (string, error) {
+	if e.rawMode != nil {
+		restore, err := e.rawMode()
+		if err != nil {
+			return "", err
+		}
+		defer restore()
+	}
+
+	e.prompt, e.line, e.pos = prompt, nil, 0
+	e.historyIndex, e.draft = len(e.history.entries), nil
+	e.refresh()
+
+	for {
+		key, err := e.readKey()
+		if err == io.EOF && len(e.line) > 0 {
+			io.WriteString(e.out, "\r\n")
+			return string(e.line), nil
+		}
+		if err != nil {
+			return "", err
+		}
+
+		if done, err := e.handle(key); done || err != nil {
+			return string(e.line), err
+		}
+	}
+}
+
+// readKey reads a character or a key sent as an escape sequence.
+func (e *editor) readKey() (rune, error) {
+	r, _, err := e.in.ReadRune()
+	if err != nil || r != keyEscape {
+		return r, err
+	}
+
+	next, _, err := e.in.ReadRune()
+	if err != nil {
+		return 0, err
+	}
+	if next != '[' && next != 'O' {
+		return keyUnknown, nil
+	}
+
+	// the sequence ends with a character from the range @ to ~
+	sequence := []rune{next}
+	for {
+		r, _, err := e.in.ReadRune()
+		if err != nil {
+			return 0, err
+		}
+		sequence = append(sequence, r)
+
+		if r >= '@' && r <= '~' {
+			break
+		}
+	}
+
+	if key, ok := escapeSequences[string(sequence)]; ok {
+		return key, nil
+	}
+
+	return keyUnknown, nil
+}
+
+// handle performs the action of the key and reports whether the line is finished.
+func (e *editor) handle(key rune) (bool, error) {
+	switch key {
+	case keyEnter, '\n':
+		io.WriteString(e.out, "\r\n")
+		return true, nil
+
+	case keyCtrlC:
+		io.WriteString(e.out, "^C\r\n")
+		e.line = nil
+		return true, errInterrupted
+
+	case keyCtrlD:
+		if len(e.line) == 0 {
+			io.WriteString(e.out, "\r\n")
+			return true, io.EOF
+		}
+		e.delete(e.pos, e.pos+1)
+
+	case keyCtrlR:
+		return e.search()
+
+	case keyBackspace, keyCtrlH:
+		e.delete(e.pos-1, e.pos)
+	case keyDelete:
+		e.delete(e.pos, e.pos+1)
+	case keyCtrlK:
+		e.delete(e.pos, len(e.line))
+	case keyCtrlU:
+		e.delete(0, e.pos)
+	case keyCtrlW:
+		e.delete(e.previousWord(), e.pos)
+
+	case keyLeft, keyCtrlB:
+		e.moveTo(e.pos - 1)
+	case keyRight, keyCtrlF:
+		e.moveTo(e.pos + 1)
+	case keyHome, keyCtrlA:
+		e.moveTo(0)
+	case keyEnd, keyCtrlE:
+		e.moveTo(len(e.line))
+
+	case keyUp, keyCtrlP:
+		e.showHistory(e.historyIndex - 1)
+	case keyDown, keyCtrlN:
+		e.showHistory(e.historyIndex + 1)
+
+	case keyTab:
+		e.complete()
+
+	case keyCtrlL:
+		io.WriteString(e.out, "\x1b[H\x1b[2J")
+
+	default:
+		if key < 0 || !unicode.IsPrint(key) {
+			return false, nil
+		}
+		e.insert(key)
+	}
+
+	e.refresh()
+	return false, nil
+}
+
+func (e *editor) insert(r rune) {
+	e.line = append(e.line, 0)
+	copy(e.line[e.pos+1:], e.line[e.pos:])
+	e.line[e.pos] = r
+	e.pos++
+}
+
+// delete removes the characters from start to end, the range is limited to the line.
+func (e *editor) delete(start, end int) {
+	if start < 0 {
+		start = 0
+	}
+	if end > len(e.line) {
+		end = len(e.line)
+	}
+	if start >= end {
+		return
+	}
+
+	e.line = append(e.line[:start], e.line[end:]...)
+	e.pos = start
+}
+
+func (e *editor) moveTo(pos int) {
+	if pos >= 0 && pos <= len(e.line) {
+		e.pos = pos
+	}
+}
+
+// previousWord returns the position of the beginning of the word before the cursor.
+func (e *editor) previousWord() int {
+	pos := e.pos
+	for pos > 0 && unicode.IsSpace(e.line[pos-1]) {
+		pos--
+	}
+	for pos > 0 && !unicode.IsSpace(e.line[pos-1]) {
+		pos--
+	}
+
+	return pos
+}
+
+// complete replaces the text before the cursor with the longest common prefix of the candidates.
+// The candidates are listed below the line if there are more of them and the prefix is already
+// typed, the hint of a single candidate is shown.
+func (e *editor) complete() {
+	if e.completer == nil {
+		return
+	}
+
+	before := string(e.line[:e.pos])
+	start, candidates := e.completer.complete(before)
+	if len(candidates) == 0 {
+		return
+	}
+
+	typed := []rune(before[start:])
+	prefix := commonPrefix(candidates)
+	if len(prefix) > len(typed) {
+		e.delete(e.pos-len(typed), e.pos)
+		for _, r := range prefix {
+			e.insert(r)
+		}
+	}
+
+	switch {
+	case len(candidates) == 1:
+		if hint := e.completer.hint(candidates[0]); hint != "" {
+			e.printBelow(hint)
+		}
+	case len(prefix) <= len(typed):
+		e.printBelow(strings.Join(candidates, "  "))
+	}
+}
+
+// printBelow prints the text below the line, the line is redrawn afterwards.
+func (e *editor) printBelow(text string) {
+	io.WriteString(e.out, "\r\n"+text+"\r\n")
+}
+
+func commonPrefix(words []string) []rune {
+	prefix := []rune(words[0])
+	for _, word := range words[1:] {
+		runes := []rune(word)
+
+		i := 0
+		for i < len(prefix) && i < len(runes) && prefix[i] == runes[i] {
+			i++
+		}
+		prefix = prefix[:i]
+	}
+
+	return prefix
+}
+
+// showHistory replaces the line with the history entry, the new line is kept aside
+// while the entries are shown.
+func (e *editor) showHistory(index int) {
+	entries := e.history.entries
+	if index < 0 || index > len(entries) || index == e.historyIndex {
+		return
+	}
+
+	if e.historyIndex == len(entries) {
+		e.draft = e.line
+	}
+
+	e.historyIndex = index
+	if index == len(entries) {
+		e.line = e.draft
+	} else {
+		e.line = []rune(entries[index])
+	}
+	e.pos = len(e.line)
+}
+
+// search lets the user type a query and shows the newest history entry containing it.
+// Ctrl-R shows the next older entry. Enter accepts the entry as the line, Ctrl-G and Ctrl-C
+// restore the line, other keys put the entry into the line and are handled as usual.
+func (e *editor) search() (bool, error) {
+	var query []rune
+	index := len(e.history.entries)
+	failed := false
+
+	for {
+		match := ""
+		if index < len(e.history.entries) {
+			match = e.history.entries[index]
+		}
+
+		status := "reverse-i-search"
+		if failed {
+			status = "failed reverse-i-search"
+		}
+		fmt.Fprintf(e.out, "\r(%s)`%s': %s\x1b[K", status, string(query), match)
+
+		key, err := e.readKey()
+		if err != nil {
+			return false, err
+		}
+
+		found := -1
+		switch {
+		case key == keyCtrlR:
+			found = e.history.search(string(query), index-1)
+		case key == keyBackspace || key == keyCtrlH:
+			if len(query) > 0 {
+				query = query[:len(query)-1]
+			}
+			found = e.history.search(string(query), len(e.history.entries)-1)
+		case key == keyCtrlG || key == keyCtrlC:
+			e.refresh()
+			return false, nil
+		case key >= 0 && unicode.IsPrint(key):
+			query = append(query, key)
+			found = e.history.search(string(query), index)
+		default:
+			if match != "" {
+				e.showHistory(index)
+			}
+			e.refresh()
+			return e.handle(key)
+		}
+
+		failed = found < 0
+		if !failed {
+			index = found
+		}
+	}
+}
+
+// refresh redraws the line and puts the cursor at its position.
+func (e *editor) refresh() {
+	var out strings.Builder
+
+	out.WriteString("\r")
+	out.WriteString(e.prompt)
+	out.WriteString(string(e.line))
+	out.WriteString("\x1b[K")
+	if back := len(e.line) - e.pos; back > 0 {
+		fmt.Fprintf(&out, "\x1b[%dD", back)
+	}
+
+	io.WriteString(e.out, out.String())
+}
@@ -0,0 +1,99 @@
+package repl
+
+import (
+	"bytes"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+const (
+	up        = "\x1b[A"
+	down      = "\x1b[B"
+	right     = "\x1b[C"
+	left      = "\x1b[D"
+	home      = "\x1b[H"
+	end       = "\x1b[F"
+	deleteKey = "\x1b[3~"
+)
+
+func TestEditorReadLine(t *testing.T) {
+	entries := []string{"let a = 1;", "puts(a)", "let b = 2;"}
+
+	testData := map[string]struct {
+		input    string
+		expected string
+		err      error
+	}{
+		"text":                {"let x = 1;\r", "let x = 1;", nil},
+		"unicode":             {"\"zażółć\"\r", "\"zażółć\"", nil},
+		"backspace":           {"12\x7f3\r", "13", nil},
+		"left and insert":     {"13" + left + "2\r", "123", nil},
+		"home and end":        {"bc" + home + "a" + end + "d\r", "abcd", nil},
+		"ctrl-a and ctrl-e":   {"bc\x01a\x05d\r", "abcd", nil},
+		"delete":              {"abc" + home + deleteKey + "\r", "bc", nil},
+		"ctrl-d deletes":      {"abc" + home + "\x04\r", "bc", nil},
+		"kill to end":         {"abc" + left + left + "\x0b\r", "a", nil},
+		"kill to start":       {"abc" + left + "\x15\r", "c", nil},
+		"delete word":         {"let xy\x17z\r", "let z", nil},
+		"cursor stays inside": {"a" + left + left + right + right + "b\r", "ab", nil},
+		"ignored keys":        {"a\x1b[5~\tb\r", "ab", nil},
+		"previous entry":      {up + "\r", "let b = 2;", nil},
+		"older entry":         {up + up + up + up + "\r", "let a = 1;", nil},
+		"back to new line":    {"x" + up + up + down + down + "\r", "x", nil},
+		"edited entry":        {up + "\x7f\x7f3;\r", "let b = 3;", nil},
+		"search":              {"\x12let\r", "let b = 2;", nil},
+		"search older":        {"\x12let\x12\r", "let a = 1;", nil},
+		"search and edit":     {"\x12puts" + end + "\x7f\r", "puts(a", nil},
+		"failed search":       {"\x12xyz\r", "", nil},
+		"cancelled search":    {"x\x12let\x07\r", "x", nil},
+		"interrupt":           {"abc\x03", "", errInterrupted},
+		"end of input":        {"\x04", "", io.EOF},
+		"unfinished line":     {"abc", "abc", nil},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			var out bytes.Buffer
+			e := newEditor(strings.NewReader(data.input), &out, &history{entries: entries})
+
+			line, err := e.readLine(">> ")
+
+			assert.Equal(t, data.err, err)
+			assert.Equal(t, data.expected, line)
+			assert.True(t, strings.HasPrefix(out.String(), "\r>> "))
+		})
+	}
+}
+
+func TestEditorRedrawsLine(t *testing.T) {
+	var out bytes.Buffer
+	e := newEditor(strings.NewReader("ab"+left+"\r"), &out, &history{})
+
+	_, err := e.readLine(">> ")
+	assert.NoError(t, err)
+
+	expected := "\r>> \x1b[K" + "\r>> a\x1b[K" + "\r>> ab\x1b[K" + "\r>> ab\x1b[K\x1b[1D" + "\r\n"
+	assert.Equal(t, expected, out.String())
+}
+
+func TestEditorUsesRawMode(t *testing.T) {
+	var events []string
+
+	e := newEditor(strings.NewReader("a\r"), &bytes.Buffer{}, &history{})
+	e.rawMode = func() (func() error, error) {
+		events = append(events, "raw")
+		return func() error {
+			events = append(events, "restored")
+			return nil
+		}, nil
+	}
+
+	_, err := e.readLine(">> ")
+	assert.NoError(t, err)
+	assert.Equal(t, []string{"raw", "restored"}, events)
+}
@@ -0,0 +1,102 @@
+package repl
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+	"strings"
+)
+
+// historySize is the number of lines kept in the history.
+const historySize = 1000
+
+// history holds the lines entered in the REPL, the oldest first. If the history has a file,
+// the lines are read from it and appended to it, so that they are available in the next sessions.
+type history struct {
+	entries []string
+	path    string
+}
+
+// loadHistory reads the history from the file, which doesn't have to exist. The history isn't
+// saved if the path is empty.
+func loadHistory(path string) (*history, error) {
+	h := &history{path: path}
+	if path == "" {
+		return h, nil
+	}
+
+	file, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return h, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		if line := scanner.Text(); line != "" {
+			h.entries = append(h.entries, line)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	if len(h.entries) > historySize {
+		h.entries = h.entries[len(h.entries)-historySize:]
+		return h, h.rewrite()
+	}
+
+	return h, nil
+}
+
+// add appends the line to the history unless it is blank or the same as the last one.
+func (h *history) add(line string) error {
+	if strings.TrimSpace(line) == "" || (len(h.entries) > 0 && h.entries[len(h.entries)-1] == line) {
+		return nil
+	}
+
+	h.entries = append(h.entries, line)
+	if len(h.entries) > historySize {
+		h.entries = h.entries[1:]
+	}
+
+	if h.path == "" {
+		return nil
+	}
+
+	file, err := os.OpenFile(h.path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	if _, err := file.WriteString(line + "\n"); err != nil {
+		file.Close()
+		return err
+	}
+
+	return file.Close()
+}
+
+// rewrite replaces the content of the file with the entries.
+func (h *history) rewrite() error {
+	return ioutil.WriteFile(h.path, []byte(strings.Join(h.entries, "\n")+"\n"), 0600)
+}
+
+// search returns the index of the newest entry not newer than the one at the given index
+// which contains the query or -1 if there isn't any.
+func (h *history) search(query string, from int) int {
+	if from >= len(h.entries) {
+		from = len(h.entries) - 1
+	}
+
+	for i := from; i >= 0; i-- {
+		if strings.Contains(h.entries[i], query) {
+			return i
+		}
+	}
+
+	return -1
+}
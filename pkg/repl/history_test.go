@@ -0,0 +1,66 @@
+package repl
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func tempHistoryFile(t *testing.T) (string, func()) {
+	dir, err := ioutil.TempDir("", "monkey")
+	assert.NoError(t, err)
+
+	return filepath.Join(dir, "history"), func() { os.RemoveAll(dir) }
+}
+
+func TestHistoryIsSaved(t *testing.T) {
+	path, cleanup := tempHistoryFile(t)
+	defer cleanup()
+
+	h, err := loadHistory(path)
+	assert.NoError(t, err)
+	assert.Empty(t, h.entries)
+
+	for _, line := range []string{"let x = 1;", "", "  ", "x", "x", "x + 1"} {
+		assert.NoError(t, h.add(line))
+	}
+	assert.Equal(t, []string{"let x = 1;", "x", "x + 1"}, h.entries)
+
+	loaded, err := loadHistory(path)
+	assert.NoError(t, err)
+	assert.Equal(t, h.entries, loaded.entries)
+}
+
+func TestHistoryIsTrimmed(t *testing.T) {
+	path, cleanup := tempHistoryFile(t)
+	defer cleanup()
+
+	var lines []string
+	for i := 0; i < historySize+10; i++ {
+		lines = append(lines, fmt.Sprintf("%d", i))
+	}
+	assert.NoError(t, ioutil.WriteFile(path, []byte(strings.Join(lines, "\n")), 0600))
+
+	h, err := loadHistory(path)
+	assert.NoError(t, err)
+	assert.Equal(t, lines[10:], h.entries)
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, strings.Join(lines[10:], "\n")+"\n", string(content))
+}
+
+func TestHistorySearch(t *testing.T) {
+	h := &history{entries: []string{"let a = 1;", "puts(a)", "let b = 2;"}}
+
+	assert.Equal(t, 2, h.search("let", 2))
+	assert.Equal(t, 0, h.search("let", 1))
+	assert.Equal(t, 1, h.search("puts", 10))
+	assert.Equal(t, -1, h.search("let b", 1))
+	assert.Equal(t, -1, h.search("let", -1))
+}
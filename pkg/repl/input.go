@@ -0,0 +1,50 @@
+package repl
+
+import (
+	"strings"
+
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+// continuing are the tokens which can't end a statement, the statement continues on the next line.
+var continuing = map[token.TokenType]bool{
+	token.OperatorAssign: true, token.OperatorPlus: true, token.OperatorMinus: true, token.OperatorBang: true,
+	token.OperatorAsterisk: true, token.OperatorSlash: true, token.OperatorEqual: true, token.OperatorNotEqual: true,
+	token.OperatorLowerThan: true, token.OperatorGreaterThan: true, token.OperatorLowerEqual: true,
+	token.OperatorGreaterEqual: true, token.OperatorAnd: true, token.OperatorOr: true, token.OperatorPercent: true,
+	token.OperatorPower: true, token.OperatorPlusAssign: true, token.OperatorMinusAssign: true,
+	token.OperatorAsteriskAssign: true, token.OperatorSlashAssign: true,
+	token.DelimiterComma: true, token.DelimiterColon: true,
+	token.KeywordFunction: true, token.KeywordMacro: true, token.KeywordLet: true, token.KeywordIf: true,
+	token.KeywordElse: true, token.KeywordReturn: true, token.KeywordWhile: true, token.KeywordFor: true,
+	token.KeywordIn: true,
+}
+
+// incomplete reports whether the input ends in the middle of a statement, so that more lines
+// should be read before it is parsed: a bracket is not closed, a string or a comment is not
+// terminated or the last token is an operator or a keyword which must be followed by something.
+func incomplete(src string) bool {
+	unterminated := false
+
+	l := lexer.New(src)
+	l.SetErrorHandler(func(tok token.Token, pos token.Position, msg string) {
+		if strings.HasPrefix(msg, "unterminated") {
+			unterminated = true
+		}
+	})
+
+	depth := 0
+	var last token.Token
+	for tok := l.NextToken(); tok.Type != token.Eof; tok = l.NextToken() {
+		switch tok.Type {
+		case token.DelimiterLeftParenthesis, token.DelimiterLeftBracket, token.DelimiterLeftBrace:
+			depth++
+		case token.DelimiterRightParenthesis, token.DelimiterRightBracket, token.DelimiterRightBrace:
+			depth--
+		}
+		last = tok
+	}
+
+	return unterminated || depth > 0 || continuing[last.Type]
+}
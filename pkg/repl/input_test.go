@@ -0,0 +1,41 @@
+package repl
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIncomplete(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected bool
+	}{
+		"empty":                          {"", false},
+		"statement":                      {"let x = 1;", false},
+		"expression":                     {"x + 1", false},
+		"open brace":                     {"let f = fn(x) {", true},
+		"open parenthesis":               {"add(1,", true},
+		"open bracket":                   {"[1, 2", true},
+		"nested":                         {"fn() { if (x) { [1, 2] }", true},
+		"closed":                         {"fn() {\n  1\n}", false},
+		"too many closed":                {"x)", false},
+		"infix operator":                 {"1 +", true},
+		"assignment":                     {"let x =", true},
+		"let":                            {"let", true},
+		"else":                           {"if (x) { 1 } else", true},
+		"comment after operator":         {"1 + // more\n", true},
+		"unterminated string":            {`"abc`, true},
+		"unterminated raw":               {"`abc\n", true},
+		"unterminated comment":           {"1 /* comment", true},
+		"closing delimiters in a string": {`"}"`, false},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, data.expected, incomplete(data.input))
+		})
+	}
+}
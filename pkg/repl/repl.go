@@ -2,46 +2,221 @@ package repl
 
 import (
 	"bufio"
+	"context"
 	"fmt"
 	"io"
+	"os"
+	"strings"
 
-	"github.com/adrian83/monkey/pkg/evaluator"
+	"github.com/adrian83/monkey/pkg/debugger"
 	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/monkey"
 	"github.com/adrian83/monkey/pkg/object"
 	"github.com/adrian83/monkey/pkg/parser"
 )
 
 const (
-	prompt    = ">>"
-	lineBreak = "\n"
+	prompt             = ">>"
+	continuationPrompt = ".."
+	lineBreak          = "\n"
 )
 
-func Start(in io.Reader, out io.Writer) {
-	scanner := bufio.NewScanner(in)
-	env := object.NewEnvironment()
+// lineReader reads the input line by line, showing the prompt before each line.
+type lineReader interface {
+	readLine(prompt string) (string, error)
+}
+
+// plainReader reads lines without editing, it is used when the input is not a terminal.
+type plainReader struct {
+	in  *bufio.Reader
+	out io.Writer
+}
+
+func (r *plainReader) readLine(prompt string) (string, error) {
+	io.WriteString(r.out, prompt)
+
+	line, err := r.in.ReadString('\n')
+	if err == io.EOF && line != "" {
+		err = nil
+	}
+
+	return strings.TrimRight(line, "\r\n"), err
+}
+
+// Option configures the REPL.
+type Option func(*session)
+
+// WithHistoryFile makes the REPL read the history from the file and append the entered lines to it.
+func WithHistoryFile(path string) Option {
+	return func(s *session) {
+		s.historyFile = path
+	}
+}
+
+type session struct {
+	out         io.Writer
+	engine      monkey.Engine
+	interpreter *monkey.Interpreter
+	inputs      []string // inputs evaluated without errors
+	lines       lineReader
+	history     *history
+	historyFile string
+
+	debugger *debugger.Debugger
+	stop     *debugger.Stop // the program stopped by the debugger
+	frame    int            // the frame of the stopped program selected by the user
+}
+
+// Start reads the input, evaluates it and prints the results until the input ends. Input which
+// ends in the middle of a statement is continued on the next line. If the input is a terminal,
+// lines can be edited and recalled from the history. Input starting with a colon is a command
+// of the REPL, ":help" lists the commands. When the evaluation stops at a breakpoint, the input
+// controls the debugger until the program continues.
+func Start(in io.Reader, out io.Writer, engine monkey.Engine, options ...Option) error {
+	s := newSession(out, engine)
+	for _, option := range options {
+		option(s)
+	}
+
+	if err := s.newInterpreter(); err != nil {
+		return err
+	}
+
+	var err error
+	s.history, err = loadHistory(s.historyFile)
+	if err != nil {
+		return err
+	}
+
+	s.lines = &plainReader{in: bufio.NewReader(in), out: out}
+	if file, ok := in.(*os.File); ok && isTerminal(file.Fd()) {
+		editor := newEditor(in, out, s.history)
+		editor.completer = s
+		editor.rawMode = func() (func() error, error) {
+			return makeRaw(file.Fd())
+		}
+		s.lines = editor
+	}
 
 	for {
-		fmt.Printf(prompt)
-		scanned := scanner.Scan()
-		if !scanned {
-			return
+		src, err := s.readInput()
+		switch {
+		case err == io.EOF:
+			return nil
+		case err == errInterrupted:
+			continue
+		case err != nil:
+			return err
+		}
+
+		switch {
+		case isCommand(src):
+			s.command(src)
+		case strings.TrimSpace(src) != "":
+			s.print(s.evaluate("", src))
 		}
+	}
+}
+
+func newSession(out io.Writer, engine monkey.Engine) *session {
+	s := &session{out: out, engine: engine}
+	s.debugger = debugger.New(s.stopped)
+
+	return s
+}
+
+// newInterpreter replaces the interpreter, so that the bindings and the inputs are forgotten.
+func (s *session) newInterpreter() error {
+	options := []monkey.Option{monkey.WithEngine(s.engine)}
+	if s.engine == monkey.EngineEval {
+		options = append(options, monkey.WithHook(s.debugger.Hook))
+	}
+
+	interpreter, err := monkey.New(options...)
+	if err != nil {
+		return err
+	}
+
+	s.debugger.SetBuiltins(interpreter.Registry())
+
+	s.interpreter, s.inputs = interpreter, nil
+	return nil
+}
 
-		line := scanner.Text()
+// readInput reads lines until they form complete statements.
+func (s *session) readInput() (string, error) {
+	var lines []string
 
-		l := lexer.New(line)
-		p := parser.New(l)
+	for {
+		linePrompt := prompt
+		if len(lines) > 0 {
+			linePrompt = continuationPrompt
+		}
 
-		program, err := p.ParseProgram()
+		line, err := s.lines.readLine(linePrompt)
+		if err == io.EOF && len(lines) > 0 {
+			// the incomplete input is evaluated to show what is missing
+			return strings.Join(lines, lineBreak), nil
+		}
 		if err != nil {
-			io.WriteString(out, err.Error())
-			continue
+			return "", err
 		}
 
-		evaluated := evaluator.Eval(program, env)
-		if evaluated != nil {
-			io.WriteString(out, evaluated.Inspect())
-			io.WriteString(out, lineBreak)
+		s.remember(line)
+
+		lines = append(lines, line)
+		src := strings.Join(lines, lineBreak)
+		if isCommand(src) || !incomplete(src) {
+			return src, nil
 		}
 	}
 }
+
+// remember adds the line to the history, saving of the history stops after the first failure.
+func (s *session) remember(line string) {
+	if err := s.history.add(line); err != nil {
+		fmt.Fprintf(s.out, "cannot save history: %v%s", err, lineBreak)
+		s.history.path = ""
+	}
+}
+
+// evaluate parses and evaluates the source, the source is kept if there are no errors.
+// Parse errors are printed and nil is returned.
+func (s *session) evaluate(filename, src string) object.Object {
+	return s.debug(filename, src, debugger.Continue)
+}
+
+// debug evaluates the source like evaluate does, the debugger starts with the action.
+func (s *session) debug(filename, src string, action debugger.Action) object.Object {
+	program, err := parser.New(lexer.NewFile(filename, src)).ParseProgram()
+	if err != nil {
+		parser.Render(s.out, src, err)
+		return nil
+	}
+
+	s.debugger.Start(action)
+	evaluated := s.interpreter.EvalProgram(context.Background(), program)
+	if !isError(evaluated) {
+		s.inputs = append(s.inputs, src)
+	}
+
+	return evaluated
+}
+
+// print prints the result of the evaluation, errors are followed by their stack traces.
+func (s *session) print(evaluated object.Object) {
+	if evaluated != nil {
+		io.WriteString(s.out, evaluated.Inspect())
+		io.WriteString(s.out, lineBreak)
+	}
+
+	if errObj, ok := evaluated.(*object.Error); ok && len(errObj.Trace) > 0 {
+		io.WriteString(s.out, lineBreak)
+		io.WriteString(s.out, errObj.StackTrace())
+	}
+}
+
+func isError(obj object.Object) bool {
+	_, ok := obj.(*object.Error)
+	return ok
+}
@@ -0,0 +1,61 @@
+package repl
+
+import (
+	"bytes"
+	"io/ioutil"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/monkey"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStart(t *testing.T) {
+	testData := map[string]struct {
+		input    string
+		expected string
+	}{
+		"expression":          {"1 + 2\n", ">>3\n>>"},
+		"bindings are kept":   {"let x = 2;\nx * 3\n", ">>>>6\n>>"},
+		"multi-line function": {"let add = fn(a, b) {\n  a + b\n};\nadd(1, 2)\n", ">>....>>3\n>>"},
+		"trailing operator":   {"1 +\n2\n", ">>..3\n>>"},
+		"blank lines":         {"\n\n1\n", ">>>>>>1\n>>"},
+		"windows line ends":   {"[1,\r\n2]\r\n", ">>..[1, 2]\n>>"},
+		"no final line end":   {"5", ">>5\n"},
+		"incomplete at end":   {"[1,\n", ">>..1:4: error:"},
+		"runtime error":       {"1 / 0\n", ">>ERROR: 1:3: division by zero\n\nmain()\n\t1:3\n>>"},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			for _, engine := range []monkey.Engine{monkey.EngineEval, monkey.EngineVM} {
+				var out bytes.Buffer
+
+				err := Start(strings.NewReader(data.input), &out, engine)
+
+				assert.NoError(t, err)
+				assert.Contains(t, out.String(), data.expected, "engine %s", engine)
+			}
+		})
+	}
+}
+
+func TestStartSavesHistory(t *testing.T) {
+	path, cleanup := tempHistoryFile(t)
+	defer cleanup()
+
+	err := Start(strings.NewReader("let f = fn(x) {\n  x\n};\n\nf(1)\n"), &bytes.Buffer{}, monkey.EngineEval, WithHistoryFile(path))
+	assert.NoError(t, err)
+
+	content, err := ioutil.ReadFile(path)
+	assert.NoError(t, err)
+	assert.Equal(t, "let f = fn(x) {\n  x\n};\nf(1)\n", string(content))
+}
+
+func TestStartUnknownEngine(t *testing.T) {
+	err := Start(strings.NewReader(""), &bytes.Buffer{}, monkey.Engine("jit"))
+	assert.Error(t, err)
+}
@@ -0,0 +1,15 @@
+//go:build !darwin && !dragonfly && !freebsd && !linux && !netbsd && !openbsd
+// +build !darwin,!dragonfly,!freebsd,!linux,!netbsd,!openbsd
+
+package repl
+
+import "errors"
+
+func makeRaw(fd uintptr) (func() error, error) {
+	return nil, errors.New("raw terminal mode is not supported")
+}
+
+// isTerminal reports false, lines are read without editing.
+func isTerminal(fd uintptr) bool {
+	return false
+}
@@ -1,27 +1,47 @@
 package token
 
+import (
+	"fmt"
+	"sort"
+	"unicode"
+	"unicode/utf8"
+)
+
 type Operator string
 
 const (
 	Illegal = "ILLEGAL"
 	Eof     = "EOF"
+	Comment = "COMMENT" // returned only if the lexer scans comments
 
 	// Identifiers + literals
 	Ident       = "IDENT" // add, foobar, x, y, ...
 	TypeInteger = "INT"   // 1343456
+	TypeFloat   = "FLOAT" // 3.14, 1e-9
 	TypeString  = "STRING"
 
 	// Operators
-	OperatorAssign      = "="
-	OperatorPlus        = "+"
-	OperatorMinus       = "-"
-	OperatorBang        = "!"
-	OperatorAsterisk    = "*"
-	OperatorSlash       = "/"
-	OperatorEqual       = "=="
-	OperatorNotEqual    = "!="
-	OperatorLowerThan   = "<"
-	OperatorGreaterThan = ">"
+	OperatorAssign       = "="
+	OperatorPlus         = "+"
+	OperatorMinus        = "-"
+	OperatorBang         = "!"
+	OperatorAsterisk     = "*"
+	OperatorSlash        = "/"
+	OperatorEqual        = "=="
+	OperatorNotEqual     = "!="
+	OperatorLowerThan    = "<"
+	OperatorGreaterThan  = ">"
+	OperatorLowerEqual   = "<="
+	OperatorGreaterEqual = ">="
+	OperatorAnd          = "&&"
+	OperatorOr           = "||"
+	OperatorPercent      = "%"
+	OperatorPower        = "**"
+
+	OperatorPlusAssign     = "+="
+	OperatorMinusAssign    = "-="
+	OperatorAsteriskAssign = "*="
+	OperatorSlashAssign    = "/="
 
 	// Delimiters
 	DelimiterComma            = ","
@@ -42,6 +62,12 @@ const (
 	KeywordIf       = "IF"
 	KeywordElse     = "ELSE"
 	KeywordReturn   = "RETURN"
+	KeywordWhile    = "WHILE"
+	KeywordFor      = "FOR"
+	KeywordIn       = "IN"
+	KeywordBreak    = "BREAK"
+	KeywordContinue = "CONTINUE"
+	KeywordMacro    = "MACRO"
 
 	codeKeywordFunction = "fn"
 	codeKeywordLet      = "let"
@@ -50,13 +76,50 @@ const (
 	codeKeywordIf       = "if"
 	codeKeywordElse     = "else"
 	codeKeywordReturn   = "return"
+	codeKeywordWhile    = "while"
+	codeKeywordFor      = "for"
+	codeKeywordIn       = "in"
+	codeKeywordBreak    = "break"
+	codeKeywordContinue = "continue"
+	codeKeywordMacro    = "macro"
 )
 
 type TokenType string
 
+// Position describes a location in the source code. Line and Column start at 1,
+// Offset is the number of bytes from the beginning of the input.
+type Position struct {
+	Filename string `json:"filename,omitempty"`
+	Offset   int    `json:"offset"`
+	Line     int    `json:"line"`
+	Column   int    `json:"column"`
+}
+
+// IsValid reports whether the position is set.
+func (p Position) IsValid() bool {
+	return p.Line > 0
+}
+
+func (p Position) String() string {
+	if !p.IsValid() {
+		if p.Filename != "" {
+			return p.Filename
+		}
+		return "-"
+	}
+
+	if p.Filename != "" {
+		return fmt.Sprintf("%s:%d:%d", p.Filename, p.Line, p.Column)
+	}
+
+	return fmt.Sprintf("%d:%d", p.Line, p.Column)
+}
+
 type Token struct {
-	Type    TokenType
-	Literal string
+	Type    TokenType `json:"type"`
+	Literal string    `json:"literal"`
+	Pos     Position  `json:"pos"` // position of the first character of the token
+	End     Position  `json:"end"` // position immediately after the token
 }
 
 var keywords = map[string]TokenType{
@@ -67,6 +130,44 @@ var keywords = map[string]TokenType{
 	codeKeywordIf:       KeywordIf,
 	codeKeywordElse:     KeywordElse,
 	codeKeywordReturn:   KeywordReturn,
+	codeKeywordWhile:    KeywordWhile,
+	codeKeywordFor:      KeywordFor,
+	codeKeywordIn:       KeywordIn,
+	codeKeywordBreak:    KeywordBreak,
+	codeKeywordContinue: KeywordContinue,
+	codeKeywordMacro:    KeywordMacro,
+}
+
+// Keywords returns the keywords as they are written in the code, sorted.
+func Keywords() []string {
+	words := make([]string, 0, len(keywords))
+	for word := range keywords {
+		words = append(words, word)
+	}
+	sort.Strings(words)
+
+	return words
+}
+
+// IsLetter reports whether the character may appear in identifiers.
+func IsLetter(ch rune) bool {
+	if ch < utf8.RuneSelf {
+		return 'a' <= ch && ch <= 'z' || 'A' <= ch && ch <= 'Z' || ch == '_'
+	}
+
+	return unicode.IsLetter(ch)
+}
+
+// IsIdentifier reports whether the lexer reads the name as an identifier, that is whether it consists
+// of letters and isn't a keyword.
+func IsIdentifier(name string) bool {
+	for _, ch := range name {
+		if !IsLetter(ch) {
+			return false
+		}
+	}
+
+	return name != "" && LookupIdent(name) == Ident
 }
 
 func LookupIdent(ident string) TokenType {
@@ -0,0 +1,679 @@
+package vm
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/adrian83/monkey/pkg/code"
+	"github.com/adrian83/monkey/pkg/compiler"
+	"github.com/adrian83/monkey/pkg/evaluator"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/token"
+)
+
+const (
+	// StackSize leaves 16 values for each of MaxFrames frames, so that calls usually reach
+	// the maximum depth before they exhaust the stack.
+	StackSize   = 16 * MaxFrames
+	GlobalsSize = 65536
+
+	// MaxFrames is the maximum number of frames, including the one of the main function, it
+	// allows calls to nest as deep as the evaluator allows by default.
+	MaxFrames = evaluator.DefaultMaxDepth + 1
+
+	// cancellationCheckInterval is the number of executed instructions between checks of the context.
+	cancellationCheckInterval = 1024
+)
+
+// Options restricts resources used by a run, zero values mean no limit except for MaxDepth
+// which is evaluator.DefaultMaxDepth then, though calls never nest deeper than MaxFrames allows.
+type Options struct {
+	MaxDepth  int // maximum number of nested function calls
+	MaxSteps  int // maximum number of executed instructions
+	MaxAllocs int // maximum number of created objects
+
+	Builtins *object.Registry // registry the bytecode was compiled with, the standard one if nil
+}
+
+// builtins are used when Options don't provide a registry.
+var builtins = object.NewRegistry()
+
+var binaryOperators = map[code.Opcode]string{
+	code.OpAdd:          token.OperatorPlus,
+	code.OpSub:          token.OperatorMinus,
+	code.OpMul:          token.OperatorAsterisk,
+	code.OpDiv:          token.OperatorSlash,
+	code.OpEqual:        token.OperatorEqual,
+	code.OpNotEqual:     token.OperatorNotEqual,
+	code.OpGreaterThan:  token.OperatorGreaterThan,
+	code.OpLowerThan:    token.OperatorLowerThan,
+	code.OpMod:          token.OperatorPercent,
+	code.OpPow:          token.OperatorPower,
+	code.OpGreaterEqual: token.OperatorGreaterEqual,
+	code.OpLowerEqual:   token.OperatorLowerEqual,
+}
+
+var unaryOperators = map[code.Opcode]string{
+	code.OpBang:  token.OperatorBang,
+	code.OpMinus: token.OperatorMinus,
+}
+
+type VM struct {
+	constants   []object.Object
+	globals     []object.Object
+	globalNames []string
+
+	stack []object.Object
+	sp    int // always points to the next free slot, top of the stack is stack[sp-1]
+
+	frames      []*Frame
+	framesIndex int
+
+	lastPopped object.Object
+
+	ctx    context.Context
+	opts   Options
+	steps  int
+	allocs int
+}
+
+func New(bytecode *compiler.Bytecode) *VM {
+	mainFn := &object.CompiledFunction{Instructions: bytecode.Instructions, Positions: bytecode.Positions}
+	mainClosure := &object.Closure{Fn: mainFn}
+
+	frames := make([]*Frame, MaxFrames)
+	frames[0] = NewFrame(mainClosure, 0)
+
+	return &VM{
+		constants:   bytecode.Constants,
+		globals:     make([]object.Object, GlobalsSize),
+		globalNames: bytecode.GlobalNames,
+
+		stack: make([]object.Object, StackSize),
+		sp:    0,
+
+		frames:      frames,
+		framesIndex: 1,
+	}
+}
+
+// NewWithGlobals creates a vm which shares global bindings with previous runs.
+func NewWithGlobals(bytecode *compiler.Bytecode, globals []object.Object) *VM {
+	vm := New(bytecode)
+	vm.globals = globals
+	return vm
+}
+
+func NewGlobals() []object.Object {
+	return make([]object.Object, GlobalsSize)
+}
+
+// Run executes the bytecode and returns the value of the last evaluated expression
+// statement, value of a top level return statement or an *object.Error.
+func (vm *VM) Run() object.Object {
+	return vm.RunContext(context.Background(), Options{})
+}
+
+// RunContext executes the bytecode until it's done, the context is cancelled or one of
+// the limits is reached. In the last two cases an error of kind ErrorCancelled,
+// ErrorDepthExceeded or ErrorBudgetExhausted is returned.
+func (vm *VM) RunContext(ctx context.Context, opts Options) object.Object {
+	if ctx == nil {
+		ctx = context.Background()
+	}
+
+	if opts.Builtins == nil {
+		opts.Builtins = builtins
+	}
+	if opts.MaxDepth == 0 {
+		opts.MaxDepth = evaluator.DefaultMaxDepth
+	}
+
+	vm.ctx = ctx
+	vm.opts = opts
+
+	for vm.currentFrame().ip < len(vm.currentFrame().Instructions())-1 {
+		vm.currentFrame().ip++
+
+		ip := vm.currentFrame().ip
+		ins := vm.currentFrame().Instructions()
+		op := code.Opcode(ins[ip])
+
+		if err := vm.step(); err != nil {
+			return vm.fail(err, ip)
+		}
+
+		var err *object.Error
+		switch op {
+		case code.OpConstant:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.constants[constIndex])
+
+		case code.OpPop:
+			vm.lastPopped = vm.pop()
+
+		case code.OpTrue:
+			err = vm.push(object.TrueValue)
+
+		case code.OpFalse:
+			err = vm.push(object.FalseValue)
+
+		case code.OpNull:
+			err = vm.push(object.NullValue)
+
+		case code.OpAdd, code.OpSub, code.OpMul, code.OpDiv,
+			code.OpEqual, code.OpNotEqual, code.OpGreaterThan, code.OpLowerThan,
+			code.OpMod, code.OpPow, code.OpGreaterEqual, code.OpLowerEqual:
+			right := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.ApplyInfix(binaryOperators[op], left, right))
+
+		case code.OpBang, code.OpMinus:
+			err = vm.pushResult(evaluator.ApplyPrefix(unaryOperators[op], vm.pop()))
+
+		case code.OpJump:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip = pos - 1
+
+		case code.OpJumpNotTruthy:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if !evaluator.IsTruthy(vm.pop()) {
+				vm.currentFrame().ip = pos - 1
+			}
+
+		case code.OpJumpTruthyOrPop, code.OpJumpNotTruthyOrPop:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			if evaluator.IsTruthy(vm.stack[vm.sp-1]) == (op == code.OpJumpTruthyOrPop) {
+				vm.currentFrame().ip = pos - 1
+			} else {
+				vm.pop()
+			}
+
+		case code.OpSetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			vm.globals[globalIndex] = vm.pop()
+
+		case code.OpGetGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.pushGlobal(int(globalIndex))
+
+		case code.OpAssignGlobal:
+			globalIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.assignGlobal(int(globalIndex), vm.pop())
+
+		case code.OpSetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			vm.setLocal(int(localIndex), vm.pop())
+
+		case code.OpGetLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			err = vm.pushLocal(int(localIndex))
+
+		case code.OpGetBuiltin:
+			builtinIndex := code.ReadUint16(ins[ip+1:])
+			vm.currentFrame().ip += 2
+			err = vm.push(vm.opts.Builtins.Get(int(builtinIndex)))
+
+		case code.OpGetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			err = vm.pushFree(int(freeIndex))
+
+		case code.OpSetFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			vm.setFree(int(freeIndex), vm.pop())
+
+		case code.OpCaptureLocal:
+			localIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			err = vm.push(vm.captureLocal(int(localIndex)))
+
+		case code.OpCaptureFree:
+			freeIndex := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			err = vm.push(vm.currentFrame().cl.Free[freeIndex])
+
+		case code.OpCurrentClosure:
+			err = vm.push(vm.currentFrame().cl)
+
+		case code.OpArray:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			array := vm.buildArray(vm.sp-numElements, vm.sp)
+			vm.sp -= numElements
+			err = vm.pushResult(array)
+
+		case code.OpHash:
+			numElements := int(code.ReadUint16(ins[ip+1:]))
+			vm.currentFrame().ip += 2
+
+			hash := vm.buildHash(vm.sp-numElements, vm.sp)
+			vm.sp -= numElements
+			err = vm.pushResult(hash)
+
+		case code.OpIndex:
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.ApplyIndex(left, index))
+
+		case code.OpSetIndex:
+			operator := binaryOperators[code.Opcode(code.ReadUint8(ins[ip+1:]))]
+			vm.currentFrame().ip++
+
+			value := vm.pop()
+			index := vm.pop()
+			left := vm.pop()
+			err = vm.pushResult(evaluator.ApplyIndexAssign(operator, left, index, value))
+
+		case code.OpCall:
+			numArgs := code.ReadUint8(ins[ip+1:])
+			vm.currentFrame().ip++
+			err = vm.executeCall(int(numArgs))
+
+		case code.OpReturnValue:
+			returnValue := vm.pop()
+			if vm.framesIndex == 1 {
+				return returnValue
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(returnValue)
+
+		case code.OpReturn:
+			if vm.framesIndex == 1 {
+				return object.NullValue
+			}
+
+			frame := vm.popFrame()
+			vm.sp = frame.basePointer - 1
+			err = vm.push(object.NullValue)
+
+		case code.OpClosure:
+			constIndex := code.ReadUint16(ins[ip+1:])
+			numFree := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			err = vm.pushClosure(int(constIndex), int(numFree))
+
+		case code.OpIter:
+			iterator, iterErr := object.NewIterator(vm.pop())
+			if iterErr != nil {
+				err = object.NewError("%v", iterErr)
+			} else {
+				err = vm.push(iterator)
+			}
+
+		case code.OpIterNext:
+			pos := int(code.ReadUint16(ins[ip+1:]))
+			numValues := code.ReadUint8(ins[ip+3:])
+			vm.currentFrame().ip += 3
+			err = vm.iterNext(pos, int(numValues))
+
+		default:
+			def, lookupErr := code.Lookup(byte(op))
+			if lookupErr != nil {
+				return object.NewError(lookupErr.Error())
+			}
+			return object.NewError("unsupported opcode: %s", def.Name)
+		}
+
+		if err != nil {
+			return vm.fail(err, ip)
+		}
+	}
+
+	return vm.lastPopped
+}
+
+// fail sets position and stack trace of the error which terminates the execution.
+func (vm *VM) fail(err *object.Error, ip int) *object.Error {
+	if !err.Pos.IsValid() {
+		err.Pos = position(vm.currentFrame().cl.Fn, ip)
+		err.Trace = vm.stackTrace(err.Pos)
+	}
+
+	return err
+}
+
+// iterNext pushes the next element (see object.Iterator.NextValue), or the next key and value
+// if numValues is 2, of the iterator on top of the stack. It jumps to the given position
+// if the iterator is exhausted.
+func (vm *VM) iterNext(pos, numValues int) *object.Error {
+	iterator := vm.stack[vm.sp-1].(*object.Iterator)
+
+	if numValues == 1 {
+		value, ok := iterator.NextValue()
+		if !ok {
+			vm.currentFrame().ip = pos - 1
+			return nil
+		}
+
+		return vm.push(value)
+	}
+
+	key, value, ok := iterator.Next()
+	if !ok {
+		vm.currentFrame().ip = pos - 1
+		return nil
+	}
+
+	if err := vm.push(key); err != nil {
+		return err
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) LastPoppedStackElem() object.Object {
+	return vm.lastPopped
+}
+
+// step counts executed instructions and checks whether the execution may continue.
+func (vm *VM) step() *object.Error {
+	vm.steps++
+
+	switch {
+	case vm.opts.MaxSteps > 0 && vm.steps > vm.opts.MaxSteps:
+		return &object.Error{Kind: object.ErrorBudgetExhausted, Message: fmt.Sprintf("step budget of %d exhausted", vm.opts.MaxSteps)}
+	case vm.steps%cancellationCheckInterval == 0 && vm.ctx.Err() != nil:
+		return &object.Error{Kind: object.ErrorCancelled, Message: fmt.Sprintf("evaluation cancelled: %v", vm.ctx.Err())}
+	default:
+		return nil
+	}
+}
+
+// allocate counts objects created by the execution against the allocation budget.
+func (vm *VM) allocate() *object.Error {
+	vm.allocs++
+	if vm.opts.MaxAllocs > 0 && vm.allocs > vm.opts.MaxAllocs {
+		return &object.Error{Kind: object.ErrorBudgetExhausted, Message: fmt.Sprintf("allocation budget of %d exhausted", vm.opts.MaxAllocs)}
+	}
+
+	return nil
+}
+
+// stackTrace returns the current call stack, the innermost call first.
+func (vm *VM) stackTrace(pos token.Position) []object.StackFrame {
+	trace := make([]object.StackFrame, 0, vm.framesIndex)
+
+	for i := vm.framesIndex - 1; i > 0; i-- {
+		trace = append(trace, object.StackFrame{Function: vm.frames[i].cl.Fn.Name, Pos: pos})
+
+		// ip of the caller points to the operand of its OpCall instruction
+		caller := vm.frames[i-1]
+		pos = position(caller.cl.Fn, caller.ip-1)
+	}
+
+	return append(trace, object.StackFrame{Function: object.MainFrame, Pos: pos})
+}
+
+// position returns the source position of the instruction, only instructions which may fail
+// have one, for others the position of the nearest preceding instruction which has it is used.
+func position(fn *object.CompiledFunction, ip int) token.Position {
+	for ; ip >= 0; ip-- {
+		if pos, ok := fn.Positions[ip]; ok {
+			return pos
+		}
+	}
+
+	return token.Position{}
+}
+
+func (vm *VM) currentFrame() *Frame {
+	return vm.frames[vm.framesIndex-1]
+}
+
+func (vm *VM) pushFrame(f *Frame) *object.Error {
+	if vm.framesIndex >= MaxFrames {
+		return depthExceeded(MaxFrames - 1)
+	}
+
+	vm.frames[vm.framesIndex] = f
+	vm.framesIndex++
+	return nil
+}
+
+func depthExceeded(maxDepth int) *object.Error {
+	return &object.Error{Kind: object.ErrorDepthExceeded, Message: fmt.Sprintf("maximum call depth of %d exceeded", maxDepth)}
+}
+
+func (vm *VM) popFrame() *Frame {
+	vm.framesIndex--
+	return vm.frames[vm.framesIndex]
+}
+
+func (vm *VM) push(o object.Object) *object.Error {
+	if vm.sp >= StackSize {
+		return object.NewError("stack overflow")
+	}
+
+	vm.stack[vm.sp] = o
+	vm.sp++
+
+	return nil
+}
+
+// pushResult pushes the result of an operation unless it's an error which terminates the execution.
+func (vm *VM) pushResult(o object.Object) *object.Error {
+	switch o := o.(type) {
+	case *object.Error:
+		return o
+	case *object.Boolean, *object.Null:
+	default:
+		if err := vm.allocate(); err != nil {
+			return err
+		}
+	}
+
+	return vm.push(o)
+}
+
+func (vm *VM) pop() object.Object {
+	o := vm.stack[vm.sp-1]
+	vm.sp--
+	return o
+}
+
+func (vm *VM) pushGlobal(index int) *object.Error {
+	value := vm.globals[index]
+	if value == nil {
+		name := ""
+		if index < len(vm.globalNames) {
+			name = vm.globalNames[index]
+		}
+		return object.NewError("identifier not found: " + name)
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) assignGlobal(index int, value object.Object) *object.Error {
+	if vm.globals[index] == nil {
+		name := ""
+		if index < len(vm.globalNames) {
+			name = vm.globalNames[index]
+		}
+		return object.NewError("identifier not found: " + name)
+	}
+
+	vm.globals[index] = value
+	return nil
+}
+
+// Locals and free variables captured by closures are kept in cells shared by
+// the function which defines them and all the closures.
+
+func (vm *VM) pushLocal(index int) *object.Error {
+	frame := vm.currentFrame()
+
+	value := deref(vm.stack[frame.basePointer+index])
+	if value == nil {
+		return object.NewError("identifier not found: " + frame.cl.Fn.LocalNames[index])
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) setLocal(index int, value object.Object) {
+	slot := &vm.stack[vm.currentFrame().basePointer+index]
+
+	if cell, ok := (*slot).(*object.Cell); ok {
+		cell.Value = value
+		return
+	}
+
+	*slot = value
+}
+
+func (vm *VM) captureLocal(index int) *object.Cell {
+	slot := &vm.stack[vm.currentFrame().basePointer+index]
+
+	cell, ok := (*slot).(*object.Cell)
+	if !ok {
+		cell = &object.Cell{Value: *slot}
+		*slot = cell
+	}
+
+	return cell
+}
+
+func (vm *VM) pushFree(index int) *object.Error {
+	fn := vm.currentFrame().cl
+
+	value := deref(fn.Free[index])
+	if value == nil {
+		name := ""
+		if index < len(fn.Fn.FreeNames) {
+			name = fn.Fn.FreeNames[index]
+		}
+		return object.NewError("identifier not found: " + name)
+	}
+
+	return vm.push(value)
+}
+
+func (vm *VM) setFree(index int, value object.Object) {
+	free := vm.currentFrame().cl.Free
+
+	if cell, ok := free[index].(*object.Cell); ok {
+		cell.Value = value
+		return
+	}
+
+	free[index] = value
+}
+
+func deref(value object.Object) object.Object {
+	if cell, ok := value.(*object.Cell); ok {
+		return cell.Value
+	}
+
+	return value
+}
+
+func (vm *VM) buildArray(startIndex, endIndex int) object.Object {
+	elements := make([]object.Object, endIndex-startIndex)
+	copy(elements, vm.stack[startIndex:endIndex])
+
+	return &object.Array{Elements: elements}
+}
+
+func (vm *VM) buildHash(startIndex, endIndex int) object.Object {
+	hashedPairs := make(map[object.HashKey]object.HashPair)
+
+	for i := startIndex; i < endIndex; i += 2 {
+		key := vm.stack[i]
+		value := vm.stack[i+1]
+
+		hashKey, ok := key.(object.Hashable)
+		if !ok {
+			return object.NewError("unusable as hash key: %s", key.Type())
+		}
+
+		hashedPairs[hashKey.HashKey()] = object.HashPair{Key: key, Value: value}
+	}
+
+	return &object.Hash{Pairs: hashedPairs}
+}
+
+func (vm *VM) executeCall(numArgs int) *object.Error {
+	callee := vm.stack[vm.sp-1-numArgs]
+
+	switch callee := callee.(type) {
+	case *object.Closure:
+		return vm.callClosure(callee, numArgs)
+	case *object.Builtin:
+		return vm.callBuiltin(callee, numArgs)
+	default:
+		return object.NewError("not a function: %s", callee.Type())
+	}
+}
+
+func (vm *VM) callClosure(cl *object.Closure, numArgs int) *object.Error {
+	if numArgs != cl.Fn.NumParameters {
+		return object.NewError("wrong number of arguments: want=%d, got=%d", cl.Fn.NumParameters, numArgs)
+	}
+
+	basePointer := vm.sp - numArgs
+	if basePointer+cl.Fn.NumLocals >= StackSize {
+		return object.NewError("stack overflow")
+	}
+
+	if vm.framesIndex > vm.opts.MaxDepth {
+		return depthExceeded(vm.opts.MaxDepth)
+	}
+
+	if err := vm.allocate(); err != nil {
+		return err
+	}
+
+	if err := vm.pushFrame(NewFrame(cl, basePointer)); err != nil {
+		return err
+	}
+
+	// clear slots of the locals which are not parameters, they may hold values of previous calls
+	for i := basePointer + numArgs; i < basePointer+cl.Fn.NumLocals; i++ {
+		vm.stack[i] = nil
+	}
+
+	vm.sp = basePointer + cl.Fn.NumLocals
+	return nil
+}
+
+func (vm *VM) callBuiltin(builtin *object.Builtin, numArgs int) *object.Error {
+	args := vm.stack[vm.sp-numArgs : vm.sp]
+
+	result := builtin.Fn(args...)
+	vm.sp = vm.sp - numArgs - 1
+
+	if result == nil {
+		result = object.NullValue
+	}
+
+	return vm.pushResult(result)
+}
+
+func (vm *VM) pushClosure(constIndex, numFree int) *object.Error {
+	function, ok := vm.constants[constIndex].(*object.CompiledFunction)
+	if !ok {
+		return object.NewError("not a function: %s", vm.constants[constIndex].Type())
+	}
+
+	free := make([]object.Object, numFree)
+	copy(free, vm.stack[vm.sp-numFree:vm.sp])
+	vm.sp -= numFree
+
+	return vm.pushResult(&object.Closure{Fn: function, Free: free})
+}
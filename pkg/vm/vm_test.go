@@ -0,0 +1,284 @@
+package vm
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/adrian83/monkey/pkg/compiler"
+	"github.com/adrian83/monkey/pkg/evaluator"
+	"github.com/adrian83/monkey/pkg/lexer"
+	"github.com/adrian83/monkey/pkg/object"
+	"github.com/adrian83/monkey/pkg/parser"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// inputs evaluated by both backends, results have to be the same
+var parityInputs = []string{
+	"5 + 5 * 2 - 10 / 2",
+	"-(5 + 10)",
+	"(1 < 2) == true",
+	"1 > 2",
+	"!!5",
+	"if (1 > 2) { 10 }",
+	"if (1 > 2) { 10 } else { 20 }",
+	`if (10 > 1) { if (10 > 1) { return 10; } return 1; }`,
+	"return 2 * 5; 9;",
+	"let a = 5; let b = a; let c = a + b + 5; c;",
+	"let add = fn(x, y) { x + y; }; add(5 + 5, add(5, 5));",
+	"fn(x) { x; }(5)",
+	"let newAdder = fn(x) { fn(y) { x + y } }; let addTwo = newAdder(2); addTwo(2);",
+	"let fib = fn(x) { if (x < 2) { return x; } fib(x - 1) + fib(x - 2) }; fib(15)",
+	`let f = fn() { let inner = fn(n) { if (n == 0) { 0 } else { inner(n - 1) } }; inner(5) }; f()`,
+	`let f = fn() { g() }; let g = fn() { 7 }; f()`,
+	`let f = fn() { let h = fn() { y }; let y = 7; h() }; f()`,
+	`let f = fn() { let h = fn() { y }; h() }; f()`,
+	`let f = fn() { let h = fn() { y }; let x = h(); let y = 7; x }; f()`,
+	`let f = fn(n) {
+		let isEven = fn(n) { if (n == 0) { true } else { isOdd(n - 1) } };
+		let isOdd = fn(n) { if (n == 0) { false } else { isEven(n - 1) } };
+		[isEven(n), isOdd(n)]
+	}; f(7)`,
+	"let x = 1; let f = fn() { let x = x + 1; x }; f()",
+	`"Hello" + " " + "World!"`,
+	`"a" == "a"`,
+	"[1.5 + 1, 7 / 2.0, -2.5, 1 == 1.0, 0.5 < 1]",
+	`{1: "a"}[1.0]`,
+	"[int(3.9), float(2), round(2.5), floor(2.7), ceil(2.1)]",
+	"1.5 + true",
+	"1 / 0",
+	"let i = 0; while (i < 5) { let i = i + 1; }; i",
+	"while (false) { 1 }",
+	"let s = 0; for (i, x in [5, 5]) { let s = s + i * x; }; s",
+	`let s = ""; for (k, v in {"b": 2, "a": 1}) { let s = s + k; }; s`,
+	`let s = ""; for (c in "héllo") { if (c == "l") { break; } let s = s + c; }; s`,
+	"let s = 0; for (x in range(10)) { if (x == 5) { continue; } let s = s + x; }; s",
+	"let s = 0; for (x in range(3)) { for (y in range(3)) { if (y > x) { break } let s = s + 1; } }; s",
+	"let f = fn() { for (x in range(10)) { if (x == 3) { return x * 10; } } 99 }; f()",
+	"let f = fn(xs) { let s = 0; for (x in xs) { let s = s + x; }; s }; f(range(5))",
+	"let f = fn() { for (x in []) { } }; f()",
+	"let n = 0; for (x in range(100000)) { let n = x; }; n",
+	"for (x in 5) { x }",
+	"let x = 10; x += 5; x -= 3; x *= 2; x /= 4; x",
+	"let a = 1; let b = 2; a = b = 3; a + b",
+	"let i = 0; while (i < 5) { i += 1 }; i",
+	"let x = 1; let f = fn() { x = 2 }; f(); x",
+	"let x = 1; let f = fn() { let x = 5; x = 2 }; f(); x",
+	"let f = fn(x) { x += 1; x }; f(1)",
+	"let counter = fn() { let c = 0; fn() { c += 1 } }; let next = counter(); next(); next(); next()",
+	"let f = fn() { let v = 1; let g = fn() { fn() { v *= 10 }() }; g(); v }; f()",
+	"let f = fn() { let fs = []; for (i in range(3)) { let fs = push(fs, fn() { i }) }; fs[0]() }; f()",
+	"let r = fn() { r = 5; r }; [r(), r]",
+	"let f = fn() { let g = fn() { g = 1; g }; [g(), g] }; f()",
+	`let a = [1, [2]]; a[0] = 10; a[1][0] += 5; let h = {}; h["k"] = a; h`,
+	"x = 1",
+	"let f = fn() { y += 1 }; f()",
+	"let x = 1; x += true",
+	"let a = [1]; a[1] = 2",
+	`let h = {}; h["k"] += 1`,
+	"[7 % 3, -7 % 3, 2 ** 10, 2 ** 3 ** 2, -2 ** 2, 2 ** -1, 7.5 % 2, 2.0 ** 3]",
+	"[1 <= 2, 2 >= 3, 2.5 >= 2, \"a\" < \"b\", \"ab\" >= \"b\"]",
+	"7 % 0",
+	`"a" <= 1`,
+	"[1 && 2, false && 2, if (false) { 1 } || 3, true || x, false && x]",
+	"false || x",
+	"let n = 0; let inc = fn() { n += 1; true }; false && inc(); true || inc(); true && inc(); false || inc(); n",
+	"let i = 0; while (i < 10 && i * i < 20) { i += 1 }; i",
+	"let f = fn(a, b) { a || b }; [f(false, 1), f(2, 3), f(if (false) { 1 }, false)]",
+	`let größe = "héllo"; [len(größe), größe[1], größe[5], bytes("é"), runes("日本")]`,
+	"for (x in [1]) { x + true }",
+	`[1, [2, "x"]] == [1, [2, "x"]]`,
+	`{"a": [1], 2: true} != {2: true, "a": [1]}`,
+	"let f = fn() { 1 }; [f == f, fn() { 1 } == fn() { 1 }, len == len]",
+	"[1, 2 * 2, 3 + 3]",
+	"let myArray = [1, 2, 3]; let i = myArray[0]; myArray[i]",
+	"[1, 2, 3][3]",
+	`let two = "two"; {"one": 10 - 9, two: 1 + 1, 4: 4, true: 5}[two]`,
+	`{"foo": 5}["bar"]`,
+	`len("hello world")`,
+	`first(rest(push([1, 2], 3)))`,
+	"5 + true; 5;",
+	"-true",
+	"true + false;",
+	`if (10 > 1) { if (10 > 1) { return true + false; } return 1; }`,
+	"foobar",
+	`fn() { foobar }()`,
+	`let inner = fn() { foobar }; let outer = fn(x) { fn() { inner() }() }; outer(1);`,
+	`"Hello" - "World"`,
+	`{"name": "Monkey"}[fn(x) { x }];`,
+	`len(1)`,
+	`len("one", "two")`,
+	`1(2)`,
+	`fn(a, b) { a }(1)`,
+	`[1, 2][true]`,
+}
+
+func TestParityWithEvaluator(t *testing.T) {
+	for _, input := range parityInputs {
+		in := input
+
+		t.Run(in, func(t *testing.T) {
+			program, err := parser.New(lexer.New(in)).ParseProgram()
+			if err != nil {
+				t.Fatalf("cannot parse input, error: %v", err)
+			}
+
+			expected := evaluator.Eval(program, object.NewEnvironment())
+			actual := run(t, in)
+
+			assert.Equal(t, expected.Type(), actual.Type())
+			assert.Equal(t, expected.Inspect(), actual.Inspect())
+
+			if expectedErr, ok := expected.(*object.Error); ok {
+				assert.Equal(t, expectedErr.Trace, actual.(*object.Error).Trace)
+			}
+		})
+	}
+}
+
+func TestClosuresCapturingLocals(t *testing.T) {
+	input := `
+	let newClosure = fn(a, b) {
+		let one = fn() { a; };
+		let two = fn() { b; };
+		fn() { one() + two(); };
+	};
+	let closure = newClosure(9, 90);
+	closure();`
+
+	assertInteger(t, run(t, input), 99)
+}
+
+func TestStackOverflowPosition(t *testing.T) {
+	// the constants pushed by the array literal overflow the stack, they have no positions
+	input := "let f = fn(x) {\n  [x + 1" + strings.Repeat(", 1", StackSize) + "]\n};\nf(1)"
+
+	errObj, ok := run(t, input).(*object.Error)
+	if assert.True(t, ok) {
+		assert.Equal(t, "stack overflow", errObj.Message)
+		assert.Equal(t, "2:6", errObj.Pos.String())
+		assert.Equal(t, "f(...)\n\t2:6\nmain()\n\t4:1\n", errObj.StackTrace())
+	}
+}
+
+func TestGlobalsSharedBetweenRuns(t *testing.T) {
+	globals := NewGlobals()
+	symbolTable := compiler.New().SymbolTable()
+	constants := []object.Object{}
+
+	for _, line := range []string{"let a = 40;", "let b = 2;", "a + b"} {
+		program, err := parser.New(lexer.New(line)).ParseProgram()
+		if err != nil {
+			t.Fatalf("cannot parse input, error: %v", err)
+		}
+
+		comp := compiler.NewWithState(symbolTable, constants)
+		if err := comp.Compile(program); err != nil {
+			t.Fatalf("compiler error: %v", err)
+		}
+
+		bytecode := comp.Bytecode()
+		constants = bytecode.Constants
+
+		result := NewWithGlobals(bytecode, globals).Run()
+		if line == "a + b" {
+			assertInteger(t, result, 42)
+		}
+	}
+}
+
+func run(t *testing.T, input string) object.Object {
+	return New(compile(t, input)).Run()
+}
+
+func compile(t *testing.T, input string) *compiler.Bytecode {
+	program, err := parser.New(lexer.New(input)).ParseProgram()
+	if err != nil {
+		t.Fatalf("cannot parse input, error: %v", err)
+	}
+
+	comp := compiler.New()
+	if err := comp.Compile(program); err != nil {
+		t.Fatalf("compiler error: %v", err)
+	}
+
+	return comp.Bytecode()
+}
+
+func assertInteger(t *testing.T, obj object.Object, expected int64) {
+	integer, ok := obj.(*object.Integer)
+	if assert.True(t, ok, "object is not Integer, got=%T (%+v)", obj, obj) {
+		assert.Equal(t, expected, integer.Value)
+	}
+}
+
+func TestRunContextLimits(t *testing.T) {
+	cancelled, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	testData := map[string]struct {
+		input        string
+		ctx          context.Context
+		opts         Options
+		expectedKind object.ErrorKind
+		expectedMsg  string
+	}{
+		"infinite recursion": {
+			"let f = fn() { f() }; f()",
+			context.Background(),
+			Options{MaxDepth: 100},
+			object.ErrorDepthExceeded,
+			"maximum call depth of 100 exceeded",
+		},
+		"default depth": {
+			"let f = fn(x) { f(x + 1) }; f(0)",
+			context.Background(),
+			Options{},
+			object.ErrorDepthExceeded,
+			"maximum call depth of 1024 exceeded",
+		},
+		"depth above the number of frames": {
+			"let f = fn(x) { f(x + 1) }; f(0)",
+			context.Background(),
+			Options{MaxDepth: 2 * MaxFrames},
+			object.ErrorDepthExceeded,
+			"maximum call depth of 1024 exceeded",
+		},
+		"steps": {
+			"let f = fn(x) { if (x > 0) { f(x - 1) } }; f(50)",
+			context.Background(),
+			Options{MaxSteps: 100},
+			object.ErrorBudgetExhausted,
+			"step budget of 100 exhausted",
+		},
+		"allocations": {
+			"let f = fn(x) { if (x > 0) { f(x - 1) } }; f(500)",
+			context.Background(),
+			Options{MaxAllocs: 100},
+			object.ErrorBudgetExhausted,
+			"allocation budget of 100 exhausted",
+		},
+		"cancellation": {
+			"let f = fn(x) { if (x > 0) { f(x - 1) } }; f(500)",
+			cancelled,
+			Options{},
+			object.ErrorCancelled,
+			"evaluation cancelled: context canceled",
+		},
+	}
+
+	for name, tData := range testData {
+		data := tData
+
+		t.Run(name, func(t *testing.T) {
+			result := New(compile(t, data.input)).RunContext(data.ctx, data.opts)
+
+			errObj, ok := result.(*object.Error)
+			if assert.True(t, ok, "expected error, got %T", result) {
+				assert.Equal(t, data.expectedKind, errObj.Kind)
+				assert.Equal(t, data.expectedMsg, errObj.Message)
+			}
+		})
+	}
+}